@@ -176,6 +176,18 @@ var (
 	initCommandsEnt = func(ui, serverCmdUi cli.Ui, runOpts *RunOptions) {}
 )
 
+// withServerBackends sets the audit, auth, secrets, storage and service
+// registration backends the server is built with on s, so that operator
+// diagnose builds its server the same way.
+func withServerBackends(s *ServerCommand) *ServerCommand {
+	s.AuditBackends = auditBackends
+	s.CredentialBackends = credentialBackends
+	s.LogicalBackends = logicalBackends
+	s.PhysicalBackends = physicalBackends
+	s.ServiceRegistrations = serviceRegistrations
+	return s
+}
+
 // Commands is the mapping of all the available commands.
 var Commands map[string]cli.CommandFactory
 
@@ -567,23 +579,17 @@ func initCommands(ui, serverCmdUi cli.Ui, runOpts *RunOptions) {
 			}, nil
 		},
 		"server": func() (cli.Command, error) {
-			return &ServerCommand{
+			return withServerBackends(&ServerCommand{
 				BaseCommand: &BaseCommand{
 					UI:          serverCmdUi,
 					tokenHelper: runOpts.TokenHelper,
 					flagAddress: runOpts.Address,
 				},
-				AuditBackends:      auditBackends,
-				CredentialBackends: credentialBackends,
-				LogicalBackends:    logicalBackends,
-				PhysicalBackends:   physicalBackends,
-
-				ServiceRegistrations: serviceRegistrations,
 
 				ShutdownCh: MakeShutdownCh(),
 				SighupCh:   MakeSighupCh(),
 				SigUSR2Ch:  MakeSigUSR2Ch(),
-			}, nil
+			}), nil
 		},
 		"ssh": func() (cli.Command, error) {
 			return &SSHCommand{
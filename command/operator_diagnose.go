@@ -3,9 +3,15 @@ package command
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -15,12 +21,21 @@ import (
 	"github.com/docker/docker/pkg/ioutils"
 	"github.com/hashicorp/consul/api"
 	log "github.com/hashicorp/go-hclog"
+	wrapping "github.com/hashicorp/go-kms-wrapping"
+	gsyslog "github.com/hashicorp/go-syslog"
 	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/command/server"
 	"github.com/hashicorp/vault/helper/metricsutil"
+	vaulthttp "github.com/hashicorp/vault/http"
 	"github.com/hashicorp/vault/internalshared/configutil"
+	"github.com/hashicorp/vault/internalshared/gatedwriter"
 	"github.com/hashicorp/vault/internalshared/listenerutil"
 	"github.com/hashicorp/vault/internalshared/reloadutil"
 	physconsul "github.com/hashicorp/vault/physical/consul"
+	physRaft "github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/helper/useragent"
 	"github.com/hashicorp/vault/sdk/physical"
 	"github.com/hashicorp/vault/sdk/version"
 	sr "github.com/hashicorp/vault/serviceregistration"
@@ -33,6 +48,47 @@ import (
 
 const OperatorDiagnoseEnableEnv = "VAULT_DIAGNOSE"
 
+// diagnoseChecks are the names of the checks diagnose runs, as reported by
+// -capabilities. Keep it in sync with the tests run below.
+var diagnoseChecks = []string{
+	"parse-config", "config-defaults", "schema-validate",
+	"check-namespace-config", "check-edition", "check-performance-standby",
+	"check-log-file", "check-log-requests-level", "check-audit-config",
+	"check-loopback", "check-sockaddr-templates", "check-legacy-tls",
+	"check-capacity", "check-cpu", "check-execution-context", "check-mlock",
+	"check-container", "check-lease-ttl", "check-telemetry", "storage",
+	"create-storage-backend", "test-storage-tls-consul",
+	"test-consul-direct-access-storage", "check-storage-pool",
+	"check-storage-transactions", "check-storage-consistency",
+	"check-storage-encryption", "raft", "test-raft-retry-join-tls",
+	"check-raft-latency", "check-raft-cluster-addr",
+	"check-raft-filesystems", "check-raft-node-id", "check-raft-path-write",
+	"check-storage-path", "check-storage-ownership",
+	"check-storage-filesystem", "check-storage-fsync",
+	"test-access-storage", "test-storage-throughput", "service-discovery",
+	"test-serviceregistration-tls-consul",
+	"test-consul-direct-access-service-discovery",
+	"check-consul-registration-timing", "check-consul-datacenter",
+	"create-seal", "check-seal-env", "check-kms-endpoint",
+	"check-kms-credentials", "check-kms-key", "check-seal-region",
+	"check-transit-seal-dependency", "test-transit-seal",
+	"check-random-source", "setup-core", "check-core-config",
+	"check-plugin-execution", "setup-ha-storage", "check-raft-ha-storage",
+	"create-ha-storage-backend", "check-ha-storage",
+	"test-serviceregistration-api-addr", "test-storage-overlap",
+	"check-dynamodb-ha", "test-ha-storage-tls-consul", "check-clustering",
+	"check-cluster-address", "check-cluster-cipher-suites",
+	"check-tls-key-separation", "init-core", "init-listeners",
+	"check-listener-interfaces", "bind-listeners", "check-firewall",
+	"create-listeners", "check-tcp-socket-options", "check-listener-tls",
+	"check-listener-ocsp", "check-listener-features", "check-ui-exposure",
+	"check-max-request-duration", "check-max-request-size",
+	"check-request-limiter", "unseal", "start-servers", "custom",
+	"mirror-server", "live", "raft-health", "raft-clock-skew",
+	"rate-limit-quotas", "audit-sinks", "config-drift", "cluster-name",
+	"api-addr-health", "performance-standby",
+}
+
 const CoreUninitializedErr = "diagnose cannot attempt this step because core could not be initialized"
 const BackendUninitializedErr = "diagnose cannot attempt this step because backend could not be initialized"
 const CoreConfigUninitializedErr = "diagnose cannot attempt this step because core config could not be set"
@@ -46,10 +102,38 @@ type OperatorDiagnoseCommand struct {
 	*BaseCommand
 	diagnose *diagnose.Session
 
-	flagDebug    bool
-	flagSkips    []string
-	flagConfigs  []string
-	cleanupGuard sync.Once
+	flagDebug          bool
+	flagSkips          []string
+	flagConfigs        []string
+	flagBundle         string
+	flagCustomChecks   map[string]string
+	flagLabels         map[string]string
+	flagSince          string
+	flagJSONSection    string
+	flagInventory      string
+	flagMirrorServer   bool
+	flagHostOnly       bool
+	flagStrictKeys     bool
+	flagSyslog         bool
+	flagPartial        bool
+	flagKeyShares      int
+	flagKeyThreshold   int
+	flagLive           bool
+	flagCapabilities   bool
+	flagColor          string
+	flagNoSkips        bool
+	flagThroughput     bool
+	flagSchemaValidate bool
+	flagDeadline       time.Duration
+	cleanupGuard       sync.Once
+
+	// invokedByServer is set when diagnose runs as part of "vault server
+	// -diagnose" rather than as its own command.
+	invokedByServer bool
+
+	// config is the parsed server configuration, retained for the support
+	// bundle once the checks have run.
+	config *server.Config
 
 	reloadFuncsLock      *sync.RWMutex
 	reloadFuncs          *map[string][]reloadutil.ReloadFunc
@@ -105,7 +189,7 @@ func (c *OperatorDiagnoseCommand) Flags() *FlagSets {
 	f.StringSliceVar(&StringSliceVar{
 		Name:   "skip",
 		Target: &c.flagSkips,
-		Usage:  "Skip the health checks named as arguments. May be 'listener', 'storage', or 'autounseal'.",
+		Usage:  "Skip the health checks named as arguments. May be 'listener' or 'autounseal'.",
 	})
 
 	f.BoolVar(&BoolVar{
@@ -116,9 +200,199 @@ func (c *OperatorDiagnoseCommand) Flags() *FlagSets {
 	})
 
 	f.StringVar(&StringVar{
-		Name:   "format",
-		Target: &c.flagFormat,
-		Usage:  "The output format",
+		Name:       "format",
+		Target:     &c.flagFormat,
+		Completion: complete.PredictSet(diagnose.OutputFormats...),
+		Usage: "The output format: \"table\", \"json\", or \"html\" for a " +
+			"self-contained report that can be shared as a single file.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:   "json-section",
+		Target: &c.flagJSONSection,
+		Usage: "With -format=json, output only the subtree of the named " +
+			"section, such as \"storage\", with its own status, and exit " +
+			"with a code for that status rather than the overall one.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "color",
+		Target:     &c.flagColor,
+		Default:    "auto",
+		Completion: complete.PredictSet("auto", "always", "never"),
+		Usage: "When to color the status of each result: \"auto\" colors " +
+			"only when writing to a terminal, \"always\" colors even when " +
+			"the output is piped, and \"never\" disables color.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "bundle",
+		Target:     &c.flagBundle,
+		Completion: complete.PredictFiles("*.tar.gz"),
+		Usage: "Path at which to write a redacted support bundle containing the " +
+			"JSON results, the metrics the checks measured, the sanitized " +
+			"configuration, and OS and runtime information. Secrets and " +
+			"addresses are scrubbed from the bundle.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "inventory",
+		Target:     &c.flagInventory,
+		Completion: complete.PredictFiles("*.json"),
+		Usage: "Path at which to write a CycloneDX-style JSON inventory of the " +
+			"deployed components: the Vault and Go versions, the storage and " +
+			"seal backends, and the plugins in the plugin directory. It is " +
+			"kept separate from the health results.",
+	})
+
+	f.StringMapVar(&StringMapVar{
+		Name:   "custom-check",
+		Target: &c.flagCustomChecks,
+		Usage: "Run an additional check, given as name=command, in a \"custom\" " +
+			"section. The command is run by the shell; an exit code of 0 is a " +
+			"success, 2 is a warning, and anything else is an error. Its output " +
+			"is used as the result message. This can be specified multiple times.",
+	})
+
+	f.StringMapVar(&StringMapVar{
+		Name:   "label",
+		Target: &c.flagLabels,
+		Usage: "Attach a label, given as key=value, to the top-level results, " +
+			"such as a datacenter or deployment ID to filter the results of " +
+			"many runs by. Labels are passed through to the output without " +
+			"being interpreted. This can be specified multiple times.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "since",
+		Target:     &c.flagSince,
+		Completion: complete.PredictFiles("*.json"),
+		Usage: "Path to the JSON results of a previous run. When set, diagnose " +
+			"fails only if a check that was ok or warning in that run is now " +
+			"worse, and lists each such regression. Problems already present " +
+			"in the previous run are ignored.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "mirror-server",
+		Target:  &c.flagMirrorServer,
+		Default: false,
+		Usage: "Instead of the diagnose checks, run the server command's own " +
+			"startup sequence up to the point where it would begin serving, " +
+			"and report the first error the server would hit.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "host-only",
+		Target:  &c.flagHostOnly,
+		Default: false,
+		Usage: "Run only the checks of the host itself, such as open file " +
+			"limits, memory locking, time synchronization, disk usage and " +
+			"the firewall, without a configuration file. This validates a " +
+			"host, such as a golden image, before Vault is configured on it.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "strict-key-separation",
+		Target:  &c.flagStrictKeys,
+		Default: false,
+		Usage: "Warn, rather than only note, when a listener's TLS key is " +
+			"reused as the client key of a raft retry_join stanza, for " +
+			"policies that require separate keys for each plane.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "syslog",
+		Target:  &c.flagSyslog,
+		Default: false,
+		Usage: "Also write each result to the local syslog, tagged " +
+			"\"vault-diagnose\", at a priority matching its status.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "partial-config",
+		Target:  &c.flagPartial,
+		Default: false,
+		Usage: "Allow an incomplete configuration, such as a lone seal stanza. " +
+			"Checks whose stanzas are missing are skipped, and the run ends " +
+			"with at least a warning so that it can't pass as a full check.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:   "key-shares",
+		Target: &c.flagKeyShares,
+		Usage: "Number of key shares that will be passed to \"vault operator init\". " +
+			"These are unseal keys under a shamir seal and recovery keys under " +
+			"auto-unseal. When set, the shares and threshold are validated.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "live",
+		Target:  &c.flagLive,
+		Default: false,
+		Usage: "After the configuration checks, also query the running Vault " +
+			"server at VAULT_ADDR, using the current token, and report on its " +
+			"runtime state, such as raft health, whether the clocks of its raft " +
+			"peers agree, whether its persisted cluster name matches " +
+			"cluster_name, whether api_addr reaches it, " +
+			"whether it runs the configuration on disk and whether it behaves " +
+			"as disable_performance_standby configures. This writes a " +
+			"burst of test entries to the consumers of socket audit devices " +
+			"to check that they don't block.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "capabilities",
+		Target:  &c.flagCapabilities,
+		Default: false,
+		Usage: "Print, as JSON, the output formats, checks and flags this " +
+			"version of diagnose supports, along with the results schema " +
+			"version, and exit without running any checks.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "no-skips",
+		Target:  &c.flagNoSkips,
+		Default: false,
+		Usage: "Fail if any check was skipped because a check it depends on " +
+			"failed, and list them. Checks named by -skip, of configuration " +
+			"that is absent or doesn't apply, or unsupported on this platform " +
+			"or build are expected skips. The reason for each skip is " +
+			"reported as skip_reason in JSON output.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "throughput-test",
+		Target:  &c.flagThroughput,
+		Default: false,
+		Usage: "Also measure storage throughput by writing a bounded number of " +
+			"entries to the storage backend from several writers at once, and " +
+			"report the writes per second. The entries are deleted afterward.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "schema-validate",
+		Target:  &c.flagSchemaValidate,
+		Default: false,
+		Usage: "Also validate each configuration file against the configuration " +
+			"schema, reporting unknown settings, values of the wrong type, and " +
+			"missing required settings, with the path of each.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:   "deadline",
+		Target: &c.flagDeadline,
+		Usage: "Time budget for the whole run. When it passes, diagnose stops, " +
+			"reports the checks that completed and marks the rest as timed " +
+			"out. Cleanup, such as closing listeners and finalizing seals, " +
+			"still runs. The default is no deadline.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:   "key-threshold",
+		Target: &c.flagKeyThreshold,
+		Usage: "Number of key shares required to reconstruct the key, as will be " +
+			"passed to \"vault operator init\".",
 	})
 	return set
 }
@@ -149,47 +423,146 @@ func (c *OperatorDiagnoseCommand) Run(args []string) int {
 }
 
 func (c *OperatorDiagnoseCommand) RunWithParsedFlags() int {
+	if c.flagCapabilities {
+		return c.printCapabilities()
+	}
 
-	if len(c.flagConfigs) == 0 {
+	if c.flagHostOnly && c.flagMirrorServer {
+		c.UI.Error("-host-only and -mirror-server can't be used together.")
+		return 3
+	}
+	if len(c.flagConfigs) == 0 && !c.flagHostOnly {
 		c.UI.Error("Must specify a configuration file using -config.")
 		return 3
 	}
 
+	if c.flagJSONSection != "" {
+		if c.flagFormat != "json" {
+			c.UI.Error("-json-section requires -format=json.")
+			return 3
+		}
+		if !strutil.StrListContains(diagnoseChecks, c.flagJSONSection) {
+			c.UI.Error(fmt.Sprintf("Unknown section %q for -json-section. The sections are listed by -capabilities.",
+				c.flagJSONSection))
+			return 3
+		}
+	}
+
+	var color bool
+	switch c.flagColor {
+	case "auto", "":
+		_, _, err := term.GetSize(int(os.Stdout.Fd()))
+		color = err == nil
+	case "always":
+		color = true
+	case "never":
+	default:
+		c.UI.Error(fmt.Sprintf("Invalid -color value %q: must be \"auto\", \"always\" or \"never\".", c.flagColor))
+		return 3
+	}
+
 	if c.diagnose == nil {
-		if c.flagFormat == "json" {
+		if c.flagFormat == "json" || c.flagFormat == "html" {
 			c.diagnose = diagnose.New(&ioutils.NopWriter{})
 		} else {
 			c.UI.Output(version.GetVersion().FullVersionNumber(true))
 			c.diagnose = diagnose.New(os.Stdout)
 		}
 	}
+	c.diagnose.SetColor(color)
 	ctx := diagnose.Context(context.Background(), c.diagnose)
 	c.diagnose.SetSkipList(c.flagSkips)
-	err := c.offlineDiagnostics(ctx)
+
+	// Checks run under runCtx, so that the deadline stops them, while the
+	// results are still finalized under ctx.
+	runCtx := ctx
+	if c.flagDeadline > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, c.flagDeadline)
+		defer cancel()
+	}
+	var err error
+	switch {
+	case c.flagHostOnly:
+		err = c.hostDiagnostics(runCtx)
+	case c.flagMirrorServer:
+		err = c.mirrorServerStartup(runCtx)
+	default:
+		err = c.offlineDiagnostics(runCtx)
+	}
+	if c.flagLive {
+		c.liveDiagnostics(runCtx)
+	}
 
 	results := c.diagnose.Finalize(ctx)
+	score := results.Summarize().HealthScore()
+	results.HealthScore = &score
+	results.Labels = c.flagLabels
+	section := results
+	if c.flagJSONSection != "" {
+		if section = results.Find(c.flagJSONSection); section == nil {
+			c.UI.Error(fmt.Sprintf("Section %q did not run.", c.flagJSONSection))
+			return 4
+		}
+		section.Labels = c.flagLabels
+	}
 	if c.flagFormat == "json" {
-		resultsJS, err := json.MarshalIndent(results, "", "  ")
+		resultsJS, err := json.MarshalIndent(section, "", "  ")
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error marshalling results: %v", err)
 			return 4
 		}
 		c.UI.Output(string(resultsJS))
+	} else if c.flagFormat == "html" {
+		if err := results.WriteHTML(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "error rendering results: %v", err)
+			return 4
+		}
 	} else {
 		c.UI.Output("\nResults:")
 		w, _, err := term.GetSize(0)
 		if err == nil {
-			results.Write(os.Stdout, w)
+			results.WriteColor(os.Stdout, w, color)
 		} else {
-			results.Write(os.Stdout, 0)
+			results.WriteColor(os.Stdout, 0, color)
+		}
+		c.UI.Output("\n" + results.Summarize().String())
+	}
+
+	if c.flagBundle != "" {
+		if bundleErr := c.writeBundle(results); bundleErr != nil {
+			c.UI.Error(fmt.Sprintf("Error writing support bundle: %v", bundleErr))
+			return 4
+		}
+	}
+
+	if c.flagInventory != "" {
+		if inventoryErr := c.writeInventory(); inventoryErr != nil {
+			c.UI.Error(fmt.Sprintf("Error writing inventory: %v", inventoryErr))
+			return 4
+		}
+	}
+
+	if c.flagSyslog {
+		if syslogErr := c.writeSyslog(results); syslogErr != nil {
+			c.UI.Error(fmt.Sprintf("Error writing results to syslog: %v", syslogErr))
+			return 4
 		}
 	}
 
 	if err != nil {
 		return 4
 	}
+	if c.flagNoSkips {
+		if code := c.reportUnexpectedSkips(results); code != 0 {
+			return code
+		}
+	}
+	if c.flagSince != "" {
+		return c.reportRegressions(results)
+	}
 	// Use a different return code
-	switch results.Status {
+	switch section.Status {
 	case diagnose.WarningStatus:
 		return 2
 	case diagnose.ErrorStatus:
@@ -198,31 +571,564 @@ func (c *OperatorDiagnoseCommand) RunWithParsedFlags() int {
 	return 0
 }
 
-func (c *OperatorDiagnoseCommand) offlineDiagnostics(ctx context.Context) error {
+// printCapabilities prints what this version of diagnose supports as JSON.
+func (c *OperatorDiagnoseCommand) printCapabilities() int {
+	var flags []string
+	for name := range c.Flags().Completions() {
+		flags = append(flags, name)
+	}
+	capsJS, err := json.MarshalIndent(diagnose.NewCapabilities(diagnoseChecks, flags), "", "  ")
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error marshalling capabilities: %v", err))
+		return 4
+	}
+	c.UI.Output(string(capsJS))
+	return 0
+}
+
+// reportRegressions compares results to the previous run given by -since,
+// listing any regressions. It returns 1 if there were regressions and 0
+// otherwise.
+func (c *OperatorDiagnoseCommand) reportRegressions(results *diagnose.Result) int {
+	previous, err := diagnose.LoadResults(c.flagSince)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error loading previous results from %s: %v", c.flagSince, err))
+		return 4
+	}
+	regressions := diagnose.FindRegressions(previous, results)
+	if len(regressions) == 0 {
+		if c.flagFormat != "json" && c.flagFormat != "html" {
+			c.UI.Info(fmt.Sprintf("No regressions since %s.", c.flagSince))
+		}
+		return 0
+	}
+	// Regressions go to stderr so that JSON output on stdout stays parseable.
+	c.UI.Error(fmt.Sprintf("%d regression(s) since %s:", len(regressions), c.flagSince))
+	for _, r := range regressions {
+		c.UI.Error("  " + r.String())
+	}
+	return 1
+}
+
+// reportUnexpectedSkips prints the checks that were unexpectedly skipped and
+// returns the exit code for -no-skips.
+func (c *OperatorDiagnoseCommand) reportUnexpectedSkips(results *diagnose.Result) int {
+	skips := diagnose.UnexpectedSkips(results)
+	if len(skips) == 0 {
+		return 0
+	}
+	// Like regressions, these go to stderr to keep JSON output parseable.
+	c.UI.Error(fmt.Sprintf("%d check(s) were unexpectedly skipped:", len(skips)))
+	for _, s := range skips {
+		c.UI.Error("  " + s)
+	}
+	return 1
+}
+
+// unusedConfigKeys returns the keys not recognized in any of the configuration
+// files. Unused keys don't survive merging, so each file is loaded on its own.
+func (c *OperatorDiagnoseCommand) unusedConfigKeys() (configutil.UnusedKeyMap, error) {
+	unused := make(configutil.UnusedKeyMap)
+	for _, path := range c.flagConfigs {
+		fileConfig, err := server.LoadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range fileConfig.UnusedKeys {
+			unused[k] = append(unused[k], v...)
+		}
+	}
+	return unused, nil
+}
+
+// configAddrs returns the configured addresses, keyed by the name of the
+// setting each comes from.
+func configAddrs(config *server.Config) map[string]string {
+	addrs := map[string]string{}
+	if config.APIAddr != "" {
+		addrs["api_addr"] = config.APIAddr
+	}
+	if config.ClusterAddr != "" {
+		addrs["cluster_addr"] = config.ClusterAddr
+	}
+	for i, l := range config.Listeners {
+		if l.Type != "tcp" {
+			continue
+		}
+		addrs[fmt.Sprintf("listener %d address", i+1)] = l.Address
+		if l.ClusterAddress != "" {
+			addrs[fmt.Sprintf("listener %d cluster_address", i+1)] = l.ClusterAddress
+		}
+	}
+	return addrs
+}
+
+// configTLSVersions returns the TLS version settings in effect, keyed by the
+// stanza and setting each comes from. Listeners default to tls12.
+func configTLSVersions(config *server.Config) map[string]string {
+	versions := map[string]string{}
+	addStanza := func(name string, conf map[string]string) {
+		for _, key := range []string{"tls_min_version", "tls_max_version"} {
+			if v := conf[key]; v != "" {
+				versions[name+" "+key] = v
+			}
+		}
+	}
+	for i, l := range config.Listeners {
+		if l.Type != "tcp" || l.TLSDisable {
+			continue
+		}
+		name := fmt.Sprintf("listener %d (%s)", i+1, l.Address)
+		minVersion := l.TLSMinVersion
+		if minVersion == "" {
+			minVersion = "tls12"
+		}
+		versions[name+" tls_min_version"] = minVersion
+		if l.TLSMaxVersion != "" {
+			versions[name+" tls_max_version"] = l.TLSMaxVersion
+		}
+	}
+	if config.Storage != nil {
+		addStanza("storage "+config.Storage.Type, config.Storage.Config)
+	}
+	if config.HAStorage != nil {
+		addStanza("ha_storage "+config.HAStorage.Type, config.HAStorage.Config)
+	}
+	if config.ServiceRegistration != nil {
+		addStanza("service_registration "+config.ServiceRegistration.Type, config.ServiceRegistration.Config)
+	}
+	for _, seal := range config.Seals {
+		addStanza("seal "+seal.Type, seal.Config)
+	}
+	return versions
+}
+
+// consulAgent asks the Consul agent that a consul storage or
+// service_registration stanza talks to for its datacenter.
+func consulAgent(conf map[string]string, logger log.Logger) (diagnose.ConsulAgent, error) {
+	consulConf := api.DefaultConfig()
+	if err := physconsul.SetupSecureTLS(consulConf, conf, logger, false); err != nil {
+		return diagnose.ConsulAgent{}, err
+	}
+	agent := diagnose.ConsulAgent{Address: consulConf.Address, Namespace: consulConf.Namespace}
+	client, err := api.NewClient(consulConf)
+	if err != nil {
+		return agent, err
+	}
+	self, err := client.Agent().Self()
+	if err != nil {
+		return agent, fmt.Errorf("could not query the Consul agent at %s: %w", consulConf.Address, err)
+	}
+	agent.Datacenter, _ = self["Config"]["Datacenter"].(string)
+	return agent, nil
+}
+
+// configFiles returns the configuration files named by -config, reading the
+// .hcl and .json files of any configuration directories the way the server
+// does.
+func (c *OperatorDiagnoseCommand) configFiles() ([]string, error) {
+	var files []string
+	for _, path := range c.flagConfigs {
+		if fi, err := os.Stat(path); err != nil {
+			return nil, err
+		} else if !fi.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		for _, ext := range []string{"*.hcl", "*.json"} {
+			matches, err := filepath.Glob(filepath.Join(path, ext))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+	}
+	return files, nil
+}
+
+// explicitConfigKeys returns the settings the configuration files set
+// explicitly.
+func (c *OperatorDiagnoseCommand) explicitConfigKeys() (map[string]bool, error) {
+	files, err := c.configFiles()
+	if err != nil {
+		return nil, err
+	}
+	explicit := make(map[string]bool)
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := diagnose.ExplicitConfigKeys(contents)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", file, err)
+		}
+		for k := range keys {
+			explicit[k] = true
+		}
+	}
+	return explicit, nil
+}
+
+// logRequestsLevel returns the log_requests_level of the configuration, with
+// later files overriding earlier ones as they do when the server merges them.
+func (c *OperatorDiagnoseCommand) logRequestsLevel() (string, error) {
+	files, err := c.configFiles()
+	if err != nil {
+		return "", err
+	}
+	var level string
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		l, err := diagnose.LogRequestsLevel(contents)
+		if err != nil {
+			return "", fmt.Errorf("error parsing %s: %w", file, err)
+		}
+		if l != "" {
+			level = l
+		}
+	}
+	return level, nil
+}
+
+// writeSyslog writes the results to the local syslog.
+func (c *OperatorDiagnoseCommand) writeSyslog(results *diagnose.Result) error {
+	logger, err := gsyslog.NewLogger(gsyslog.LOG_INFO, "USER", diagnose.SyslogTag)
+	if err != nil {
+		return err
+	}
+	defer logger.Close()
+	return diagnose.WriteSyslog(logger, results)
+}
+
+// writeBundle writes the redacted results and their metrics, along with the
+// redacted configuration and host information, to the support bundle path.
+func (c *OperatorDiagnoseCommand) writeBundle(results *diagnose.Result) error {
+	b := &diagnose.Bundle{
+		Results: diagnose.RedactResults(results),
+		Host:    diagnose.HostInfo(version.GetVersion().FullVersionNumber(false)),
+	}
+	if c.config != nil {
+		b.Config = diagnose.RedactConfig(c.config.Sanitized())
+	}
+	return b.Write(c.flagBundle)
+}
+
+// writeInventory writes the inventory of the components the configuration
+// deploys to the inventory path.
+func (c *OperatorDiagnoseCommand) writeInventory() error {
+	src := diagnose.InventorySources{
+		VaultVersion: version.GetVersion().FullVersionNumber(false),
+	}
+	if c.config != nil {
+		if c.config.Storage != nil {
+			src.StorageType = c.config.Storage.Type
+		}
+		if c.config.HAStorage != nil {
+			src.HAStorageType = c.config.HAStorage.Type
+		}
+		for _, seal := range c.config.Seals {
+			if !seal.Disabled {
+				src.SealTypes = append(src.SealTypes, seal.Type)
+			}
+		}
+		src.PluginDirectory = c.config.PluginDirectory
+	}
+	inv, err := diagnose.BuildInventory(src)
+	if err != nil {
+		return err
+	}
+	return inv.Write(c.flagInventory)
+}
+
+// invocation describes how this run was started, for reporting.
+func (c *OperatorDiagnoseCommand) invocation() diagnose.Invocation {
+	inv := diagnose.Invocation{
+		Command:        "vault operator diagnose",
+		EnableEnv:      OperatorDiagnoseEnableEnv,
+		EnableEnvValue: os.Getenv(OperatorDiagnoseEnableEnv),
+		Skips:          c.flagSkips,
+		MirrorServer:   c.flagMirrorServer,
+		HostOnly:       c.flagHostOnly,
+	}
+	if c.invokedByServer {
+		inv.Command = "vault server -diagnose"
+	}
+	return inv
+}
+
+// liveDiagnostics runs the checks that query a running Vault server.
+func (c *OperatorDiagnoseCommand) liveDiagnostics(ctx context.Context) {
+	diagnose.Test(ctx, "live", func(ctx context.Context) error {
+		client, err := c.Client()
+		if err != nil {
+			return fmt.Errorf("could not create a client for the running server: %w", err)
+		}
+
+		diagnose.Test(ctx, "raft-health", func(ctx context.Context) error {
+			localID := os.Getenv(physRaft.EnvVaultRaftNodeID)
+			if localID == "" && c.config != nil && c.config.Storage != nil {
+				localID = c.config.Storage.Config["node_id"]
+			}
+			return diagnose.RaftLiveCheck(ctx, client, localID)
+		})
+
+		diagnose.Test(ctx, "raft-clock-skew", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
+			if c.config == nil {
+				diagnose.Skipped(ctx, diagnose.SkipDependencyFailed, "the configuration files could not be parsed")
+				return nil
+			}
+			if c.config.Storage == nil || c.config.Storage.Type != "raft" {
+				diagnose.Skipped(ctx, diagnose.SkipNotApplicable, "the server does not use raft storage")
+				return nil
+			}
+			return diagnose.RaftClockSkewLiveCheck(ctx, client, diagnose.RaftRetryJoinAddrs(c.config.Storage.Config))
+		}))
+
+		diagnose.Test(ctx, "rate-limit-quotas", func(ctx context.Context) error {
+			return diagnose.RateLimitQuotaLiveCheck(ctx, client)
+		})
+
+		diagnose.Test(ctx, "audit-sinks", func(ctx context.Context) error {
+			return diagnose.AuditSinkLiveCheck(ctx, client)
+		})
+
+		diagnose.Test(ctx, "config-drift", func(ctx context.Context) error {
+			if c.config == nil {
+				diagnose.Skipped(ctx, diagnose.SkipDependencyFailed, "the configuration files could not be parsed")
+				return nil
+			}
+			return diagnose.ConfigDriftLiveCheck(ctx, client, c.config.Sanitized())
+		})
+
+		diagnose.Test(ctx, "cluster-name", func(ctx context.Context) error {
+			if c.config == nil {
+				diagnose.Skipped(ctx, diagnose.SkipDependencyFailed, "the configuration files could not be parsed")
+				return nil
+			}
+			return diagnose.ClusterNameLiveCheck(ctx, client, c.config.ClusterName)
+		})
+
+		diagnose.Test(ctx, "api-addr-health", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
+			var configured string
+			if c.config != nil {
+				configured = c.config.APIAddr
+			}
+			addr, source, err := diagnose.ResolveAPIAddr(configured, nil)
+			if err != nil {
+				diagnose.Skipped(ctx, diagnose.SkipStanzaAbsent, "api_addr is not set, so there is no advertised address to probe")
+				return nil
+			}
+			return diagnose.APIAddrHealthLiveCheck(ctx, client, addr, source)
+		}))
+
+		diagnose.Test(ctx, "performance-standby", func(ctx context.Context) error {
+			if c.config == nil {
+				diagnose.Skipped(ctx, diagnose.SkipDependencyFailed, "the configuration files could not be parsed")
+				return nil
+			}
+			return diagnose.PerformanceStandbyLiveCheck(ctx, client, c.config.DisablePerformanceStandby)
+		})
+		return nil
+	})
+}
+
+// newServerCommand constructs a ServerCommand with the same backends the
+// server command is built with, for use by the checks.
+func (c *OperatorDiagnoseCommand) newServerCommand() *ServerCommand {
 	rloadFuncs := make(map[string][]reloadutil.ReloadFunc)
-	server := &ServerCommand{
+	return withServerBackends(&ServerCommand{
 		// TODO: set up a different one?
 		// In particular, a UI instance that won't output?
 		BaseCommand: c.BaseCommand,
 
-		// TODO: refactor to a common place?
-		AuditBackends:        auditBackends,
-		CredentialBackends:   credentialBackends,
-		LogicalBackends:      logicalBackends,
-		PhysicalBackends:     physicalBackends,
-		ServiceRegistrations: serviceRegistrations,
-
 		// TODO: other ServerCommand options?
 
 		logger:          log.NewInterceptLogger(nil),
 		allLoggers:      []log.Logger{},
 		reloadFuncs:     &rloadFuncs,
 		reloadFuncsLock: new(sync.RWMutex),
+	})
+}
+
+// mirrorServerStartup runs the server command's own startup sequence, up to
+// but not including starting the listeners, reporting the first error the
+// server would hit. The backends, seals and core created along the way are
+// closed, finalized and shut down before returning.
+func (c *OperatorDiagnoseCommand) mirrorServerStartup(ctx context.Context) error {
+	server := c.newServerCommand()
+	server.flagConfigs = c.flagConfigs
+	server.logOutput = ioutil.Discard
+	server.gatedWriter = gatedwriter.NewWriter(ioutil.Discard)
+
+	// The server waits out unreadable storage until it is shut down; the
+	// run's deadline shuts it down here.
+	server.ShutdownCh = make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			close(server.ShutdownCh)
+		case <-stop:
+		}
+	}()
+
+	ctx, span := diagnose.StartSpan(ctx, "mirror-server")
+	defer span.End()
+
+	diagnose.InvocationCheck(ctx, c.invocation())
+
+	config, err := server.parseConfig()
+	if err != nil {
+		return diagnose.SpotError(ctx, "parse-config", err)
+	}
+	diagnose.SpotOk(ctx, "parse-config", "")
+	c.config = config
+
+	level, _, _, logFormat, err := server.processLogLevelAndFormat(config)
+	if err != nil {
+		return diagnose.SpotError(ctx, "log-level", err)
+	}
+	config.LogFormat = logFormat.String()
+	server.logger = log.NewInterceptLogger(&log.LoggerOptions{
+		Output:     server.gatedWriter,
+		Level:      level,
+		JSONFormat: logFormat == logging.JSONFormat,
+	})
+	server.allLoggers = []log.Logger{server.logger}
+	if _, err := server.adjustLogLevel(config, false); err != nil {
+		return diagnose.SpotError(ctx, "log-level", err)
+	}
+	diagnose.SpotOk(ctx, "log-level", "")
+
+	if err := applyConfigEnv(config); err != nil {
+		return diagnose.SpotError(ctx, "environment", err)
+	}
+
+	inmemMetrics, metricSink, prometheusEnabled, err := configutil.SetupTelemetry(&configutil.SetupTelemetryOpts{
+		Config:      config.Telemetry,
+		Ui:          c.UI,
+		ServiceName: "vault",
+		DisplayName: "Vault",
+		UserAgent:   useragent.String(),
+		ClusterName: config.ClusterName,
+	})
+	if err != nil {
+		return diagnose.SpotError(ctx, "telemetry", fmt.Errorf("Error initializing telemetry: %s", err))
+	}
+	diagnose.SpotOk(ctx, "telemetry", "")
+	metricsHelper := metricsutil.NewMetricsHelper(inmemMetrics, prometheusEnabled)
+
+	setup, err := server.setupCoreConfig(config, metricsHelper, metricSink, make([]string, 0), make(map[string]string))
+	if setup != nil {
+		defer closeBackend(setup.backend)
+		for _, seal := range setup.seals {
+			defer seal.Finalize(context.Background())
+		}
+	}
+	if err != nil {
+		return diagnose.SpotError(ctx, "setup-core-config", err)
+	}
+	diagnose.SpotOk(ctx, "setup-core-config", "")
+
+	coreConfig := setup.coreConfig
+	_, redirectErr, err := server.completeCoreConfig(config, &coreConfig)
+	if config.HAStorage != nil && coreConfig.HAPhysical != nil {
+		defer closeBackend(coreConfig.HAPhysical)
+	}
+	if redirectErr != nil {
+		// The server only reports this error and carries on
+		diagnose.SpotWarn(ctx, "redirect-addr", redirectErr.Error())
+	}
+	if err != nil {
+		return diagnose.SpotError(ctx, "complete-core-config", err)
 	}
+	diagnose.SpotOk(ctx, "complete-core-config", "")
+
+	core, err := vault.CreateCore(&coreConfig)
+	if core != nil {
+		defer core.Shutdown()
+	}
+	if err != nil {
+		if vault.IsFatalError(err) {
+			return diagnose.SpotError(ctx, "create-core", fmt.Errorf("Error initializing core: %s", err))
+		}
+		diagnose.SpotWarn(ctx, "create-core", "A non-fatal error occurred during initialization: "+err.Error())
+	} else {
+		diagnose.SpotOk(ctx, "create-core", "")
+	}
+	return nil
+}
+
+// closeBackend closes a storage backend that holds resources open, such as
+// raft's database.
+func closeBackend(b interface{}) {
+	if closer, ok := b.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// hostDiagnostics runs the checks of the host alone, which need no
+// configuration. Checks that take a setting from the configuration use the
+// server's defaults: a single listener on the default address, with mlock
+// enabled.
+func (c *OperatorDiagnoseCommand) hostDiagnostics(ctx context.Context) error {
+	ctx, span := diagnose.StartSpan(ctx, "host")
+	defer span.End()
+
+	diagnose.InvocationCheck(ctx, c.invocation())
+	diagnose.OSChecks(ctx)
+
+	defaultListener := &configutil.Listener{Type: "tcp", Address: "127.0.0.1:8200"}
+	diagnose.Test(ctx, "check-capacity", func(ctx context.Context) error {
+		est := diagnose.EstimateCapacity(1, 0, nil)
+		fdLimit, memoryLimit := diagnose.ProcessLimits()
+		diagnose.CapacityCheck(ctx, est, fdLimit, memoryLimit)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-mlock", func(ctx context.Context) error {
+		diagnose.MlockCheck(ctx)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-random-source", func(ctx context.Context) error {
+		diagnose.RandomSourceCheck(ctx)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-execution-context", func(ctx context.Context) error {
+		diagnose.ExecutionContextCheck(ctx)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-container", func(ctx context.Context) error {
+		return diagnose.ContainerCheck(ctx, false, "")
+	})
+
+	diagnose.Test(ctx, "check-cpu", func(ctx context.Context) error {
+		diagnose.CPUCheck(ctx, diagnose.UsableCPUs(), false)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-firewall", func(ctx context.Context) error {
+		return diagnose.FirewallCheck(ctx, []*configutil.Listener{defaultListener}, false)
+	})
+	return nil
+}
+
+func (c *OperatorDiagnoseCommand) offlineDiagnostics(ctx context.Context) error {
+	server := c.newServerCommand()
 
 	ctx, span := diagnose.StartSpan(ctx, "initialization")
 	defer span.End()
 
+	diagnose.InvocationCheck(ctx, c.invocation())
+
 	// OS Specific checks
 	diagnose.OSChecks(ctx)
 
@@ -233,12 +1139,179 @@ func (c *OperatorDiagnoseCommand) offlineDiagnostics(ctx context.Context) error
 	} else {
 		diagnose.SpotOk(ctx, "parse-config", "")
 	}
+	c.config = config
+
+	// Finding the unused keys loads each file again, so it's done once for
+	// every check that needs them.
+	unused, unusedErr := c.unusedConfigKeys()
+
+	if c.flagDebug {
+		diagnose.Test(ctx, "config-defaults", func(ctx context.Context) error {
+			explicit, err := c.explicitConfigKeys()
+			if err != nil {
+				return err
+			}
+			diagnose.ConfigDefaultsCheck(ctx, config.Sanitized(), explicit)
+			return nil
+		})
+	}
+
+	if c.flagSchemaValidate {
+		diagnose.Test(ctx, "schema-validate", func(ctx context.Context) error {
+			files, err := c.configFiles()
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				contents, err := ioutil.ReadFile(file)
+				if err != nil {
+					return err
+				}
+				violations, err := diagnose.ValidateConfigSchema(contents)
+				if err != nil {
+					return fmt.Errorf("error parsing %s: %w", file, err)
+				}
+				diagnose.ConfigSchemaCheck(ctx, file, violations)
+			}
+			return nil
+		})
+	}
+
+	// In partial mode, checks needing a missing stanza are skipped rather
+	// than failed. The run is always flagged so it can't stand in for a
+	// check of the complete configuration.
+	requires := func(present bool, f func(context.Context) error) func(context.Context) error {
+		return diagnose.RequiresStanza(present || !c.flagPartial, f)
+	}
+	hasStorage := config.Storage != nil
+	hasListeners := len(config.Listeners) > 0
+	if c.flagPartial {
+		var missing []string
+		if !hasStorage {
+			missing = append(missing, "storage")
+		}
+		if !hasListeners {
+			missing = append(missing, "listener")
+		}
+		msg := "running against a partial configuration; run without -partial-config before deploying"
+		if len(missing) > 0 {
+			msg = fmt.Sprintf("running against a partial configuration missing %s; run without -partial-config before deploying",
+				strings.Join(missing, ", "))
+		}
+		diagnose.SpotWarn(ctx, "partial-config", msg)
+	}
+
+	diagnose.Test(ctx, "check-namespace-config", func(ctx context.Context) error {
+		diagnose.NamespaceConfigCheck(ctx, config.Seals)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-edition", func(ctx context.Context) error {
+		if unusedErr != nil {
+			return unusedErr
+		}
+		stanzas := diagnose.EnterpriseStanzas(unused)
+		if config.LicensePath != "" {
+			stanzas = append(stanzas, "license_path")
+		}
+		if config.DisablePerformanceStandby {
+			stanzas = append(stanzas, "disable_performance_standby")
+		}
+		diagnose.EditionCheck(ctx, stanzas)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-performance-standby", func(ctx context.Context) error {
+		diagnose.PerformanceStandbyCheck(ctx, config.DisablePerformanceStandby)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-log-file", func(ctx context.Context) error {
+		if unusedErr != nil {
+			return unusedErr
+		}
+		diagnose.LogFileCheck(ctx, unused)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-log-requests-level", func(ctx context.Context) error {
+		level, err := c.logRequestsLevel()
+		if err != nil {
+			return err
+		}
+		diagnose.LogRequestsLevelCheck(ctx, level)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-audit-config", func(ctx context.Context) error {
+		if unusedErr != nil {
+			return unusedErr
+		}
+		diagnose.AuditConfigCheck(ctx, unused)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-loopback", func(ctx context.Context) error {
+		diagnose.LoopbackCheck(ctx, configAddrs(config))
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-sockaddr-templates", func(ctx context.Context) error {
+		diagnose.SockaddrTemplateCheck(ctx, configAddrs(config))
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-legacy-tls", func(ctx context.Context) error {
+		diagnose.LegacyTLSCheck(ctx, configTLSVersions(config))
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-capacity", func(ctx context.Context) error {
+		var storageConfig map[string]string
+		if config.Storage != nil {
+			storageConfig = config.Storage.Config
+		}
+		est := diagnose.EstimateCapacity(len(config.Listeners), config.CacheSize, storageConfig)
+		fdLimit, memoryLimit := diagnose.ProcessLimits()
+		diagnose.CapacityCheck(ctx, est, fdLimit, memoryLimit)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-lease-ttl", func(ctx context.Context) error {
+		diagnose.LeaseTTLCheck(ctx, config.DefaultLeaseTTL, config.MaxLeaseTTL)
+		diagnose.TokenRenewalCheck(ctx, config.MaxLeaseTTL)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-telemetry", func(ctx context.Context) error {
+		diagnose.TelemetryCheck(ctx, config.Telemetry)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-execution-context", func(ctx context.Context) error {
+		diagnose.ExecutionContextCheck(ctx)
+		return nil
+	})
+
+	diagnose.Test(ctx, "check-container", func(ctx context.Context) error {
+		var storagePath string
+		if config.Storage != nil && (config.Storage.Type == "raft" || config.Storage.Type == "file") {
+			storagePath = config.Storage.Config["path"]
+		}
+		return diagnose.ContainerCheck(ctx, config.DisableMlock, storagePath)
+	})
+
+	diagnose.Test(ctx, "check-cpu", func(ctx context.Context) error {
+		raftVoter := config.Storage != nil && config.Storage.Type == "raft"
+		diagnose.CPUCheck(ctx, diagnose.UsableCPUs(), raftVoter)
+		return nil
+	})
 
 	var metricSink *metricsutil.ClusterMetricSink
 	var metricsHelper *metricsutil.MetricsHelper
 
 	var backend *physical.Backend
-	diagnose.Test(ctx, "storage", func(ctx context.Context) error {
+	diagnose.Test(ctx, "storage", requires(hasStorage, func(ctx context.Context) error {
 		diagnose.Test(ctx, "create-storage-backend", func(ctx context.Context) error {
 
 			b, err := server.setupStorage(config)
@@ -271,6 +1344,92 @@ func (c *OperatorDiagnoseCommand) offlineDiagnostics(ctx context.Context) error
 			})
 		}
 
+		diagnose.Test(ctx, "check-storage-pool", func(ctx context.Context) error {
+			return diagnose.StoragePoolCheck(ctx, config.Storage.Type, config.Storage.Config)
+		})
+
+		if backend != nil {
+			diagnose.Test(ctx, "check-storage-transactions", func(ctx context.Context) error {
+				diagnose.StorageTransactionsCheck(ctx, config.Storage.Type, *backend, config.HAStorage != nil)
+				return nil
+			})
+		}
+
+		diagnose.Test(ctx, "check-storage-consistency", func(ctx context.Context) error {
+			diagnose.StorageConsistencyCheck(ctx, config.Storage.Type, config.Storage.Config)
+			return nil
+		})
+
+		if diagnose.IsCloudStorage(config.Storage.Type) {
+			diagnose.Test(ctx, "check-storage-encryption", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
+				return diagnose.StorageEncryptionCheck(ctx, config.Storage.Type, config.Storage.Config)
+			}))
+		}
+
+		if config.Storage.Type == storageTypeRaft {
+			diagnose.Test(ctx, "raft", func(ctx context.Context) error {
+				diagnose.RaftMaxEntrySizeCheck(ctx, config.Storage.Config)
+				diagnose.RaftTimingCheck(ctx, config.Storage.Config)
+				diagnose.RaftPathConfigCheck(ctx, config.Storage.Config["path"], c.flagConfigs)
+				diagnose.RaftLogStoreCheck(ctx, config.Storage.Config)
+				diagnose.RaftSnapshotReplayCheck(ctx, config.Storage.Config)
+				diagnose.RaftJoinTransferCheck(ctx, config.Storage.Config)
+				return nil
+			})
+			if config.Storage.Config["path"] != "" {
+				diagnose.Test(ctx, "check-raft-filesystems", func(ctx context.Context) error {
+					return diagnose.RaftFilesystemsCheck(ctx, config.Storage.Config["path"])
+				})
+				diagnose.Test(ctx, "check-raft-node-id", func(ctx context.Context) error {
+					return diagnose.RaftNodeIDCheck(ctx, config.Storage.Config)
+				})
+				if !c.skipEndEnd {
+					diagnose.Test(ctx, "check-raft-path-write", func(ctx context.Context) error {
+						return diagnose.RaftPathWriteCheck(ctx, config.Storage.Config["path"])
+					})
+				}
+			}
+			diagnose.Test(ctx, "test-raft-retry-join-tls", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
+				return diagnose.RaftRetryJoinTLSCheck(ctx, config.Storage.Config)
+			}))
+			diagnose.Test(ctx, "check-raft-latency", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
+				return diagnose.RaftLatencyCheck(ctx, config.Storage.Config)
+			}))
+			diagnose.Test(ctx, "check-raft-cluster-addr", func(ctx context.Context) error {
+				addr, source := config.ClusterAddr, "cluster_addr"
+				if envCA := os.Getenv("VAULT_CLUSTER_ADDR"); envCA != "" {
+					addr, source = envCA, "VAULT_CLUSTER_ADDR"
+				}
+				diagnose.RaftClusterAddrCheck(ctx, addr, source)
+				return nil
+			})
+		}
+
+		if config.Storage.Type == storageTypeRaft || config.Storage.Type == storageTypeFile {
+			if config.Storage.Config["path"] != "" {
+				diagnose.Test(ctx, "check-storage-path", func(ctx context.Context) error {
+					return diagnose.StoragePathCheck(ctx, config.Storage.Config["path"])
+				})
+				diagnose.Test(ctx, "check-storage-ownership", func(ctx context.Context) error {
+					return diagnose.StorageOwnershipCheck(ctx, config.Storage.Config["path"])
+				})
+			}
+			diagnose.Test(ctx, "check-storage-filesystem", func(ctx context.Context) error {
+				path := config.Storage.Config["path"]
+				if path == "" {
+					diagnose.Skipped(ctx, diagnose.SkipStanzaAbsent, "no storage path configured")
+					return nil
+				}
+				return diagnose.StorageFilesystemCheck(ctx, path)
+			})
+
+			if !c.skipEndEnd && config.Storage.Config["path"] != "" {
+				diagnose.Test(ctx, "check-storage-fsync", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
+					return diagnose.StorageFsyncCheck(ctx, config.Storage.Config["path"])
+				}))
+			}
+		}
+
 		// Attempt to use storage backend
 		if !c.skipEndEnd {
 			diagnose.Test(ctx, "test-access-storage", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
@@ -283,12 +1442,12 @@ func (c *OperatorDiagnoseCommand) offlineDiagnostics(ctx context.Context) error
 				uuid := "diagnose/latency/" + uuidSuffix
 				dur, err := diagnose.EndToEndLatencyCheckWrite(ctx, uuid, *backend)
 				if err != nil {
-					return err
+					return diagnose.StorageThrottleCheck(ctx, config.Storage.Type, "write", err)
 				}
 				maxDuration = dur
 				dur, err = diagnose.EndToEndLatencyCheckRead(ctx, uuid, *backend)
 				if err != nil {
-					return err
+					return diagnose.StorageThrottleCheck(ctx, config.Storage.Type, "read", err)
 				}
 				if dur > maxDuration {
 					maxDuration = dur
@@ -296,26 +1455,38 @@ func (c *OperatorDiagnoseCommand) offlineDiagnostics(ctx context.Context) error
 				}
 				dur, err = diagnose.EndToEndLatencyCheckDelete(ctx, uuid, *backend)
 				if err != nil {
-					return err
+					return diagnose.StorageThrottleCheck(ctx, config.Storage.Type, "delete", err)
 				}
 				if dur > maxDuration {
 					maxDuration = dur
 					maxDurationCrudOperation = "delete"
 				}
 
+				diagnose.StorageThrottleCheck(ctx, config.Storage.Type, "", nil)
+
 				if maxDuration > time.Duration(0) {
 					diagnose.Warn(ctx, diagnose.LatencyWarning+fmt.Sprintf("duration: %s, ", maxDuration)+fmt.Sprintf("operation: %s", maxDurationCrudOperation))
 				}
 				return nil
 			}))
 		}
+
+		if !c.skipEndEnd && c.flagThroughput && backend != nil {
+			diagnose.Test(ctx, "test-storage-throughput", func(ctx context.Context) error {
+				uuidSuffix, err := uuid.GenerateUUID()
+				if err != nil {
+					return err
+				}
+				return diagnose.StorageThroughputCheck(ctx, config.Storage.Type, "diagnose/throughput/"+uuidSuffix+"/", *backend, 30*time.Second)
+			})
+		}
 		return nil
-	})
+	}))
 
 	var configSR sr.ServiceRegistration
 	diagnose.Test(ctx, "service-discovery", func(ctx context.Context) error {
 		if config.ServiceRegistration == nil || config.ServiceRegistration.Config == nil {
-			diagnose.Skipped(ctx, "no service registration configured")
+			diagnose.Skipped(ctx, diagnose.SkipStanzaAbsent, "no service registration configured")
 			return nil
 		}
 		srConfig := config.ServiceRegistration.Config
@@ -338,11 +1509,73 @@ func (c *OperatorDiagnoseCommand) offlineDiagnostics(ctx context.Context) error
 				}
 				return nil
 			})
+			diagnose.Test(ctx, "check-consul-registration-timing", func(ctx context.Context) error {
+				return diagnose.ConsulRegistrationTimingCheck(ctx, config.ServiceRegistration.Config)
+			})
+
+			storageStanza := config.Storage
+			if config.HAStorage != nil {
+				storageStanza = config.HAStorage
+			}
+			if storageStanza != nil && storageStanza.Type == "consul" {
+				diagnose.Test(ctx, "check-consul-datacenter", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
+					storageAgent, err := consulAgent(storageStanza.Config, server.logger)
+					if err != nil {
+						return err
+					}
+					registrationAgent, err := consulAgent(config.ServiceRegistration.Config, server.logger)
+					if err != nil {
+						return err
+					}
+					diagnose.ConsulDatacenterCheck(ctx, storageAgent, registrationAgent)
+					return nil
+				}))
+			}
 		}
 		return nil
 	})
 
 	sealcontext, sealspan := diagnose.StartSpan(ctx, "create-seal")
+	diagnose.SealOrderCheck(sealcontext, config.Seals)
+	diagnose.KeySharesCheck(sealcontext, c.flagKeyShares, c.flagKeyThreshold, config.Seals)
+	diagnose.SealLibraryChecks(sealcontext, config.Seals)
+	for _, configSeal := range config.Seals {
+		if configSeal.Disabled {
+			continue
+		}
+		configSeal := configSeal
+		if len(diagnose.SealEnvVars(configSeal.Type)) > 0 {
+			diagnose.Test(sealcontext, "check-seal-env", func(ctx context.Context) error {
+				diagnose.SealEnvCheck(ctx, configSeal.Type, configSeal.Config)
+				return nil
+			})
+		}
+		if endpoints := diagnose.KMSEndpoints(configSeal.Type, configSeal.Config); len(endpoints) > 0 {
+			diagnose.Test(sealcontext, "check-kms-endpoint", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
+				diagnose.KMSEndpointCheck(ctx, configSeal.Type, endpoints)
+				return nil
+			}))
+		}
+		if configSeal.Type == wrapping.AWSKMS {
+			diagnose.Test(sealcontext, "check-kms-credentials", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
+				return diagnose.AWSKMSCredentialsCheck(ctx, configSeal.Config)
+			}))
+		}
+		if diagnose.KMSKeyResolvable(configSeal.Type) {
+			diagnose.Test(sealcontext, "check-kms-key", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
+				return diagnose.KMSKeyCheck(ctx, configSeal.Type, configSeal.Config)
+			}))
+		}
+		switch configSeal.Type {
+		case wrapping.AWSKMS, wrapping.GCPCKMS, wrapping.AliCloudKMS:
+			if config.Storage != nil {
+				diagnose.Test(sealcontext, "check-seal-region", func(ctx context.Context) error {
+					diagnose.SealRegionCheck(ctx, configSeal.Type, configSeal.Config, config.Storage.Type, config.Storage.Config)
+					return nil
+				})
+			}
+		}
+	}
 	var seals []vault.Seal
 	var sealConfigError error
 	barrierSeal, barrierWrapper, unwrapSeal, seals, sealConfigError, err := setSeal(server, config, make([]string, 0), make(map[string]string))
@@ -377,9 +1610,43 @@ func (c *OperatorDiagnoseCommand) offlineDiagnostics(ctx context.Context) error
 	}
 
 SEALFAIL:
+	for _, configSeal := range config.Seals {
+		if configSeal.Disabled || configSeal.Type != wrapping.Transit {
+			continue
+		}
+		configSeal := configSeal
+		diagnose.Test(sealcontext, "check-transit-seal-dependency", func(ctx context.Context) error {
+			self := map[string]string{}
+			if addr, source, err := diagnose.ResolveAPIAddr(config.APIAddr, nil); err == nil {
+				setting := "api_addr"
+				if source != setting {
+					setting = fmt.Sprintf("api_addr (from %s)", source)
+				}
+				self[setting] = addr
+			}
+			for i, l := range config.Listeners {
+				if l.Type == "tcp" {
+					self[fmt.Sprintf("listener %d address", i+1)] = l.Address
+				}
+			}
+			var peers []string
+			if config.Storage != nil && config.Storage.Type == "raft" {
+				peers = diagnose.RaftRetryJoinAddrs(config.Storage.Config)
+			}
+			diagnose.TransitSealDependencyCheck(ctx, configSeal.Config, self, peers)
+			return nil
+		})
+		diagnose.Test(sealcontext, "test-transit-seal", diagnose.Skippable("autounseal", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
+			return diagnose.TransitSealCheck(ctx, configSeal.Config)
+		})))
+	}
 	sealspan.End()
+	diagnose.Test(ctx, "check-random-source", func(ctx context.Context) error {
+		diagnose.RandomSourceCheck(ctx)
+		return nil
+	})
 	var coreConfig vault.CoreConfig
-	if err := diagnose.Test(ctx, "setup-core", func(ctx context.Context) error {
+	if err := diagnose.Test(ctx, "setup-core", requires(hasStorage, func(ctx context.Context) error {
 		var secureRandomReader io.Reader
 		// prepare a secure random reader for core
 		secureRandomReader, err = configutil.CreateSecureRandomReaderFunc(config.SharedConfig, barrierWrapper)
@@ -393,27 +1660,90 @@ SEALFAIL:
 		}
 		coreConfig = createCoreConfig(server, config, *backend, configSR, barrierSeal, unwrapSeal, metricsHelper, metricSink, secureRandomReader)
 		return nil
-	}); err != nil {
+	})); err != nil {
 		diagnose.Error(ctx, err)
 	}
 
+	diagnose.Test(ctx, "check-core-config", requires(hasStorage, func(ctx context.Context) error {
+		if coreConfig.RawConfig == nil {
+			return fmt.Errorf(CoreConfigUninitializedErr)
+		}
+		features := diagnose.CoreFeatures{
+			StorageType:                    coreConfig.StorageType,
+			DisableSealWrap:                coreConfig.DisableSealWrap,
+			DisableCache:                   coreConfig.DisableCache,
+			CacheSize:                      coreConfig.CacheSize,
+			PluginDirectory:                coreConfig.PluginDirectory,
+			EnableResponseHeaderRaftNodeID: coreConfig.EnableResponseHeaderRaftNodeID,
+		}
+		if coreConfig.Seal != nil {
+			features.SealType = coreConfig.Seal.BarrierType()
+		}
+		diagnose.CoreConfigCheck(ctx, features)
+		return nil
+	}))
+
+	diagnose.Test(ctx, "check-plugin-execution", func(ctx context.Context) error {
+		return diagnose.PluginExecutionCheck(ctx, coreConfig.PluginDirectory, coreConfig.DisableMlock)
+	})
+
 	var disableClustering bool
-	diagnose.Test(ctx, "setup-ha-storage", func(ctx context.Context) error {
+	diagnose.Test(ctx, "setup-ha-storage", requires(hasStorage, func(ctx context.Context) error {
 		if backend == nil {
 			return fmt.Errorf(BackendUninitializedErr)
 		}
+		if config.HAStorage != nil {
+			diagnose.Test(ctx, "check-raft-ha-storage", func(ctx context.Context) error {
+				return diagnose.RaftHAStorageCheck(ctx, config.Storage.Type, config.HAStorage.Type)
+			})
+		}
+		var haErr error
 		diagnose.Test(ctx, "create-ha-storage-backend", func(ctx context.Context) error {
 			// Initialize the separate HA storage backend, if it exists
-			disableClustering, err = initHaBackend(server, config, &coreConfig, *backend)
-			if err != nil {
-				return err
+			disableClustering, haErr = initHaBackend(server, config, &coreConfig, *backend)
+			if haErr != nil {
+				return haErr
 			}
 			return nil
 		})
+		// Raft is always HA, so only other ha_storage types can lack support.
+		// initHaBackend leaves HAPhysical unset for a backend without HA
+		// support, and sets it for one with HA disabled.
+		if config.HAStorage != nil && config.HAStorage.Type != storageTypeRaft &&
+			(coreConfig.HAPhysical != nil || errors.Is(haErr, errHAStorageUnsupported)) {
+			diagnose.Test(ctx, "check-ha-storage", func(ctx context.Context) error {
+				return diagnose.HAStorageCheck(ctx, config.HAStorage.Type, coreConfig.HAPhysical)
+			})
+		}
+		if config.ServiceRegistration != nil && config.ServiceRegistration.Config != nil {
+			diagnose.Test(ctx, "test-serviceregistration-api-addr", func(ctx context.Context) error {
+				stanza := config.Storage
+				if config.HAStorage != nil {
+					stanza = config.HAStorage
+				}
+				// Detect the address from the backend the server detects it
+				// from.
+				var detect physical.RedirectDetect
+				var ok bool
+				if coreConfig.HAPhysical != nil && coreConfig.HAPhysical.HAEnabled() {
+					detect, ok = coreConfig.HAPhysical.(physical.RedirectDetect)
+				} else {
+					detect, ok = (*backend).(physical.RedirectDetect)
+				}
+				var detectFunc func() (string, error)
+				if ok {
+					detectFunc = func() (string, error) {
+						return server.detectRedirect(detect, config)
+					}
+				}
+				diagnose.ServiceRegistrationAPIAddrCheck(ctx, stanza.RedirectAddr, detectFunc)
+				return nil
+			})
+		}
 
 		diagnose.Test(ctx, "test-consul-direct-access-storage", func(ctx context.Context) error {
 			if config.HAStorage == nil {
-				diagnose.Skipped(ctx, "no HA storage configured")
+				diagnose.Skipped(ctx, diagnose.SkipStanzaAbsent, "no HA storage configured")
 			} else {
 				dirAccess := diagnose.ConsulDirectAccess(config.HAStorage.Config)
 				if dirAccess != "" {
@@ -422,6 +1752,21 @@ SEALFAIL:
 			}
 			return nil
 		})
+		if config.HAStorage != nil {
+			diagnose.Test(ctx, "test-storage-overlap", func(ctx context.Context) error {
+				diagnose.StorageOverlapCheck(ctx, config.Storage.Type, config.Storage.Config, config.HAStorage.Type, config.HAStorage.Config)
+				return nil
+			})
+		}
+		haStanza := config.Storage
+		if config.HAStorage != nil {
+			haStanza = config.HAStorage
+		}
+		if haStanza.Type == "dynamodb" && diagnose.DynamoDBHAEnabled(haStanza.Config) {
+			diagnose.Test(ctx, "check-dynamodb-ha", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
+				return diagnose.DynamoDBHACheck(ctx, haStanza.Config)
+			}))
+		}
 		if config.HAStorage != nil && config.HAStorage.Type == storageTypeConsul {
 			diagnose.Test(ctx, "test-ha-storage-tls-consul", func(ctx context.Context) error {
 				err = physconsul.SetupSecureTLS(api.DefaultConfig(), config.HAStorage.Config, server.logger, true)
@@ -432,7 +1777,7 @@ SEALFAIL:
 			})
 		}
 		return nil
-	})
+	}))
 
 	// Determine the redirect address from environment variables
 	err = determineRedirectAddr(server, &coreConfig, config)
@@ -441,16 +1786,88 @@ SEALFAIL:
 	}
 	diagnose.SpotOk(ctx, "determine-redirect", "")
 
+	// findClusterAddress forces the scheme of the cluster address to https,
+	// so the configured address is kept to check its scheme.
+	configuredClusterAddr, clusterAddrSource := coreConfig.ClusterAddr, "cluster_addr"
+	if envCA := os.Getenv("VAULT_CLUSTER_ADDR"); envCA != "" {
+		configuredClusterAddr, clusterAddrSource = envCA, "VAULT_CLUSTER_ADDR"
+	}
 	err = findClusterAddress(server, &coreConfig, config, disableClustering)
 	if err != nil {
 		return diagnose.SpotError(ctx, "find-cluster-addr", err)
 	}
 	diagnose.SpotOk(ctx, "find-cluster-addr", "")
+	if !disableClustering && configuredClusterAddr != "" {
+		diagnose.ClusterAddrSchemeCheck(ctx, configuredClusterAddr, clusterAddrSource)
+	}
+
+	if coreConfig.HAPhysical != nil {
+		diagnose.Test(ctx, "check-clustering", func(ctx context.Context) error {
+			stanza := config.Storage
+			if config.HAStorage != nil {
+				stanza = config.HAStorage
+			}
+			cc := diagnose.ClusteringConfig{
+				Disabled:             disableClustering,
+				StanzaType:           stanza.Type,
+				StanzaDisabled:       stanza.DisableClustering,
+				ClusterAddr:          stanza.ClusterAddr,
+				EffectiveClusterAddr: coreConfig.ClusterAddr,
+			}
+			if cc.ClusterAddr == "" {
+				cc.ClusterAddr = config.ClusterAddr
+			}
+			for _, l := range config.Listeners {
+				if l.ClusterAddress != "" {
+					cc.ClusterListeners = append(cc.ClusterListeners, l.Address)
+				}
+			}
+			diagnose.ClusteringCoherenceCheck(ctx, cc)
+			return nil
+		})
+
+		if !disableClustering && coreConfig.ClusterAddr != "" {
+			diagnose.Test(ctx, "check-cluster-address", func(ctx context.Context) error {
+				diagnose.ClusterAddressOverrideCheck(ctx, coreConfig.ClusterAddr, config.Listeners)
+				return nil
+			})
+
+			diagnose.Test(ctx, "check-cluster-cipher-suites", func(ctx context.Context) error {
+				diagnose.ClusterCipherSuitesCheck(ctx, config.ClusterCipherSuites)
+				return nil
+			})
+		}
+	}
+
+	diagnose.Test(ctx, "check-tls-key-separation", func(ctx context.Context) error {
+		var raftConf map[string]string
+		if config.Storage != nil && config.Storage.Type == storageTypeRaft {
+			raftConf = config.Storage.Config
+		}
+		return diagnose.TLSKeySeparationCheck(ctx, config.Listeners, raftConf, disableClustering, c.flagStrictKeys)
+	})
+
+	// Peers must be able to route to the advertised addresses, so a wildcard
+	// host that slipped through from a listener address is always an error.
+	if coreConfig.ClusterAddr != "" {
+		if err := diagnose.WildcardAddrCheck(coreConfig.ClusterAddr); err != nil {
+			diagnose.SpotError(ctx, "check-cluster-addr-wildcard", err)
+		} else {
+			diagnose.SpotOk(ctx, "check-cluster-addr-wildcard", coreConfig.ClusterAddr)
+		}
+	}
+	if coreConfig.RedirectAddr != "" {
+		if err := diagnose.WildcardAddrCheck(coreConfig.RedirectAddr); err != nil {
+			diagnose.SpotError(ctx, "check-redirect-addr-wildcard", err)
+		} else {
+			diagnose.SpotOk(ctx, "check-redirect-addr-wildcard", coreConfig.RedirectAddr)
+		}
+	}
 
 	// Run all the checks that are utilized when initializing a core object
 	// without actually calling core.Init. These are in the init-core section
 	// as they are runtime checks.
-	diagnose.Test(ctx, "init-core", func(ctx context.Context) error {
+	diagnose.Test(ctx, "init-core", requires(hasStorage, func(ctx context.Context) error {
 		var newCoreError error
 		if coreConfig.RawConfig == nil {
 			return fmt.Errorf(CoreConfigUninitializedErr)
@@ -465,15 +1882,31 @@ SEALFAIL:
 					"check the logs for more information."))
 		}
 		return nil
-	})
+	}))
 
 	var lns []listenerutil.Listener
-	diagnose.Test(ctx, "init-listeners", func(ctx context.Context) error {
+	diagnose.Test(ctx, "init-listeners", requires(hasListeners, func(ctx context.Context) error {
 		disableClustering := config.HAStorage != nil && config.HAStorage.DisableClustering
 		infoKeys := make([]string, 0, 10)
 		info := make(map[string]string)
 		var listeners []listenerutil.Listener
 		var status int
+
+		// Bind each port as the running user before the real listeners are
+		// created, so that a privilege or port conflict is reported precisely.
+		diagnose.Test(ctx, "check-listener-interfaces", diagnose.Skippable("listener", func(ctx context.Context) error {
+			return diagnose.ListenerInterfaceCheck(ctx, config.Listeners)
+		}))
+
+		diagnose.Test(ctx, "bind-listeners", diagnose.Skippable("listener", func(ctx context.Context) error {
+			diagnose.ListenerBindChecks(ctx, config.Listeners)
+			return nil
+		}))
+
+		diagnose.Test(ctx, "check-firewall", diagnose.Skippable("listener", func(ctx context.Context) error {
+			return diagnose.FirewallCheck(ctx, config.Listeners, disableClustering)
+		}))
+
 		diagnose.Test(ctx, "create-listeners", func(ctx context.Context) error {
 			status, listeners, _, err = server.InitListeners(config, disableClustering, &infoKeys, &info)
 			if status != 0 {
@@ -484,6 +1917,10 @@ SEALFAIL:
 
 		lns = listeners
 
+		diagnose.Test(ctx, "check-tcp-socket-options", func(ctx context.Context) error {
+			return diagnose.TCPSocketOptionsCheck(ctx, config.Listeners, tcpKeepAliveListener, tcpKeepAlivePeriod)
+		})
+
 		// Make sure we close all listeners from this point on
 		listenerCloseFunc := func() {
 			for _, ln := range lns {
@@ -502,6 +1939,8 @@ SEALFAIL:
 				}
 				if ln.Config.TLSDisableClientCerts {
 					diagnose.Warn(ctx, "TLS for a listener is turned on without requiring client certs.")
+				} else if ln.Config.TLSClientCAFile != "" {
+					diagnose.TLSClientCAChecks(ctx, ln.Config)
 				}
 
 				// Check ciphersuite and load ca/cert/key files
@@ -523,8 +1962,51 @@ SEALFAIL:
 			}
 			return nil
 		})
+
+		diagnose.Test(ctx, "check-listener-ocsp", diagnose.WithTimeout(30*time.Second, func(ctx context.Context) error {
+			diagnose.ListenerOCSPChecks(ctx, lns)
+			return nil
+		}))
+
+		diagnose.Test(ctx, "check-listener-features", func(ctx context.Context) error {
+			diagnose.ListenerFeatureChecks(ctx, config.Listeners)
+			return nil
+		})
+
+		diagnose.Test(ctx, "check-ui-exposure", func(ctx context.Context) error {
+			enabled, source := config.EnableUI, "the default"
+			if config.EnableUIRaw != nil {
+				source = "ui"
+			}
+			if env := os.Getenv("VAULT_UI"); env != "" {
+				if b, err := strconv.ParseBool(env); err == nil {
+					enabled, source = b, "VAULT_UI"
+				}
+			}
+			diagnose.UIExposureCheck(ctx, enabled, source, config.Listeners)
+			return nil
+		})
+
+		diagnose.Test(ctx, "check-max-request-duration", func(ctx context.Context) error {
+			diagnose.MaxRequestDurationCheck(ctx, config.DefaultMaxRequestDuration, vault.DefaultMaxRequestDuration, config.Listeners)
+			return nil
+		})
+
+		diagnose.Test(ctx, "check-max-request-size", func(ctx context.Context) error {
+			var storageType string
+			var storageConf map[string]string
+			if config.Storage != nil {
+				storageType, storageConf = config.Storage.Type, config.Storage.Config
+			}
+			return diagnose.MaxRequestSizeCheck(ctx, vaulthttp.DefaultMaxRequestSize, config.Listeners, storageType, storageConf)
+		})
+
+		diagnose.Test(ctx, "check-request-limiter", func(ctx context.Context) error {
+			diagnose.RequestLimiterCheck(ctx, config.Listeners)
+			return nil
+		})
 		return nil
-	})
+	}))
 
 	// TODO: Diagnose logging configuration
 
@@ -565,5 +2047,31 @@ SEALFAIL:
 		}
 		return nil
 	})
+
+	// Operator-supplied checks run last, under their own section, with the
+	// same timeout as the built-in checks.
+	if len(c.flagCustomChecks) > 0 {
+		diagnose.Test(ctx, "custom", func(ctx context.Context) error {
+			names := make([]string, 0, len(c.flagCustomChecks))
+			for name := range c.flagCustomChecks {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				diagnose.RunCustomCheck(ctx, name, c.flagCustomChecks[name], 30*time.Second)
+			}
+			return nil
+		})
+	}
 	return nil
 }
+
+// tcpKeepAlivePeriod is the keep-alive period server.TCPKeepAliveListener
+// requests on accepted connections.
+const tcpKeepAlivePeriod = 3 * time.Minute
+
+// tcpKeepAliveListener wraps ln as the server wraps its tcp listeners. It
+// exists because the server package is shadowed where listeners are checked.
+func tcpKeepAliveListener(ln *net.TCPListener) net.Listener {
+	return server.TCPKeepAliveListener{TCPListener: ln}
+}
@@ -6,6 +6,8 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
@@ -26,6 +28,60 @@ func testOperatorDiagnoseCommand(tb testing.TB) *OperatorDiagnoseCommand {
 	}
 }
 
+// TestOperatorDiagnoseCommand_ServerBackends checks that diagnose builds its
+// server with the same backends as the server command, so that it accepts and
+// rejects the same configurations.
+func TestOperatorDiagnoseCommand_ServerBackends(t *testing.T) {
+	ui := cli.NewMockUi()
+	initCommands(ui, ui, &RunOptions{})
+	cmd, err := Commands["server"]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := cmd.(*ServerCommand)
+	actual := testOperatorDiagnoseCommand(t).newServerCommand()
+
+	compare := func(kind string, expected, actual []string) {
+		t.Helper()
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%s backends differ: the server registers %v, diagnose registers %v", kind, expected, actual)
+		}
+	}
+	compare("audit", backendNames(expected.AuditBackends), backendNames(actual.AuditBackends))
+	compare("auth", backendNames(expected.CredentialBackends), backendNames(actual.CredentialBackends))
+	compare("secrets", backendNames(expected.LogicalBackends), backendNames(actual.LogicalBackends))
+	compare("storage", backendNames(expected.PhysicalBackends), backendNames(actual.PhysicalBackends))
+	compare("service registration", backendNames(expected.ServiceRegistrations), backendNames(actual.ServiceRegistrations))
+}
+
+// backendNames returns the sorted names of a map of backend factories.
+func backendNames(factories interface{}) []string {
+	var names []string
+	for _, k := range reflect.ValueOf(factories).MapKeys() {
+		names = append(names, k.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TestOperatorDiagnoseCommand_NoSkipsCommunity checks that -no-skips accepts
+// the skips every community build and non-Linux host makes.
+func TestOperatorDiagnoseCommand_NoSkipsCommunity(t *testing.T) {
+	cmd := testOperatorDiagnoseCommand(t)
+	cmd.flagNoSkips = true
+	ctx := diagnose.Context(context.Background(), cmd.diagnose)
+	func() {
+		ctx, span := diagnose.StartSpan(ctx, "initialization")
+		defer span.End()
+		diagnose.NamespaceConfigCheck(ctx, nil)
+		diagnose.SpotSkipped(ctx, "firewall", diagnose.SkipNotApplicablePlatform, "firewall rules are only read on Linux")
+	}()
+	results := cmd.diagnose.Finalize(ctx)
+	if code := cmd.reportUnexpectedSkips(results); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, cmd.UI.(*cli.MockUi).ErrorWriter.String())
+	}
+}
+
 func TestOperatorDiagnoseCommand_Run(t *testing.T) {
 	t.Parallel()
 	cases := []struct {
@@ -187,6 +243,55 @@ func TestOperatorDiagnoseCommand_Run(t *testing.T) {
 				},
 			},
 		},
+		{
+			"diagnose_service_registration_without_storage",
+			[]string{
+				"-config", "./server/test-fixtures/nostore_sr_config.hcl",
+			},
+			[]*diagnose.Result{
+				{
+					Name:    "storage",
+					Status:  diagnose.ErrorStatus,
+					Message: "no storage stanza found in config",
+				},
+				{
+					Name:   "service-discovery",
+					Status: diagnose.OkStatus,
+				},
+				{
+					Name:    "setup-ha-storage",
+					Status:  diagnose.ErrorStatus,
+					Message: BackendUninitializedErr,
+				},
+			},
+		},
+		{
+			"diagnose_mirror_server",
+			[]string{
+				"-config", "./server/test-fixtures/mirror_inmem_config.hcl",
+				"-mirror-server",
+			},
+			[]*diagnose.Result{
+				{
+					Name:   "mirror-server",
+					Status: diagnose.OkStatus,
+					Children: []*diagnose.Result{
+						{
+							Name:   "setup-core-config",
+							Status: diagnose.OkStatus,
+						},
+						{
+							Name:   "complete-core-config",
+							Status: diagnose.OkStatus,
+						},
+						{
+							Name:   "create-core",
+							Status: diagnose.OkStatus,
+						},
+					},
+				},
+			},
+		},
 		{
 			"diagnose_listener_config_ok",
 			[]string{
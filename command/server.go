@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -63,6 +64,10 @@ var (
 
 var memProfilerEnabled = false
 
+// errHAStorageUnsupported is returned by initHaBackend for an ha_storage
+// backend that doesn't implement HA.
+var errHAStorageUnsupported = errors.New("Specified HA storage does not support HA")
+
 var enableFourClusterDev = func(c *ServerCommand, base *vault.CoreConfig, info map[string]string, infoKeys []string, devListenAddress, tempDir string) int {
 	c.logger.Error("-dev-four-cluster only supported in enterprise Vault")
 	return 1
@@ -75,6 +80,7 @@ const (
 	// are declared internally for value comparison and reusability.
 	storageTypeRaft   = "raft"
 	storageTypeConsul = "consul"
+	storageTypeFile   = "file"
 )
 
 type ServerCommand struct {
@@ -1027,10 +1033,11 @@ func (c *ServerCommand) Run(args []string) int {
 		}
 		// TODO: add a file output flag to Diagnose
 		diagnose := &OperatorDiagnoseCommand{
-			BaseCommand: c.BaseCommand,
-			flagDebug:   false,
-			flagSkips:   []string{},
-			flagConfigs: c.flagConfigs,
+			BaseCommand:     c.BaseCommand,
+			flagDebug:       false,
+			flagSkips:       []string{},
+			flagConfigs:     c.flagConfigs,
+			invokedByServer: true,
 		}
 		diagnose.RunWithParsedFlags()
 	}
@@ -1140,20 +1147,9 @@ func (c *ServerCommand) Run(args []string) int {
 
 	logProxyEnvironmentVariables(c.logger)
 
-	if envMlock := os.Getenv("VAULT_DISABLE_MLOCK"); envMlock != "" {
-		var err error
-		config.DisableMlock, err = strconv.ParseBool(envMlock)
-		if err != nil {
-			c.UI.Output("Error parsing the environment variable VAULT_DISABLE_MLOCK")
-			return 1
-		}
-	}
-
-	if envLicensePath := os.Getenv(EnvVaultLicensePath); envLicensePath != "" {
-		config.LicensePath = envLicensePath
-	}
-	if envLicense := os.Getenv(EnvVaultLicense); envLicense != "" {
-		config.License = envLicense
+	if err := applyConfigEnv(config); err != nil {
+		c.UI.Output(err.Error())
+		return 1
 	}
 
 	// If mlockall(2) isn't supported, show a warning. We disable this in dev
@@ -1182,43 +1178,15 @@ func (c *ServerCommand) Run(args []string) int {
 	}
 	metricsHelper := metricsutil.NewMetricsHelper(inmemMetrics, prometheusEnabled)
 
-	// Initialize the storage backend
-	backend, err := c.setupStorage(config)
-	if err != nil {
-		c.UI.Error(err.Error())
-		return 1
-	}
-
-	// Prevent server startup if migration is active
-	// TODO: Use OpenTelemetry to integrate this into Diagnose
-	if c.storageMigrationActive(backend) {
-		return 1
-	}
-
-	// Initialize the Service Discovery, if there is one
-	var configSR sr.ServiceRegistration
-	if config.ServiceRegistration != nil {
-		configSR, err = beginServiceRegistration(c, config)
-		if err != nil {
-			c.UI.Output(err.Error())
-			return 1
-		}
-	}
-
 	infoKeys := make([]string, 0, 10)
 	info := make(map[string]string)
 	info["log level"] = logLevelString
 	infoKeys = append(infoKeys, "log level")
-	barrierSeal, barrierWrapper, unwrapSeal, seals, sealConfigError, err := setSeal(c, config, infoKeys, info)
-
-	// Check error here
-	if err != nil {
-		c.UI.Error(err.Error())
-		return 1
-	}
-
-	if seals != nil {
-		for _, seal := range seals {
+	// Set up storage, service registration and the seals, and build the
+	// core's configuration from them
+	setup, err := c.setupCoreConfig(config, metricsHelper, metricSink, infoKeys, info)
+	if setup != nil {
+		for _, seal := range setup.seals {
 			// Ensure that the seal finalizer is called, even if using verify-only
 			defer func(seal *vault.Seal) {
 				err = (*seal).Finalize(context.Background())
@@ -1228,20 +1196,16 @@ func (c *ServerCommand) Run(args []string) int {
 			}(&seal)
 		}
 	}
-
-	if barrierSeal == nil {
-		c.UI.Error(fmt.Sprintf("Could not create barrier seal! Most likely proper Seal configuration information was not set, but no error was generated."))
-		return 1
-	}
-
-	// prepare a secure random reader for core
-	secureRandomReader, err := configutil.CreateSecureRandomReaderFunc(config.SharedConfig, barrierWrapper)
 	if err != nil {
-		c.UI.Error(err.Error())
+		// storageMigrationActive has already reported the migration
+		if err != errStorageMigrationActive {
+			c.UI.Error(err.Error())
+		}
 		return 1
 	}
+	configSR, sealConfigError := setup.configSR, setup.sealConfigError
 
-	coreConfig := createCoreConfig(c, config, backend, configSR, barrierSeal, unwrapSeal, metricsHelper, metricSink, secureRandomReader)
+	coreConfig := setup.coreConfig
 	if c.flagDevThreeNode {
 		return c.enableThreeNodeDevCluster(&coreConfig, info, infoKeys, c.flagDevListenAddr, os.Getenv("VAULT_DEV_TEMP_DIR"))
 	}
@@ -1250,47 +1214,15 @@ func (c *ServerCommand) Run(args []string) int {
 		return enableFourClusterDev(c, &coreConfig, info, infoKeys, c.flagDevListenAddr, os.Getenv("VAULT_DEV_TEMP_DIR"))
 	}
 
-	// Initialize the separate HA storage backend, if it exists
-	disableClustering, err := initHaBackend(c, config, &coreConfig, backend)
-	if err != nil {
-		c.UI.Output(err.Error())
-		return 1
-	}
-
-	// Determine the redirect address from environment variables
-	err = determineRedirectAddr(c, &coreConfig, config)
-	if err != nil {
-		c.UI.Output(err.Error())
+	disableClustering, redirectErr, err := c.completeCoreConfig(config, &coreConfig)
+	if redirectErr != nil {
+		c.UI.Output(redirectErr.Error())
 	}
-
-	// After the redirect bits are sorted out, if no cluster address was
-	// explicitly given, derive one from the redirect addr
-	err = findClusterAddress(c, &coreConfig, config, disableClustering)
 	if err != nil {
 		c.UI.Output(err.Error())
 		return 1
 	}
 
-	// Override the UI enabling config by the environment variable
-	if enableUI := os.Getenv("VAULT_UI"); enableUI != "" {
-		var err error
-		coreConfig.EnableUI, err = strconv.ParseBool(enableUI)
-		if err != nil {
-			c.UI.Output("Error parsing the environment variable VAULT_UI")
-			return 1
-		}
-	}
-
-	// If ServiceRegistration is configured, then the backend must support HA
-	isBackendHA := coreConfig.HAPhysical != nil && coreConfig.HAPhysical.HAEnabled()
-	if !c.flagDev && (coreConfig.GetServiceRegistration() != nil) && !isBackendHA {
-		c.UI.Output("service_registration is configured, but storage does not support HA")
-		return 1
-	}
-
-	// Apply any enterprise configuration onto the coreConfig.
-	adjustCoreConfigForEnt(config, &coreConfig)
-
 	// Initialize the core
 	core, newCoreError := vault.NewCore(&coreConfig)
 	if newCoreError != nil {
@@ -2122,6 +2054,131 @@ func (c *ServerCommand) removePidFile(pidPath string) error {
 	return os.Remove(pidPath)
 }
 
+// applyConfigEnv overrides the configuration with the environment variables
+// that take precedence over it at startup.
+func applyConfigEnv(config *server.Config) error {
+	if envMlock := os.Getenv("VAULT_DISABLE_MLOCK"); envMlock != "" {
+		var err error
+		config.DisableMlock, err = strconv.ParseBool(envMlock)
+		if err != nil {
+			return errors.New("Error parsing the environment variable VAULT_DISABLE_MLOCK")
+		}
+	}
+
+	if envLicensePath := os.Getenv(EnvVaultLicensePath); envLicensePath != "" {
+		config.LicensePath = envLicensePath
+	}
+	if envLicense := os.Getenv(EnvVaultLicense); envLicense != "" {
+		config.License = envLicense
+	}
+	return nil
+}
+
+// coreSetup is what setupCoreConfig creates on the way to the core's
+// configuration.
+type coreSetup struct {
+	coreConfig      vault.CoreConfig
+	backend         physical.Backend
+	configSR        sr.ServiceRegistration
+	seals           []vault.Seal
+	sealConfigError error
+}
+
+// errStorageMigrationActive is returned by setupCoreConfig when a storage
+// migration prevents startup. storageMigrationActive has already reported the
+// migration by then.
+var errStorageMigrationActive = errors.New("Storage migration in progress; server startup is prevented until the migration completes")
+
+// setupCoreConfig runs the startup steps that build the core's configuration:
+// it sets up storage, checks for a storage migration, begins service
+// registration and sets up the seals. It is shared with diagnose's
+// -mirror-server, so that diagnose follows the server's own startup. Once
+// storage is set up, the setup is returned even with an error, so that the
+// caller can close the backend and finalize the seals.
+func (c *ServerCommand) setupCoreConfig(config *server.Config, metricsHelper *metricsutil.MetricsHelper,
+	metricSink *metricsutil.ClusterMetricSink, infoKeys []string, info map[string]string) (*coreSetup, error) {
+	// Initialize the storage backend
+	backend, err := c.setupStorage(config)
+	if err != nil {
+		return nil, err
+	}
+	setup := &coreSetup{backend: backend}
+
+	// Prevent server startup if migration is active
+	if c.storageMigrationActive(backend) {
+		return setup, errStorageMigrationActive
+	}
+
+	// Initialize the Service Discovery, if there is one
+	if config.ServiceRegistration != nil {
+		setup.configSR, err = beginServiceRegistration(c, config)
+		if err != nil {
+			return setup, err
+		}
+	}
+
+	barrierSeal, barrierWrapper, unwrapSeal, seals, sealConfigError, err := setSeal(c, config, infoKeys, info)
+	setup.seals = seals
+	setup.sealConfigError = sealConfigError
+	if err != nil {
+		return setup, err
+	}
+	if barrierSeal == nil {
+		return setup, errors.New("Could not create barrier seal! Most likely proper Seal configuration information was not set, but no error was generated.")
+	}
+
+	// prepare a secure random reader for core
+	secureRandomReader, err := configutil.CreateSecureRandomReaderFunc(config.SharedConfig, barrierWrapper)
+	if err != nil {
+		return setup, err
+	}
+
+	setup.coreConfig = createCoreConfig(c, config, backend, setup.configSR, barrierSeal, unwrapSeal, metricsHelper, metricSink, secureRandomReader)
+	return setup, nil
+}
+
+// completeCoreConfig runs the startup steps that complete the core's
+// configuration built by setupCoreConfig: it initializes the separate HA
+// storage backend, if there is one, determines the API and cluster addresses
+// and applies any enterprise configuration. Like setupCoreConfig, it is shared
+// with diagnose's -mirror-server. The server starts despite failing to
+// determine the API address, so that failure is returned as a warning, apart
+// from the error.
+func (c *ServerCommand) completeCoreConfig(config *server.Config, coreConfig *vault.CoreConfig) (disableClustering bool, warning error, err error) {
+	// Initialize the separate HA storage backend, if it exists
+	disableClustering, err = initHaBackend(c, config, coreConfig, coreConfig.Physical)
+	if err != nil {
+		return false, nil, err
+	}
+
+	// Determine the redirect address from environment variables
+	warning = determineRedirectAddr(c, coreConfig, config)
+
+	// After the redirect bits are sorted out, if no cluster address was
+	// explicitly given, derive one from the redirect addr
+	if err := findClusterAddress(c, coreConfig, config, disableClustering); err != nil {
+		return false, warning, err
+	}
+
+	// Override the UI enabling config by the environment variable
+	if enableUI := os.Getenv("VAULT_UI"); enableUI != "" {
+		coreConfig.EnableUI, err = strconv.ParseBool(enableUI)
+		if err != nil {
+			return false, warning, errors.New("Error parsing the environment variable VAULT_UI")
+		}
+	}
+
+	// If ServiceRegistration is configured, then the backend must support HA
+	isBackendHA := coreConfig.HAPhysical != nil && coreConfig.HAPhysical.HAEnabled()
+	if !c.flagDev && (coreConfig.GetServiceRegistration() != nil) && !isBackendHA {
+		return false, warning, errors.New("service_registration is configured, but storage does not support HA")
+	}
+
+	// Apply any enterprise configuration onto the coreConfig.
+	adjustCoreConfigForEnt(config, coreConfig)
+	return disableClustering, warning, nil
+}
+
 // storageMigrationActive checks and warns against in-progress storage migrations.
 // This function will block until storage is available.
 func (c *ServerCommand) storageMigrationActive(backend physical.Backend) bool {
@@ -2279,7 +2336,7 @@ func initHaBackend(c *ServerCommand, config *server.Config, coreConfig *vault.Co
 		}
 
 		if coreConfig.HAPhysical, ok = habackend.(physical.HABackend); !ok {
-			return false, fmt.Errorf("Specified HA storage does not support HA")
+			return false, errHAStorageUnsupported
 		}
 
 		if !coreConfig.HAPhysical.HAEnabled() {
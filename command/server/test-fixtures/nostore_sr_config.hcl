@@ -0,0 +1,15 @@
+disable_cache = true
+disable_mlock = true
+
+ui = true
+
+listener "tcp" {
+    address = "127.0.0.1:1024"
+    tls_disable = true
+}
+
+service_registration "consul" {
+    address = "127.0.0.1:8500"
+}
+
+// No storage or ha_storage stanza in config!
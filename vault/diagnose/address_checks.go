@@ -0,0 +1,240 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-sockaddr/template"
+)
+
+const wildcardAddrError = "address %q uses the unspecified host %q, which other nodes cannot route to"
+
+// WildcardAddrCheck returns an error if the host portion of addr is empty or an
+// unspecified address such as 0.0.0.0 or ::. The address may be either a URL or
+// a bare host:port pair.
+func WildcardAddrCheck(addr string) error {
+	host := addrHost(addr)
+	if host == "" {
+		return fmt.Errorf(wildcardAddrError, addr, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		return fmt.Errorf(wildcardAddrError, addr, host)
+	}
+	return nil
+}
+
+// addrHost extracts the host portion of a URL or host:port pair, without any
+// IPv6 brackets.
+func addrHost(addr string) string {
+	hostPort := addr
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err == nil {
+			hostPort = u.Host
+		}
+	}
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host = hostPort
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
+
+// IsLoopbackAddr reports whether addr, a URL or host:port pair, resolves to a
+// loopback address. Hostnames other than localhost are not resolved and are
+// treated as non-loopback.
+func IsLoopbackAddr(addr string) bool {
+	host := addrHost(addr)
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ClusterAddrSchemeCheck reports the scheme of the configured cluster
+// address, set by source. The cluster port always uses TLS, so any scheme but
+// https is an error: the server quietly replaces it, and the configuration
+// misstates how peers connect.
+func ClusterAddrSchemeCheck(ctx context.Context, addr, source string) {
+	testName := "cluster-addr-scheme"
+	u, err := url.Parse(addr)
+	switch {
+	case err != nil:
+		SpotError(ctx, testName, fmt.Errorf("%s %q is not a valid URL: %w", source, addr, err))
+	case u.Scheme == "https":
+		SpotOk(ctx, testName, fmt.Sprintf("%s %s uses https", source, addr))
+	case u.Scheme == "":
+		SpotError(ctx, testName, fmt.Errorf("%s %q has no scheme; the cluster port always uses TLS, so it must "+
+			"be https", source, addr))
+	default:
+		SpotError(ctx, testName, fmt.Errorf("%s %q uses the scheme %q, but the cluster port always uses TLS, so "+
+			"it must be https", source, addr, u.Scheme))
+	}
+}
+
+// RaftClusterAddrCheck confirms that a node using raft storage has a
+// cluster_addr, set by source, that its peers can route to. Unlike other
+// storage backends, raft doesn't derive the cluster address from api_addr:
+// the server refuses to start without one, and a wildcard address is
+// advertised to peers that can't connect to it.
+func RaftClusterAddrCheck(ctx context.Context, addr, source string) {
+	testName := "raft-cluster-addr"
+	if addr == "" {
+		SpotError(ctx, testName, errors.New("raft storage requires a cluster address, but neither cluster_addr "+
+			"nor VAULT_CLUSTER_ADDR is set"),
+			Advice("Set cluster_addr to an https address on the cluster port that the other nodes can reach."))
+		return
+	}
+	if err := WildcardAddrCheck(addr); err != nil {
+		SpotError(ctx, testName, fmt.Errorf("%s: %w", source, err))
+		return
+	}
+	SpotOk(ctx, testName, fmt.Sprintf("cluster_addr is %s (from %s)", addr, source))
+}
+
+// apiAddrEnvVars are the environment variables that override api_addr, in the
+// order the server consults them.
+var apiAddrEnvVars = []string{"VAULT_API_ADDR", "VAULT_REDIRECT_ADDR", "VAULT_ADVERTISE_ADDR"}
+
+// ResolveAPIAddr determines the api_addr the server will use, the way the
+// server does: from the environment, then the configuration, then by asking
+// the storage backend to detect it. detect may be nil if the backend cannot
+// detect an address. It returns the address along with where it came from.
+func ResolveAPIAddr(configured string, detect func() (string, error)) (string, string, error) {
+	for _, env := range apiAddrEnvVars {
+		if addr := os.Getenv(env); addr != "" {
+			return addr, env, nil
+		}
+	}
+	if configured != "" {
+		return configured, "api_addr", nil
+	}
+	if detect == nil {
+		return "", "", errors.New("api_addr is not set and the storage backend cannot detect it")
+	}
+	addr, err := detect()
+	if err != nil {
+		return "", "", fmt.Errorf("api_addr is not set and detecting it failed: %w", err)
+	}
+	if addr == "" {
+		return "", "", errors.New("api_addr is not set and could not be detected")
+	}
+	return addr, "detection", nil
+}
+
+// ServiceRegistrationAPIAddrCheck confirms that service registration will
+// advertise a usable api_addr.
+func ServiceRegistrationAPIAddrCheck(ctx context.Context, configured string, detect func() (string, error)) {
+	addr, source, err := ResolveAPIAddr(configured, detect)
+	if err != nil {
+		SpotError(ctx, "api-addr", fmt.Errorf("service registration is configured, but %w; "+
+			"set api_addr so that a reachable address is registered", err))
+		return
+	}
+	if err := WildcardAddrCheck(addr); err != nil {
+		SpotError(ctx, "api-addr", fmt.Errorf("service registration would register an unreachable address: %w", err))
+		return
+	}
+	SpotOk(ctx, "api-addr", fmt.Sprintf("%s (from %s)", addr, source))
+}
+
+// loopbackAvailable reports whether a listener can be bound on the loopback
+// address of the given network, "tcp4" or "tcp6".
+var loopbackAvailable = func(network string) bool {
+	addr := "127.0.0.1:0"
+	if network == "tcp6" {
+		addr = "[::1]:0"
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
+
+// isIPv4Loopback reports whether the host of addr is a literal IPv4 loopback
+// address such as 127.0.0.1.
+func isIPv4Loopback(addr string) bool {
+	ip := net.ParseIP(addrHost(addr))
+	return ip != nil && ip.To4() != nil && ip.IsLoopback()
+}
+
+// LoopbackCheck reports which loopback addresses the host supports, and warns
+// when the IPv4 loopback is missing, as on IPv6-only hosts, but addrs still
+// point at it. addrs maps the name of each configured setting to its address.
+func LoopbackCheck(ctx context.Context, addrs map[string]string) {
+	ipv4, ipv6 := loopbackAvailable("tcp4"), loopbackAvailable("tcp6")
+	switch {
+	case ipv4 && ipv6:
+		SpotInfo(ctx, "loopback", "IPv4 and IPv6 loopback are available")
+	case ipv4:
+		SpotInfo(ctx, "loopback", "only IPv4 loopback is available")
+	case ipv6:
+		SpotInfo(ctx, "loopback", "only IPv6 loopback is available")
+	default:
+		SpotWarn(ctx, "loopback", "no loopback address is available, so local clients cannot reach Vault")
+	}
+	if ipv4 {
+		return
+	}
+
+	settings := make([]string, 0, len(addrs))
+	for setting := range addrs {
+		settings = append(settings, setting)
+	}
+	sort.Strings(settings)
+	for _, setting := range settings {
+		addr := addrs[setting]
+		if !isIPv4Loopback(addr) {
+			continue
+		}
+		advice := "use a routable address"
+		if ipv6 {
+			advice = "use [::1] instead"
+		}
+		SpotWarn(ctx, "ipv4-loopback", fmt.Sprintf("%s is %s, but IPv4 loopback is not available on this host; %s",
+			setting, addr, advice))
+	}
+}
+
+// SockaddrTemplateCheck finds go-sockaddr templates, such as
+// {{ GetPrivateIP }}, in addrs and evaluates each against the host's
+// interfaces, reporting what it resolves to. This version of Vault does not
+// evaluate templates in these settings and would use the template text as the
+// address, so every template is an error, and the resolved value is what to
+// set instead. addrs maps the name of each configured setting to its address.
+func SockaddrTemplateCheck(ctx context.Context, addrs map[string]string) {
+	settings := make([]string, 0, len(addrs))
+	for setting, addr := range addrs {
+		if strings.Contains(addr, "{{") {
+			settings = append(settings, setting)
+		}
+	}
+	sort.Strings(settings)
+	for _, setting := range settings {
+		addr := addrs[setting]
+		resolved, err := template.Parse(addr)
+		resolved = strings.TrimSpace(resolved)
+		switch {
+		case err != nil:
+			SpotError(ctx, "sockaddr-template", fmt.Errorf("%s is the template %q, which cannot be evaluated: %w", setting, addr, err))
+		case resolved == "" || addrHost(resolved) == "":
+			SpotError(ctx, "sockaddr-template", fmt.Errorf("%s is the template %q, which resolves to no address on this host", setting, addr))
+		case strings.ContainsAny(resolved, " \t"):
+			SpotError(ctx, "sockaddr-template", fmt.Errorf("%s is the template %q, which resolves to more than one address on this host: %s",
+				setting, addr, resolved))
+		default:
+			SpotError(ctx, "sockaddr-template", fmt.Errorf("%s is the template %q, which resolves to %s on this host, but this "+
+				"version of Vault does not evaluate templates and would use the text as the address; set %s to the resolved address",
+				setting, addr, resolved, setting))
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWildcardAddrCheck(t *testing.T) {
+	testCases := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{"https://10.0.0.1:8201", false},
+		{"https://vault.example.com:8201", false},
+		{"https://[2001:db8::1]:8201", false},
+		{"10.0.0.1:8201", false},
+		{"https://0.0.0.0:8201", true},
+		{"https://[::]:8201", true},
+		{"0.0.0.0:8201", true},
+		{"https://:8201", true},
+	}
+
+	for _, tc := range testCases {
+		err := WildcardAddrCheck(tc.addr)
+		if tc.wantErr && err == nil {
+			t.Errorf("expected an error for %q", tc.addr)
+		}
+		if !tc.wantErr && err != nil {
+			t.Errorf("unexpected error for %q: %v", tc.addr, err)
+		}
+	}
+}
+
+func TestClusterAddrSchemeCheck(t *testing.T) {
+	testCases := []struct {
+		addr   string
+		status status
+	}{
+		{"https://10.0.0.1:8201", OkStatus},
+		{"http://10.0.0.1:8201", ErrorStatus},
+		{"tcp://10.0.0.1:8201", ErrorStatus},
+		{"/10.0.0.1:8201", ErrorStatus},
+	}
+	for _, tc := range testCases {
+		results := checkResults(t, func(ctx context.Context) {
+			ClusterAddrSchemeCheck(ctx, tc.addr, "cluster_addr")
+		})
+		if len(results) != 1 || results[0].Status != tc.status {
+			t.Errorf("%s: expected %s, got %#v", tc.addr, tc.status, results)
+		}
+	}
+}
+
+func TestRaftClusterAddrCheck(t *testing.T) {
+	testCases := []struct {
+		addr   string
+		status status
+	}{
+		{"https://10.0.0.1:8201", OkStatus},
+		{"", ErrorStatus},
+		{"https://0.0.0.0:8201", ErrorStatus},
+	}
+	for _, tc := range testCases {
+		results := checkResults(t, func(ctx context.Context) {
+			RaftClusterAddrCheck(ctx, tc.addr, "cluster_addr")
+		})
+		if len(results) != 1 || results[0].Status != tc.status {
+			t.Errorf("%q: expected %s, got %#v", tc.addr, tc.status, results)
+		}
+	}
+}
+
+func TestResolveAPIAddr(t *testing.T) {
+	for _, env := range apiAddrEnvVars {
+		if v, ok := os.LookupEnv(env); ok {
+			defer os.Setenv(env, v)
+			os.Unsetenv(env)
+		}
+	}
+	detect := func() (string, error) { return "https://10.0.0.2:8200", nil }
+
+	addr, source, err := ResolveAPIAddr("https://10.0.0.1:8200", detect)
+	if err != nil || addr != "https://10.0.0.1:8200" || source != "api_addr" {
+		t.Fatalf("unexpected result from config: %q, %q, %v", addr, source, err)
+	}
+	addr, source, err = ResolveAPIAddr("", detect)
+	if err != nil || addr != "https://10.0.0.2:8200" || source != "detection" {
+		t.Fatalf("unexpected result from detection: %q, %q, %v", addr, source, err)
+	}
+	if _, _, err := ResolveAPIAddr("", nil); err == nil {
+		t.Fatal("expected an error without a way to detect the address")
+	}
+	if _, _, err := ResolveAPIAddr("", func() (string, error) { return "", errors.New("no leader") }); err == nil {
+		t.Fatal("expected an error when detection fails")
+	}
+
+	os.Setenv("VAULT_API_ADDR", "https://10.0.0.3:8200")
+	defer os.Unsetenv("VAULT_API_ADDR")
+	addr, source, err = ResolveAPIAddr("https://10.0.0.1:8200", detect)
+	if err != nil || addr != "https://10.0.0.3:8200" || source != "VAULT_API_ADDR" {
+		t.Fatalf("unexpected result from the environment: %q, %q, %v", addr, source, err)
+	}
+}
+
+func TestLoopbackCheck(t *testing.T) {
+	defer func(f func(string) bool) { loopbackAvailable = f }(loopbackAvailable)
+	loopbackAvailable = func(network string) bool { return network == "tcp6" }
+
+	addrs := map[string]string{
+		"api_addr":           "https://vault.example.com:8200",
+		"cluster_addr":       "https://127.0.0.1:8201",
+		"listener 1 address": "127.0.0.1:8200",
+	}
+	results := checkResults(t, func(ctx context.Context) {
+		LoopbackCheck(ctx, addrs)
+	})
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %#v", results)
+	}
+	if results[0].Status != InfoStatus || results[0].Message != "only IPv6 loopback is available" {
+		t.Fatalf("unexpected loopback result: %#v", results[0])
+	}
+	for _, r := range results[1:] {
+		if r.Name != "ipv4-loopback" || r.Status != WarningStatus {
+			t.Fatalf("expected an IPv4 loopback warning, got %#v", r)
+		}
+	}
+
+	loopbackAvailable = func(string) bool { return true }
+	results = checkResults(t, func(ctx context.Context) {
+		LoopbackCheck(ctx, addrs)
+	})
+	if len(results) != 1 || results[0].Status != InfoStatus {
+		t.Fatalf("expected only an info result with IPv4 loopback, got %#v", results)
+	}
+}
+
+func TestSockaddrTemplateCheck(t *testing.T) {
+	addrs := map[string]string{
+		"api_addr":           "https://vault.example.com:8200",
+		"cluster_addr":       `https://{{ GetAllInterfaces | include "flags" "loopback" | include "type" "IPv4" | attr "address" }}:8201`,
+		"listener 1 address": `{{ GetAllInterfaces | include "name" "no-such-interface" | attr "address" }}:8200`,
+		"listener 2 address": "{{ GetBogus }}:8200",
+	}
+	results := checkResults(t, func(ctx context.Context) {
+		SockaddrTemplateCheck(ctx, addrs)
+	})
+	if len(results) != 3 {
+		t.Fatalf("expected a result for each template, got %#v", results)
+	}
+	for _, r := range results {
+		if r.Status != ErrorStatus {
+			t.Fatalf("expected an error, got %#v", r)
+		}
+	}
+	if !strings.Contains(results[0].Message, "resolves to https://127.0.0.1:8201") {
+		t.Fatalf("expected cluster_addr to resolve to the loopback address, got %q", results[0].Message)
+	}
+	if !strings.Contains(results[1].Message, "resolves to no address") {
+		t.Fatalf("expected listener 1 to resolve to no address, got %q", results[1].Message)
+	}
+	if !strings.Contains(results[2].Message, "cannot be evaluated") {
+		t.Fatalf("expected listener 2 to fail to evaluate, got %q", results[2].Message)
+	}
+}
@@ -0,0 +1,79 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// APIAddrHealthLiveCheck queries sys/health through the advertised api_addr
+// rather than the local listener, confirming that the address clients are
+// told to use reaches a working Vault end to end, through any load balancer
+// or NAT in between. source is where addr came from. The health of the server
+// client points at is used to tell whether api_addr reaches the same cluster.
+func APIAddrHealthLiveCheck(ctx context.Context, client *api.Client, addr, source string) error {
+	probe, err := client.Clone()
+	if err != nil {
+		return err
+	}
+	if err := probe.SetAddress(addr); err != nil {
+		return fmt.Errorf("api_addr %s (from %s) is not a valid address: %w", addr, source, err)
+	}
+	probe.SetMaxRetries(0)
+
+	// The local health is only used to compare cluster IDs, so a failure
+	// here skips the comparison rather than the check.
+	local, _ := client.Sys().Health()
+
+	start := time.Now()
+	remote, err := probe.Sys().Health()
+	latency := time.Since(start)
+	if err != nil {
+		SpotError(ctx, "api-addr-health", fmt.Errorf("api_addr %s (from %s) did not answer sys/health: %w", addr, source, err),
+			Advice("Check that the load balancer, DNS record or NAT rule behind api_addr routes to a Vault listener."))
+		return nil
+	}
+	checkAPIAddrHealth(ctx, addr, source, local, remote, latency)
+	return nil
+}
+
+func checkAPIAddrHealth(ctx context.Context, addr, source string, local, remote *api.HealthResponse, latency time.Duration) {
+	testName := "api-addr-health"
+	state := []string{"initialized"}
+	switch {
+	case !remote.Initialized:
+		state = []string{"not initialized"}
+	case remote.Sealed:
+		state = append(state, "sealed")
+	case remote.PerformanceStandby:
+		state = append(state, "unsealed", "performance standby")
+	case remote.Standby:
+		state = append(state, "unsealed", "standby")
+	default:
+		state = append(state, "unsealed", "active")
+	}
+	if remote.Version != "" {
+		state = append(state, "version "+remote.Version)
+	}
+	if remote.ClusterName != "" {
+		state = append(state, "cluster "+remote.ClusterName)
+	}
+	SpotInfo(ctx, testName, fmt.Sprintf("api_addr %s (from %s) answered sys/health in %s: %s",
+		addr, source, latency.Round(time.Millisecond), strings.Join(state, ", ")))
+
+	switch {
+	case local != nil && local.ClusterID != "" && remote.ClusterID != "" && local.ClusterID != remote.ClusterID:
+		SpotError(ctx, testName, fmt.Errorf("api_addr %s reaches cluster %s, but this server belongs to cluster %s",
+			addr, remote.ClusterID, local.ClusterID),
+			Advice("Point api_addr, or the load balancer behind it, at this cluster."))
+	case !remote.Initialized:
+		SpotWarn(ctx, testName, fmt.Sprintf("api_addr %s reaches a server that is not initialized, so clients using it will be refused", addr))
+	case remote.Sealed:
+		SpotWarn(ctx, testName, fmt.Sprintf("api_addr %s reaches a sealed server, so clients using it will be refused", addr))
+	default:
+		SpotOk(ctx, testName, fmt.Sprintf("api_addr %s serves requests", addr))
+	}
+}
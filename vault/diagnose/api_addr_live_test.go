@@ -0,0 +1,88 @@
+package diagnose
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestAPIAddrHealthLiveCheck(t *testing.T) {
+	health := func(clusterID string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/sys/health" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"initialized":true,"sealed":false,"standby":false,"version":"1.8.0","cluster_id":"` + clusterID + `"}`))
+		}))
+	}
+	local := health("a")
+	defer local.Close()
+	same := health("a")
+	defer same.Close()
+	other := health("b")
+	defer other.Close()
+
+	conf := api.DefaultConfig()
+	conf.Address = local.URL
+	client, err := api.NewClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name   string
+		addr   string
+		status status
+	}{
+		{"same cluster", same.URL, OkStatus},
+		{"other cluster", other.URL, ErrorStatus},
+		{"unreachable", "http://127.0.0.1:1", ErrorStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				if err := APIAddrHealthLiveCheck(ctx, client, tc.addr, "api_addr"); err != nil {
+					t.Fatal(err)
+				}
+			})
+			last := results[len(results)-1]
+			if last.Status != tc.status {
+				t.Fatalf("expected a %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
+
+func TestCheckAPIAddrHealth(t *testing.T) {
+	testCases := []struct {
+		name   string
+		remote *api.HealthResponse
+		status status
+		state  string
+	}{
+		{"active", &api.HealthResponse{Initialized: true, Version: "1.8.0"}, OkStatus, "initialized, unsealed, active, version 1.8.0"},
+		{"standby", &api.HealthResponse{Initialized: true, Standby: true}, OkStatus, "initialized, unsealed, standby"},
+		{"sealed", &api.HealthResponse{Initialized: true, Sealed: true}, WarningStatus, "initialized, sealed"},
+		{"uninitialized", &api.HealthResponse{Sealed: true}, WarningStatus, "not initialized"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkAPIAddrHealth(ctx, "https://vault.example.com", "api_addr", nil, tc.remote, 12*time.Millisecond)
+			})
+			if len(results) != 2 || results[1].Status != tc.status {
+				t.Fatalf("expected a %s result, got %#v", tc.status, results)
+			}
+			if !strings.Contains(results[0].Message, "in 12ms: "+tc.state) {
+				t.Fatalf("expected the health response in %q", results[0].Message)
+			}
+		})
+	}
+}
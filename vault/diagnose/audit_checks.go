@@ -0,0 +1,180 @@
+package diagnose
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/internalshared/configutil"
+	"github.com/hashicorp/vault/sdk/helper/parseutil"
+)
+
+// auditConfigKeys are the configuration stanzas that other tools, and later
+// Vault versions, use to declare audit devices. This version parses neither.
+var auditConfigKeys = []string{"audit", "audit_device"}
+
+// AuditConfigCheck reports whether the configuration declares audit devices.
+// In this version of Vault, audit devices are only enabled through the API
+// and persisted in the encrypted barrier, so there is no file configuration
+// to compare with the stored devices, and the stored devices can't be read
+// without unsealing. Audit stanzas are ignored, which this check warns about.
+func AuditConfigCheck(ctx context.Context, unused configutil.UnusedKeyMap) {
+	var found []string
+	for _, key := range auditConfigKeys {
+		if _, ok := unused[key]; ok {
+			found = append(found, key)
+		}
+	}
+	if len(found) == 0 {
+		SpotSkipped(ctx, "audit-config", SkipNotApplicable, "audit devices are managed with \"vault audit enable\" and stored "+
+			"in the encrypted barrier, so there is nothing to compare against the configuration")
+		return
+	}
+	sort.Strings(found)
+	SpotWarn(ctx, "audit-config", fmt.Sprintf("%s set, but this version of Vault does not configure audit devices "+
+		"from the configuration file, so the stanzas are ignored; enable audit devices with \"vault audit enable\"",
+		strings.Join(found, ", ")))
+}
+
+const (
+	// auditBurstEntries and auditBurstEntrySize size the burst written to
+	// socket audit devices, about a megabyte, enough to fill the socket
+	// buffers of a consumer that isn't reading.
+	auditBurstEntries   = 1024
+	auditBurstEntrySize = 1024
+
+	// auditDefaultWriteTimeout mirrors the socket audit device's default
+	// write_timeout.
+	auditDefaultWriteTimeout = 2 * time.Second
+)
+
+// AuditSinkLiveCheck lists the audit devices of the running server and, as
+// audit is synchronous and a blocked device blocks every request, checks that
+// their sinks can't block: it writes a burst of test entries to each socket
+// device and measures how long the writes are held up by the consumer, and
+// warns about file devices writing to a named pipe. The sinks are reached
+// from this host, so run it on the server's host.
+func AuditSinkLiveCheck(ctx context.Context, client *api.Client) error {
+	audits, err := client.Sys().ListAudit()
+	if err != nil {
+		return fmt.Errorf("could not list the audit devices: %w", err)
+	}
+	paths := make([]string, 0, len(audits))
+	for path := range audits {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	checked := false
+	for _, path := range paths {
+		a := audits[path]
+		switch a.Type {
+		case "socket":
+			checked = true
+			network := a.Options["socket_type"]
+			if network == "" {
+				network = "tcp"
+			}
+			timeout := auditDefaultWriteTimeout
+			if raw := a.Options["write_timeout"]; raw != "" {
+				if timeout, err = parseutil.ParseDurationSecond(raw); err != nil {
+					SpotError(ctx, "audit-sink", fmt.Errorf("audit device %s has an invalid write_timeout %q: %w", path, raw, err))
+					continue
+				}
+			}
+			auditSocketCheck(ctx, path, network, a.Options["address"], timeout, auditBurstEntries)
+		case "file":
+			checked = true
+			auditFileCheck(ctx, path, a.Options["file_path"])
+		}
+	}
+	if !checked {
+		Skipped(ctx, SkipNotApplicable, "no socket or file audit devices are enabled")
+	}
+	return nil
+}
+
+// auditSocketCheck writes entries test entries to a socket audit device's
+// consumer, each with the device's write timeout, and reports the slowest
+// write. Writes only wait when the socket buffers are full, so a slow write
+// means the consumer doesn't drain promptly.
+func auditSocketCheck(ctx context.Context, path, network, address string, timeout time.Duration, entries int) {
+	testName := "audit-sink"
+	if network == "udp" {
+		SpotInfo(ctx, testName, fmt.Sprintf("audit device %s sends to %s over udp, which never blocks, but drops "+
+			"entries the consumer can't keep up with", path, address))
+		return
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		SpotError(ctx, testName, fmt.Errorf("audit device %s could not connect to %s: %w", path, address, err))
+		return
+	}
+	defer conn.Close()
+
+	entry := []byte(`{"type":"diagnose","message":"vault operator diagnose audit backpressure test","padding":"`)
+	entry = append(entry, bytes.Repeat([]byte("x"), auditBurstEntrySize-len(entry)-3)...)
+	entry = append(entry, "\"}\n"...)
+
+	var slowest time.Duration
+	start := time.Now()
+	for i := 0; i < entries; i++ {
+		writeStart := time.Now()
+		conn.SetWriteDeadline(writeStart.Add(timeout))
+		if _, err := conn.Write(entry); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				SpotWarn(ctx, testName, fmt.Sprintf("audit device %s blocked for longer than its write_timeout of %s "+
+					"after %d test entries, so the consumer at %s doesn't drain promptly and requests will fail "+
+					"or stall while it falls behind", path, timeout, i, address),
+					Advice("Make sure the consumer reads continuously, or enable a second audit device so that "+
+						"requests succeed while one is blocked."))
+			} else {
+				SpotError(ctx, testName, fmt.Errorf("audit device %s failed writing to %s: %w", path, address, err))
+			}
+			return
+		}
+		if d := time.Since(writeStart); d > slowest {
+			slowest = d
+		}
+	}
+	elapsed := time.Since(start)
+
+	message := fmt.Sprintf("audit device %s wrote %d test entries to %s in %s; the slowest write took %s",
+		path, entries, address, elapsed.Round(time.Millisecond), slowest.Round(time.Microsecond))
+	if slowest > timeout/2 {
+		SpotWarn(ctx, testName, message+fmt.Sprintf(", more than half the write_timeout of %s, so the consumer "+
+			"is prone to blocking requests under load", timeout))
+		return
+	}
+	SpotOk(ctx, testName, message)
+}
+
+// auditFileCheck warns when a file audit device writes to a named pipe, which
+// blocks every request whenever its reader falls behind or goes away.
+func auditFileCheck(ctx context.Context, path, filePath string) {
+	testName := "audit-sink"
+	if filePath == "" || strings.EqualFold(filePath, "stdout") || strings.EqualFold(filePath, "discard") {
+		return
+	}
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		SpotSkipped(ctx, testName, SkipDependencyFailed, fmt.Sprintf("could not stat the file of audit device %s: %v", path, err))
+		return
+	}
+	if fi.Mode()&os.ModeNamedPipe != 0 {
+		SpotWarn(ctx, testName, fmt.Sprintf("audit device %s writes to the named pipe %s, which blocks every request "+
+			"whenever its reader falls behind or stops", path, filePath),
+			Advice("Write to a regular file, or use a socket device with a write_timeout."))
+		return
+	}
+	SpotOk(ctx, testName, fmt.Sprintf("audit device %s writes to %s", path, filePath))
+}
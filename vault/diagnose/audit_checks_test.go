@@ -0,0 +1,108 @@
+package diagnose
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+func TestAuditConfigCheck(t *testing.T) {
+	results := checkResults(t, func(ctx context.Context) {
+		AuditConfigCheck(ctx, configutil.UnusedKeyMap{})
+	})
+	if len(results) != 1 || results[0].Status != SkippedStatus {
+		t.Fatalf("expected a skipped result without audit stanzas, got %#v", results)
+	}
+
+	results = checkResults(t, func(ctx context.Context) {
+		AuditConfigCheck(ctx, configutil.UnusedKeyMap{"audit": []token.Pos{{}}})
+	})
+	if len(results) != 1 || results[0].Status != WarningStatus {
+		t.Fatalf("expected a warning with an audit stanza, got %#v", results)
+	}
+}
+
+func TestAuditSocketCheck(t *testing.T) {
+	draining, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer draining.Close()
+	go func() {
+		for {
+			conn, err := draining.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+
+	// A consumer that accepts the connection but never reads from it.
+	stalled, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stalled.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		conn, err := stalled.Accept()
+		if err != nil {
+			return
+		}
+		<-done
+		conn.Close()
+	}()
+
+	results := checkResults(t, func(ctx context.Context) {
+		auditSocketCheck(ctx, "socket/", "tcp", draining.Addr().String(), 2*time.Second, auditBurstEntries)
+		auditSocketCheck(ctx, "stalled/", "tcp", stalled.Addr().String(), 100*time.Millisecond, 64*auditBurstEntries)
+		auditSocketCheck(ctx, "udp/", "udp", "127.0.0.1:9", time.Second, auditBurstEntries)
+	})
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if results[0].Status != OkStatus {
+		t.Fatalf("expected a draining consumer to be ok, got %s: %s", results[0].Status, results[0].Message)
+	}
+	if results[1].Status != WarningStatus || !strings.Contains(results[1].Message, "blocked") {
+		t.Fatalf("expected a stalled consumer to warn, got %s: %s", results[1].Status, results[1].Message)
+	}
+	if results[2].Status != InfoStatus {
+		t.Fatalf("expected info for udp, got %s", results[2].Status)
+	}
+}
+
+func TestAuditFileCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "audit.log")
+	if err := ioutil.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	results := checkResults(t, func(ctx context.Context) {
+		auditFileCheck(ctx, "file/", file)
+		auditFileCheck(ctx, "stdout/", "stdout")
+		auditFileCheck(ctx, "missing/", filepath.Join(dir, "missing.log"))
+	})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Status != OkStatus || results[1].Status != SkippedStatus {
+		t.Fatalf("unexpected results: %s, %s", results[0].Status, results[1].Status)
+	}
+}
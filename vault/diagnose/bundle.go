@@ -0,0 +1,210 @@
+package diagnose
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+const redactedValue = "redacted"
+
+// redactedKeySubstrings lists the config key fragments whose values are
+// scrubbed before a config is written to a support bundle. Addresses are
+// included because internal topology is considered sensitive.
+var redactedKeySubstrings = []string{
+	"addr",
+	"token",
+	"password",
+	"secret",
+	"credential",
+	"access_key",
+	"client_id",
+}
+
+// redactedAddrPatterns match the addresses scrubbed from result messages before
+// results are written to a support bundle: URLs and IP addresses. They are
+// applied in order, so that a URL is scrubbed whole before its host could be
+// matched on its own. Host:port pairs are scrubbed last, by hostPortPattern.
+var redactedAddrPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s,;"'()<>]+`),
+	regexp.MustCompile(`\[[0-9a-fA-F:.%]+\](?::\d+)?`),
+	regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`),
+	regexp.MustCompile(`\b(?:[0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}\b|(?:\b[0-9a-fA-F]{1,4})?::(?:[0-9a-fA-F]{1,4}\b(?::[0-9a-fA-F]{1,4}\b)*)?`),
+}
+
+// hostPortPattern matches a host and port, along with the start of a further
+// colon-separated field, so that a time of day such as 15:04:05 can be told
+// apart from one.
+var hostPortPattern = regexp.MustCompile(`\b[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9-]+)*:\d{1,5}\b(?::\d)?`)
+
+// Bundle holds the artifacts written to a diagnose support bundle.
+type Bundle struct {
+	Results *Result
+	Config  map[string]interface{}
+	Host    map[string]interface{}
+}
+
+// HostInfo returns anonymized information about the OS and runtime diagnose is
+// running under. It deliberately omits the hostname and any addresses.
+func HostInfo(vaultVersion string) map[string]interface{} {
+	return map[string]interface{}{
+		"vault_version": vaultVersion,
+		"go_version":    runtime.Version(),
+		"os":            runtime.GOOS,
+		"arch":          runtime.GOARCH,
+		"num_cpu":       runtime.NumCPU(),
+	}
+}
+
+// RedactConfig returns a copy of a sanitized config map with the values of
+// secret and address-like keys replaced, recursing into nested maps and lists.
+func RedactConfig(config map[string]interface{}) map[string]interface{} {
+	if config == nil {
+		return nil
+	}
+	redacted := make(map[string]interface{}, len(config))
+	for k, v := range config {
+		if isRedactedKey(k) {
+			redacted[k] = redactedValue
+			continue
+		}
+		redacted[k] = redactValue(v)
+	}
+	return redacted
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return RedactConfig(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, e := range val {
+			out[i] = redactValue(e)
+		}
+		return out
+	case []map[string]interface{}:
+		out := make([]interface{}, len(val))
+		for i, e := range val {
+			out[i] = RedactConfig(e)
+		}
+		return out
+	}
+	return v
+}
+
+// RedactResults returns a copy of the results with the addresses in their
+// messages, warnings, advice and metric names replaced. Checks report the
+// addresses they resolved, connected to or found in the configuration, so the
+// results would otherwise expose the topology RedactConfig scrubs from the
+// configuration.
+func RedactResults(r *Result) *Result {
+	if r == nil {
+		return nil
+	}
+	redacted := *r
+	redacted.Message = redactAddrs(r.Message)
+	redacted.Advice = redactAddrs(r.Advice)
+	if r.Warnings != nil {
+		redacted.Warnings = make([]string, len(r.Warnings))
+		for i, w := range r.Warnings {
+			redacted.Warnings[i] = redactAddrs(w)
+		}
+	}
+	if r.Metrics != nil {
+		redacted.Metrics = make([]Metric, len(r.Metrics))
+		for i, m := range r.Metrics {
+			m.Name = redactAddrs(m.Name)
+			redacted.Metrics[i] = m
+		}
+	}
+	if r.Children != nil {
+		redacted.Children = make([]*Result, len(r.Children))
+		for i, c := range r.Children {
+			redacted.Children[i] = RedactResults(c)
+		}
+	}
+	return &redacted
+}
+
+func redactAddrs(msg string) string {
+	for _, p := range redactedAddrPatterns {
+		msg = p.ReplaceAllString(msg, redactedValue)
+	}
+	return hostPortPattern.ReplaceAllStringFunc(msg, func(m string) string {
+		if strings.Count(m, ":") > 1 {
+			return m
+		}
+		return redactedValue
+	})
+}
+
+func isRedactedKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range redactedKeySubstrings {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write stores the bundle as a gzipped tar archive at path, with one JSON
+// document per artifact. The metrics the checks recorded are also written on
+// their own, so they can be compared without walking the results.
+func (b *Bundle) Write(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	files := map[string]interface{}{
+		"results.json": b.Results,
+		"metrics.json": b.Results.AllMetrics(),
+		"config.json":  b.Config,
+		"host.json":    b.Host,
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	for _, name := range names {
+		data, err := json.MarshalIndent(files[name], "", "  ")
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0o600,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
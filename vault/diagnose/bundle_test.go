@@ -0,0 +1,161 @@
+package diagnose
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRedactConfig(t *testing.T) {
+	config := map[string]interface{}{
+		"api_addr":     "https://10.0.0.1:8200",
+		"cluster_name": "testcluster",
+		"listeners": []interface{}{
+			map[string]interface{}{
+				"type": "tcp",
+				"config": map[string]interface{}{
+					"address":     "10.0.0.1:8200",
+					"tls_disable": true,
+				},
+			},
+		},
+		"storage": map[string]interface{}{
+			"type":         "consul",
+			"cluster_addr": "https://10.0.0.1:8201",
+		},
+	}
+	expected := map[string]interface{}{
+		"api_addr":     redactedValue,
+		"cluster_name": "testcluster",
+		"listeners": []interface{}{
+			map[string]interface{}{
+				"type": "tcp",
+				"config": map[string]interface{}{
+					"address":     redactedValue,
+					"tls_disable": true,
+				},
+			},
+		},
+		"storage": map[string]interface{}{
+			"type":         "consul",
+			"cluster_addr": redactedValue,
+		},
+	}
+
+	redacted := RedactConfig(config)
+	if !reflect.DeepEqual(redacted, expected) {
+		t.Fatalf("redaction mismatch: got %#v", redacted)
+	}
+	if config["api_addr"] == redactedValue {
+		t.Fatalf("input config was modified")
+	}
+}
+
+func TestRedactResults(t *testing.T) {
+	r := &Result{
+		Name:     "initialization",
+		Status:   WarningStatus,
+		Warnings: []string{"dial tcp 10.0.0.3:8201: connect: connection refused"},
+		Children: []*Result{
+			{
+				Name:    "api-addr",
+				Status:  OkStatus,
+				Message: "https://vault.internal.example.com:8200 (from api_addr)",
+			},
+			{
+				Name:    "raft-latency",
+				Status:  OkStatus,
+				Message: "the round trip to vault-2.internal:8201 takes 2ms",
+				Metrics: []Metric{{Name: "round-trip to vault-2.internal:8201", Value: 2, Unit: "ms"}},
+			},
+			{
+				Name:    "loopback",
+				Status:  InfoStatus,
+				Message: "listener 1 binds [::1]:8200 and fe80::1ff:fe23:4567:890a",
+				Advice:  "Bind 0.0.0.0:8200 instead; 3 of 5 nodes, took 1.5s.",
+			},
+			{
+				Name:    "client-ca",
+				Status:  WarningStatus,
+				Message: "ca.internal:8200: client CA expires on 2026-10-17T15:04:05Z",
+			},
+		},
+	}
+	redacted := RedactResults(r)
+
+	expected := []string{
+		"dial tcp redacted: connect: connection refused",
+		"redacted (from api_addr)",
+		"the round trip to redacted takes 2ms",
+		"round-trip to redacted",
+		"listener 1 binds redacted and redacted",
+		"Bind redacted instead; 3 of 5 nodes, took 1.5s.",
+		"redacted: client CA expires on 2026-10-17T15:04:05Z",
+	}
+	actual := []string{
+		redacted.Warnings[0],
+		redacted.Children[0].Message,
+		redacted.Children[1].Message,
+		redacted.Children[1].Metrics[0].Name,
+		redacted.Children[2].Message,
+		redacted.Children[2].Advice,
+		redacted.Children[3].Message,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("redaction mismatch:\n%q\nexpected\n%q", actual, expected)
+	}
+	if r.Children[0].Message == redacted.Children[0].Message || r.Children[1].Metrics[0].Name == "round-trip to redacted" {
+		t.Fatalf("input results were modified")
+	}
+}
+
+func TestBundleWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "bundle.tar.gz")
+	b := &Bundle{
+		Results: &Result{Name: "initialization", Status: OkStatus},
+		Config:  map[string]interface{}{"cluster_name": "testcluster"},
+		Host:    HostInfo("1.0.0"),
+	}
+	if err := b.Write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	expected := []string{"config.json", "host.json", "metrics.json", "results.json"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("unexpected bundle contents: %v", names)
+	}
+}
@@ -0,0 +1,33 @@
+package diagnose
+
+import "sort"
+
+// ResultsSchemaVersion is the version of the JSON results format. It is
+// incremented whenever a change to the format could break a consumer.
+const ResultsSchemaVersion = 1
+
+// OutputFormats are the values accepted by -format.
+var OutputFormats = []string{"table", "json", "html"}
+
+// Capabilities describes what this version of diagnose supports, so that
+// automation can adapt to it before invoking it.
+type Capabilities struct {
+	SchemaVersion int      `json:"schema_version"`
+	Formats       []string `json:"formats"`
+	Checks        []string `json:"checks"`
+	Flags         []string `json:"flags"`
+}
+
+// NewCapabilities returns the capabilities for the given check names and
+// flag names, which are sorted.
+func NewCapabilities(checks, flags []string) *Capabilities {
+	c := &Capabilities{
+		SchemaVersion: ResultsSchemaVersion,
+		Formats:       OutputFormats,
+		Checks:        append([]string(nil), checks...),
+		Flags:         append([]string(nil), flags...),
+	}
+	sort.Strings(c.Checks)
+	sort.Strings(c.Flags)
+	return c
+}
@@ -0,0 +1,33 @@
+package diagnose
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNewCapabilities(t *testing.T) {
+	checks := []string{"storage", "init-listeners"}
+	c := NewCapabilities(checks, []string{"-config", "-bundle"})
+	if checks[0] != "storage" {
+		t.Fatalf("input checks were modified")
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"schema_version": float64(ResultsSchemaVersion),
+		"formats":        []interface{}{"table", "json", "html"},
+		"checks":         []interface{}{"init-listeners", "storage"},
+		"flags":          []interface{}{"-bundle", "-config"},
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Fatalf("unexpected capabilities: %#v", decoded)
+	}
+}
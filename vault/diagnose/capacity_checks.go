@@ -0,0 +1,115 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+
+	"github.com/hashicorp/vault/sdk/physical"
+)
+
+const (
+	// baseFileDescriptors covers the files, sockets and pipes a server holds
+	// independent of its configuration.
+	baseFileDescriptors = 256
+
+	// connectionsPerListener is the number of concurrent client connections a
+	// busy listener is assumed to hold.
+	connectionsPerListener = 1024
+
+	// baseMemoryBytes is the resident memory of an idle server.
+	baseMemoryBytes = 256 << 20
+
+	// cacheEntryBytes is the assumed average size of a physical cache entry.
+	cacheEntryBytes = 1024
+
+	// minRaftVoterCPUs is the fewest CPUs on which a raft voter can keep up
+	// with heartbeats while also serving requests.
+	minRaftVoterCPUs = 2
+)
+
+// CapacityEstimate is the estimated peak resource usage of a configuration.
+type CapacityEstimate struct {
+	FileDescriptors uint64
+	MemoryBytes     uint64
+}
+
+// EstimateCapacity estimates the file descriptors and memory a configuration
+// will need at peak, from its listener count, physical cache size and storage
+// max_parallel. A cacheSize of zero means the default cache size, and a
+// negative one means the cache is disabled.
+func EstimateCapacity(listeners, cacheSize int, storageConfig map[string]string) CapacityEstimate {
+	maxParallel := physical.DefaultParallelOperations
+	if raw := storageConfig["max_parallel"]; raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			maxParallel = n
+		}
+	}
+	switch {
+	case cacheSize == 0:
+		cacheSize = physical.DefaultCacheSize
+	case cacheSize < 0:
+		cacheSize = 0
+	}
+
+	return CapacityEstimate{
+		FileDescriptors: uint64(baseFileDescriptors + listeners*connectionsPerListener + maxParallel),
+		MemoryBytes:     uint64(baseMemoryBytes + cacheSize*cacheEntryBytes),
+	}
+}
+
+// CapacityCheck compares a capacity estimate against the process limits,
+// producing a single verdict. A limit of zero means the limit is unknown.
+func CapacityCheck(ctx context.Context, est CapacityEstimate, fdLimit, memoryLimit uint64) {
+	fds, memory := "unknown", "unknown"
+	if fdLimit != 0 {
+		fds = strconv.FormatUint(fdLimit, 10)
+	}
+	if memoryLimit != 0 {
+		memory = formatBytes(memoryLimit)
+	}
+	summary := fmt.Sprintf("configuration needs ~%d file descriptors and ~%s of memory; limits are %s file descriptors and %s of memory",
+		est.FileDescriptors, formatBytes(est.MemoryBytes), fds, memory)
+
+	if (fdLimit != 0 && est.FileDescriptors > fdLimit) || (memoryLimit != 0 && est.MemoryBytes > memoryLimit) {
+		SpotWarn(ctx, "capacity", summary)
+		return
+	}
+	SpotOk(ctx, "capacity", summary)
+}
+
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// UsableCPUs returns the number of CPUs the process may use: the CPUs it is
+// allowed to run on, further limited by any cgroup CPU quota.
+func UsableCPUs() float64 {
+	cpus := float64(runtime.NumCPU())
+	if quota := cgroupCPULimit(); quota > 0 && quota < cpus {
+		cpus = quota
+	}
+	return cpus
+}
+
+// CPUCheck reports the usable CPU count, warning when a raft voter has fewer
+// than it needs to keep up with heartbeats under load.
+func CPUCheck(ctx context.Context, cpus float64, raftVoter bool) {
+	count := strconv.FormatFloat(cpus, 'f', -1, 64)
+	if raftVoter && cpus < minRaftVoterCPUs {
+		SpotWarn(ctx, "cpu", fmt.Sprintf("%s usable CPUs, fewer than the %d a raft voter needs to keep up with "+
+			"heartbeats under load; leadership may be lost when busy", count, minRaftVoterCPUs))
+		return
+	}
+	SpotOk(ctx, "cpu", fmt.Sprintf("%s usable CPUs", count))
+}
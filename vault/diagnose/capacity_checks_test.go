@@ -0,0 +1,110 @@
+package diagnose
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/hashicorp/vault/sdk/physical"
+)
+
+func TestEstimateCapacity(t *testing.T) {
+	est := EstimateCapacity(2, 0, nil)
+	expectedFDs := uint64(baseFileDescriptors + 2*connectionsPerListener + physical.DefaultParallelOperations)
+	if est.FileDescriptors != expectedFDs {
+		t.Fatalf("expected %d file descriptors, got %d", expectedFDs, est.FileDescriptors)
+	}
+	expectedMemory := uint64(baseMemoryBytes + physical.DefaultCacheSize*cacheEntryBytes)
+	if est.MemoryBytes != expectedMemory {
+		t.Fatalf("expected %d bytes of memory, got %d", expectedMemory, est.MemoryBytes)
+	}
+
+	est = EstimateCapacity(1, -1, map[string]string{"max_parallel": "512"})
+	if est.FileDescriptors != baseFileDescriptors+connectionsPerListener+512 {
+		t.Fatalf("max_parallel not honored: %d", est.FileDescriptors)
+	}
+	if est.MemoryBytes != baseMemoryBytes {
+		t.Fatalf("disabled cache not honored: %d", est.MemoryBytes)
+	}
+}
+
+func TestCapacityCheck(t *testing.T) {
+	est := CapacityEstimate{FileDescriptors: 2048, MemoryBytes: 1 << 30}
+	testCases := []struct {
+		name        string
+		fdLimit     uint64
+		memoryLimit uint64
+		expected    *Result
+	}{
+		{
+			"sufficient",
+			65536, 8 << 30,
+			&Result{Name: "capacity", Status: OkStatus, Message: "configuration needs ~2048 file descriptors and ~1.0GiB of memory; limits are 65536 file descriptors and 8.0GiB of memory"},
+		},
+		{
+			"low fd limit",
+			1024, 8 << 30,
+			&Result{Name: "capacity", Status: WarningStatus, Message: "configuration needs ~2048 file descriptors and ~1.0GiB of memory; limits are 1024 file descriptors and 8.0GiB of memory"},
+		},
+		{
+			"low memory",
+			65536, 512 << 20,
+			&Result{Name: "capacity", Status: WarningStatus, Message: "configuration needs ~2048 file descriptors and ~1.0GiB of memory; limits are 65536 file descriptors and 512.0MiB of memory"},
+		},
+		{
+			"unknown limits",
+			0, 0,
+			&Result{Name: "capacity", Status: OkStatus, Message: "configuration needs ~2048 file descriptors and ~1.0GiB of memory; limits are unknown file descriptors and unknown of memory"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				CapacityCheck(ctx, est, tc.fdLimit, tc.memoryLimit)
+			})
+			expected := []*Result{tc.expected}
+			if !reflect.DeepEqual(results, expected) {
+				t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results, expected), "\n"))
+			}
+		})
+	}
+}
+
+func TestCPUCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cpus      float64
+		raftVoter bool
+		expected  []*Result
+	}{
+		{
+			"raft voter",
+			4, true,
+			[]*Result{{Name: "cpu", Status: OkStatus, Message: "4 usable CPUs"}},
+		},
+		{
+			"quota-limited raft voter",
+			1.5, true,
+			[]*Result{{Name: "cpu", Status: WarningStatus, Message: "1.5 usable CPUs, fewer than the 2 a raft voter " +
+				"needs to keep up with heartbeats under load; leadership may be lost when busy"}},
+		},
+		{
+			"single CPU without raft",
+			1, false,
+			[]*Result{{Name: "cpu", Status: OkStatus, Message: "1 usable CPUs"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				CPUCheck(ctx, tc.cpus, tc.raftVoter)
+			})
+			if !reflect.DeepEqual(results, tc.expected) {
+				t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results, tc.expected), "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package diagnose
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+	"github.com/hashicorp/vault/sdk/helper/tlsutil"
+)
+
+// defaultClusterCipherSuites are the suites the cluster transport allows when
+// cluster_cipher_suites is not set, mirroring the core's defaults.
+var defaultClusterCipherSuites = []uint16{
+	tls.TLS_AES_128_GCM_SHA256,
+	tls.TLS_AES_256_GCM_SHA384,
+	tls.TLS_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+}
+
+// ClusteringConfig describes the clustering-related settings of an HA
+// storage configuration, along with the state the server derives from them.
+type ClusteringConfig struct {
+	// Disabled is the effective disable_clustering value the server acts on.
+	Disabled bool
+
+	// StanzaType and StanzaDisabled are the type and disable_clustering
+	// value of the storage stanza providing HA.
+	StanzaType     string
+	StanzaDisabled bool
+
+	// ClusterAddr is the configured cluster_addr, from either the storage
+	// stanza or the top level.
+	ClusterAddr string
+
+	// ClusterListeners are the addresses of listeners that set
+	// cluster_address.
+	ClusterListeners []string
+
+	// EffectiveClusterAddr is the cluster address after synthesis from the
+	// redirect address and environment.
+	EffectiveClusterAddr string
+}
+
+// ClusteringCoherenceCheck reports the effective clustering state and warns
+// on combinations that disable clustering while configuring clustering
+// addresses, or that disagree about whether clustering is disabled.
+func ClusteringCoherenceCheck(ctx context.Context, cc ClusteringConfig) {
+	if cc.Disabled {
+		SpotInfo(ctx, "clustering-state", "clustering is disabled")
+	} else if cc.EffectiveClusterAddr != "" {
+		SpotInfo(ctx, "clustering-state", fmt.Sprintf("clustering is enabled with cluster address %s", cc.EffectiveClusterAddr))
+	} else {
+		SpotWarn(ctx, "clustering-state", "clustering is enabled but no cluster address could be determined; "+
+			"set cluster_addr or api_addr so that standbys can forward requests")
+	}
+
+	if cc.StanzaDisabled != cc.Disabled {
+		SpotWarn(ctx, "disable-clustering", fmt.Sprintf("the %s storage stanza has disable_clustering=%t, but the "+
+			"server uses the top-level value of %t; set disable_clustering at the top level of the configuration",
+			cc.StanzaType, cc.StanzaDisabled, cc.Disabled))
+	}
+
+	if !cc.Disabled {
+		return
+	}
+	if cc.ClusterAddr != "" {
+		SpotWarn(ctx, "disable-clustering", fmt.Sprintf("clustering is disabled, so cluster_addr %q is ignored", cc.ClusterAddr))
+	}
+	if len(cc.ClusterListeners) > 0 {
+		SpotWarn(ctx, "disable-clustering", fmt.Sprintf("clustering is disabled, but listeners %s set cluster_address",
+			strings.Join(cc.ClusterListeners, ", ")))
+	}
+}
+
+// ClusterAddressOverrideCheck compares the cluster_address of each listener
+// that overrides it against the effective cluster_addr. Peers connect to the
+// advertised cluster_addr, so a listener bound to a different port, or to a
+// different specific host, leaves forwarding pointed at nothing.
+func ClusterAddressOverrideCheck(ctx context.Context, effective string, listeners []*configutil.Listener) {
+	var overrides []*configutil.Listener
+	for _, l := range listeners {
+		if l.ClusterAddress != "" {
+			overrides = append(overrides, l)
+		}
+	}
+	if len(overrides) == 0 {
+		SpotSkipped(ctx, "cluster-address", SkipStanzaAbsent, "no listener overrides cluster_address")
+		return
+	}
+
+	u, err := url.Parse(effective)
+	if err != nil || u.Host == "" {
+		SpotError(ctx, "cluster-address", fmt.Errorf("could not parse cluster_addr %q", effective))
+		return
+	}
+	advHost, advPort := u.Hostname(), u.Port()
+
+	for _, l := range overrides {
+		host, port, err := net.SplitHostPort(l.ClusterAddress)
+		if err != nil {
+			SpotError(ctx, "cluster-address", fmt.Errorf("listener %s: could not parse cluster_address %q: %w",
+				l.Address, l.ClusterAddress, err))
+			continue
+		}
+		ip, advIP := net.ParseIP(host), net.ParseIP(advHost)
+		switch {
+		case advPort != "" && port != advPort:
+			SpotError(ctx, "cluster-address", fmt.Errorf("listener %s binds cluster traffic to port %s, "+
+				"but cluster_addr %s advertises port %s", l.Address, port, effective, advPort))
+		case ip != nil && !ip.IsUnspecified() && advIP != nil && !ip.Equal(advIP):
+			SpotError(ctx, "cluster-address", fmt.Errorf("listener %s binds cluster traffic to %s, "+
+				"but cluster_addr %s advertises %s", l.Address, host, effective, advHost))
+		default:
+			SpotOk(ctx, "cluster-address", fmt.Sprintf("listener %s: cluster_address %s serves cluster_addr %s",
+				l.Address, l.ClusterAddress, effective))
+		}
+	}
+}
+
+// ClusterCipherSuitesCheck reports the suites the cluster listener allows for
+// the configured cluster_cipher_suites. The cluster transport authenticates
+// with ECDSA certificates the core generates, so over TLS 1.2 only ECDHE_ECDSA
+// suites can be negotiated; a list with none of them leaves nodes unable to
+// forward requests or replicate raft over TLS 1.2.
+func ClusterCipherSuitesCheck(ctx context.Context, configured string) {
+	var suites []uint16
+	switch configured {
+	case "":
+		suites = defaultClusterCipherSuites
+	case "tls12", "tls13":
+		SpotOk(ctx, "cluster-cipher-suites", fmt.Sprintf("%q uses Go's default cipher suites", configured))
+		return
+	default:
+		var err error
+		suites, err = tlsutil.ParseCiphers(configured)
+		if err != nil {
+			SpotError(ctx, "cluster-cipher-suites", fmt.Errorf("cluster_cipher_suites cannot be parsed, so the core will fail to start: %w", err))
+			return
+		}
+	}
+	if len(suites) == 0 {
+		SpotWarn(ctx, "cluster-cipher-suites", "cluster_cipher_suites allows no cipher suites")
+		return
+	}
+
+	insecure := make(map[uint16]bool)
+	for _, cs := range tls.InsecureCipherSuites() {
+		insecure[cs.ID] = true
+	}
+	names := make([]string, 0, len(suites))
+	var usable, weak []string
+	for _, id := range suites {
+		name := tls.CipherSuiteName(id)
+		names = append(names, name)
+		if strings.HasPrefix(name, "TLS_ECDHE_ECDSA_") {
+			usable = append(usable, name)
+		}
+		if insecure[id] {
+			weak = append(weak, name)
+		}
+	}
+	switch {
+	case len(usable) == 0:
+		SpotWarn(ctx, "cluster-cipher-suites", fmt.Sprintf("cluster_cipher_suites allows %s, none of which can be used "+
+			"with the ECDSA certificates of the cluster transport over TLS 1.2; include an ECDHE_ECDSA suite",
+			strings.Join(names, ", ")))
+	case len(weak) > 0:
+		SpotWarn(ctx, "cluster-cipher-suites", fmt.Sprintf("cluster_cipher_suites allows the insecure suites %s",
+			strings.Join(weak, ", ")))
+	default:
+		SpotOk(ctx, "cluster-cipher-suites", strings.Join(names, ", "))
+	}
+}
+
+// certPublicKey returns the encoded public key of the first certificate in
+// PEM data.
+func certPublicKey(data []byte) ([]byte, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no PEM certificate found")
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.RawSubjectPublicKeyInfo, nil
+	}
+}
+
+// TLSKeySeparationCheck reports whether a listener's TLS key is reused
+// outside the client-facing API. The cluster transport never is: it uses a
+// certificate and key the server generates for the cluster, so this is
+// reported when clustering is enabled. What can share a key is the client
+// certificate a raft retry_join stanza presents to the leader's API, which
+// compares with the listener certificates by public key. Reuse is noted,
+// since it is often intentional; with strict set, it is a warning for
+// policies that require separate keys.
+func TLSKeySeparationCheck(ctx context.Context, listeners []*configutil.Listener, storageConf map[string]string, clusteringDisabled, strict bool) error {
+	testName := "tls-key-separation"
+	if !clusteringDisabled {
+		SpotInfo(ctx, testName, "the cluster transport uses the certificate and key the server generates for the "+
+			"cluster, so it never shares a key with a listener")
+	}
+
+	infos, err := raftJoinInfos(storageConf)
+	if err != nil {
+		return err
+	}
+	type clientCert struct {
+		leader string
+		key    []byte
+	}
+	var clientCerts []clientCert
+	for _, info := range infos {
+		data := []byte(info.LeaderClientCert)
+		if info.LeaderClientCertFile != "" {
+			if data, err = ioutil.ReadFile(info.LeaderClientCertFile); err != nil {
+				return fmt.Errorf("could not read leader_client_cert_file %s: %w", info.LeaderClientCertFile, err)
+			}
+		}
+		if len(data) == 0 {
+			continue
+		}
+		key, err := certPublicKey(data)
+		if err != nil {
+			return fmt.Errorf("could not parse the retry_join client certificate for %s: %w", info.LeaderAPIAddr, err)
+		}
+		clientCerts = append(clientCerts, clientCert{leader: info.LeaderAPIAddr, key: key})
+	}
+
+	// Unreadable listener certificates are reported by the listener TLS checks.
+	shared := false
+	for _, l := range listeners {
+		if l.Type != "tcp" || l.TLSDisable || l.TLSCertFile == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(l.TLSCertFile)
+		if err != nil {
+			continue
+		}
+		key, err := certPublicKey(data)
+		if err != nil {
+			continue
+		}
+		for _, cc := range clientCerts {
+			if !bytes.Equal(key, cc.key) {
+				continue
+			}
+			shared = true
+			msg := fmt.Sprintf("the listener on %s and the retry_join client certificate for %s share a key",
+				l.Address, cc.leader)
+			if strict {
+				SpotWarn(ctx, testName, msg, Advice("Issue the retry_join client certificate with its own key."))
+			} else {
+				SpotInfo(ctx, testName, msg)
+			}
+		}
+	}
+	if !shared {
+		SpotOk(ctx, testName, "no listener's TLS key is shared with another plane")
+	}
+	return nil
+}
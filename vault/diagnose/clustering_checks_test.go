@@ -0,0 +1,175 @@
+package diagnose
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+func TestClusteringCoherenceCheck(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cc       ClusteringConfig
+		expected []*Result
+	}{
+		{
+			"enabled",
+			ClusteringConfig{StanzaType: "consul", EffectiveClusterAddr: "https://10.0.0.1:8201"},
+			[]*Result{
+				{Name: "clustering-state", Status: InfoStatus, Message: "clustering is enabled with cluster address https://10.0.0.1:8201"},
+			},
+		},
+		{
+			"enabled without address",
+			ClusteringConfig{StanzaType: "consul"},
+			[]*Result{
+				{Name: "clustering-state", Status: WarningStatus, Message: "clustering is enabled but no cluster address could be determined; " +
+					"set cluster_addr or api_addr so that standbys can forward requests"},
+			},
+		},
+		{
+			"disabled with addresses",
+			ClusteringConfig{
+				Disabled:         true,
+				StanzaType:       "consul",
+				StanzaDisabled:   true,
+				ClusterAddr:      "https://10.0.0.1:8201",
+				ClusterListeners: []string{"0.0.0.0:8200"},
+			},
+			[]*Result{
+				{Name: "clustering-state", Status: InfoStatus, Message: "clustering is disabled"},
+				{Name: "disable-clustering", Status: WarningStatus, Message: `clustering is disabled, so cluster_addr "https://10.0.0.1:8201" is ignored`},
+				{Name: "disable-clustering", Status: WarningStatus, Message: "clustering is disabled, but listeners 0.0.0.0:8200 set cluster_address"},
+			},
+		},
+		{
+			"stanza disagrees",
+			ClusteringConfig{StanzaType: "consul", StanzaDisabled: true, EffectiveClusterAddr: "https://10.0.0.1:8201"},
+			[]*Result{
+				{Name: "clustering-state", Status: InfoStatus, Message: "clustering is enabled with cluster address https://10.0.0.1:8201"},
+				{Name: "disable-clustering", Status: WarningStatus, Message: "the consul storage stanza has disable_clustering=true, but the " +
+					"server uses the top-level value of false; set disable_clustering at the top level of the configuration"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				ClusteringCoherenceCheck(ctx, tc.cc)
+			})
+			if !reflect.DeepEqual(results, tc.expected) {
+				t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results, tc.expected), "\n"))
+			}
+		})
+	}
+}
+
+func TestClusterAddressOverrideCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		listeners []*configutil.Listener
+		status    status
+	}{
+		{"no overrides", []*configutil.Listener{{Address: "0.0.0.0:8200"}}, SkippedStatus},
+		{"wildcard", []*configutil.Listener{{Address: "0.0.0.0:8200", ClusterAddress: "0.0.0.0:8201"}}, OkStatus},
+		{"same host", []*configutil.Listener{{Address: "10.0.0.1:8200", ClusterAddress: "10.0.0.1:8201"}}, OkStatus},
+		{"other port", []*configutil.Listener{{Address: "0.0.0.0:8200", ClusterAddress: "0.0.0.0:8301"}}, ErrorStatus},
+		{"other host", []*configutil.Listener{{Address: "10.0.0.2:8200", ClusterAddress: "10.0.0.2:8201"}}, ErrorStatus},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				ClusterAddressOverrideCheck(ctx, "https://10.0.0.1:8201", tc.listeners)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
+
+func TestClusterCipherSuitesCheck(t *testing.T) {
+	testCases := []struct {
+		configured string
+		status     status
+	}{
+		{"", OkStatus},
+		{"tls13", OkStatus},
+		{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", OkStatus},
+		{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", WarningStatus},
+		{"TLS_ECDHE_ECDSA_WITH_RC4_128_SHA", WarningStatus},
+		{"TLS_NOT_A_SUITE", ErrorStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.configured, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				ClusterCipherSuitesCheck(ctx, tc.configured)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
+
+func TestTLSKeySeparationCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-key-separation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	listenerCert := filepath.Join(dir, "listener.pem")
+	otherCert := filepath.Join(dir, "other.pem")
+	if err := ioutil.WriteFile(listenerCert, clientCAPEM(t, "vault", now, now.Add(time.Hour)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(otherCert, clientCAPEM(t, "join", now, now.Add(time.Hour)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	listeners := []*configutil.Listener{{Type: "tcp", Address: "0.0.0.0:8200", TLSCertFile: listenerCert}}
+	retryJoin := func(cert string) map[string]string {
+		return map[string]string{"retry_join": `[{"leader_api_addr": "https://vault-0:8200", "leader_client_cert_file": "` + cert + `"}]`}
+	}
+
+	testCases := []struct {
+		name     string
+		conf     map[string]string
+		disabled bool
+		strict   bool
+		expected []status
+	}{
+		{"no retry_join", map[string]string{}, false, false, []status{InfoStatus, OkStatus}},
+		{"separate keys", retryJoin(otherCert), false, false, []status{InfoStatus, OkStatus}},
+		{"shared key", retryJoin(listenerCert), false, false, []status{InfoStatus, InfoStatus}},
+		{"shared key strict", retryJoin(listenerCert), true, true, []status{WarningStatus}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				if err := TLSKeySeparationCheck(ctx, listeners, tc.conf, tc.disabled, tc.strict); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %#v", len(tc.expected), results)
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("result %d: expected %s, got %s: %s", i, tc.expected[i], r.Status, r.Message)
+				}
+			}
+		})
+	}
+}
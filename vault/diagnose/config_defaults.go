@@ -0,0 +1,116 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/vault/sdk/physical"
+)
+
+// unlabeledConfigBlocks are the configuration blocks without a label, such as
+// telemetry { ... }, whose settings are reported individually.
+var unlabeledConfigBlocks = map[string]bool{"telemetry": true}
+
+// appliedConfigDefaults describe what the server uses for settings left at
+// their zero value, where that differs from the zero value itself.
+var appliedConfigDefaults = map[string]string{
+	"max_lease_ttl":                "768h (32 days)",
+	"default_lease_ttl":            "768h (32 days)",
+	"cache_size":                   fmt.Sprintf("%d entries", physical.DefaultCacheSize),
+	"default_max_request_duration": "90s",
+	"cluster_cipher_suites":        "the TLS 1.3 suites and the TLS 1.2 ECDHE_ECDSA AES-GCM and ChaCha20 suites",
+	"log_level":                    "info",
+	"log_format":                   "standard",
+}
+
+// ExplicitConfigKeys returns the settings an HCL or JSON configuration file
+// sets explicitly: its top-level keys, and "block.key" for the keys of
+// unlabeled blocks such as telemetry.
+func ExplicitConfigKeys(contents []byte) (map[string]bool, error) {
+	file, err := hcl.ParseBytes(contents)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, errors.New("the file doesn't contain a root object")
+	}
+
+	keys := make(map[string]bool)
+	for _, item := range list.Items {
+		if len(item.Keys) == 0 {
+			continue
+		}
+		key := objectKey(item.Keys[0])
+		keys[key] = true
+		obj, ok := item.Val.(*ast.ObjectType)
+		if !ok || len(item.Keys) != 1 || !unlabeledConfigBlocks[key] {
+			continue
+		}
+		for _, sub := range obj.List.Items {
+			if len(sub.Keys) > 0 {
+				keys[key+"."+objectKey(sub.Keys[0])] = true
+			}
+		}
+	}
+	return keys, nil
+}
+
+func objectKey(k *ast.ObjectKey) string {
+	if s, ok := k.Token.Value().(string); ok {
+		return s
+	}
+	return k.Token.Text
+}
+
+// ConfigDefaultsCheck reports which settings of the effective configuration
+// were set explicitly, and which took their default, along with the value the
+// server applies for each default. Stanzas such as listeners and seals are
+// always explicit and are not listed.
+func ConfigDefaultsCheck(ctx context.Context, effective map[string]interface{}, explicit map[string]bool) {
+	settings := make(map[string]interface{})
+	for k, v := range effective {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if !unlabeledConfigBlocks[k] {
+				continue
+			}
+			for sub, sv := range v {
+				settings[k+"."+sub] = sv
+			}
+		case []interface{}:
+		default:
+			settings[k] = v
+		}
+	}
+
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var set []string
+	for _, k := range keys {
+		if explicit[k] {
+			set = append(set, k)
+			continue
+		}
+		value, ok := appliedConfigDefaults[k]
+		if !ok {
+			value = fmt.Sprintf("%v", settings[k])
+			if value == "" {
+				value = "unset"
+			}
+		}
+		SpotInfo(ctx, "config-default", fmt.Sprintf("%s: %s", k, value))
+	}
+	if len(set) > 0 {
+		SpotInfo(ctx, "config-explicit", strings.Join(set, ", "))
+	}
+}
@@ -0,0 +1,80 @@
+package diagnose
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExplicitConfigKeys(t *testing.T) {
+	expected := map[string]bool{
+		"ui":                                  true,
+		"cache_size":                          true,
+		"listener":                            true,
+		"telemetry":                           true,
+		"telemetry.prometheus_retention_time": true,
+	}
+
+	hclConfig := `
+ui = true
+cache_size = 1000
+
+listener "tcp" {
+  address = "127.0.0.1:8200"
+}
+
+telemetry {
+  prometheus_retention_time = "24h"
+}
+`
+	keys, err := ExplicitConfigKeys([]byte(hclConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("unexpected HCL keys: %v", keys)
+	}
+
+	jsonConfig := `{
+  "ui": true,
+  "cache_size": 1000,
+  "listener": {"tcp": {"address": "127.0.0.1:8200"}},
+  "telemetry": {"prometheus_retention_time": "24h"}
+}`
+	keys, err = ExplicitConfigKeys([]byte(jsonConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("unexpected JSON keys: %v", keys)
+	}
+}
+
+func TestConfigDefaultsCheck(t *testing.T) {
+	effective := map[string]interface{}{
+		"cache_size":    1000,
+		"enable_ui":     false,
+		"max_lease_ttl": time.Duration(0),
+		"listeners":     []interface{}{map[string]interface{}{"type": "tcp"}},
+		"storage":       map[string]interface{}{"type": "raft"},
+		"telemetry":     map[string]interface{}{"disable_hostname": true},
+	}
+	explicit := map[string]bool{"cache_size": true, "telemetry": true, "telemetry.disable_hostname": true}
+	results := checkResults(t, func(ctx context.Context) {
+		ConfigDefaultsCheck(ctx, effective, explicit)
+	})
+	expected := []*Result{
+		{Name: "config-default", Status: InfoStatus, Message: "enable_ui: false"},
+		{Name: "config-default", Status: InfoStatus, Message: "max_lease_ttl: 768h (32 days)"},
+		{Name: "config-explicit", Status: InfoStatus, Message: "cache_size, telemetry.disable_hostname"},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %#v", len(expected), results)
+	}
+	for i, exp := range expected {
+		if results[i].Name != exp.Name || results[i].Status != exp.Status || results[i].Message != exp.Message {
+			t.Fatalf("result %d: expected %#v, got %#v", i, exp, results[i])
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package diagnose
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// ConfigDriftLiveCheck compares the sanitized configuration of the running
+// server, from sys/config/state/sanitized, with local, the sanitized form of
+// the configuration files diagnose read. A difference means the files were
+// edited after the server last loaded them, and the change takes effect only
+// once the server reloads or restarts.
+func ConfigDriftLiveCheck(ctx context.Context, client *api.Client, local map[string]interface{}) error {
+	secret, err := client.Logical().Read("sys/config/state/sanitized")
+	if err != nil {
+		return fmt.Errorf("could not read the running server's configuration: %w", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return errors.New("the running server returned no configuration")
+	}
+	return compareSanitizedConfig(ctx, local, secret.Data)
+}
+
+func compareSanitizedConfig(ctx context.Context, local, running map[string]interface{}) error {
+	testName := "config-drift"
+	localNorm, localHash, err := normalizeConfig(local)
+	if err != nil {
+		return err
+	}
+	runningNorm, runningHash, err := normalizeConfig(running)
+	if err != nil {
+		return err
+	}
+	if localHash == runningHash {
+		SpotOk(ctx, testName, fmt.Sprintf("the running server's configuration matches the files on disk (sha256 %s)", localHash[:12]))
+		return nil
+	}
+
+	keys := make(map[string]bool)
+	for k := range localNorm {
+		keys[k] = true
+	}
+	for k := range runningNorm {
+		keys[k] = true
+	}
+	var differing []string
+	for k := range keys {
+		if !reflect.DeepEqual(localNorm[k], runningNorm[k]) {
+			differing = append(differing, k)
+		}
+	}
+	sort.Strings(differing)
+	SpotWarn(ctx, testName, fmt.Sprintf("the running server's configuration (sha256 %s) differs from the files on disk "+
+		"(sha256 %s) in %s; the files were changed after the server loaded them", runningHash[:12], localHash[:12],
+		strings.Join(differing, ", ")),
+		Advice("Reload the server with SIGHUP, or restart it for settings that can't be reloaded, to apply the change."))
+	return nil
+}
+
+// normalizeConfig round-trips a sanitized configuration through JSON, so that
+// one built locally and one decoded from the API compare alike, and returns
+// it with the SHA-256 of its canonical encoding.
+func normalizeConfig(conf map[string]interface{}) (map[string]interface{}, string, error) {
+	data, err := json.Marshal(conf)
+	if err != nil {
+		return nil, "", err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var norm map[string]interface{}
+	if err := dec.Decode(&norm); err != nil {
+		return nil, "", err
+	}
+	// Maps are encoded with sorted keys, so the encoding is canonical.
+	canonical, err := json.Marshal(norm)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return norm, hex.EncodeToString(sum[:]), nil
+}
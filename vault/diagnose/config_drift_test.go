@@ -0,0 +1,64 @@
+package diagnose
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestConfigDriftLiveCheck(t *testing.T) {
+	running := map[string]interface{}{
+		"api_addr":        "https://vault.example.com:8200",
+		"default_max_ttl": 3600,
+		"listeners":       []interface{}{map[string]interface{}{"type": "tcp"}},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/sys/config/state/sanitized" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": running})
+	}))
+	defer srv.Close()
+
+	conf := api.DefaultConfig()
+	conf.Address = srv.URL
+	client, err := api.NewClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := map[string]interface{}{
+		"api_addr":        "https://vault.example.com:8200",
+		"default_max_ttl": int64(3600),
+		"listeners":       []map[string]interface{}{{"type": "tcp"}},
+	}
+	results := checkResults(t, func(ctx context.Context) {
+		if err := ConfigDriftLiveCheck(ctx, client, local); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(results) != 1 || results[0].Status != OkStatus {
+		t.Fatalf("expected the configurations to match, got %#v", results)
+	}
+
+	local["default_max_ttl"] = 7200
+	local["ui"] = true
+	results = checkResults(t, func(ctx context.Context) {
+		if err := ConfigDriftLiveCheck(ctx, client, local); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(results) != 1 || results[0].Status != WarningStatus {
+		t.Fatalf("expected a warning for the changed configuration, got %#v", results)
+	}
+	if !strings.Contains(results[0].Message, "in default_max_ttl, ui;") {
+		t.Fatalf("expected the differing settings in %q", results[0].Message)
+	}
+}
@@ -0,0 +1,241 @@
+package diagnose
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/token"
+)
+
+// ConfigSchema is a JSON schema of the server configuration, in the structure
+// HCL files have once blocks are nested under their labels, for use by
+// editors as well as by ValidateConfigSchema.
+//
+//go:embed config_schema.json
+var ConfigSchema []byte
+
+// schemaNode is the subset of JSON schema that ValidateConfigSchema supports:
+// type, properties, additionalProperties, required and $ref to a definition.
+type schemaNode struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 schemaTypes            `json:"type"`
+	Properties           map[string]*schemaNode `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Required             []string               `json:"required"`
+	Definitions          map[string]*schemaNode `json:"definitions"`
+}
+
+// schemaTypes is a JSON schema type, which is either a single type name or a
+// list of them.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*t = many
+	return nil
+}
+
+// SchemaViolation is a place where a configuration file doesn't match the
+// schema, with the dotted path of the offending setting.
+type SchemaViolation struct {
+	Path    string
+	Message string
+}
+
+func (v SchemaViolation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// configObject is an HCL object with the values of each key, which HCL allows
+// to repeat.
+type configObject map[string][]interface{}
+
+// ValidateConfigSchema validates an HCL or JSON configuration file against
+// ConfigSchema, and returns the unknown keys, type mismatches and missing
+// required settings it finds. Unlike parsing the configuration, which ignores
+// unknown keys, this catches misspelled settings and stanzas.
+func ValidateConfigSchema(contents []byte) ([]SchemaViolation, error) {
+	var root schemaNode
+	if err := json.Unmarshal(ConfigSchema, &root); err != nil {
+		return nil, fmt.Errorf("invalid configuration schema: %w", err)
+	}
+	file, err := hcl.ParseBytes(contents)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, errors.New("the file doesn't contain a root object")
+	}
+	v := &schemaValidator{definitions: root.Definitions}
+	v.validate("", objectListValue(list), &root)
+	return v.violations, nil
+}
+
+type schemaValidator struct {
+	definitions map[string]*schemaNode
+	violations  []SchemaViolation
+}
+
+func (v *schemaValidator) violation(path, format string, args ...interface{}) {
+	v.violations = append(v.violations, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *schemaValidator) resolve(s *schemaNode) *schemaNode {
+	for s != nil && s.Ref != "" {
+		s = v.definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+	}
+	return s
+}
+
+func (v *schemaValidator) validate(path string, value interface{}, s *schemaNode) {
+	s = v.resolve(s)
+	if s == nil {
+		return
+	}
+	if len(s.Type) > 0 {
+		actual := valueType(value)
+		if !s.Type.allows(actual) {
+			v.violation(path, "expected %s, got %s", strings.Join(s.Type, " or "), actual)
+			return
+		}
+	}
+	obj, ok := value.(configObject)
+	if !ok {
+		return
+	}
+
+	for _, req := range s.Required {
+		if _, ok := obj[req]; !ok {
+			v.violation(path, "missing required setting %q", req)
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sub := joinPath(path, k)
+		prop, known := s.Properties[k]
+		if !known {
+			var additional *schemaNode
+			allowed := true
+			if len(s.AdditionalProperties) > 0 {
+				if err := json.Unmarshal(s.AdditionalProperties, &allowed); err != nil {
+					additional = &schemaNode{}
+					json.Unmarshal(s.AdditionalProperties, additional)
+				}
+			}
+			if !allowed {
+				v.violation(sub, "unknown setting")
+				continue
+			}
+			prop = additional
+		}
+		for _, value := range obj[k] {
+			v.validate(sub, value, prop)
+		}
+	}
+}
+
+func (t schemaTypes) allows(actual string) bool {
+	for _, typ := range t {
+		if typ == actual {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// valueType returns the JSON schema type of a value built from the HCL AST.
+func valueType(value interface{}) string {
+	switch value := value.(type) {
+	case configObject:
+		return "object"
+	case []interface{}:
+		return "array"
+	case token.Type:
+		switch value {
+		case token.NUMBER, token.FLOAT:
+			return "number"
+		case token.BOOL:
+			return "boolean"
+		}
+	}
+	return "string"
+}
+
+// objectListValue converts an HCL object list to a configObject. A labeled
+// block such as listener "tcp" { ... } becomes a listener object holding a tcp
+// object. Literal values are represented by their token type, which is all
+// validation needs.
+func objectListValue(list *ast.ObjectList) configObject {
+	obj := make(configObject)
+	for _, item := range list.Items {
+		if len(item.Keys) == 0 {
+			continue
+		}
+		value := nodeValue(item.Val)
+		for i := len(item.Keys) - 1; i > 0; i-- {
+			value = configObject{objectKey(item.Keys[i]): {value}}
+		}
+		key := objectKey(item.Keys[0])
+		obj[key] = append(obj[key], value)
+	}
+	return obj
+}
+
+func nodeValue(node ast.Node) interface{} {
+	switch n := node.(type) {
+	case *ast.ObjectType:
+		return objectListValue(n.List)
+	case *ast.ListType:
+		values := make([]interface{}, 0, len(n.List))
+		for _, elem := range n.List {
+			values = append(values, nodeValue(elem))
+		}
+		return values
+	case *ast.LiteralType:
+		return n.Token.Type
+	}
+	return token.STRING
+}
+
+// ConfigSchemaCheck reports the schema violations found in a configuration
+// file, or that it matches the schema.
+func ConfigSchemaCheck(ctx context.Context, file string, violations []SchemaViolation) {
+	if len(violations) == 0 {
+		SpotOk(ctx, "config-schema", fmt.Sprintf("%s matches the configuration schema", file))
+		return
+	}
+	for _, v := range violations {
+		SpotError(ctx, "config-schema", fmt.Errorf("%s: %s", file, v))
+	}
+}
@@ -0,0 +1,156 @@
+package diagnose
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestConfigSchemaIsValidJSON(t *testing.T) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(ConfigSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateConfigSchema(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   string
+		expected []SchemaViolation
+	}{
+		{
+			name: "valid",
+			config: `
+ui = true
+cache_size = 1000
+disable_mlock = "true"
+max_lease_ttl = "768h"
+
+storage "raft" {
+  path = "/var/lib/vault"
+  retry_join {
+    leader_api_addr = "https://10.0.0.1:8200"
+  }
+}
+
+listener "tcp" {
+  address = "127.0.0.1:8200"
+  tls_disable = true
+}
+
+listener "unix" {
+  address = "/run/vault.sock"
+}
+
+seal "awskms" {
+  kms_key_id = "alias/vault"
+}
+
+telemetry {
+  prometheus_retention_time = "24h"
+}
+`,
+		},
+		{
+			name: "valid json",
+			config: `{
+  "ui": true,
+  "listener": [{"tcp": {"address": "127.0.0.1:8200"}}],
+  "storage": {"file": {"path": "/var/lib/vault"}}
+}`,
+		},
+		{
+			name:     "unknown top-level key",
+			config:   `disable_mlok = true`,
+			expected: []SchemaViolation{{Path: "disable_mlok", Message: "unknown setting"}},
+		},
+		{
+			name: "unknown listener key",
+			config: `
+listener "tcp" {
+  address = "127.0.0.1:8200"
+  tls_cert = "/etc/vault/cert.pem"
+}
+`,
+			expected: []SchemaViolation{{Path: "listener.tcp.tls_cert", Message: "unknown setting"}},
+		},
+		{
+			name: "unknown telemetry key",
+			config: `
+telemetry {
+  statsd_adress = "127.0.0.1:8125"
+}
+`,
+			expected: []SchemaViolation{{Path: "telemetry.statsd_adress", Message: "unknown setting"}},
+		},
+		{
+			name:     "type mismatch",
+			config:   `ui = ["true"]`,
+			expected: []SchemaViolation{{Path: "ui", Message: "expected boolean or string, got array"}},
+		},
+		{
+			name:     "block for a value",
+			config:   `api_addr { host = "vault" }`,
+			expected: []SchemaViolation{{Path: "api_addr", Message: "expected string, got object"}},
+		},
+		{
+			name:     "value for a block",
+			config:   `storage = "raft"`,
+			expected: []SchemaViolation{{Path: "storage", Message: "expected object, got string"}},
+		},
+		{
+			name: "missing required",
+			config: `
+listener "unix" {
+  socket_mode = "0600"
+}
+`,
+			expected: []SchemaViolation{{Path: "listener.unix", Message: `missing required setting "address"`}},
+		},
+		{
+			name: "unknown listener type",
+			config: `
+listener "udp" {
+  address = "127.0.0.1:8200"
+}
+`,
+			expected: []SchemaViolation{{Path: "listener.udp", Message: "unknown setting"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations, err := ValidateConfigSchema([]byte(tc.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(violations, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, violations)
+			}
+		})
+	}
+}
+
+func TestValidateConfigSchemaParseError(t *testing.T) {
+	if _, err := ValidateConfigSchema([]byte(`listener "tcp" {`)); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
+
+func TestConfigSchemaCheck(t *testing.T) {
+	results := checkResults(t, func(ctx context.Context) {
+		ConfigSchemaCheck(ctx, "vault.hcl", nil)
+		ConfigSchemaCheck(ctx, "vault.hcl", []SchemaViolation{{Path: "ui", Message: "expected boolean or string, got array"}})
+	})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Status != OkStatus {
+		t.Fatalf("expected ok, got %s", results[0].Status)
+	}
+	if results[1].Status != ErrorStatus || results[1].Message != "vault.hcl: ui: expected boolean or string, got array" {
+		t.Fatalf("unexpected result: %s %s", results[1].Status, results[1].Message)
+	}
+}
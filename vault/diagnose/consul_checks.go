@@ -0,0 +1,111 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/parseutil"
+)
+
+const (
+	// consulDefaultCheckTimeout, consulCheckMinBuffer and
+	// consulCheckJitterFactor mirror the Consul service registration's
+	// default check_timeout, and the buffer and jitter it subtracts from it
+	// to schedule the updates of its TTL check.
+	consulDefaultCheckTimeout = 5 * time.Second
+	consulCheckMinBuffer      = 100 * time.Millisecond
+	consulCheckJitterFactor   = 16
+
+	// consulMinCheckTimeout and consulMaxCheckTimeout bound the check_timeout
+	// that neither marks a node critical over a short pause nor keeps a
+	// failed node passing for long.
+	consulMinCheckTimeout = 2 * time.Second
+	consulMaxCheckTimeout = time.Minute
+)
+
+// ConsulAgent describes the Consul agent a stanza talks to.
+type ConsulAgent struct {
+	Address    string
+	Datacenter string
+	Namespace  string
+}
+
+func (a ConsulAgent) String() string {
+	namespace := a.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	return fmt.Sprintf("datacenter %s, namespace %s (via %s)", a.Datacenter, namespace, a.Address)
+}
+
+// ConsulDatacenterCheck warns when the storage and service_registration
+// stanzas talk to Consul agents in different datacenters or namespaces, in
+// which case Vault registers its service somewhere other than where its data
+// lives, and clients that discover it through Consul may find the wrong
+// cluster.
+func ConsulDatacenterCheck(ctx context.Context, storage, registration ConsulAgent) {
+	if storage.Datacenter != registration.Datacenter || storage.Namespace != registration.Namespace {
+		SpotWarn(ctx, "consul-datacenter", fmt.Sprintf("storage uses %s, but service_registration uses %s",
+			storage, registration))
+		return
+	}
+	SpotOk(ctx, "consul-datacenter", fmt.Sprintf("storage and service_registration both use %s", storage))
+}
+
+// ConsulRegistrationTimingCheck reports the timing of the TTL check the
+// Consul service registration keeps for the node, from check_timeout. Vault
+// updates the check a little before each timeout, so a short timeout leaves
+// little margin, and a pause longer than that marks the node critical and
+// drops it from discovery until the next update. A long timeout keeps a node
+// that stopped without deregistering, such as one that hung, passing until
+// the timeout expires. Vault doesn't set deregister_critical_service_after on
+// the check, so a node that stops this way stays registered as critical.
+func ConsulRegistrationTimingCheck(ctx context.Context, conf map[string]string) error {
+	testName := "consul-registration-timing"
+	timeout := consulDefaultCheckTimeout
+	source := "the default"
+	if raw, ok := conf["check_timeout"]; ok {
+		d, err := parseutil.ParseDurationSecond(raw)
+		if err != nil {
+			return SpotError(ctx, testName, fmt.Errorf("failed to parse 'check_timeout': %w", err))
+		}
+		timeout = d
+		source = "check_timeout"
+	}
+
+	// The check is updated every timeout, less the buffer and a random
+	// stagger of up to a sixteenth of the rest.
+	latest := timeout - consulCheckMinBuffer
+	earliest := latest - latest/consulCheckJitterFactor
+	if earliest < consulCheckMinBuffer {
+		return SpotError(ctx, testName, fmt.Errorf("check_timeout %s leaves less than %s between updates of the TTL "+
+			"check, so the service registration rejects it and the server won't start", timeout, consulCheckMinBuffer))
+	}
+	SpotInfo(ctx, testName, fmt.Sprintf("the TTL check lasts %s (from %s) and is updated every %s to %s, leaving "+
+		"%s to %s before a late update marks the node critical; deregister_critical_service_after is not set, so a "+
+		"node that stops without deregistering stays registered as critical", timeout, source,
+		earliest.Round(time.Millisecond), latest.Round(time.Millisecond),
+		(timeout-latest).Round(time.Millisecond), (timeout-earliest).Round(time.Millisecond)))
+
+	if _, ok := conf["deregister_critical_service_after"]; ok {
+		SpotWarn(ctx, testName, "deregister_critical_service_after is not a setting of the consul service_registration "+
+			"and is ignored; Vault's check never deregisters the node",
+			Advice("Remove the setting, and remove the registrations of nodes that were decommissioned from Consul directly."))
+	}
+	switch {
+	case timeout < consulMinCheckTimeout:
+		SpotWarn(ctx, testName, fmt.Sprintf("check_timeout %s is below %s; a garbage collection pause or a slow Consul "+
+			"agent longer than %s marks the node critical, dropping it from discovery intermittently",
+			timeout, consulMinCheckTimeout, (timeout-latest).Round(time.Millisecond)),
+			Advice(fmt.Sprintf("Raise check_timeout to at least %s, such as the default of %s.", consulMinCheckTimeout, consulDefaultCheckTimeout)))
+	case timeout > consulMaxCheckTimeout:
+		SpotWarn(ctx, testName, fmt.Sprintf("check_timeout %s is above %s; a node that hangs or stops without "+
+			"deregistering keeps passing its check for up to %s, so clients are routed to it", timeout,
+			consulMaxCheckTimeout, timeout),
+			Advice(fmt.Sprintf("Lower check_timeout to at most %s.", consulMaxCheckTimeout)))
+	default:
+		SpotOk(ctx, testName, fmt.Sprintf("check_timeout %s neither drops the node over short pauses nor keeps a failed node passing for long", timeout))
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package diagnose
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestConsulDatacenterCheck(t *testing.T) {
+	testCases := []struct {
+		name         string
+		storage      ConsulAgent
+		registration ConsulAgent
+		status       status
+	}{
+		{
+			"same agent",
+			ConsulAgent{Address: "127.0.0.1:8500", Datacenter: "dc1"},
+			ConsulAgent{Address: "127.0.0.1:8500", Datacenter: "dc1"},
+			OkStatus,
+		},
+		{
+			"different datacenters",
+			ConsulAgent{Address: "127.0.0.1:8500", Datacenter: "dc1"},
+			ConsulAgent{Address: "consul.dc2:8500", Datacenter: "dc2"},
+			WarningStatus,
+		},
+		{
+			"different namespaces",
+			ConsulAgent{Address: "127.0.0.1:8500", Datacenter: "dc1", Namespace: "vault"},
+			ConsulAgent{Address: "127.0.0.1:8500", Datacenter: "dc1"},
+			WarningStatus,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				ConsulDatacenterCheck(ctx, tc.storage, tc.registration)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
+
+func TestConsulRegistrationTimingCheck(t *testing.T) {
+	testCases := []struct {
+		name     string
+		conf     map[string]string
+		expected []status
+	}{
+		{"default", map[string]string{}, []status{InfoStatus, OkStatus}},
+		{"seconds", map[string]string{"check_timeout": "30"}, []status{InfoStatus, OkStatus}},
+		{"short", map[string]string{"check_timeout": "1s"}, []status{InfoStatus, WarningStatus}},
+		{"long", map[string]string{"check_timeout": "5m"}, []status{InfoStatus, WarningStatus}},
+		{"deregister", map[string]string{"deregister_critical_service_after": "1h"}, []status{InfoStatus, WarningStatus, OkStatus}},
+		{"too short to start", map[string]string{"check_timeout": "150ms"}, []status{ErrorStatus}},
+		{"invalid", map[string]string{"check_timeout": "soon"}, []status{ErrorStatus}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				ConsulRegistrationTimingCheck(ctx, tc.conf)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %d", len(tc.expected), len(results))
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("result %d: expected %s, got %s: %s", i, tc.expected[i], r.Status, r.Message)
+				}
+			}
+		})
+	}
+
+	results := checkResults(t, func(ctx context.Context) {
+		ConsulRegistrationTimingCheck(ctx, map[string]string{})
+	})
+	expected := "the TTL check lasts 5s (from the default) and is updated every 4.594s to 4.9s, leaving 100ms to 406ms " +
+		"before a late update marks the node critical"
+	if !strings.HasPrefix(results[0].Message, expected) {
+		t.Fatalf("expected %q, got %q", expected, results[0].Message)
+	}
+}
@@ -0,0 +1,183 @@
+package diagnose
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// capIPCLock is the bit of CAP_IPC_LOCK in the capability sets of
+// /proc/self/status.
+const capIPCLock = 14
+
+// containerInfo is what the process can see of the container it runs in.
+type containerInfo struct {
+	runtime string
+
+	// capIPCLock is whether CAP_IPC_LOCK, needed to mlock, is in the
+	// effective capability set.
+	capIPCLock bool
+
+	// seccomp is the seccomp mode of the process: 0 for none, 1 for strict
+	// and 2 for a filter.
+	seccomp int
+
+	mounts []mountEntry
+}
+
+// mountEntry is a mount point and whether it is mounted read-only.
+type mountEntry struct {
+	point    string
+	readOnly bool
+}
+
+// mountFor returns the mount containing path, or nil if none does.
+func (c *containerInfo) mountFor(path string) *mountEntry {
+	var found *mountEntry
+	for i, m := range c.mounts {
+		within := path == m.point || strings.HasPrefix(path, strings.TrimSuffix(m.point, "/")+"/")
+		if within && (found == nil || len(m.point) >= len(found.point)) {
+			found = &c.mounts[i]
+		}
+	}
+	return found
+}
+
+// ContainerCheck detects whether the server runs in a container, and under
+// which runtime, and reports the container's constraints that affect Vault: a
+// read-only root filesystem, a dropped CAP_IPC_LOCK capability and a seccomp
+// filter. It warns when a constraint conflicts with the configuration, such
+// as mlock being enabled without CAP_IPC_LOCK, which prevents the server from
+// starting, or the storage path being on a read-only mount.
+func ContainerCheck(ctx context.Context, disableMlock bool, storagePath string) error {
+	if runtime.GOOS != "linux" {
+		Skipped(ctx, SkipNotApplicablePlatform, "containers are only detected on Linux")
+		return nil
+	}
+	info, err := readContainerInfo("/proc", "/", os.Getenv)
+	if err != nil {
+		return err
+	}
+	if info.runtime == "" {
+		Skipped(ctx, SkipNotApplicable, "not running in a container")
+		return nil
+	}
+	checkContainer(ctx, info, disableMlock, storagePath)
+	return nil
+}
+
+// readContainerInfo detects the container runtime from the files and
+// environment variables runtimes set, and from the process's cgroup, and
+// reads the process's capabilities, seccomp mode and mounts.
+func readContainerInfo(procRoot, fsRoot string, getenv func(string) string) (*containerInfo, error) {
+	info := &containerInfo{}
+
+	status, err := ioutil.ReadFile(filepath.Join(procRoot, "self", "status"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read the process status: %w", err)
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(status)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "CapEff:":
+			if caps, err := strconv.ParseUint(fields[1], 16, 64); err == nil {
+				info.capIPCLock = caps&(1<<capIPCLock) != 0
+			}
+		case "Seccomp:":
+			info.seccomp, _ = strconv.Atoi(fields[1])
+		}
+	}
+
+	if mountinfo, err := ioutil.ReadFile(filepath.Join(procRoot, "self", "mountinfo")); err == nil {
+		scanner := bufio.NewScanner(strings.NewReader(string(mountinfo)))
+		for scanner.Scan() {
+			// The fifth and sixth fields are the mount point and its options.
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 6 {
+				continue
+			}
+			m := mountEntry{point: fields[4]}
+			for _, opt := range strings.Split(fields[5], ",") {
+				if opt == "ro" {
+					m.readOnly = true
+				}
+			}
+			info.mounts = append(info.mounts, m)
+		}
+	}
+
+	cgroup, _ := ioutil.ReadFile(filepath.Join(procRoot, "1", "cgroup"))
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(fsRoot, name))
+		return err == nil
+	}
+	switch {
+	case getenv("KUBERNETES_SERVICE_HOST") != "" || strings.Contains(string(cgroup), "kubepods"):
+		info.runtime = "kubernetes"
+	case exists("run/.containerenv") || getenv("container") == "podman" || strings.Contains(string(cgroup), "libpod"):
+		info.runtime = "podman"
+	case exists(".dockerenv") || strings.Contains(string(cgroup), "docker"):
+		info.runtime = "docker"
+	case strings.Contains(string(cgroup), "containerd"):
+		info.runtime = "containerd"
+	case strings.Contains(string(cgroup), "lxc"):
+		info.runtime = "lxc"
+	default:
+		info.runtime = getenv("container")
+	}
+	return info, nil
+}
+
+func checkContainer(ctx context.Context, info *containerInfo, disableMlock bool, storagePath string) {
+	testName := "container"
+	SpotInfo(ctx, testName, fmt.Sprintf("running in a %s container", info.runtime))
+
+	var constraints []string
+	root := info.mountFor("/")
+	if root != nil && root.readOnly {
+		constraints = append(constraints, "read-only root filesystem")
+	}
+	if !info.capIPCLock {
+		constraints = append(constraints, "CAP_IPC_LOCK dropped")
+	}
+	switch info.seccomp {
+	case 1:
+		constraints = append(constraints, "seccomp strict mode")
+	case 2:
+		constraints = append(constraints, "seccomp filter")
+	}
+	if len(constraints) > 0 {
+		SpotInfo(ctx, testName, "container constraints: "+strings.Join(constraints, ", "))
+	}
+
+	conflict := false
+	if !disableMlock && !info.capIPCLock {
+		conflict = true
+		SpotWarn(ctx, testName, "mlock is enabled but the container dropped CAP_IPC_LOCK, so the server will fail "+
+			"to lock its memory and won't start",
+			Advice("Add the IPC_LOCK capability, with --cap-add=IPC_LOCK or the pod's securityContext, or set "+
+				"disable_mlock = true."))
+	}
+	if storagePath != "" {
+		if abs, err := filepath.Abs(storagePath); err == nil {
+			if m := info.mountFor(abs); m != nil && m.readOnly {
+				conflict = true
+				SpotWarn(ctx, testName, fmt.Sprintf("the storage path %s is on the read-only mount %s", storagePath, m.point),
+					Advice("Mount a writable volume at the storage path."))
+			}
+		}
+	}
+	if !conflict {
+		SpotOk(ctx, testName, "the container's constraints don't conflict with the configuration")
+	}
+}
@@ -0,0 +1,123 @@
+package diagnose
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadContainerInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	proc := filepath.Join(dir, "proc")
+	root := filepath.Join(dir, "root")
+	for _, d := range []string{filepath.Join(proc, "self"), filepath.Join(proc, "1"), root} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(proc, "self", "status"): "Name:\tvault\nCapEff:\t00000000a80425fb\nSeccomp:\t2\n",
+		filepath.Join(proc, "self", "mountinfo"): "600 500 0:50 / / ro,relatime - overlay overlay rw\n" +
+			"601 600 8:1 /vol /vault/data rw,relatime - ext4 /dev/sda1 rw\n",
+		filepath.Join(proc, "1", "cgroup"): "0::/\n",
+		filepath.Join(root, ".dockerenv"):  "",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(name, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	noEnv := func(string) string { return "" }
+	info, err := readContainerInfo(proc, root, noEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &containerInfo{
+		runtime:    "docker",
+		capIPCLock: false,
+		seccomp:    2,
+		mounts:     []mountEntry{{point: "/", readOnly: true}, {point: "/vault/data"}},
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, info)
+	}
+
+	k8sEnv := func(name string) string {
+		if name == "KUBERNETES_SERVICE_HOST" {
+			return "10.96.0.1"
+		}
+		return ""
+	}
+	if info, err := readContainerInfo(proc, root, k8sEnv); err != nil || info.runtime != "kubernetes" {
+		t.Fatalf("expected kubernetes, got %+v, %v", info, err)
+	}
+
+	os.Remove(filepath.Join(root, ".dockerenv"))
+	if info, err := readContainerInfo(proc, root, noEnv); err != nil || info.runtime != "" {
+		t.Fatalf("expected no container, got %+v, %v", info, err)
+	}
+}
+
+func TestCheckContainer(t *testing.T) {
+	mounts := []mountEntry{{point: "/", readOnly: true}, {point: "/vault/data"}}
+	cases := []struct {
+		name         string
+		info         *containerInfo
+		disableMlock bool
+		storagePath  string
+		expected     []status
+	}{
+		{
+			name:        "no conflicts",
+			info:        &containerInfo{runtime: "docker", capIPCLock: true, mounts: mounts},
+			storagePath: "/vault/data",
+			expected:    []status{InfoStatus, InfoStatus, OkStatus},
+		},
+		{
+			name:     "unconstrained",
+			info:     &containerInfo{runtime: "docker", capIPCLock: true},
+			expected: []status{InfoStatus, OkStatus},
+		},
+		{
+			name:     "mlock without CAP_IPC_LOCK",
+			info:     &containerInfo{runtime: "kubernetes", seccomp: 2},
+			expected: []status{InfoStatus, InfoStatus, WarningStatus},
+		},
+		{
+			name:         "mlock disabled without CAP_IPC_LOCK",
+			info:         &containerInfo{runtime: "kubernetes"},
+			disableMlock: true,
+			expected:     []status{InfoStatus, InfoStatus, OkStatus},
+		},
+		{
+			name:        "storage on read-only root",
+			info:        &containerInfo{runtime: "podman", capIPCLock: true, mounts: mounts},
+			storagePath: "/var/lib/vault",
+			expected:    []status{InfoStatus, InfoStatus, WarningStatus},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkContainer(ctx, tc.info, tc.disableMlock, tc.storagePath)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %d", len(tc.expected), len(results))
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("result %d: expected %s, got %s: %s", i, tc.expected[i], r.Status, r.Message)
+				}
+			}
+		})
+	}
+}
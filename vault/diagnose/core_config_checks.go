@@ -0,0 +1,87 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CoreFeatures is the part of the core configuration whose settings depend on
+// one another. The vault package imports diagnose, so the caller copies these
+// out of its vault.CoreConfig.
+type CoreFeatures struct {
+	StorageType                    string
+	SealType                       string
+	DisableSealWrap                bool
+	DisableCache                   bool
+	CacheSize                      int
+	PluginDirectory                string
+	EnableResponseHeaderRaftNodeID bool
+}
+
+// CoreConfigCheck cross-checks the features enabled in the assembled core
+// configuration against the settings they depend on, which the checks of the
+// individual stanzas don't correlate, and reports the features it validated.
+func CoreConfigCheck(ctx context.Context, f CoreFeatures) {
+	var validated []string
+	ok := true
+
+	if !f.DisableSealWrap {
+		if f.SealType == "shamir" {
+			SpotInfo(ctx, "core-config", "seal wrapping is enabled, but has no effect with the shamir seal; "+
+				"it requires an auto-unseal seal")
+		} else {
+			validated = append(validated, fmt.Sprintf("seal wrapping with the %s seal", f.SealType))
+		}
+	}
+
+	if f.DisableCache {
+		if f.CacheSize != 0 {
+			SpotWarn(ctx, "core-config", fmt.Sprintf("cache_size is set to %d, but disable_cache is set, so the size is ignored",
+				f.CacheSize))
+			ok = false
+		}
+	} else {
+		size := "the default size"
+		if f.CacheSize != 0 {
+			size = fmt.Sprintf("%d entries", f.CacheSize)
+		}
+		validated = append(validated, "the storage cache with "+size)
+	}
+
+	if f.PluginDirectory != "" {
+		fi, err := os.Stat(f.PluginDirectory)
+		switch {
+		case err != nil:
+			SpotError(ctx, "core-config", fmt.Errorf("plugin_directory %s cannot be used, so plugins cannot be registered: %w",
+				f.PluginDirectory, err))
+			ok = false
+		case !fi.IsDir():
+			SpotError(ctx, "core-config", fmt.Errorf("plugin_directory %s is not a directory, so plugins cannot be registered",
+				f.PluginDirectory))
+			ok = false
+		default:
+			validated = append(validated, "plugins from "+f.PluginDirectory)
+		}
+	}
+
+	if f.EnableResponseHeaderRaftNodeID {
+		if f.StorageType != "raft" {
+			SpotWarn(ctx, "core-config", fmt.Sprintf("enable_response_header_raft_node_id is set, but the %s storage "+
+				"backend has no raft node ID, so the header will be empty", f.StorageType))
+			ok = false
+		} else {
+			validated = append(validated, "the raft node ID response header")
+		}
+	}
+
+	if !ok {
+		return
+	}
+	if len(validated) == 0 {
+		SpotOk(ctx, "core-config", "no features with dependencies are enabled")
+		return
+	}
+	SpotOk(ctx, "core-config", "validated "+strings.Join(validated, ", "))
+}
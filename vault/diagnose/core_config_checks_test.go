@@ -0,0 +1,44 @@
+package diagnose
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCoreConfigCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-core-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		name     string
+		features CoreFeatures
+		expected []status
+	}{
+		{"defaults", CoreFeatures{StorageType: "raft", SealType: "shamir"}, []status{InfoStatus, OkStatus}},
+		{"auto-unseal", CoreFeatures{StorageType: "raft", SealType: "awskms", PluginDirectory: dir}, []status{OkStatus}},
+		{"cache size ignored", CoreFeatures{StorageType: "raft", SealType: "awskms", DisableCache: true, CacheSize: 1000}, []status{WarningStatus}},
+		{"missing plugin directory", CoreFeatures{StorageType: "raft", SealType: "awskms", PluginDirectory: filepath.Join(dir, "missing")}, []status{ErrorStatus}},
+		{"raft header without raft", CoreFeatures{StorageType: "consul", SealType: "awskms", EnableResponseHeaderRaftNodeID: true}, []status{WarningStatus}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				CoreConfigCheck(ctx, tc.features)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %#v", len(tc.expected), results)
+			}
+			for i, s := range tc.expected {
+				if results[i].Status != s {
+					t.Fatalf("result %d: expected %s, got %#v", i, s, results[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package diagnose
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// CustomCheckWarnExitCode is the exit code with which a custom check command
+// signals a warning. It matches the exit code diagnose itself uses for warnings.
+const CustomCheckWarnExitCode = 2
+
+// RunCustomCheck runs an operator-supplied shell command as the named diagnose
+// check. An exit code of zero is a success and CustomCheckWarnExitCode is a
+// warning; any other exit code, or a failure to run the command, is an error.
+// The command's trimmed stdout is used as the result message.
+func RunCustomCheck(ctx context.Context, name, command string, timeout time.Duration) error {
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(cctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(cctx, "/bin/sh", "-c", command)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	message := strings.TrimSpace(stdout.String())
+
+	if cctx.Err() == context.DeadlineExceeded {
+		return SpotError(ctx, name, fmt.Errorf("timed out after %s", timeout))
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		SpotOk(ctx, name, message)
+		return nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == CustomCheckWarnExitCode:
+		if message == "" {
+			message = "custom check reported a warning"
+		}
+		SpotWarn(ctx, name, message)
+		return nil
+	case errors.As(err, &exitErr):
+		if message == "" {
+			return SpotError(ctx, name, fmt.Errorf("custom check exited with code %d", exitErr.ExitCode()))
+		}
+		return SpotError(ctx, name, errors.New(message))
+	default:
+		return SpotError(ctx, name, fmt.Errorf("could not run custom check: %w", err))
+	}
+}
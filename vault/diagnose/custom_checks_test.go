@@ -0,0 +1,68 @@
+// +build !windows
+
+package diagnose
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestRunCustomCheck(t *testing.T) {
+	expected := &Result{
+		Name:   "custom",
+		Status: ErrorStatus,
+		Children: []*Result{
+			{
+				Name:    "passes",
+				Status:  OkStatus,
+				Message: "all good",
+			},
+			{
+				Name:    "warns",
+				Status:  WarningStatus,
+				Message: "getting close",
+			},
+			{
+				Name:    "fails",
+				Status:  ErrorStatus,
+				Message: "broken",
+			},
+			{
+				Name:    "hangs",
+				Status:  ErrorStatus,
+				Message: "timed out after 100ms",
+			},
+		},
+	}
+
+	sess := New(os.Stdout)
+	ctx := Context(context.Background(), sess)
+	func() {
+		ctx, span := StartSpan(ctx, "custom")
+		defer span.End()
+
+		checks := []struct {
+			name, command string
+		}{
+			{"passes", "echo all good"},
+			{"warns", "echo getting close; exit 2"},
+			{"fails", "echo broken; exit 1"},
+			{"hangs", "exec sleep 5"},
+		}
+		for _, c := range checks {
+			RunCustomCheck(ctx, c.name, c.command, 100*time.Millisecond)
+		}
+	}()
+
+	results := sess.Finalize(ctx)
+	results.ZeroTimes()
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results, expected), "\n"))
+	}
+}
@@ -0,0 +1,110 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	physDynamoDB "github.com/hashicorp/vault/physical/dynamodb"
+)
+
+// dynamoDBExpiresAttribute is the attribute the HA lock's expiry is written
+// to, in nanoseconds since the epoch.
+const dynamoDBExpiresAttribute = "Expires"
+
+// DynamoDBHAEnabled reports whether the dynamodb backend runs with HA, from
+// DYNAMODB_HA_ENABLED or ha_enabled as the backend reads it.
+func DynamoDBHAEnabled(conf map[string]string) bool {
+	haEnabled := os.Getenv("DYNAMODB_HA_ENABLED")
+	if haEnabled == "" {
+		haEnabled = conf["ha_enabled"]
+	}
+	enabled, _ := strconv.ParseBool(haEnabled)
+	return enabled
+}
+
+// DynamoDBHACheck checks the assumptions the dynamodb backend's HA lock makes
+// about its table and clocks. The lock doesn't use DynamoDB's TTL: a node
+// writes the lock's expiry itself, from its own clock, and another node may
+// take the lock over with a conditional write once its own clock passes that
+// expiry. The check reports the table's TTL configuration, warning when TTL is
+// set on the lock's expiry attribute in the belief that it expires the lock,
+// and warns when this node's clock is far enough from DynamoDB's that it would
+// take over a lock that is still being renewed.
+func DynamoDBHACheck(ctx context.Context, conf map[string]string) error {
+	table := dynamoDBTable(conf)
+	sess, region, err := awsStorageSession(conf, "AWS_DYNAMODB_ENDPOINT", "us-east-1")
+	if err != nil {
+		return err
+	}
+
+	req, out := dynamodb.New(sess).DescribeTimeToLiveRequest(&dynamodb.DescribeTimeToLiveInput{TableName: aws.String(table)})
+	req.SetContext(ctx)
+	sent := time.Now()
+	if err := req.Send(); err != nil {
+		return fmt.Errorf("could not describe the TTL of table %q in region %q: %w", table, region, err)
+	}
+	received := time.Now()
+	checkDynamoDBTTL(ctx, table, out.TimeToLiveDescription)
+
+	// The Date header has a resolution of a second, so the skew is measured
+	// against the middle of the request.
+	date, err := http.ParseTime(req.HTTPResponse.Header.Get("Date"))
+	if err != nil {
+		SpotSkipped(ctx, "dynamodb-clock", SkipNotApplicable, "the DynamoDB response has no Date to compare this node's clock with")
+		return nil
+	}
+	checkDynamoDBClock(ctx, sent.Add(received.Sub(sent)/2).Sub(date))
+	return nil
+}
+
+func checkDynamoDBTTL(ctx context.Context, table string, desc *dynamodb.TimeToLiveDescription) {
+	testName := "dynamodb-ttl"
+	status := dynamodb.TimeToLiveStatusDisabled
+	var attr string
+	if desc != nil {
+		status = aws.StringValue(desc.TimeToLiveStatus)
+		attr = aws.StringValue(desc.AttributeName)
+	}
+	if status == dynamodb.TimeToLiveStatusDisabled || status == dynamodb.TimeToLiveStatusDisabling {
+		SpotOk(ctx, testName, fmt.Sprintf("table %s: TTL %s; the HA lock is expired by Vault from its %s attribute",
+			table, status, dynamoDBExpiresAttribute))
+		return
+	}
+	if attr != dynamoDBExpiresAttribute {
+		SpotInfo(ctx, testName, fmt.Sprintf("table %s: TTL %s on attribute %s, which Vault doesn't write, "+
+			"so it doesn't affect Vault's items or the HA lock", table, status, attr))
+		return
+	}
+	SpotWarn(ctx, testName, fmt.Sprintf("table %s: TTL %s on attribute %s, but Vault writes the HA lock's expiry there "+
+		"in nanoseconds, which TTL treats as too far in the future to expire; the lock is only released by Vault "+
+		"itself, so TTL won't clear a lock left by a failed node", table, status, attr),
+		Advice("Disable TTL on the table; the standby nodes take over an expired lock on their own."))
+}
+
+func checkDynamoDBClock(ctx context.Context, skew time.Duration) {
+	testName := "dynamodb-clock"
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+	// Allow for the Date header's resolution of a second.
+	if abs < physDynamoDB.DynamoDBLockRenewInterval+time.Second {
+		SpotOk(ctx, testName, fmt.Sprintf("this node's clock is within %s of DynamoDB's", abs.Round(time.Millisecond)))
+		return
+	}
+	direction := "ahead of"
+	if skew < 0 {
+		direction = "behind"
+	}
+	SpotWarn(ctx, testName, fmt.Sprintf("this node's clock is %s %s DynamoDB's; the HA lock lasts %s and is renewed "+
+		"every %s from the active node's clock, so a skew this large can let a standby take over a lock that is "+
+		"still held, or delay takeover after the active node fails", abs.Round(time.Millisecond), direction,
+		physDynamoDB.DynamoDBLockTTL, physDynamoDB.DynamoDBLockRenewInterval),
+		Advice("Synchronize the clocks of all Vault nodes with NTP."))
+}
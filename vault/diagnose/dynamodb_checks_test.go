@@ -0,0 +1,68 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestDynamoDBHAEnabled(t *testing.T) {
+	if DynamoDBHAEnabled(map[string]string{}) {
+		t.Fatal("expected HA to be disabled by default")
+	}
+	if !DynamoDBHAEnabled(map[string]string{"ha_enabled": "true"}) {
+		t.Fatal("expected HA to be enabled")
+	}
+}
+
+func TestCheckDynamoDBTTL(t *testing.T) {
+	testCases := []struct {
+		name   string
+		desc   *dynamodb.TimeToLiveDescription
+		status status
+	}{
+		{"not described", nil, OkStatus},
+		{"disabled", &dynamodb.TimeToLiveDescription{TimeToLiveStatus: aws.String(dynamodb.TimeToLiveStatusDisabled)}, OkStatus},
+		{"other attribute", &dynamodb.TimeToLiveDescription{
+			TimeToLiveStatus: aws.String(dynamodb.TimeToLiveStatusEnabled),
+			AttributeName:    aws.String("ttl"),
+		}, InfoStatus},
+		{"lock expiry", &dynamodb.TimeToLiveDescription{
+			TimeToLiveStatus: aws.String(dynamodb.TimeToLiveStatusEnabled),
+			AttributeName:    aws.String("Expires"),
+		}, WarningStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkDynamoDBTTL(ctx, "vault", tc.desc)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
+
+func TestCheckDynamoDBClock(t *testing.T) {
+	testCases := []struct {
+		skew   time.Duration
+		status status
+	}{
+		{0, OkStatus},
+		{-3 * time.Second, OkStatus},
+		{10 * time.Second, WarningStatus},
+		{-10 * time.Second, WarningStatus},
+	}
+	for _, tc := range testCases {
+		results := checkResults(t, func(ctx context.Context) {
+			checkDynamoDBClock(ctx, tc.skew)
+		})
+		if len(results) != 1 || results[0].Status != tc.status {
+			t.Fatalf("%s: expected a %s result, got %#v", tc.skew, tc.status, results)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+// enterpriseOnlyStanzas are top-level configuration stanzas that only
+// Enterprise builds understand. A community build parses them as unknown
+// keys and ignores them.
+var enterpriseOnlyStanzas = []string{"replication", "sentinel", "entropy", "kmip", "control_group"}
+
+// EnterpriseStanzas returns the Enterprise-only stanzas among the unused
+// configuration keys, sorted.
+func EnterpriseStanzas(unused configutil.UnusedKeyMap) []string {
+	var found []string
+	for _, stanza := range enterpriseOnlyStanzas {
+		if _, ok := unused[stanza]; ok {
+			found = append(found, stanza)
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// EditionCheck reports the build edition and confirms the configuration
+// doesn't rely on Enterprise features the build lacks. stanzas names the
+// Enterprise-only settings present in the configuration.
+func EditionCheck(ctx context.Context, stanzas []string) {
+	SpotInfo(ctx, "edition", Edition())
+	if len(stanzas) == 0 {
+		SpotOk(ctx, "enterprise-config", "no Enterprise-only settings configured")
+		return
+	}
+	if !enterpriseBuild {
+		SpotError(ctx, "enterprise-config", fmt.Errorf("%s require an Enterprise build, but this is a %s build, so they are ignored; "+
+			"check that the correct binary was installed", strings.Join(stanzas, ", "), Edition()))
+		return
+	}
+	SpotOk(ctx, "enterprise-config", strings.Join(stanzas, ", "))
+}
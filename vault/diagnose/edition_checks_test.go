@@ -0,0 +1,41 @@
+package diagnose
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+func TestEnterpriseStanzas(t *testing.T) {
+	unused := configutil.UnusedKeyMap{
+		"sentinel":     []token.Pos{{}},
+		"replication":  []token.Pos{{}},
+		"cluster_nmae": []token.Pos{{}},
+	}
+	expected := []string{"replication", "sentinel"}
+	if stanzas := EnterpriseStanzas(unused); !reflect.DeepEqual(stanzas, expected) {
+		t.Fatalf("expected %v, got %v", expected, stanzas)
+	}
+}
+
+func TestEditionCheck(t *testing.T) {
+	results := checkResults(t, func(ctx context.Context) {
+		EditionCheck(ctx, []string{"replication"})
+	})
+	if len(results) != 2 {
+		t.Fatalf("expected two results, got %d", len(results))
+	}
+	if results[0].Name != "edition" || results[0].Status != InfoStatus || results[0].Message != Edition() {
+		t.Fatalf("unexpected edition result: %#v", results[0])
+	}
+	expected := status(ErrorStatus)
+	if enterpriseBuild {
+		expected = OkStatus
+	}
+	if results[1].Status != expected {
+		t.Fatalf("expected enterprise-config status %s, got %s", expected, results[1].Status)
+	}
+}
@@ -0,0 +1,11 @@
+// +build !enterprise
+
+package diagnose
+
+// enterpriseBuild reports whether this binary includes Enterprise features.
+const enterpriseBuild = false
+
+// Edition returns the name of the edition this binary was built as.
+func Edition() string {
+	return "community"
+}
@@ -0,0 +1,100 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// serverEnvPrefixes are the prefixes of the environment variables that the
+// server, its seals or its storage backends read.
+var serverEnvPrefixes = []string{"VAULT_", "AWS_", "GOOGLE_", "AZURE_", "ALICLOUD_", "OCI_", "CONSUL_"}
+
+// clientEnvVars are the variables with a server prefix that only configure the
+// CLI, which are expected to be set in a shell but not in a service unit.
+var clientEnvVars = map[string]bool{
+	api.EnvVaultAddress:       true,
+	api.EnvVaultAgentAddr:     true,
+	api.EnvVaultCACert:        true,
+	api.EnvVaultCAPath:        true,
+	api.EnvVaultClientCert:    true,
+	api.EnvVaultClientKey:     true,
+	api.EnvVaultClientTimeout: true,
+	api.EnvVaultSRVLookup:     true,
+	api.EnvVaultSkipVerify:    true,
+	api.EnvVaultNamespace:     true,
+	api.EnvVaultTLSServerName: true,
+	api.EnvVaultWrapTTL:       true,
+	api.EnvVaultMaxRetries:    true,
+	api.EnvVaultToken:         true,
+	api.EnvVaultMFA:           true,
+	api.EnvRateLimit:          true,
+	"VAULT_FORMAT":            true,
+	"VAULT_CLI_NO_COLOR":      true,
+}
+
+// ExecutionContextCheck reports whether diagnose is running under systemd,
+// detected by the INVOCATION_ID and JOURNAL_STREAM variables it sets, or
+// interactively. A systemd unit doesn't inherit the environment of an
+// operator's shell, so interactively it warns about the server variables set
+// in the shell, which the service won't see unless the unit sets them too.
+// Under systemd it warns when HOME is unset, as credential files under the
+// home directory then aren't found.
+func ExecutionContextCheck(ctx context.Context) {
+	checkExecutionContext(ctx, os.Environ())
+}
+
+func checkExecutionContext(ctx context.Context, environ []string) {
+	testName := "execution-context"
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	var serverVars []string
+	for k := range env {
+		if clientEnvVars[k] {
+			continue
+		}
+		for _, prefix := range serverEnvPrefixes {
+			if strings.HasPrefix(k, prefix) {
+				serverVars = append(serverVars, k)
+				break
+			}
+		}
+	}
+	sort.Strings(serverVars)
+
+	_, invocation := env["INVOCATION_ID"]
+	_, journal := env["JOURNAL_STREAM"]
+	if !invocation && !journal {
+		SpotInfo(ctx, testName, "running interactively, not under systemd")
+		if len(serverVars) > 0 {
+			SpotWarn(ctx, testName, fmt.Sprintf("%s set in this shell, but a systemd unit doesn't inherit the shell's "+
+				"environment, so the service may run without them", strings.Join(serverVars, ", ")),
+				Advice("Set them in the unit with Environment= or EnvironmentFile=, or run diagnose with the unit's "+
+					"environment to reproduce the service's startup."))
+		}
+		return
+	}
+
+	if id := env["INVOCATION_ID"]; id != "" {
+		SpotInfo(ctx, testName, fmt.Sprintf("running under systemd, invocation %s", id))
+	} else {
+		SpotInfo(ctx, testName, "running under systemd")
+	}
+	if len(serverVars) > 0 {
+		SpotInfo(ctx, testName, "server environment variables set: "+strings.Join(serverVars, ", "))
+	}
+	if env["HOME"] == "" {
+		SpotWarn(ctx, testName, "HOME is not set, so credential files under the home directory, such as "+
+			"~/.aws/credentials, won't be found as they are in an interactive shell",
+			Advice("Set User= in the unit, which sets HOME, or set the credentials in the unit's environment."))
+	}
+}
@@ -0,0 +1,35 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExecutionContextCheck(t *testing.T) {
+	testCases := []struct {
+		name     string
+		environ  []string
+		expected []status
+	}{
+		{"interactive", []string{"HOME=/root", "VAULT_ADDR=https://127.0.0.1:8200"}, []status{InfoStatus}},
+		{"interactive with server variables", []string{"HOME=/root", "AWS_REGION=us-east-1", "VAULT_TOKEN=s.x"}, []status{InfoStatus, WarningStatus}},
+		{"systemd", []string{"HOME=/var/lib/vault", "INVOCATION_ID=abc", "JOURNAL_STREAM=8:1234"}, []status{InfoStatus}},
+		{"systemd with server variables", []string{"HOME=/var/lib/vault", "INVOCATION_ID=abc", "VAULT_RAFT_NODE_ID=vault-1"}, []status{InfoStatus, InfoStatus}},
+		{"systemd without HOME", []string{"JOURNAL_STREAM=8:1234"}, []status{InfoStatus, WarningStatus}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkExecutionContext(ctx, tc.environ)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %#v", len(tc.expected), results)
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("expected %s for result %d, got %#v", tc.expected[i], i, r)
+				}
+			}
+		})
+	}
+}
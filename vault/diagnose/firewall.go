@@ -0,0 +1,274 @@
+package diagnose
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+// firewallPort is a port a listener accepts connections on, and the address
+// it was derived from.
+type firewallPort struct {
+	addr string
+	port int
+}
+
+// firewallRule is a rule that filters incoming tcp connections by destination
+// port.
+type firewallRule struct {
+	text    string
+	ports   [][2]int
+	verdict string
+}
+
+func (r firewallRule) matches(port int) bool {
+	for _, p := range r.ports {
+		if port >= p[0] && port <= p[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r firewallRule) blocks() bool {
+	return r.verdict == "drop" || r.verdict == "reject"
+}
+
+// firewallRuleset is the part of the host firewall's rules that can block a
+// listener: the policy for incoming packets, and the rules by port.
+type firewallRuleset struct {
+	source      string
+	inputPolicy string
+	rules       []firewallRule
+}
+
+// FirewallCheck inspects the active rules of the host firewall, as listed by
+// iptables-save or, failing that, nft, for rules that block the ports of tcp
+// listeners and their cluster listeners. A port can bind locally and still be
+// unreachable by clients and peers because of the firewall, which no local
+// bind reveals. Rules are matched by port only, so a rule limited to some
+// sources is reported as possibly blocking.
+func FirewallCheck(ctx context.Context, listeners []*configutil.Listener, disableClustering bool) error {
+	if runtime.GOOS != "linux" {
+		Skipped(ctx, SkipNotApplicablePlatform, "firewall rules are only inspected on Linux")
+		return nil
+	}
+	ports := listenerPorts(listeners, disableClustering)
+	if len(ports) == 0 {
+		Skipped(ctx, SkipNotApplicable, "no tcp listeners are configured")
+		return nil
+	}
+
+	var fw *firewallRuleset
+	var errs []string
+	for _, tool := range []struct {
+		name  string
+		args  []string
+		parse func(string) *firewallRuleset
+	}{
+		{"iptables-save", []string{"-t", "filter"}, parseIptablesSave},
+		{"nft", []string{"list", "ruleset"}, parseNftRuleset},
+	} {
+		if _, err := exec.LookPath(tool.name); err != nil {
+			continue
+		}
+		out, err := exec.CommandContext(ctx, tool.name, tool.args...).Output()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", tool.name, err))
+			continue
+		}
+		fw = tool.parse(string(out))
+		break
+	}
+	switch {
+	case fw == nil && len(errs) == 0:
+		Skipped(ctx, SkipNotApplicable, "neither iptables-save nor nft is installed")
+		return nil
+	case fw == nil:
+		Skipped(ctx, SkipDependencyFailed, "could not read the firewall rules, which usually requires root: "+
+			strings.Join(errs, "; "))
+		return nil
+	}
+	checkFirewall(ctx, ports, fw)
+	return nil
+}
+
+// listenerPorts returns the ports of tcp listeners, and of their cluster
+// listeners, which when not set explicitly use the next port.
+func listenerPorts(listeners []*configutil.Listener, disableClustering bool) []firewallPort {
+	var ports []firewallPort
+	for _, l := range listeners {
+		if l.Type != "tcp" {
+			continue
+		}
+		_, p, err := net.SplitHostPort(l.Address)
+		if err != nil {
+			continue
+		}
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			continue
+		}
+		ports = append(ports, firewallPort{addr: l.Address, port: port})
+		if disableClustering {
+			continue
+		}
+		if l.ClusterAddress == "" {
+			ports = append(ports, firewallPort{addr: l.Address + " (cluster)", port: port + 1})
+		} else if _, p, err := net.SplitHostPort(l.ClusterAddress); err == nil {
+			if port, err := strconv.Atoi(p); err == nil {
+				ports = append(ports, firewallPort{addr: l.ClusterAddress, port: port})
+			}
+		}
+	}
+	return ports
+}
+
+func checkFirewall(ctx context.Context, ports []firewallPort, fw *firewallRuleset) {
+	testName := "firewall"
+	for _, p := range ports {
+		var blocking, accepting []string
+		for _, r := range fw.rules {
+			if !r.matches(p.port) {
+				continue
+			}
+			if r.blocks() {
+				blocking = append(blocking, r.text)
+			} else if r.verdict == "accept" {
+				accepting = append(accepting, r.text)
+			}
+		}
+		switch {
+		case len(blocking) > 0:
+			message := fmt.Sprintf("%s rules may block port %d of %s: %s", fw.source, p.port, p.addr,
+				strings.Join(blocking, "; "))
+			if len(accepting) > 0 {
+				message += fmt.Sprintf(", while other rules accept it: %s", strings.Join(accepting, "; "))
+			}
+			SpotWarn(ctx, testName, message,
+				Advice("Check that the rules only block sources that are not clients or peers of this node."))
+		case len(accepting) > 0:
+			SpotOk(ctx, testName, fmt.Sprintf("%s rules accept port %d of %s: %s", fw.source, p.port, p.addr,
+				strings.Join(accepting, "; ")))
+		case fw.inputPolicy == "drop" || fw.inputPolicy == "reject":
+			SpotWarn(ctx, testName, fmt.Sprintf("the %s policy for incoming packets is %s and no rule accepts port %d "+
+				"of %s", fw.source, fw.inputPolicy, p.port, p.addr),
+				Advice(fmt.Sprintf("Add a rule accepting tcp port %d.", p.port)))
+		default:
+			SpotOk(ctx, testName, fmt.Sprintf("no %s rule filters port %d of %s", fw.source, p.port, p.addr))
+		}
+	}
+}
+
+// parseIptablesSave parses the filter table in the output of iptables-save.
+func parseIptablesSave(out string) *firewallRuleset {
+	fw := &firewallRuleset{source: "iptables"}
+	inFilter := false
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "*"):
+			inFilter = line == "*filter"
+			continue
+		case !inFilter:
+			continue
+		case strings.HasPrefix(line, ":INPUT "):
+			if fields := strings.Fields(line); len(fields) > 1 {
+				fw.inputPolicy = strings.ToLower(fields[1])
+			}
+			continue
+		case !strings.HasPrefix(line, "-A "):
+			continue
+		}
+
+		fields := strings.Fields(line)
+		rule := firewallRule{text: line}
+		tcp := true
+		for i := 0; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "-p", "--protocol":
+				tcp = fields[i+1] == "tcp" || fields[i+1] == "all"
+			case "--dport", "--destination-port", "--dports", "--destination-ports":
+				rule.ports = append(rule.ports, parsePortRanges(fields[i+1], ",", ":")...)
+			case "-j", "--jump":
+				rule.verdict = strings.ToLower(fields[i+1])
+			}
+		}
+		if tcp && len(rule.ports) > 0 {
+			fw.rules = append(fw.rules, rule)
+		}
+	}
+	return fw
+}
+
+// parseNftRuleset parses the output of nft list ruleset, which lists one rule
+// or chain statement per line.
+func parseNftRuleset(out string) *firewallRuleset {
+	fw := &firewallRuleset{source: "nftables"}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.Contains(line, "hook input") {
+			if i := strings.Index(line, "policy "); i >= 0 {
+				if fields := strings.Fields(line[i:]); len(fields) > 1 {
+					fw.inputPolicy = strings.Trim(fields[1], ";")
+				}
+			}
+			continue
+		}
+
+		fields := strings.Fields(strings.NewReplacer("{", " { ", "}", " } ", ",", " ").Replace(line))
+		rule := firewallRule{text: line}
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] != "dport" || (i > 0 && fields[i-1] != "tcp" && fields[i-1] != "th") {
+				continue
+			}
+			if fields[i+1] != "{" {
+				rule.ports = append(rule.ports, parsePortRanges(fields[i+1], ",", "-")...)
+				continue
+			}
+			for j := i + 2; j < len(fields) && fields[j] != "}"; j++ {
+				rule.ports = append(rule.ports, parsePortRanges(fields[j], ",", "-")...)
+			}
+		}
+		for _, f := range fields {
+			switch f {
+			case "accept", "drop", "reject":
+				rule.verdict = f
+			}
+		}
+		if len(rule.ports) > 0 && rule.verdict != "" {
+			fw.rules = append(fw.rules, rule)
+		}
+	}
+	return fw
+}
+
+// parsePortRanges parses a list of ports and port ranges, ignoring service
+// names and anything else that isn't a number.
+func parsePortRanges(s, listSep, rangeSep string) [][2]int {
+	var ranges [][2]int
+	for _, part := range strings.Split(s, listSep) {
+		bounds := strings.SplitN(part, rangeSep, 2)
+		low, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			continue
+		}
+		high := low
+		if len(bounds) == 2 {
+			if high, err = strconv.Atoi(bounds[1]); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, [2]int{low, high})
+	}
+	return ranges
+}
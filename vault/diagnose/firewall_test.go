@@ -0,0 +1,136 @@
+package diagnose
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+const testIptablesSave = `# Generated by iptables-save v1.8.7
+*nat
+:PREROUTING ACCEPT [0:0]
+-A PREROUTING -p tcp --dport 8200 -j DNAT --to-destination 10.0.0.2
+COMMIT
+*filter
+:INPUT DROP [0:0]
+:FORWARD DROP [0:0]
+:OUTPUT ACCEPT [0:0]
+-A INPUT -m state --state RELATED,ESTABLISHED -j ACCEPT
+-A INPUT -p tcp -m tcp --dport 8200 -j ACCEPT
+-A INPUT -p tcp -m multiport --dports 8300:8400,9000 -j REJECT --reject-with tcp-reset
+-A INPUT -p udp -m udp --dport 8201 -j DROP
+COMMIT
+`
+
+const testNftRuleset = `table inet filter {
+	chain input {
+		type filter hook input priority filter; policy drop;
+		ct state established,related accept
+		tcp dport { 22, 8200 } accept
+		tcp dport 8300-8400 drop
+		udp dport 8201 drop
+	}
+}
+`
+
+func TestParseIptablesSave(t *testing.T) {
+	fw := parseIptablesSave(testIptablesSave)
+	if fw.inputPolicy != "drop" {
+		t.Fatalf("expected policy drop, got %q", fw.inputPolicy)
+	}
+	expected := []firewallRule{
+		{text: "-A INPUT -p tcp -m tcp --dport 8200 -j ACCEPT", ports: [][2]int{{8200, 8200}}, verdict: "accept"},
+		{text: "-A INPUT -p tcp -m multiport --dports 8300:8400,9000 -j REJECT --reject-with tcp-reset",
+			ports: [][2]int{{8300, 8400}, {9000, 9000}}, verdict: "reject"},
+	}
+	if !reflect.DeepEqual(fw.rules, expected) {
+		t.Fatalf("unexpected rules: %+v", fw.rules)
+	}
+}
+
+func TestParseNftRuleset(t *testing.T) {
+	fw := parseNftRuleset(testNftRuleset)
+	if fw.inputPolicy != "drop" {
+		t.Fatalf("expected policy drop, got %q", fw.inputPolicy)
+	}
+	expected := []firewallRule{
+		{text: "tcp dport { 22, 8200 } accept", ports: [][2]int{{22, 22}, {8200, 8200}}, verdict: "accept"},
+		{text: "tcp dport 8300-8400 drop", ports: [][2]int{{8300, 8400}}, verdict: "drop"},
+	}
+	if !reflect.DeepEqual(fw.rules, expected) {
+		t.Fatalf("unexpected rules: %+v", fw.rules)
+	}
+}
+
+func TestListenerPorts(t *testing.T) {
+	listeners := []*configutil.Listener{
+		{Type: "tcp", Address: "0.0.0.0:8200"},
+		{Type: "tcp", Address: "127.0.0.1:8300", ClusterAddress: "127.0.0.1:9000"},
+		{Type: "unix", Address: "/run/vault.sock"},
+	}
+	expected := []firewallPort{
+		{addr: "0.0.0.0:8200", port: 8200},
+		{addr: "0.0.0.0:8200 (cluster)", port: 8201},
+		{addr: "127.0.0.1:8300", port: 8300},
+		{addr: "127.0.0.1:9000", port: 9000},
+	}
+	if ports := listenerPorts(listeners, false); !reflect.DeepEqual(ports, expected) {
+		t.Fatalf("unexpected ports: %+v", ports)
+	}
+	if ports := listenerPorts(listeners, true); len(ports) != 2 {
+		t.Fatalf("expected no cluster ports with clustering disabled, got %+v", ports)
+	}
+}
+
+func TestCheckFirewall(t *testing.T) {
+	ports := []firewallPort{
+		{addr: "0.0.0.0:8200", port: 8200},
+		{addr: "0.0.0.0:8200 (cluster)", port: 8201},
+		{addr: "127.0.0.1:8350", port: 8350},
+	}
+	cases := []struct {
+		name     string
+		fw       *firewallRuleset
+		expected []status
+		contains string
+	}{
+		{
+			name:     "iptables",
+			fw:       parseIptablesSave(testIptablesSave),
+			expected: []status{OkStatus, WarningStatus, WarningStatus},
+			contains: "--dports 8300:8400,9000 -j REJECT",
+		},
+		{
+			name:     "nftables",
+			fw:       parseNftRuleset(testNftRuleset),
+			expected: []status{OkStatus, WarningStatus, WarningStatus},
+			contains: "tcp dport 8300-8400 drop",
+		},
+		{
+			name:     "no rules",
+			fw:       parseIptablesSave("*filter\n:INPUT ACCEPT [0:0]\nCOMMIT\n"),
+			expected: []status{OkStatus, OkStatus, OkStatus},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkFirewall(ctx, ports, tc.fw)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %d", len(tc.expected), len(results))
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("result %d: expected %s, got %s: %s", i, tc.expected[i], r.Status, r.Message)
+				}
+			}
+			if tc.contains != "" && !strings.Contains(results[2].Message, tc.contains) {
+				t.Fatalf("expected the blocking rule in %q", results[2].Message)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package diagnose
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -20,11 +21,16 @@ const (
 	spotCheckWarnEventName    = "spot-check-warn"
 	spotCheckErrorEventName   = "spot-check-error"
 	spotCheckSkippedEventName = "spot-check-skipped"
+	spotCheckInfoEventName    = "spot-check-info"
 	adviceEventName           = "advice"
+	metricEventName           = "metric"
 	errorMessageKey           = attribute.Key("error.message")
 	nameKey                   = attribute.Key("name")
 	messageKey                = attribute.Key("message")
 	adviceKey                 = attribute.Key("advice")
+	skipReasonKey             = attribute.Key("skip.reason")
+	metricValueKey            = attribute.Key("metric.value")
+	metricUnitKey             = attribute.Key("metric.unit")
 )
 
 var (
@@ -64,6 +70,12 @@ func New(w io.Writer) *Session {
 	return sess
 }
 
+// SetColor sets whether progress is written with colored status prefixes.
+// Color is on by default.
+func (s *Session) SetColor(color bool) {
+	s.tc.color = color
+}
+
 func (s *Session) SetSkipList(ls []string) {
 	for _, e := range ls {
 		s.skip[e] = true
@@ -128,10 +140,35 @@ func Error(ctx context.Context, err error, options ...trace.EventOption) error {
 	return err
 }
 
+// SkipReason is a machine-readable code for why a check was skipped,
+// accompanying the human readable message.
+type SkipReason string
+
+const (
+	// SkipRequested is for checks named by -skip.
+	SkipRequested SkipReason = "user-requested-skip"
+
+	// SkipStanzaAbsent is for checks of a configuration stanza or setting
+	// that is not present.
+	SkipStanzaAbsent SkipReason = "stanza-absent"
+
+	// SkipNotApplicable is for checks that don't apply to the configuration,
+	// such as pool settings of a backend without a connection pool.
+	SkipNotApplicable SkipReason = "not-applicable"
+
+	// SkipNotApplicablePlatform is for checks unsupported on this platform or
+	// build.
+	SkipNotApplicablePlatform SkipReason = "not-applicable-platform"
+
+	// SkipDependencyFailed is for checks that could not run because something
+	// they depend on failed or was unavailable.
+	SkipDependencyFailed SkipReason = "dependency-failed"
+)
+
 // Skipped marks the current span skipped
-func Skipped(ctx context.Context, message string) {
+func Skipped(ctx context.Context, reason SkipReason, message string) {
 	span := trace.SpanFromContext(ctx)
-	span.AddEvent(skippedEventName)
+	span.AddEvent(skippedEventName, trace.WithAttributes(skipReasonKey.String(string(reason))))
 	span.SetStatus(codes.Error, message)
 }
 
@@ -165,10 +202,27 @@ func SpotError(ctx context.Context, checkName string, err error, options ...trac
 }
 
 // SpotSkipped adds a Skipped result without adding a new Span.
-func SpotSkipped(ctx context.Context, checkName, message string, options ...trace.EventOption) {
+func SpotSkipped(ctx context.Context, checkName string, reason SkipReason, message string, options ...trace.EventOption) {
+	options = append(options, trace.WithAttributes(skipReasonKey.String(string(reason))))
 	addSpotCheckResult(ctx, spotCheckSkippedEventName, checkName, message, options...)
 }
 
+// SpotInfo adds a purely informational result, such as an effective
+// configuration value, without adding a new Span. Info results are neutral:
+// they count as neither a pass nor a problem.
+func SpotInfo(ctx context.Context, checkName, message string, options ...trace.EventOption) {
+	addSpotCheckResult(ctx, spotCheckInfoEventName, checkName, message, options...)
+}
+
+// RecordMetric records a measurement a check took, such as a latency or a
+// rate, on the current span. Metrics are reported with the span's result, apart
+// from its message, so that they can be tabulated and compared across runs.
+func RecordMetric(ctx context.Context, name string, value float64, unit string) {
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent(metricEventName, trace.WithAttributes(nameKey.String(name), metricValueKey.Float64(value),
+		metricUnitKey.String(unit)))
+}
+
 // Advice builds an EventOption containing advice message.  Use to add to spot results.
 func Advice(message string) trace.EventOption {
 	return trace.WithAttributes(adviceKey.String(message))
@@ -200,12 +254,21 @@ func SpotCheck(ctx context.Context, checkName string, f func() error) error {
 	return nil
 }
 
+// DeadlineMessage is the message for checks that did not start or did not
+// finish before the context's deadline, such as the one set by -deadline.
+const DeadlineMessage = "timed out: the deadline for the run passed before this check completed"
+
 // Test creates a new named span, and executes the provided function within it.  If the function returns an error,
-// the span is considered to have failed.
+// the span is considered to have failed. If the context's deadline has already passed, the function is not run
+// and the span fails as timed out.
 func Test(ctx context.Context, spanName string, function testFunction, options ...trace.SpanOption) error {
 	ctx, span := StartSpan(ctx, spanName, options...)
 	defer span.End()
 
+	if ctx.Err() == context.DeadlineExceeded {
+		span.SetStatus(codes.Error, DeadlineMessage)
+		return errors.New(DeadlineMessage)
+	}
 	err := function(ctx)
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
@@ -214,24 +277,33 @@ func Test(ctx context.Context, spanName string, function testFunction, options .
 }
 
 // WithTimeout wraps a context consuming function, and when called, returns an error if the sub-function does not
-// complete within the timeout, e.g.
+// complete within the timeout, or before the context is done, e.g.
 //
 // diagnose.Test(ctx, "my-span", diagnose.WithTimeout(5 * time.Second, myTestFunc))
 func WithTimeout(d time.Duration, f testFunction) testFunction {
 	return func(ctx context.Context) error {
-		rch := make(chan error)
+		rch := make(chan error, 1)
 		t := time.NewTimer(d)
 		defer t.Stop()
 		go func() { rch <- f(ctx) }()
 		select {
 		case <-t.C:
 			return fmt.Errorf("timed out after %s", d.String())
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return errors.New(DeadlineMessage)
+			}
+			return ctx.Err()
 		case err := <-rch:
 			return err
 		}
 	}
 }
 
+// RequestedSkipMessage is the message for checks skipped because they were
+// named by -skip.
+const RequestedSkipMessage = "skipped as requested"
+
 // Skippable wraps a Test function with logic that will not run the test if the skipName
 // was in the session's skip list
 func Skippable(skipName string, f testFunction) testFunction {
@@ -241,9 +313,26 @@ func Skippable(skipName string, f testFunction) testFunction {
 			if !session.IsSkipped(skipName) {
 				return f(ctx)
 			} else {
-				Skipped(ctx, "skipped as requested")
+				Skipped(ctx, SkipRequested, RequestedSkipMessage)
 			}
 		}
 		return nil
 	}
 }
+
+// PartialConfigSkipMessage is the message for checks skipped because the
+// stanza they need is absent from a partial configuration.
+const PartialConfigSkipMessage = "stanza not present in partial config"
+
+// RequiresStanza wraps a Test function with logic that will not run the test,
+// marking it skipped instead, if the configuration stanza it needs is not
+// present. Use it only when a partial configuration has been requested.
+func RequiresStanza(present bool, f testFunction) testFunction {
+	return func(ctx context.Context) error {
+		if !present {
+			Skipped(ctx, SkipStanzaAbsent, PartialConfigSkipMessage)
+			return nil
+		}
+		return f(ctx)
+	}
+}
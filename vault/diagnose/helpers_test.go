@@ -2,12 +2,14 @@ package diagnose
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"github.com/go-test/deep"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 const getMoreCoffee = "You'll find more coffee in the freezer door, or consider buying more for the office."
@@ -35,9 +37,10 @@ func TestDiagnoseOtelResults(t *testing.T) {
 				Message: "no scones",
 			},
 			{
-				Name:    "dispose-grounds",
-				Status:  SkippedStatus,
-				Message: "skipped as requested",
+				Name:       "dispose-grounds",
+				Status:     SkippedStatus,
+				Message:    "skipped as requested",
+				SkipReason: SkipRequested,
 			},
 		},
 	}
@@ -105,3 +108,222 @@ func disposeGrounds(_ context.Context) error {
 	//Done!
 	return nil
 }
+
+func TestSpotInfoIsNeutral(t *testing.T) {
+	results := checkResults(t, func(ctx context.Context) {
+		SpotInfo(ctx, "edition", "community")
+		SpotOk(ctx, "grind-beans", "")
+	})
+	expected := []*Result{
+		{Name: "edition", Status: InfoStatus, Message: "community"},
+		{Name: "grind-beans", Status: OkStatus},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results, expected), "\n"))
+	}
+
+	root := &Result{Name: "make-coffee", Children: results}
+	if status := root.finalize(); status != OkStatus {
+		t.Fatalf("info results should not affect the overall status, got %s", status)
+	}
+	summary := root.Summarize()
+	if summary != (Summary{Ok: 1, Info: 1}) {
+		t.Fatalf("unexpected summary: %s", summary)
+	}
+}
+
+func TestRequiresStanza(t *testing.T) {
+	sess := New(os.Stdout)
+	ctx := Context(context.Background(), sess)
+	ran := false
+	func() {
+		ctx, span := StartSpan(ctx, "make-coffee")
+		defer span.End()
+		Test(ctx, "steam-milk", RequiresStanza(false, func(ctx context.Context) error {
+			ran = true
+			return nil
+		}))
+	}()
+	if ran {
+		t.Fatal("test ran without its stanza")
+	}
+	results := sess.Finalize(ctx)
+	results.ZeroTimes()
+	expected := []*Result{{Name: "steam-milk", Status: SkippedStatus, Message: PartialConfigSkipMessage, SkipReason: SkipStanzaAbsent}}
+	if !reflect.DeepEqual(results.Children, expected) {
+		t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results.Children, expected), "\n"))
+	}
+}
+
+func TestSpotSkippedReason(t *testing.T) {
+	results := checkResults(t, func(ctx context.Context) {
+		SpotSkipped(ctx, "froth-milk", SkipDependencyFailed, "the steam wand is broken")
+	})
+	expected := []*Result{{Name: "froth-milk", Status: SkippedStatus, Message: "the steam wand is broken", SkipReason: SkipDependencyFailed}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results, expected), "\n"))
+	}
+	out, err := json.Marshal(results[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"skip_reason":"dependency-failed"`) {
+		t.Fatalf("skip reason missing from JSON: %s", out)
+	}
+}
+
+func TestSummaryHealthScore(t *testing.T) {
+	testCases := []struct {
+		summary  Summary
+		expected int
+	}{
+		{Summary{}, 100},
+		{Summary{Ok: 10, Info: 3, Skipped: 2}, 100},
+		{Summary{Ok: 9, Warnings: 2}, 90},
+		{Summary{Ok: 8, Errors: 1}, 66},
+		{Summary{Errors: 3}, 0},
+	}
+	for _, tc := range testCases {
+		if score := tc.summary.HealthScore(); score != tc.expected {
+			t.Errorf("%s: expected a score of %d, got %d", tc.summary, tc.expected, score)
+		}
+	}
+}
+
+func TestResultLabels(t *testing.T) {
+	r := &Result{Name: "diagnose", Status: OkStatus}
+	out, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), `"labels"`) {
+		t.Fatalf("expected no labels in JSON: %s", out)
+	}
+
+	r.Labels = map[string]string{"datacenter": "east", "deploy": "1234"}
+	if out, err = json.Marshal(r); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"labels":{"datacenter":"east","deploy":"1234"}`) {
+		t.Fatalf("labels missing from JSON: %s", out)
+	}
+}
+
+func TestRecordMetric(t *testing.T) {
+	results := checkResults(t, func(ctx context.Context) {
+		Test(ctx, "brew", func(ctx context.Context) error {
+			RecordMetric(ctx, "brew-time", 240.5, "s")
+			return nil
+		})
+	})
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	expected := []Metric{{Name: "brew-time", Value: 240.5, Unit: "s"}}
+	if !reflect.DeepEqual(results[0].Metrics, expected) {
+		t.Fatalf("expected metrics %v, got %v", expected, results[0].Metrics)
+	}
+}
+
+func TestWriteColor(t *testing.T) {
+	r := &Result{
+		Name:   "make-coffee",
+		Status: WarningStatus,
+		Children: []*Result{
+			{Name: "grind-beans", Status: OkStatus},
+			{Name: "brew", Status: WarningStatus, Message: "out of filters"},
+		},
+	}
+
+	var sb strings.Builder
+	if err := r.WriteColor(&sb, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := "[ warn ] make-coffee\n  [  ok  ] grind-beans\n  [ warn ] brew: out of filters\n"
+	if sb.String() != expected {
+		t.Fatalf("unexpected uncolored output:\n%q", sb.String())
+	}
+
+	sb.Reset()
+	if err := r.WriteColor(&sb, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), status_ok+"grind-beans") {
+		t.Fatalf("expected colored output, got:\n%q", sb.String())
+	}
+}
+
+func TestWriteSectionElapsed(t *testing.T) {
+	r := &Result{
+		Name:   "make-coffee",
+		Status: OkStatus,
+		Children: []*Result{
+			{Name: "brew", Status: OkStatus, elapsed: 12345 * time.Millisecond, Children: []*Result{
+				{Name: "pour", Status: OkStatus, elapsed: time.Second},
+			}},
+			{Name: "grind-beans", Status: OkStatus, elapsed: 1500 * time.Microsecond},
+			{Name: "sip", Status: OkStatus},
+		},
+	}
+	var sb strings.Builder
+	if err := r.WriteColor(&sb, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := "[  ok  ] make-coffee\n  [  ok  ] brew (12.3s)\n    [  ok  ] pour\n  [  ok  ] grind-beans (2ms)\n  [  ok  ] sip\n"
+	if sb.String() != expected {
+		t.Fatalf("unexpected output:\n%q", sb.String())
+	}
+}
+
+func TestResultFind(t *testing.T) {
+	pour := &Result{Name: "pour", Status: WarningStatus}
+	r := &Result{
+		Name: "make-coffee",
+		Children: []*Result{
+			{Name: "grind-beans", Status: OkStatus},
+			{Name: "brew", Status: WarningStatus, Children: []*Result{pour}},
+		},
+	}
+	if found := r.Find("pour"); found != pour {
+		t.Fatalf("expected the pour result, got %#v", found)
+	}
+	if found := r.Find("make-coffee"); found != r {
+		t.Fatalf("expected the root result, got %#v", found)
+	}
+	if found := r.Find("sip"); found != nil {
+		t.Fatalf("expected no result, got %#v", found)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	sess := New(os.Stdout)
+	ctx := Context(context.Background(), sess)
+	deadlineCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	ran := false
+	func() {
+		ctx, span := StartSpan(deadlineCtx, "make-coffee")
+		defer span.End()
+		Test(ctx, "brew", WithTimeout(time.Minute, func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}))
+		Test(ctx, "pour", func(ctx context.Context) error {
+			ran = true
+			return nil
+		})
+	}()
+	if ran {
+		t.Fatal("test ran after the deadline")
+	}
+	results := sess.Finalize(ctx)
+	results.ZeroTimes()
+	expected := []*Result{
+		{Name: "brew", Status: ErrorStatus, Message: DeadlineMessage},
+		{Name: "pour", Status: ErrorStatus, Message: DeadlineMessage},
+	}
+	if !reflect.DeepEqual(results.Children, expected) {
+		t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results.Children, expected), "\n"))
+	}
+}
@@ -0,0 +1,129 @@
+package diagnose
+
+import (
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/version"
+)
+
+// htmlReport is the data the HTML report template renders.
+type htmlReport struct {
+	Version     string
+	Generated   time.Time
+	Root        *Result
+	Summary     Summary
+	HealthScore int
+	Timings     []htmlTiming
+	Metrics     []CheckMetric
+}
+
+// htmlTiming is a row of the timings table: when a section started, relative
+// to the start of the run, and how long it took.
+type htmlTiming struct {
+	Name    string
+	Status  status
+	Offset  time.Duration
+	Elapsed time.Duration
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"class": func(s status) string {
+		switch s {
+		case ErrorStatus:
+			return "error"
+		case WarningStatus:
+			return "warn"
+		case OkStatus:
+			return "ok"
+		case SkippedStatus:
+			return "skipped"
+		}
+		return "info"
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Vault Diagnose Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #1f2328; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #d0d7de; padding: 0.3em 0.8em; text-align: left; }
+details { margin-left: 1.5em; }
+summary { cursor: pointer; }
+.leaf { margin-left: 2.6em; }
+.status { display: inline-block; width: 4.5em; font-family: monospace; font-weight: bold; }
+.ok { color: #1a7f37; } .warn { color: #9a6700; } .error { color: #cf222e; }
+.skipped { color: #6e7781; } .info { color: #0969da; }
+.message { color: #57606a; }
+.advice { color: #57606a; font-style: italic; margin-left: 6em; }
+</style>
+</head>
+<body>
+<h1>Vault Diagnose Report</h1>
+<p>{{.Version}}, generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}</p>
+<h2>Summary</h2>
+<table>
+<tr><th>Health score</th><td>{{.HealthScore}}</td></tr>
+<tr><th class="ok">Ok</th><td>{{.Summary.Ok}}</td></tr>
+<tr><th class="info">Info</th><td>{{.Summary.Info}}</td></tr>
+<tr><th class="warn">Warnings</th><td>{{.Summary.Warnings}}</td></tr>
+<tr><th class="error">Errors</th><td>{{.Summary.Errors}}</td></tr>
+<tr><th class="skipped">Skipped</th><td>{{.Summary.Skipped}}</td></tr>
+</table>
+<h2>Results</h2>
+{{template "result" .Root}}
+{{if .Timings}}<h2>Timings</h2>
+<table>
+<tr><th>Section</th><th>Status</th><th>Started after</th><th>Took</th></tr>
+{{range .Timings}}<tr><td>{{.Name}}</td><td class="{{class .Status}}">{{.Status}}</td><td>{{.Offset}}</td><td>{{if .Elapsed}}{{.Elapsed}}{{end}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Metrics}}<h2>Metrics</h2>
+<table>
+<tr><th>Check</th><th>Metric</th><th>Value</th></tr>
+{{range .Metrics}}<tr><td>{{.Check}}</td><td>{{.Name}}</td><td>{{printf "%.2f" .Value}} {{.Unit}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+{{define "line"}}<span class="status {{class .Status}}">{{.Status}}</span> {{.Name}}{{if .Message}}<span class="message">: {{.Message}}</span>{{end}}{{end}}
+{{define "details"}}{{range .Warnings}}<div class="leaf"><span class="status warn">warn</span> {{.}}</div>
+{{end}}{{if .Advice}}<div class="advice">{{.Advice}}</div>
+{{end}}{{end}}
+{{define "result"}}{{if .Children}}<details{{if ne (class .Status) "ok"}} open{{end}}><summary>{{template "line" .}}</summary>
+{{template "details" .}}{{range .Children}}{{template "result" .}}{{end}}</details>
+{{else}}<div class="leaf">{{template "line" .}}</div>
+{{template "details" .}}{{end}}{{end}}`))
+
+// WriteHTML renders the results as a self-contained HTML report, with
+// collapsible sections, the summary counts, the start time and duration of
+// each section and the metrics the checks recorded.
+// Sections that did not pass start expanded. It uses no external assets, so
+// the report can be shared as a single file.
+func (r *Result) WriteHTML(w io.Writer) error {
+	summary := r.Summarize()
+	report := htmlReport{
+		Version:     version.GetVersion().FullVersionNumber(true),
+		Generated:   time.Now(),
+		Root:        r,
+		Summary:     summary,
+		HealthScore: summary.HealthScore(),
+	}
+	if r.HealthScore != nil {
+		report.HealthScore = *r.HealthScore
+	}
+	for _, c := range r.Children {
+		if c.Time.IsZero() || r.Time.IsZero() {
+			continue
+		}
+		report.Timings = append(report.Timings, htmlTiming{
+			Name:    c.Name,
+			Status:  c.Status,
+			Offset:  c.Time.Sub(r.Time).Round(time.Millisecond),
+			Elapsed: roundElapsed(c.elapsed),
+		})
+	}
+	report.Metrics = r.AllMetrics()
+	return htmlTemplate.Execute(w, report)
+}
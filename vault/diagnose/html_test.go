@@ -0,0 +1,58 @@
+package diagnose
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteHTML(t *testing.T) {
+	start := time.Now()
+	r := &Result{
+		Name:   "initialization",
+		Status: WarningStatus,
+		Time:   start,
+		Children: []*Result{
+			{
+				Name:    "grind-beans",
+				Status:  OkStatus,
+				Time:    start.Add(time.Second),
+				Metrics: []Metric{{Name: "grind-time", Value: 812.5, Unit: "ms"}},
+				elapsed: 1234 * time.Millisecond,
+			},
+			{
+				Name:   "brew",
+				Status: WarningStatus,
+				Time:   start.Add(2 * time.Second),
+				Children: []*Result{
+					{Name: "filters", Status: WarningStatus, Message: "running low on <filters>", Advice: "buy more"},
+				},
+			},
+		},
+	}
+
+	var sb strings.Builder
+	if err := r.WriteHTML(&sb); err != nil {
+		t.Fatal(err)
+	}
+	out := sb.String()
+	for _, expected := range []string{
+		"<!DOCTYPE html>",
+		`<tr><th class="warn">Warnings</th><td>1</td></tr>`,
+		"running low on &lt;filters&gt;",
+		"buy more",
+		"<details open><summary>",
+		"<td>brew</td><td class=\"warn\">warn</td><td>2s</td><td></td>",
+		"<td>grind-beans</td><td class=\"ok\">ok</td><td>1s</td><td>1.2s</td>",
+		"<td>grind-beans</td><td>grind-time</td><td>812.50 ms</td>",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected the report to contain %q:\n%s", expected, out)
+		}
+	}
+	for _, external := range []string{"<script src", "<link", "\u001b["} {
+		if strings.Contains(out, external) {
+			t.Fatalf("expected a self-contained report without %q:\n%s", external, out)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// RaftNodeIDCheck compares the raft node ID the configuration gives this node
+// with the one persisted in the raft path on an earlier start. Raft storage
+// takes the ID from VAULT_RAFT_NODE_ID, then node_id, and otherwise generates
+// one and persists it to the node-id file. Once the node is part of a
+// cluster, the raft configuration lists it by that ID, so setting a
+// different one later makes the node a stranger to its own cluster: it isn't
+// a voter under the new ID, and its old ID remains a peer that never answers.
+func RaftNodeIDCheck(ctx context.Context, conf map[string]string) error {
+	return checkRaftNodeID(ctx, conf, os.Getenv)
+}
+
+func checkRaftNodeID(ctx context.Context, conf map[string]string, getenv func(string) string) error {
+	testName := "raft-node-id"
+	configured, source := getenv("VAULT_RAFT_NODE_ID"), "VAULT_RAFT_NODE_ID"
+	if configured == "" {
+		configured, source = conf["node_id"], "node_id"
+	}
+
+	idPath := filepath.Join(conf["path"], "node-id")
+	raw, err := ioutil.ReadFile(idPath)
+	switch {
+	case os.IsNotExist(err):
+		if configured == "" {
+			SpotInfo(ctx, testName, fmt.Sprintf("no node ID is configured or persisted in %s yet; one will be "+
+				"generated on the first start", idPath))
+			return nil
+		}
+		SpotOk(ctx, testName, fmt.Sprintf("the node ID %s (from %s) is used; a configured ID isn't persisted, so "+
+			"there is no earlier ID to compare it with", configured, source))
+		return nil
+	case err != nil:
+		return SpotError(ctx, testName, fmt.Errorf("could not read the persisted node ID: %w", err))
+	}
+	persisted := strings.TrimSpace(string(raw))
+
+	switch {
+	case configured == "":
+		SpotOk(ctx, testName, fmt.Sprintf("the node ID %s persisted in %s is used", persisted, idPath))
+	case configured == persisted:
+		SpotOk(ctx, testName, fmt.Sprintf("the node ID %s (from %s) matches the one persisted in %s", configured, source, idPath))
+	default:
+		SpotWarn(ctx, testName, fmt.Sprintf("%s sets the node ID %s, but the node started as %s, persisted in %s; "+
+			"if it joined a cluster as %s, the cluster won't recognize it under the new ID", source, configured,
+			persisted, idPath, persisted),
+			Advice(fmt.Sprintf("Set %s back to %s, or remove the node from the cluster, clear its raft data and "+
+				"rejoin it under the new ID.", source, persisted)))
+	}
+	return nil
+}
+
+// ClusterNameLiveCheck compares cluster_name in the configuration with the
+// name the running cluster reports. The name is persisted in storage when the
+// cluster is first set up, and the stored name takes precedence, so a
+// cluster_name changed afterward is silently ignored.
+func ClusterNameLiveCheck(ctx context.Context, client *api.Client, configured string) error {
+	health, err := client.Sys().Health()
+	if err != nil {
+		return fmt.Errorf("could not read the cluster name from sys/health: %w", err)
+	}
+	checkClusterName(ctx, configured, health.ClusterName)
+	return nil
+}
+
+func checkClusterName(ctx context.Context, configured, persisted string) {
+	testName := "cluster-name"
+	switch {
+	case persisted == "":
+		// Sealed servers can't read the name from storage.
+		Skipped(ctx, SkipNotApplicable, "the server didn't report its cluster name; it may be sealed")
+	case configured == "":
+		SpotOk(ctx, testName, fmt.Sprintf("the cluster is named %s; cluster_name is not set", persisted))
+	case configured == persisted:
+		SpotOk(ctx, testName, fmt.Sprintf("cluster_name %s matches the name persisted by the cluster", configured))
+	default:
+		SpotWarn(ctx, testName, fmt.Sprintf("cluster_name is %s, but the cluster's name was persisted as %s when it "+
+			"was first set up, and the persisted name takes precedence, so cluster_name is ignored", configured, persisted),
+			Advice(fmt.Sprintf("Set cluster_name to %s, so that the configuration matches the name in metrics and "+
+				"responses.", persisted)))
+	}
+}
@@ -0,0 +1,86 @@
+package diagnose
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckRaftNodeID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-node-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	persisted, err := ioutil.TempDir("", "diagnose-node-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(persisted)
+	if err := ioutil.WriteFile(filepath.Join(persisted, "node-id"), []byte("node-a"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	envID := func(id string) func(string) string {
+		return func(name string) string {
+			if name == "VAULT_RAFT_NODE_ID" {
+				return id
+			}
+			return ""
+		}
+	}
+	cases := []struct {
+		name     string
+		conf     map[string]string
+		getenv   func(string) string
+		expected status
+	}{
+		{"first start", map[string]string{"path": dir}, envID(""), InfoStatus},
+		{"configured, nothing persisted", map[string]string{"path": dir, "node_id": "node-a"}, envID(""), OkStatus},
+		{"persisted", map[string]string{"path": persisted}, envID(""), OkStatus},
+		{"configured matches", map[string]string{"path": persisted, "node_id": "node-a"}, envID(""), OkStatus},
+		{"configured differs", map[string]string{"path": persisted, "node_id": "node-b"}, envID(""), WarningStatus},
+		{"environment differs", map[string]string{"path": persisted, "node_id": "node-a"}, envID("node-b"), WarningStatus},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkRaftNodeID(ctx, tc.conf, tc.getenv)
+			})
+			if len(results) != 1 || results[0].Status != tc.expected {
+				t.Fatalf("expected a %s result, got %#v", tc.expected, results)
+			}
+		})
+	}
+}
+
+func TestCheckClusterName(t *testing.T) {
+	cases := []struct {
+		name       string
+		configured string
+		persisted  string
+		expected   []status
+	}{
+		{"unset", "", "vault-cluster-1a2b3c4d", []status{OkStatus}},
+		{"matches", "prod", "prod", []status{OkStatus}},
+		{"changed", "prod-east", "prod", []status{WarningStatus}},
+		{"sealed", "prod", "", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkClusterName(ctx, tc.configured, tc.persisted)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %d", len(tc.expected), len(results))
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("result %d: expected %s, got %s: %s", i, tc.expected[i], r.Status, r.Message)
+				}
+			}
+		})
+	}
+}
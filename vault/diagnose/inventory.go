@@ -0,0 +1,213 @@
+package diagnose
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"time"
+)
+
+// inventorySpecVersion is the CycloneDX specification version the inventory
+// document follows.
+const inventorySpecVersion = "1.4"
+
+// backendModules maps storage and seal types to the Go module that implements
+// their client, whose version is reported as the component's version. Types
+// implemented within Vault itself have no separate version.
+var backendModules = map[string]string{
+	"raft":          "github.com/hashicorp/raft",
+	"consul":        "github.com/hashicorp/consul/api",
+	"etcd":          "go.etcd.io/etcd",
+	"awskms":        "github.com/hashicorp/go-kms-wrapping",
+	"azurekeyvault": "github.com/hashicorp/go-kms-wrapping",
+	"gcpckms":       "github.com/hashicorp/go-kms-wrapping",
+	"alicloudkms":   "github.com/hashicorp/go-kms-wrapping",
+	"ocikms":        "github.com/hashicorp/go-kms-wrapping",
+	"transit":       "github.com/hashicorp/go-kms-wrapping",
+}
+
+// InventorySources is what the inventory is built from: the running binary
+// and the parts of the configuration that name deployed components.
+type InventorySources struct {
+	VaultVersion    string
+	StorageType     string
+	HAStorageType   string
+	SealTypes       []string
+	PluginDirectory string
+}
+
+// Inventory is a CycloneDX-style bill of materials of what a Vault server
+// deploys, kept apart from the health results so that inventory tooling can
+// consume it on its own.
+type Inventory struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Metadata    InventoryMetadata    `json:"metadata"`
+	Components  []InventoryComponent `json:"components"`
+}
+
+// InventoryMetadata identifies when the inventory was taken and the server it
+// describes.
+type InventoryMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Component InventoryComponent `json:"component"`
+}
+
+// InventoryComponent is a component of the deployment.
+type InventoryComponent struct {
+	Type       string              `json:"type"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	Hashes     []InventoryHash     `json:"hashes,omitempty"`
+	Properties []InventoryProperty `json:"properties,omitempty"`
+}
+
+// InventoryHash is a digest of a component's file.
+type InventoryHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+// InventoryProperty is a name and value describing a component.
+type InventoryProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// BuildInventory lists the components a server with the given configuration
+// deploys: Vault itself, the Go runtime it was built with, its storage and
+// seal backends, with the versions of their client libraries, and the plugin
+// binaries in the plugin directory. Plugins registered in the catalog are
+// stored in the barrier and can't be listed before unsealing, so the plugin
+// directory is what is reported.
+func BuildInventory(src InventorySources) (*Inventory, error) {
+	inv := &Inventory{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: inventorySpecVersion,
+		Version:     1,
+		Metadata: InventoryMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Component: InventoryComponent{
+				Type:    "application",
+				Name:    "vault",
+				Version: src.VaultVersion,
+				Properties: []InventoryProperty{
+					{Name: "os", Value: runtime.GOOS},
+					{Name: "arch", Value: runtime.GOARCH},
+				},
+			},
+		},
+		Components: []InventoryComponent{
+			{Type: "platform", Name: "go", Version: runtime.Version()},
+		},
+	}
+
+	backend := func(kind, typ string) InventoryComponent {
+		return InventoryComponent{
+			Type:       "library",
+			Name:       fmt.Sprintf("%s/%s", kind, typ),
+			Version:    moduleVersion(backendModules[typ]),
+			Properties: []InventoryProperty{{Name: "vault:" + kind, Value: typ}},
+		}
+	}
+	if src.StorageType != "" {
+		inv.Components = append(inv.Components, backend("storage", src.StorageType))
+	}
+	if src.HAStorageType != "" {
+		inv.Components = append(inv.Components, backend("ha_storage", src.HAStorageType))
+	}
+	for _, seal := range src.SealTypes {
+		inv.Components = append(inv.Components, backend("seal", seal))
+	}
+
+	if src.PluginDirectory != "" {
+		plugins, err := pluginComponents(src.PluginDirectory)
+		if err != nil {
+			return nil, err
+		}
+		inv.Components = append(inv.Components, plugins...)
+	}
+	return inv, nil
+}
+
+// moduleVersion returns the version of a module this binary was built with,
+// or an empty string if it isn't known.
+func moduleVersion(path string) string {
+	if path == "" {
+		return ""
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != path {
+			continue
+		}
+		if dep.Replace != nil {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return ""
+}
+
+// pluginComponents lists the regular files in the plugin directory, with
+// their SHA-256 digests, the value a plugin is registered in the catalog with.
+func pluginComponents(dir string) ([]InventoryComponent, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the plugin directory: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var plugins []InventoryComponent
+	for _, e := range entries {
+		if !e.Mode().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, InventoryComponent{
+			Type:       "file",
+			Name:       e.Name(),
+			Hashes:     []InventoryHash{{Algorithm: "SHA-256", Content: sum}},
+			Properties: []InventoryProperty{{Name: "vault:plugin_directory", Value: dir}},
+		})
+	}
+	return plugins, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("could not hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Write writes the inventory as indented JSON to path.
+func (inv *Inventory) Write(path string) error {
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,93 @@
+package diagnose
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestBuildInventory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-inventory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	plugins := filepath.Join(dir, "plugins")
+	if err := os.MkdirAll(filepath.Join(plugins, "subdir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(plugins, "vault-plugin-secrets-kv"), []byte("plugin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := BuildInventory(InventorySources{
+		VaultVersion:    "1.8.0",
+		StorageType:     "raft",
+		SealTypes:       []string{"awskms"},
+		PluginDirectory: plugins,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.BOMFormat != "CycloneDX" || inv.Metadata.Component.Name != "vault" || inv.Metadata.Component.Version != "1.8.0" {
+		t.Fatalf("unexpected metadata: %+v", inv.Metadata)
+	}
+
+	names := make(map[string]InventoryComponent)
+	for _, c := range inv.Components {
+		names[c.Name] = c
+	}
+	if len(inv.Components) != 4 {
+		t.Fatalf("expected 4 components, got %+v", inv.Components)
+	}
+	if names["go"].Version != runtime.Version() {
+		t.Fatalf("unexpected go component: %+v", names["go"])
+	}
+	if _, ok := names["storage/raft"]; !ok {
+		t.Fatalf("expected a storage/raft component, got %+v", inv.Components)
+	}
+	if _, ok := names["seal/awskms"]; !ok {
+		t.Fatalf("expected a seal/awskms component, got %+v", inv.Components)
+	}
+	plugin := names["vault-plugin-secrets-kv"]
+	// The SHA-256 of "plugin"
+	expected := "5e689e2b01672bf33996e75d5e372ff60c536ce1599a1458e867cd8f4bef5160"
+	if len(plugin.Hashes) != 1 || plugin.Hashes[0].Algorithm != "SHA-256" || plugin.Hashes[0].Content != expected {
+		t.Fatalf("unexpected plugin component: %+v", plugin)
+	}
+
+	if _, err := BuildInventory(InventorySources{PluginDirectory: filepath.Join(dir, "missing")}); err == nil {
+		t.Fatal("expected an error for a missing plugin directory")
+	}
+}
+
+func TestInventoryWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-inventory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	inv, err := BuildInventory(InventorySources{VaultVersion: "1.8.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "inventory.json")
+	if err := inv.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["bomFormat"] != "CycloneDX" || decoded["specVersion"] != inventorySpecVersion {
+		t.Fatalf("unexpected inventory: %s", data)
+	}
+}
@@ -0,0 +1,78 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SkippableSections are the names that can be given to -skip.
+var SkippableSections = []string{"listener", "autounseal"}
+
+// Invocation describes how a diagnose run was started and configured.
+type Invocation struct {
+	// Command is the command line that started the run, such as
+	// "vault operator diagnose" or "vault server -diagnose".
+	Command string
+
+	// EnableEnv is the name and value of the environment variable that
+	// gates diagnose.
+	EnableEnv      string
+	EnableEnvValue string
+
+	Skips        []string
+	MirrorServer bool
+	HostOnly     bool
+}
+
+// InvocationCheck reports how diagnose was invoked and warns about skip
+// settings that have no effect.
+func InvocationCheck(ctx context.Context, inv Invocation) {
+	summary := fmt.Sprintf("run via %q, enabled by %s=%s", inv.Command, inv.EnableEnv, inv.EnableEnvValue)
+	if inv.HostOnly {
+		summary += "; host checks only"
+	}
+	if len(inv.Skips) > 0 {
+		summary += fmt.Sprintf("; skipping %s", strings.Join(inv.Skips, ", "))
+	}
+	SpotInfo(ctx, "invocation", summary)
+
+	for _, skip := range inv.Skips {
+		known := false
+		for _, s := range SkippableSections {
+			if skip == s {
+				known = true
+				break
+			}
+		}
+		if !known {
+			SpotWarn(ctx, "skip", fmt.Sprintf("%q does not name a skippable section and has no effect; valid names are %s",
+				skip, strings.Join(SkippableSections, ", ")))
+		}
+	}
+	if inv.MirrorServer && len(inv.Skips) > 0 {
+		SpotWarn(ctx, "skip", "-skip has no effect with -mirror-server, which runs the full server startup sequence")
+	}
+	if inv.HostOnly && len(inv.Skips) > 0 {
+		SpotWarn(ctx, "skip", "-skip has no effect with -host-only, which runs none of the skippable sections")
+	}
+}
+
+// UnexpectedSkips returns the paths of the checks in results that were
+// skipped because a dependency failed, or that were skipped without a reason.
+// Checks named by -skip, of stanzas that are absent or don't apply, or that
+// are unsupported on this platform or build are expected skips.
+func UnexpectedSkips(results *Result) []string {
+	var paths []string
+	for _, l := range leafResults(results) {
+		if l.result.Status != SkippedStatus {
+			continue
+		}
+		switch l.result.SkipReason {
+		case SkipRequested, SkipStanzaAbsent, SkipNotApplicable, SkipNotApplicablePlatform:
+			continue
+		}
+		paths = append(paths, l.path)
+	}
+	return paths
+}
@@ -0,0 +1,71 @@
+package diagnose
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestInvocationCheck(t *testing.T) {
+	results := checkResults(t, func(ctx context.Context) {
+		InvocationCheck(ctx, Invocation{
+			Command:        "vault operator diagnose",
+			EnableEnv:      "VAULT_DIAGNOSE",
+			EnableEnvValue: "1",
+			Skips:          []string{"listener", "storage"},
+			MirrorServer:   true,
+		})
+	})
+	expected := []*Result{
+		{Name: "invocation", Status: InfoStatus, Message: `run via "vault operator diagnose", enabled by VAULT_DIAGNOSE=1; skipping listener, storage`},
+		{Name: "skip", Status: WarningStatus, Message: `"storage" does not name a skippable section and has no effect; valid names are listener, autounseal`},
+		{Name: "skip", Status: WarningStatus, Message: "-skip has no effect with -mirror-server, which runs the full server startup sequence"},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results, expected), "\n"))
+	}
+}
+
+func TestUnexpectedSkips(t *testing.T) {
+	results := &Result{
+		Name: "initialization",
+		Children: []*Result{
+			{Name: "init-listeners", Status: SkippedStatus, SkipReason: SkipRequested},
+			{Name: "storage", Status: SkippedStatus, SkipReason: SkipStanzaAbsent},
+			{Name: "setup-core", Children: []*Result{
+				{Name: "init-randreader", Status: OkStatus},
+				{Name: "kernel network parameters", Status: SkippedStatus, SkipReason: SkipNotApplicablePlatform},
+				{Name: "storage-pool", Status: SkippedStatus, SkipReason: SkipNotApplicable},
+				{Name: "seal-key-type", Status: SkippedStatus, SkipReason: SkipDependencyFailed},
+			}},
+		},
+	}
+	expected := []string{
+		"initialization/setup-core/seal-key-type",
+	}
+	if skips := UnexpectedSkips(results); !reflect.DeepEqual(skips, expected) {
+		t.Fatalf("unexpected skips: %v", skips)
+	}
+}
+
+func TestInvocationCheckHostOnly(t *testing.T) {
+	results := checkResults(t, func(ctx context.Context) {
+		InvocationCheck(ctx, Invocation{
+			Command:        "vault operator diagnose",
+			EnableEnv:      "VAULT_DIAGNOSE",
+			EnableEnvValue: "1",
+			Skips:          []string{"listener"},
+			HostOnly:       true,
+		})
+	})
+	expected := []*Result{
+		{Name: "invocation", Status: InfoStatus, Message: `run via "vault operator diagnose", enabled by VAULT_DIAGNOSE=1; host checks only; skipping listener`},
+		{Name: "skip", Status: WarningStatus, Message: "-skip has no effect with -host-only, which runs none of the skippable sections"},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results, expected), "\n"))
+	}
+}
@@ -0,0 +1,67 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// systemMaxLeaseTTL mirrors the max_lease_ttl core applies when it is unset,
+// which is also the default_lease_ttl it applies when that is unset.
+const systemMaxLeaseTTL = 32 * 24 * time.Hour
+
+// LeaseTTLCheck explains the effective system lease TTLs and how mounts, roles
+// and tokens narrow them, given default_lease_ttl and max_lease_ttl as set in
+// the configuration, or zero if unset. Core refuses to start when the
+// effective default exceeds the effective maximum, which also happens when
+// only max_lease_ttl is set, below the 32 day default of default_lease_ttl.
+func LeaseTTLCheck(ctx context.Context, defaultTTL, maxTTL time.Duration) {
+	testName := "lease-ttl"
+	defaultSource, maxSource := "default_lease_ttl", "max_lease_ttl"
+	if defaultTTL == 0 {
+		defaultTTL, defaultSource = systemMaxLeaseTTL, "built-in default"
+	}
+	if maxTTL == 0 {
+		maxTTL, maxSource = systemMaxLeaseTTL, "built-in default"
+	}
+
+	if defaultTTL > maxTTL {
+		advice := "Lower default_lease_ttl to at most max_lease_ttl."
+		if defaultSource != "default_lease_ttl" {
+			advice = "Set default_lease_ttl to at most max_lease_ttl, as its built-in default is 32 days."
+		}
+		SpotError(ctx, testName, fmt.Errorf("the system default lease TTL of %s (%s) is longer than the system max lease TTL "+
+			"of %s (%s), so the server will fail to start", defaultTTL, defaultSource, maxTTL, maxSource), Advice(advice))
+		return
+	}
+	SpotInfo(ctx, testName, fmt.Sprintf("system default lease TTL %s (%s), max lease TTL %s (%s)",
+		defaultTTL, defaultSource, maxTTL, maxSource))
+	SpotInfo(ctx, testName, "mounts inherit these unless tuned, and a mount's max lease TTL can only lower the system "+
+		"maximum; roles and tokens can narrow them further, and a lease never outlives the smallest maximum that "+
+		"applies to it")
+}
+
+// reauthChurnThreshold is the max lease TTL below which a long-running service
+// must log in again often enough to add noticeable load to its auth method.
+const reauthChurnThreshold = 24 * time.Hour
+
+// TokenRenewalCheck reports how often a long-running service has to
+// authenticate again given the effective system max lease TTL, the ceiling a
+// token can be renewed to, and warns when it is short enough that a fleet of
+// services logging in again will add noticeable load to the auth methods.
+// maxTTL is max_lease_ttl as set in the configuration, or zero if unset.
+func TokenRenewalCheck(ctx context.Context, maxTTL time.Duration) {
+	testName := "token-renewal"
+	if maxTTL == 0 {
+		maxTTL = systemMaxLeaseTTL
+	}
+	if maxTTL < reauthChurnThreshold {
+		logins := float64(24*time.Hour) / float64(maxTTL)
+		SpotWarn(ctx, testName, fmt.Sprintf("tokens can be renewed for at most %s, so each long-running service "+
+			"authenticates again about %.0f times a day, which adds up across many clients", maxTTL, logins),
+			Advice("Raise max_lease_ttl, or tune a longer max lease TTL on the auth mounts used by services."))
+		return
+	}
+	SpotInfo(ctx, testName, fmt.Sprintf("tokens can be renewed for up to %s before a service must authenticate again",
+		maxTTL))
+}
@@ -0,0 +1,60 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLeaseTTLCheck(t *testing.T) {
+	testCases := []struct {
+		name       string
+		defaultTTL time.Duration
+		maxTTL     time.Duration
+		status     status
+	}{
+		{"unset", 0, 0, InfoStatus},
+		{"default below max", time.Hour, 24 * time.Hour, InfoStatus},
+		{"equal", 24 * time.Hour, 24 * time.Hour, InfoStatus},
+		{"default above max", 48 * time.Hour, 24 * time.Hour, ErrorStatus},
+		{"max below the built-in default", 0, 24 * time.Hour, ErrorStatus},
+		{"default above the built-in max", 1000 * time.Hour, 0, ErrorStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				LeaseTTLCheck(ctx, tc.defaultTTL, tc.maxTTL)
+			})
+			if len(results) == 0 {
+				t.Fatal("expected results")
+			}
+			for _, r := range results {
+				if r.Status != tc.status {
+					t.Fatalf("expected %s, got %#v", tc.status, results)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenRenewalCheck(t *testing.T) {
+	testCases := []struct {
+		name   string
+		maxTTL time.Duration
+		status status
+	}{
+		{"unset", 0, InfoStatus},
+		{"a day", 24 * time.Hour, InfoStatus},
+		{"an hour", time.Hour, WarningStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				TokenRenewalCheck(ctx, tc.maxTTL)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
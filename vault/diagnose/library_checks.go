@@ -0,0 +1,172 @@
+package diagnose
+
+import (
+	"context"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+// sealLibraryEnvVar overrides the lib setting of a pkcs11 seal.
+const sealLibraryEnvVar = "VAULT_HSM_LIB"
+
+var (
+	elfArches = map[elf.Machine]string{
+		elf.EM_386:     "386",
+		elf.EM_X86_64:  "amd64",
+		elf.EM_ARM:     "arm",
+		elf.EM_AARCH64: "arm64",
+		elf.EM_PPC64:   "ppc64le", // big-endian ppc64 is handled in elfArch
+		elf.EM_S390:    "s390x",
+	}
+	machoArches = map[macho.Cpu]string{
+		macho.Cpu386:   "386",
+		macho.CpuAmd64: "amd64",
+		macho.CpuArm:   "arm",
+		macho.CpuArm64: "arm64",
+	}
+	peArches = map[uint16]string{
+		pe.IMAGE_FILE_MACHINE_I386:  "386",
+		pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+		pe.IMAGE_FILE_MACHINE_ARMNT: "arm",
+		pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+	}
+)
+
+// SealLibraryChecks checks the shared library of each pkcs11 seal, which is
+// only loaded, and so only fails, when the seal is first used.
+func SealLibraryChecks(ctx context.Context, seals []*configutil.KMS) {
+	for _, s := range seals {
+		if s.Type != "pkcs11" {
+			continue
+		}
+		lib := os.Getenv(sealLibraryEnvVar)
+		if lib == "" {
+			lib = s.Config["lib"]
+		}
+		if lib == "" {
+			SpotError(ctx, "seal-library", errors.New("the pkcs11 seal does not set lib, the path to the PKCS#11 library"))
+			continue
+		}
+		SharedLibraryCheck(ctx, "seal-library", lib)
+	}
+}
+
+// SharedLibraryCheck confirms that path is a shared library that this
+// process could load, reporting its architecture on success.
+func SharedLibraryCheck(ctx context.Context, name, path string) {
+	arch, err := sharedLibraryArch(path)
+	switch {
+	case os.IsNotExist(err):
+		SpotError(ctx, name, fmt.Errorf("library %s not found", path))
+	case err != nil:
+		SpotError(ctx, name, fmt.Errorf("library %s cannot be loaded: %w", path, err))
+	case arch != runtime.GOARCH:
+		SpotError(ctx, name, fmt.Errorf("library %s is built for %s, but Vault is built for %s", path, arch, runtime.GOARCH))
+	default:
+		SpotOk(ctx, name, fmt.Sprintf("%s (%s)", path, arch))
+	}
+}
+
+// sharedLibraryArch returns the GOARCH a shared library at path was built
+// for, or an error if it is not a shared library in the platform's format.
+func sharedLibraryArch(path string) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+
+	var arch string
+	var ok bool
+	switch runtime.GOOS {
+	case "darwin":
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		return machoArch(f, runtime.GOARCH)
+	case "windows":
+		f, err := pe.Open(path)
+		if err != nil {
+			return "", errors.New("not a PE file")
+		}
+		defer f.Close()
+		if f.Characteristics&pe.IMAGE_FILE_DLL == 0 {
+			return "", errors.New("not a DLL")
+		}
+		arch, ok = peArches[f.Machine]
+	default:
+		f, err := elf.Open(path)
+		if err != nil {
+			return "", errors.New("not an ELF file")
+		}
+		defer f.Close()
+		if f.Type != elf.ET_DYN {
+			return "", errors.New("not a shared object")
+		}
+		arch, ok = elfArch(f)
+	}
+	if !ok {
+		return "", errors.New("built for an unknown architecture")
+	}
+	return arch, nil
+}
+
+// machoArch returns the architecture of the dylib in r. For a universal
+// library, that is goarch when the library has a slice for it, or else the
+// architectures of all its slices.
+func machoArch(r io.ReaderAt, goarch string) (string, error) {
+	fat, err := macho.NewFatFile(r)
+	switch {
+	case err == nil:
+		var arches []string
+		for _, a := range fat.Arches {
+			if a.Type != macho.TypeDylib {
+				return "", errors.New("not a dynamic library")
+			}
+			arch, ok := machoArches[a.Cpu]
+			if !ok {
+				arch = a.Cpu.String()
+			}
+			if arch == goarch {
+				return arch, nil
+			}
+			arches = append(arches, arch)
+		}
+		return strings.Join(arches, "/"), nil
+	case err != macho.ErrNotFat:
+		return "", errors.New("not a Mach-O file")
+	}
+
+	f, err := macho.NewFile(r)
+	if err != nil {
+		return "", errors.New("not a Mach-O file")
+	}
+	if f.Type != macho.TypeDylib {
+		return "", errors.New("not a dynamic library")
+	}
+	arch, ok := machoArches[f.Cpu]
+	if !ok {
+		return "", errors.New("built for an unknown architecture")
+	}
+	return arch, nil
+}
+
+// elfArch returns the GOARCH of the ELF file f. EM_PPC64 covers both
+// ppc64le and big-endian ppc64, which differ only in byte order.
+func elfArch(f *elf.File) (string, bool) {
+	if f.Machine == elf.EM_PPC64 && f.ByteOrder == binary.BigEndian {
+		return "ppc64", true
+	}
+	arch, ok := elfArches[f.Machine]
+	return arch, ok
+}
@@ -0,0 +1,162 @@
+package diagnose
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"debug/macho"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+func TestSharedLibraryCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-library")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	notLib := filepath.Join(dir, "libfake.so")
+	if err := ioutil.WriteFile(notLib, []byte("not a library"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := checkResults(t, func(ctx context.Context) {
+		SharedLibraryCheck(ctx, "seal-library", filepath.Join(dir, "missing.so"))
+		SharedLibraryCheck(ctx, "seal-library", notLib)
+	})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %#v", results)
+	}
+	for _, r := range results {
+		if r.Status != ErrorStatus {
+			t.Fatalf("expected an error, got %#v", r)
+		}
+	}
+
+	// Any system shared library will do to exercise the success path.
+	matches, _ := filepath.Glob("/lib/*-linux-gnu/libc.so.6")
+	if runtime.GOOS != "linux" || len(matches) == 0 {
+		t.Skip("no system shared library to inspect")
+	}
+	results = checkResults(t, func(ctx context.Context) {
+		SharedLibraryCheck(ctx, "seal-library", matches[0])
+	})
+	if len(results) != 1 || results[0].Status != OkStatus {
+		t.Fatalf("expected %s to be loadable, got %#v", matches[0], results)
+	}
+}
+
+func TestSealLibraryChecks(t *testing.T) {
+	if v, ok := os.LookupEnv(sealLibraryEnvVar); ok {
+		defer os.Setenv(sealLibraryEnvVar, v)
+		os.Unsetenv(sealLibraryEnvVar)
+	}
+	results := checkResults(t, func(ctx context.Context) {
+		SealLibraryChecks(ctx, []*configutil.KMS{
+			{Type: "shamir"},
+			{Type: "pkcs11", Config: map[string]string{}},
+		})
+	})
+	if len(results) != 1 || results[0].Status != ErrorStatus {
+		t.Fatalf("expected an error for a pkcs11 seal without lib, got %#v", results)
+	}
+}
+
+// machoHeader returns the header of a 64-bit little-endian Mach-O file of
+// type fileType, with no load commands.
+func machoHeader(cpu macho.Cpu, fileType macho.Type) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, macho.FileHeader{Magic: macho.Magic64, Cpu: cpu, Type: fileType})
+	binary.Write(&buf, binary.LittleEndian, uint32(0)) // reserved
+	return buf.Bytes()
+}
+
+// machoFat returns a universal Mach-O file with a slice of each of thin.
+func machoFat(thin map[macho.Cpu][]byte, order []macho.Cpu) []byte {
+	const align = 12
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, []uint32{macho.MagicFat, uint32(len(order))})
+	offset := uint32(1 << align)
+	for _, cpu := range order {
+		binary.Write(&buf, binary.BigEndian, []uint32{uint32(cpu), 0, offset, uint32(len(thin[cpu])), align})
+		offset += 1 << align
+	}
+	for _, cpu := range order {
+		pad := make([]byte, (1<<align)-buf.Len()%(1<<align))
+		buf.Write(pad)
+		buf.Write(thin[cpu])
+	}
+	return buf.Bytes()
+}
+
+func TestMachoArch(t *testing.T) {
+	thin := map[macho.Cpu][]byte{
+		macho.CpuAmd64: machoHeader(macho.CpuAmd64, macho.TypeDylib),
+		macho.CpuArm64: machoHeader(macho.CpuArm64, macho.TypeDylib),
+	}
+	universal := machoFat(thin, []macho.Cpu{macho.CpuAmd64, macho.CpuArm64})
+	testCases := []struct {
+		name   string
+		data   []byte
+		goarch string
+		arch   string
+		err    bool
+	}{
+		{"thin", thin[macho.CpuArm64], "arm64", "arm64", false},
+		{"thin executable", machoHeader(macho.CpuArm64, macho.TypeExec), "arm64", "", true},
+		{"universal amd64", universal, "amd64", "amd64", false},
+		{"universal arm64", universal, "arm64", "arm64", false},
+		{"universal without the slice", universal, "386", "amd64/arm64", false},
+		{"not mach-o", []byte("not a library, just some text"), "arm64", "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			arch, err := machoArch(bytes.NewReader(tc.data), tc.goarch)
+			if (err != nil) != tc.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if arch != tc.arch {
+				t.Fatalf("expected %q, got %q", tc.arch, arch)
+			}
+		})
+	}
+}
+
+func TestElfArch(t *testing.T) {
+	testCases := []struct {
+		order binary.ByteOrder
+		data  elf.Data
+		arch  string
+	}{
+		{binary.LittleEndian, elf.ELFDATA2LSB, "ppc64le"},
+		{binary.BigEndian, elf.ELFDATA2MSB, "ppc64"},
+	}
+	for _, tc := range testCases {
+		var ident [elf.EI_NIDENT]byte
+		copy(ident[:], elf.ELFMAG)
+		ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+		ident[elf.EI_DATA] = byte(tc.data)
+		ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+		var buf bytes.Buffer
+		binary.Write(&buf, tc.order, elf.Header64{
+			Ident:   ident,
+			Type:    uint16(elf.ET_DYN),
+			Machine: uint16(elf.EM_PPC64),
+			Version: uint32(elf.EV_CURRENT),
+			Ehsize:  64,
+		})
+		f, err := elf.NewFile(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if arch, ok := elfArch(f); !ok || arch != tc.arch {
+			t.Fatalf("expected %s, got %q", tc.arch, arch)
+		}
+	}
+}
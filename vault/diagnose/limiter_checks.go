@@ -0,0 +1,112 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/internalshared/configutil"
+	"github.com/hashicorp/vault/sdk/helper/parseutil"
+	"github.com/mitchellh/mapstructure"
+)
+
+// RateLimitQuota is a rate-limit quota of a running Vault.
+type RateLimitQuota struct {
+	Name          string  `mapstructure:"name"`
+	Path          string  `mapstructure:"path"`
+	Rate          float64 `mapstructure:"rate"`
+	Interval      int     `mapstructure:"interval"`
+	BlockInterval int     `mapstructure:"block_interval"`
+}
+
+// RequestLimiterCheck reports the effective request limiter state of each
+// listener. This version of Vault has no request limiter, so
+// disable_request_limiter is ignored, but a listener that disables it is
+// warned about, as the setting is often left over from debugging and would
+// take effect on upgrade.
+func RequestLimiterCheck(ctx context.Context, listeners []*configutil.Listener) {
+	var set bool
+	for i, l := range listeners {
+		raw, ok := l.RawConfig["disable_request_limiter"]
+		if !ok {
+			continue
+		}
+		set = true
+		disabled, err := parseutil.ParseBool(raw)
+		switch {
+		case err != nil:
+			SpotError(ctx, "request-limiter", fmt.Errorf("listener %d (%s) has an invalid disable_request_limiter: %w", i+1, l.Address, err))
+		case disabled:
+			SpotWarn(ctx, "request-limiter", fmt.Sprintf("listener %d (%s) disables the request limiter; this version of Vault "+
+				"ignores the setting, but if it was left over from debugging, remove it before upgrading to a version "+
+				"that honors it", i+1, l.Address))
+		default:
+			SpotInfo(ctx, "request-limiter", fmt.Sprintf("listener %d (%s) enables the request limiter, which this version "+
+				"of Vault does not have", i+1, l.Address))
+		}
+	}
+	if !set {
+		SpotInfo(ctx, "request-limiter", "this version of Vault has no request limiter; use rate-limit quotas to protect against overload")
+	}
+}
+
+// RateLimitQuotaLiveCheck lists the rate-limit quotas of the running Vault that
+// client points at, and reports those that apply globally.
+func RateLimitQuotaLiveCheck(ctx context.Context, client *api.Client) error {
+	list, err := client.Logical().List("sys/quotas/rate-limit")
+	if err != nil {
+		return fmt.Errorf("could not list the rate-limit quotas: %w", err)
+	}
+	var names []string
+	if list != nil {
+		if err := mapstructure.Decode(list.Data["keys"], &names); err != nil {
+			return fmt.Errorf("could not parse the rate-limit quotas: %w", err)
+		}
+	}
+
+	quotas := make([]RateLimitQuota, 0, len(names))
+	for _, name := range names {
+		secret, err := client.Logical().Read("sys/quotas/rate-limit/" + name)
+		if err != nil {
+			return fmt.Errorf("could not read rate-limit quota %q: %w", name, err)
+		}
+		if secret == nil {
+			continue
+		}
+		var quota RateLimitQuota
+		if err := mapstructure.Decode(secret.Data, &quota); err != nil {
+			return fmt.Errorf("could not parse rate-limit quota %q: %w", name, err)
+		}
+		quotas = append(quotas, quota)
+	}
+	RateLimitQuotaCheck(ctx, quotas)
+	return nil
+}
+
+// RateLimitQuotaCheck reports the global rate-limit quotas, those with no path,
+// and notes when there are none, which leaves the node with no overload
+// protection short of its request duration limits.
+func RateLimitQuotaCheck(ctx context.Context, quotas []RateLimitQuota) {
+	var global []string
+	for _, q := range quotas {
+		if strings.Trim(q.Path, "/") != "" {
+			continue
+		}
+		desc := fmt.Sprintf("%s allows %g requests every %ds", q.Name, q.Rate, q.Interval)
+		if q.BlockInterval > 0 {
+			desc += fmt.Sprintf(", blocking for %ds once exceeded", q.BlockInterval)
+		}
+		global = append(global, desc)
+	}
+	if len(quotas) == 0 {
+		SpotWarn(ctx, "rate-limit-quotas", "no rate-limit quotas are configured, so requests are not rate limited")
+		return
+	}
+	if len(global) == 0 {
+		SpotWarn(ctx, "rate-limit-quotas", fmt.Sprintf("%d rate-limit quota(s) are configured, but none apply globally, "+
+			"so requests to other paths are not rate limited", len(quotas)))
+		return
+	}
+	SpotOk(ctx, "rate-limit-quotas", "global quotas: "+strings.Join(global, "; "))
+}
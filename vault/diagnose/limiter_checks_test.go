@@ -0,0 +1,51 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+func TestRequestLimiterCheck(t *testing.T) {
+	listeners := []*configutil.Listener{
+		{Address: "127.0.0.1:8200", RawConfig: map[string]interface{}{}},
+		{Address: "127.0.0.1:8210", RawConfig: map[string]interface{}{"disable_request_limiter": true}},
+		{Address: "127.0.0.1:8220", RawConfig: map[string]interface{}{"disable_request_limiter": "nope"}},
+	}
+	results := checkResults(t, func(ctx context.Context) {
+		RequestLimiterCheck(ctx, listeners)
+	})
+	if len(results) != 2 || results[0].Status != WarningStatus || results[1].Status != ErrorStatus {
+		t.Fatalf("unexpected results: %#v", results)
+	}
+
+	results = checkResults(t, func(ctx context.Context) {
+		RequestLimiterCheck(ctx, listeners[:1])
+	})
+	if len(results) != 1 || results[0].Status != InfoStatus {
+		t.Fatalf("unexpected results: %#v", results)
+	}
+}
+
+func TestRateLimitQuotaCheck(t *testing.T) {
+	testCases := []struct {
+		name   string
+		quotas []RateLimitQuota
+		status status
+	}{
+		{"none", nil, WarningStatus},
+		{"mount only", []RateLimitQuota{{Name: "kv", Path: "secret/", Rate: 10, Interval: 1}}, WarningStatus},
+		{"global", []RateLimitQuota{{Name: "global", Rate: 500, Interval: 1, BlockInterval: 30}}, OkStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				RateLimitQuotaCheck(ctx, tc.quotas)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+// BindCheck attempts to bind addr as the current process and immediately
+// releases it. A failure includes the errno, when one is available, so that
+// privilege problems can be told apart from ports already in use.
+func BindCheck(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		var errno syscall.Errno
+		if errors.As(err, &errno) {
+			return fmt.Errorf("%w (errno %d, uid %d)", err, int(errno), os.Geteuid())
+		}
+		return err
+	}
+	return ln.Close()
+}
+
+// ListenerBindChecks attempts to bind the address and cluster address of every
+// tcp listener, which proves both that the ports are free and that the process
+// has the privileges to use them.
+func ListenerBindChecks(ctx context.Context, listeners []*configutil.Listener) {
+	for _, l := range listeners {
+		if l.Type != "tcp" {
+			continue
+		}
+		addrs := []string{l.Address}
+		if l.ClusterAddress != "" {
+			addrs = append(addrs, l.ClusterAddress)
+		}
+		for _, addr := range addrs {
+			if err := BindCheck(addr); err != nil {
+				SpotError(ctx, "bind-listener", fmt.Errorf("%s: %w", addr, err))
+			} else {
+				SpotOk(ctx, "bind-listener", addr)
+			}
+		}
+	}
+}
+
+// localInterface is a network interface of the host and its addresses.
+type localInterface struct {
+	name  string
+	addrs []net.IP
+}
+
+// ListenerInterfaceCheck confirms that every specific address tcp listeners
+// are configured on, including cluster addresses, belongs to an interface of
+// this host, and reports the interface. An address copied from another host's
+// configuration would otherwise fail at bind with "cannot assign requested
+// address".
+func ListenerInterfaceCheck(ctx context.Context, listeners []*configutil.Listener) error {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return fmt.Errorf("could not list the network interfaces: %w", err)
+	}
+	var local []localInterface
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		li := localInterface{name: iface.Name}
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok {
+				li.addrs = append(li.addrs, ipnet.IP)
+			}
+		}
+		local = append(local, li)
+	}
+	listenerInterfaceCheck(ctx, listeners, local)
+	return nil
+}
+
+func listenerInterfaceCheck(ctx context.Context, listeners []*configutil.Listener, local []localInterface) {
+	testName := "listener-interface"
+	for _, l := range listeners {
+		if l.Type != "tcp" {
+			continue
+		}
+		addrs := []string{l.Address}
+		if l.ClusterAddress != "" {
+			addrs = append(addrs, l.ClusterAddress)
+		}
+		for _, addr := range addrs {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				host = addr
+			}
+			var ips []net.IP
+			if ip := net.ParseIP(host); ip != nil {
+				ips = []net.IP{ip}
+			} else if host != "" {
+				ips, err = net.LookupIP(host)
+				if err != nil {
+					SpotError(ctx, testName, fmt.Errorf("%s: could not resolve %s: %w", addr, host, err))
+					continue
+				}
+			}
+			if len(ips) == 0 || ips[0].IsUnspecified() {
+				SpotOk(ctx, testName, fmt.Sprintf("%s listens on all interfaces", addr))
+				continue
+			}
+			if name := interfaceOf(ips, local); name != "" {
+				SpotOk(ctx, testName, fmt.Sprintf("%s is on interface %s", addr, name))
+			} else {
+				SpotError(ctx, testName, fmt.Errorf("%s: no interface of this host has the address %s, so the listener "+
+					"can't bind it", addr, host))
+			}
+		}
+	}
+}
+
+// interfaceOf returns the name of the first interface with one of ips, or
+// an empty string if there is none.
+func interfaceOf(ips []net.IP, local []localInterface) string {
+	for _, ip := range ips {
+		for _, li := range local {
+			for _, a := range li.addrs {
+				if a.Equal(ip) {
+					return li.name
+				}
+			}
+		}
+	}
+	return ""
+}
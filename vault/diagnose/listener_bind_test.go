@@ -0,0 +1,59 @@
+package diagnose
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+func TestBindCheck(t *testing.T) {
+	if err := BindCheck("127.0.0.1:0"); err != nil {
+		t.Fatalf("unexpected error binding a free port: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	if err := BindCheck(ln.Addr().String()); err == nil {
+		t.Fatalf("expected an error binding a port that is in use")
+	}
+}
+
+func TestListenerInterfaceCheck(t *testing.T) {
+	local := []localInterface{
+		{name: "lo", addrs: []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}},
+		{name: "eth0", addrs: []net.IP{net.ParseIP("10.0.0.5")}},
+	}
+	testCases := []struct {
+		name     string
+		listener *configutil.Listener
+		expected []status
+	}{
+		{"wildcard", &configutil.Listener{Type: "tcp", Address: "0.0.0.0:8200"}, []status{OkStatus}},
+		{"empty host", &configutil.Listener{Type: "tcp", Address: ":8200"}, []status{OkStatus}},
+		{"local", &configutil.Listener{Type: "tcp", Address: "10.0.0.5:8200", ClusterAddress: "[::1]:8201"}, []status{OkStatus, OkStatus}},
+		{"foreign", &configutil.Listener{Type: "tcp", Address: "10.0.0.6:8200"}, []status{ErrorStatus}},
+		{"foreign cluster address", &configutil.Listener{Type: "tcp", Address: "10.0.0.5:8200", ClusterAddress: "10.1.0.5:8201"}, []status{OkStatus, ErrorStatus}},
+		{"unix", &configutil.Listener{Type: "unix", Address: "/run/vault.sock"}, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				listenerInterfaceCheck(ctx, []*configutil.Listener{tc.listener}, local)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %#v", len(tc.expected), results)
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("expected %s for result %d, got %#v", tc.expected[i], i, r)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+// sensitiveListenerFeatures are listener features that expose operational
+// endpoints which should only be reachable locally.
+var sensitiveListenerFeatures = map[string]bool{
+	"unauthenticated_pprof_access": true,
+}
+
+// ListenerFeatures returns the names of the optional listener features enabled
+// in l, in a stable order.
+func ListenerFeatures(l *configutil.Listener) []string {
+	var features []string
+	if l.Telemetry.UnauthenticatedMetricsAccess {
+		features = append(features, "unauthenticated_metrics_access")
+	}
+	if l.Profiling.UnauthenticatedPProfAccess {
+		features = append(features, "unauthenticated_pprof_access")
+	}
+	if l.CorsEnabled {
+		features = append(features, "cors_enabled")
+	}
+	if l.ProxyProtocolBehavior != "" {
+		features = append(features, "proxy_protocol_behavior="+l.ProxyProtocolBehavior)
+	}
+	if len(l.XForwardedForAuthorizedAddrs) > 0 {
+		features = append(features, "x_forwarded_for_authorized_addrs")
+	}
+	return features
+}
+
+// ListenerFeatureChecks reports the optional features enabled on each
+// listener, warning when a sensitive one is enabled on a listener that is
+// reachable from other hosts.
+func ListenerFeatureChecks(ctx context.Context, listeners []*configutil.Listener) {
+	for _, l := range listeners {
+		features := ListenerFeatures(l)
+		if len(features) == 0 {
+			SpotOk(ctx, "listener-features", fmt.Sprintf("%s: no optional features enabled", l.Address))
+			continue
+		}
+
+		local := l.Type == "unix" || IsLoopbackAddr(l.Address)
+		var exposed []string
+		for _, f := range features {
+			if sensitiveListenerFeatures[f] && !local {
+				exposed = append(exposed, f)
+			}
+		}
+		if len(exposed) > 0 {
+			SpotWarn(ctx, "listener-features", fmt.Sprintf("%s: %s enabled on a non-loopback listener; "+
+				"consider restricting it to a loopback or unix socket listener", l.Address, strings.Join(exposed, ", ")))
+			continue
+		}
+		SpotOk(ctx, "listener-features", fmt.Sprintf("%s: %s", l.Address, strings.Join(features, ", ")))
+	}
+}
+
+// UIExposureCheck reports whether the UI is enabled, as set by source, and
+// which listeners serve it. Every listener serves the UI when it is enabled,
+// so it is reachable wherever the API is; the check warns when a listener
+// that other hosts can reach serves it without TLS.
+func UIExposureCheck(ctx context.Context, enabled bool, source string, listeners []*configutil.Listener) {
+	testName := "ui-exposure"
+	if !enabled {
+		SpotOk(ctx, testName, fmt.Sprintf("the UI is disabled (from %s)", source))
+		return
+	}
+
+	var served []string
+	exposed := false
+	for _, l := range listeners {
+		var reach string
+		switch {
+		case l.Type == "unix":
+			reach = "unix socket"
+		case IsLoopbackAddr(l.Address):
+			reach = "loopback"
+		case WildcardAddrCheck(l.Address) != nil:
+			reach = "all interfaces"
+		default:
+			reach = "external interface"
+		}
+		if l.TLSDisable {
+			reach += ", no TLS"
+		}
+		served = append(served, fmt.Sprintf("%s (%s)", l.Address, reach))
+
+		if l.Type != "unix" && !IsLoopbackAddr(l.Address) && l.TLSDisable {
+			exposed = true
+			SpotWarn(ctx, testName, fmt.Sprintf("%s serves the UI to other hosts without TLS, so logins and tokens "+
+				"entered in the browser are sent in the clear", l.Address),
+				Advice("Enable TLS on the listener, or bind it to a loopback address if the UI is only used locally."))
+		}
+	}
+	if len(served) == 0 {
+		SpotInfo(ctx, testName, fmt.Sprintf("the UI is enabled (from %s), but no listener serves it", source))
+		return
+	}
+	SpotInfo(ctx, testName, fmt.Sprintf("the UI is enabled (from %s) and served at /ui/ on %s", source, strings.Join(served, ", ")))
+	if !exposed {
+		SpotOk(ctx, testName, "no listener serves the UI to other hosts without TLS")
+	}
+}
@@ -0,0 +1,80 @@
+package diagnose
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+func TestListenerFeatures(t *testing.T) {
+	l := &configutil.Listener{
+		Type:    "tcp",
+		Address: "0.0.0.0:8200",
+		Telemetry: configutil.ListenerTelemetry{
+			UnauthenticatedMetricsAccess: true,
+		},
+		Profiling: configutil.ListenerProfiling{
+			UnauthenticatedPProfAccess: true,
+		},
+	}
+	expected := []string{"unauthenticated_metrics_access", "unauthenticated_pprof_access"}
+	if features := ListenerFeatures(l); !reflect.DeepEqual(features, expected) {
+		t.Fatalf("expected %v, got %v", expected, features)
+	}
+	if features := ListenerFeatures(&configutil.Listener{Type: "tcp"}); len(features) != 0 {
+		t.Fatalf("expected no features, got %v", features)
+	}
+}
+
+func TestIsLoopbackAddr(t *testing.T) {
+	testCases := map[string]bool{
+		"127.0.0.1:8200":         true,
+		"localhost:8200":         true,
+		"[::1]:8200":             true,
+		"https://127.0.0.1:8200": true,
+		"0.0.0.0:8200":           false,
+		"10.0.0.1:8200":          false,
+		"vault.example.com:8200": false,
+	}
+	for addr, expected := range testCases {
+		if IsLoopbackAddr(addr) != expected {
+			t.Errorf("IsLoopbackAddr(%q) != %t", addr, expected)
+		}
+	}
+}
+
+func TestUIExposureCheck(t *testing.T) {
+	listeners := []*configutil.Listener{
+		{Type: "tcp", Address: "127.0.0.1:8200", TLSDisable: true},
+		{Type: "tcp", Address: "0.0.0.0:8300"},
+		{Type: "unix", Address: "/run/vault.sock"},
+	}
+	results := checkResults(t, func(ctx context.Context) {
+		UIExposureCheck(ctx, true, "ui", listeners)
+	})
+	if len(results) != 2 || results[0].Status != InfoStatus || results[1].Status != OkStatus {
+		t.Fatalf("expected the exposure and an ok result, got %#v", results)
+	}
+	expected := "127.0.0.1:8200 (loopback, no TLS), 0.0.0.0:8300 (all interfaces), /run/vault.sock (unix socket)"
+	if !strings.Contains(results[0].Message, expected) {
+		t.Fatalf("expected %q in %q", expected, results[0].Message)
+	}
+
+	listeners = append(listeners, &configutil.Listener{Type: "tcp", Address: "10.0.0.5:8200", TLSDisable: true})
+	results = checkResults(t, func(ctx context.Context) {
+		UIExposureCheck(ctx, true, "VAULT_UI", listeners)
+	})
+	if len(results) != 2 || results[0].Status != WarningStatus {
+		t.Fatalf("expected a warning for the plaintext external listener, got %#v", results)
+	}
+
+	results = checkResults(t, func(ctx context.Context) {
+		UIExposureCheck(ctx, false, "the default", listeners)
+	})
+	if len(results) != 1 || results[0].Status != OkStatus {
+		t.Fatalf("expected an ok result with the UI disabled, got %#v", results)
+	}
+}
@@ -0,0 +1,109 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+// errSocketOptionsUnsupported is returned by tcpSocketOptions on platforms
+// whose socket options it can't read.
+var errSocketOptionsUnsupported = errors.New("reading TCP socket options is unsupported on this platform")
+
+// tcpOptions are the TCP socket options in effect on a connection.
+type tcpOptions struct {
+	noDelay   bool
+	keepAlive bool
+
+	// keepAliveIdle is how long the connection is idle before the first
+	// probe, keepAliveInterval the time between probes and keepAliveCount
+	// the number of unanswered probes before the connection is dropped.
+	keepAliveIdle     time.Duration
+	keepAliveInterval time.Duration
+	keepAliveCount    int
+}
+
+// TCPSocketOptionsCheck reports the TCP socket options the tcp listeners
+// apply to the connections they accept, as the operating system reports them.
+// wrap applies the listener's options, as the server does, to a listener;
+// keepAlivePeriod is the keep-alive period it requests. The options are read
+// from a loopback connection accepted through wrap, since the options of a
+// connection to a configured listener are the same, and the check warns when
+// the operating system clamped the requested period.
+func TCPSocketOptionsCheck(ctx context.Context, listeners []*configutil.Listener, wrap func(*net.TCPListener) net.Listener, keepAlivePeriod time.Duration) error {
+	var addrs []string
+	for _, l := range listeners {
+		if l.Type == "tcp" {
+			addrs = append(addrs, l.Address)
+		}
+	}
+	if len(addrs) == 0 {
+		Skipped(ctx, SkipStanzaAbsent, "no tcp listeners are configured")
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("could not listen on a loopback address: %w", err)
+	}
+	defer ln.Close()
+	wrapped := wrap(ln.(*net.TCPListener))
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		return fmt.Errorf("could not connect to the loopback listener: %w", err)
+	}
+	defer client.Close()
+	conn, err := wrapped.Accept()
+	if err != nil {
+		return fmt.Errorf("could not accept a loopback connection: %w", err)
+	}
+	defer conn.Close()
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return fmt.Errorf("the listener accepted a %T rather than a TCP connection", conn)
+	}
+
+	opts, err := tcpSocketOptions(tc)
+	if errors.Is(err, errSocketOptionsUnsupported) {
+		Skipped(ctx, SkipNotApplicablePlatform, err.Error())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	checkTCPSocketOptions(ctx, addrs, opts, keepAlivePeriod)
+	return nil
+}
+
+func checkTCPSocketOptions(ctx context.Context, addrs []string, opts tcpOptions, keepAlivePeriod time.Duration) {
+	testName := "tcp-socket-options"
+	onOff := func(b bool) string {
+		if b {
+			return "on"
+		}
+		return "off"
+	}
+	desc := fmt.Sprintf("TCP_NODELAY %s, SO_KEEPALIVE %s", onOff(opts.noDelay), onOff(opts.keepAlive))
+	if opts.keepAlive {
+		desc += fmt.Sprintf(", keep-alive idle %s, interval %s, %d probes, so an unresponsive peer is dropped after %s",
+			opts.keepAliveIdle, opts.keepAliveInterval, opts.keepAliveCount,
+			opts.keepAliveIdle+time.Duration(opts.keepAliveCount)*opts.keepAliveInterval)
+	}
+	SpotInfo(ctx, testName, fmt.Sprintf("%s accept connections with %s", strings.Join(addrs, ", "), desc))
+
+	switch {
+	case !opts.keepAlive:
+		SpotWarn(ctx, testName, "keep-alives are off on accepted connections, so connections to unresponsive clients are never dropped")
+	case opts.keepAliveIdle != keepAlivePeriod:
+		SpotWarn(ctx, testName, fmt.Sprintf("the listeners request a keep-alive idle time of %s, but the operating "+
+			"system applied %s", keepAlivePeriod, opts.keepAliveIdle))
+	default:
+		SpotOk(ctx, testName, fmt.Sprintf("the requested keep-alive period of %s is applied", keepAlivePeriod))
+	}
+}
@@ -0,0 +1,96 @@
+package diagnose
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+type keepAliveListener struct {
+	*net.TCPListener
+}
+
+func (ln keepAliveListener) Accept() (net.Conn, error) {
+	tc, err := ln.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+	tc.SetKeepAlive(true)
+	tc.SetKeepAlivePeriod(3 * time.Minute)
+	return tc, nil
+}
+
+func TestTCPSocketOptionsCheck(t *testing.T) {
+	listeners := []*configutil.Listener{{Type: "tcp", Address: "127.0.0.1:8200"}}
+	wrap := func(ln *net.TCPListener) net.Listener { return keepAliveListener{ln} }
+	results := checkResults(t, func(ctx context.Context) {
+		if err := TCPSocketOptionsCheck(ctx, listeners, wrap, 3*time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if runtime.GOOS != "linux" {
+		if len(results) != 1 || results[0].Status != SkippedStatus {
+			t.Fatalf("expected the check to be skipped, got %+v", results)
+		}
+		return
+	}
+	if len(results) != 2 || results[0].Status != InfoStatus || results[1].Status != OkStatus {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if !strings.Contains(results[0].Message, "keep-alive idle 3m0s") {
+		t.Fatalf("expected the keep-alive idle time in %q", results[0].Message)
+	}
+}
+
+func TestCheckTCPSocketOptions(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     tcpOptions
+		expected []status
+		contains string
+	}{
+		{
+			name: "applied",
+			opts: tcpOptions{noDelay: true, keepAlive: true, keepAliveIdle: 3 * time.Minute,
+				keepAliveInterval: 15 * time.Second, keepAliveCount: 9},
+			expected: []status{InfoStatus, OkStatus},
+			contains: "dropped after 5m15s",
+		},
+		{
+			name: "clamped",
+			opts: tcpOptions{noDelay: true, keepAlive: true, keepAliveIdle: 2 * time.Minute,
+				keepAliveInterval: 75 * time.Second, keepAliveCount: 9},
+			expected: []status{InfoStatus, WarningStatus},
+			contains: "TCP_NODELAY on, SO_KEEPALIVE on",
+		},
+		{
+			name:     "keep-alives off",
+			opts:     tcpOptions{},
+			expected: []status{InfoStatus, WarningStatus},
+			contains: "TCP_NODELAY off, SO_KEEPALIVE off",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkTCPSocketOptions(ctx, []string{"0.0.0.0:8200"}, tc.opts, 3*time.Minute)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %d", len(tc.expected), len(results))
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("result %d: expected %s, got %s: %s", i, tc.expected[i], r.Status, r.Message)
+				}
+			}
+			if !strings.Contains(results[0].Message, tc.contains) {
+				t.Fatalf("expected %q in %q", tc.contains, results[0].Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/vault/internalshared/configutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+)
+
+// logFileKeys are the file logging and rotation settings of later Vault
+// versions. This version always logs to stderr, so the settings are parsed
+// as unknown keys and have no effect.
+var logFileKeys = []string{"log_file", "log_rotate_bytes", "log_rotate_duration", "log_rotate_max_files"}
+
+// LogFileCheck reports whether the configuration sets file logging options.
+// This version of Vault cannot write or rotate a log file, so rather than
+// probing a rotation that will never happen, it warns that the settings are
+// ignored.
+func LogFileCheck(ctx context.Context, unused configutil.UnusedKeyMap) {
+	var found []string
+	for _, key := range logFileKeys {
+		if _, ok := unused[key]; ok {
+			found = append(found, key)
+		}
+	}
+	if len(found) == 0 {
+		SpotSkipped(ctx, "log-file", SkipStanzaAbsent, "log_file is not configured; logs are written to stderr")
+		return
+	}
+	sort.Strings(found)
+	SpotWarn(ctx, "log-file", fmt.Sprintf("%s set, but this version of Vault does not support logging to a file, "+
+		"so logs are written to stderr and no rotation takes place; capture and rotate stderr with the service manager instead",
+		strings.Join(found, ", ")))
+}
+
+// logRequestsLevels are the request logging levels of later Vault versions,
+// from the most to the least verbose.
+var logRequestsLevels = []string{"trace", "debug", "info", "warn", "error", "off"}
+
+// LogRequestsLevel returns the log_requests_level set in a configuration file,
+// or an empty string if the file doesn't set it.
+func LogRequestsLevel(contents []byte) (string, error) {
+	var conf struct {
+		LogRequestsLevel string `hcl:"log_requests_level"`
+	}
+	if err := hcl.Decode(&conf, string(contents)); err != nil {
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSpace(conf.LogRequestsLevel)), nil
+}
+
+// LogRequestsLevelCheck reports the effective request logging level. Later
+// versions of Vault log every request at log_requests_level, and at trace or
+// debug that includes request paths and bodies, which may carry secrets. This
+// version never logs requests, so the setting has no effect yet, but a verbose
+// level is flagged before an upgrade turns it on.
+func LogRequestsLevelCheck(ctx context.Context, level string) {
+	testName := "log-requests-level"
+	if level == "" || level == "off" {
+		SpotOk(ctx, testName, "request logging is off")
+		return
+	}
+	if !strutil.StrListContains(logRequestsLevels, level) {
+		SpotWarn(ctx, testName, fmt.Sprintf("log_requests_level = %q is not a log level; expected one of %s",
+			level, strings.Join(logRequestsLevels, ", ")))
+		return
+	}
+	msg := fmt.Sprintf("log_requests_level = %q set, but this version of Vault does not log requests, "+
+		"so the effective request logging level is off", level)
+	if level != "trace" && level != "debug" {
+		SpotInfo(ctx, testName, msg)
+		return
+	}
+	SpotWarn(ctx, testName, msg+fmt.Sprintf("; once upgraded, requests will be logged at %s, "+
+		"which can write request paths and bodies containing secrets to the logs", level),
+		Advice("Remove log_requests_level, or set it to off, unless request logging is needed and the logs are protected."))
+}
@@ -0,0 +1,61 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+func TestLogFileCheck(t *testing.T) {
+	results := checkResults(t, func(ctx context.Context) {
+		LogFileCheck(ctx, configutil.UnusedKeyMap{"cluster_nmae": []token.Pos{{}}})
+	})
+	if len(results) != 1 || results[0].Status != SkippedStatus {
+		t.Fatalf("expected a skipped result without log_file, got %#v", results)
+	}
+
+	results = checkResults(t, func(ctx context.Context) {
+		LogFileCheck(ctx, configutil.UnusedKeyMap{
+			"log_rotate_bytes": []token.Pos{{}},
+			"log_file":         []token.Pos{{}},
+		})
+	})
+	if len(results) != 1 || results[0].Status != WarningStatus {
+		t.Fatalf("expected a warning with log_file set, got %#v", results)
+	}
+}
+
+func TestLogRequestsLevel(t *testing.T) {
+	level, err := LogRequestsLevel([]byte("log_requests_level = \"DEBUG\"\nlistener \"tcp\" {\n  address = \"127.0.0.1:8200\"\n}\n"))
+	if err != nil || level != "debug" {
+		t.Fatalf("expected debug, got %q, %v", level, err)
+	}
+	level, err = LogRequestsLevel([]byte(`{"ui": true}`))
+	if err != nil || level != "" {
+		t.Fatalf("expected no level, got %q, %v", level, err)
+	}
+}
+
+func TestLogRequestsLevelCheck(t *testing.T) {
+	testCases := []struct {
+		level  string
+		status status
+	}{
+		{"", OkStatus},
+		{"off", OkStatus},
+		{"info", InfoStatus},
+		{"trace", WarningStatus},
+		{"debug", WarningStatus},
+		{"verbose", WarningStatus},
+	}
+	for _, tc := range testCases {
+		results := checkResults(t, func(ctx context.Context) {
+			LogRequestsLevelCheck(ctx, tc.level)
+		})
+		if len(results) != 1 || results[0].Status != tc.status {
+			t.Fatalf("%q: expected a %s result, got %#v", tc.level, tc.status, results)
+		}
+	}
+}
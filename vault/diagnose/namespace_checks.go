@@ -0,0 +1,46 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+// SealNamespaces returns the namespaces referenced by the configured seals,
+// keyed by seal type.
+func SealNamespaces(seals []*configutil.KMS) map[string]string {
+	namespaces := make(map[string]string)
+	for _, s := range seals {
+		if s.Disabled || s.Config == nil {
+			continue
+		}
+		if ns := s.Config["namespace"]; ns != "" {
+			namespaces[s.Type] = ns
+		}
+	}
+	return namespaces
+}
+
+// NamespaceConfigCheck reports namespace-related configuration. Namespaces
+// named in a seal stanza live on the remote Vault the seal talks to, so they
+// are valid on any edition; only the local license is edition-specific.
+func NamespaceConfigCheck(ctx context.Context, seals []*configutil.KMS) {
+	namespaces := SealNamespaces(seals)
+	if len(namespaces) == 0 {
+		SpotInfo(ctx, "namespace-config", "no namespaces referenced in config")
+	} else {
+		var refs []string
+		for sealType, ns := range namespaces {
+			refs = append(refs, fmt.Sprintf("%s seal uses remote namespace %q", sealType, ns))
+		}
+		sort.Strings(refs)
+		SpotInfo(ctx, "namespace-config", strings.Join(refs, "; "))
+	}
+
+	if !enterpriseBuild {
+		SpotSkipped(ctx, "namespace-license", SkipNotApplicable, fmt.Sprintf("this is a %s build, which does not support namespaces locally", Edition()))
+	}
+}
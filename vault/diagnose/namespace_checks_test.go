@@ -0,0 +1,21 @@
+package diagnose
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+func TestSealNamespaces(t *testing.T) {
+	seals := []*configutil.KMS{
+		{Type: "transit", Config: map[string]string{"namespace": "ns1/"}},
+		{Type: "awskms", Config: map[string]string{"region": "us-east-1"}},
+		{Type: "shamir"},
+		{Type: "transit", Disabled: true, Config: map[string]string{"namespace": "old/"}},
+	}
+	expected := map[string]string{"transit": "ns1/"}
+	if ns := SealNamespaces(seals); !reflect.DeepEqual(ns, expected) {
+		t.Fatalf("expected %v, got %v", expected, ns)
+	}
+}
@@ -5,9 +5,11 @@ package diagnose
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/mem"
 )
 
 func diskUsage(ctx context.Context) error {
@@ -42,3 +44,121 @@ partLoop:
 	}
 	return nil
 }
+
+// ephemeralFilesystems are filesystem types whose contents do not survive a
+// restart of the host or container.
+var ephemeralFilesystems = map[string]bool{
+	"tmpfs":   true,
+	"ramfs":   true,
+	"overlay": true,
+	"aufs":    true,
+}
+
+// StorageFilesystemCheck reports the filesystem type backing the given storage
+// path, warning when it is ephemeral.
+func StorageFilesystemCheck(ctx context.Context, path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	partitions, err := disk.Partitions(true)
+	if err != nil {
+		return err
+	}
+
+	mount := mountOf(absPath, partitions)
+	if mount.Mountpoint == "" {
+		return fmt.Errorf("could not determine the filesystem backing %s", absPath)
+	}
+
+	testName := "storage filesystem"
+	if ephemeralFilesystems[mount.Fstype] {
+		SpotWarn(ctx, testName, fmt.Sprintf("%s is on an ephemeral %s filesystem mounted at %s; data will be lost on restart",
+			absPath, mount.Fstype, mount.Mountpoint))
+	} else {
+		SpotOk(ctx, testName, fmt.Sprintf("%s is on a %s filesystem", absPath, mount.Fstype))
+	}
+	return nil
+}
+
+// mountOf returns the partition with the longest mount point containing path,
+// or an empty partition if there is none.
+func mountOf(path string, partitions []disk.PartitionStat) disk.PartitionStat {
+	var mount disk.PartitionStat
+	for _, partition := range partitions {
+		if isPathWithin(path, partition.Mountpoint) && len(partition.Mountpoint) > len(mount.Mountpoint) {
+			mount = partition
+		}
+	}
+	return mount
+}
+
+// RaftFilesystemsCheck reports the filesystem and device of each part of the
+// raft storage layout under path: the FSM database, the raft log, the
+// snapshots and, if a newer Vault created one, the write-ahead log. This
+// version of Vault has no setting to place them separately, but directories
+// can be symlinked or mounted elsewhere, and a write that is durable on one
+// filesystem but not another leaves them inconsistent after a crash, so it
+// warns when they are not all on the same filesystem.
+func RaftFilesystemsCheck(ctx context.Context, path string) error {
+	partitions, err := disk.Partitions(true)
+	if err != nil {
+		return err
+	}
+	parts := []struct{ name, path string }{
+		{"data", path},
+		{"raft log", filepath.Join(path, "raft")},
+		{"snapshots", filepath.Join(path, "raft", "snapshots")},
+		{"write-ahead log", filepath.Join(path, "raft", "wal")},
+	}
+	var located []string
+	mounts := make(map[string]bool)
+	for _, part := range parts {
+		resolved, err := filepath.EvalSymlinks(part.path)
+		if err != nil {
+			continue
+		}
+		if resolved, err = filepath.Abs(resolved); err != nil {
+			continue
+		}
+		mount := mountOf(resolved, partitions)
+		if mount.Mountpoint == "" {
+			continue
+		}
+		mounts[mount.Mountpoint] = true
+		located = append(located, fmt.Sprintf("%s on %s (%s at %s)", part.name, mount.Device, mount.Fstype, mount.Mountpoint))
+	}
+
+	testName := "raft filesystems"
+	switch {
+	case len(located) == 0:
+		SpotSkipped(ctx, testName, SkipNotApplicable, "the raft path does not exist yet")
+	case len(mounts) > 1:
+		SpotWarn(ctx, testName, "the raft storage is split across filesystems: "+strings.Join(located, ", ")+
+			"; differing durability guarantees can leave them inconsistent after a crash",
+			Advice("Keep the whole raft path on a single filesystem."))
+	default:
+		SpotOk(ctx, testName, strings.Join(located, ", "))
+	}
+	return nil
+}
+
+func isPathWithin(path, dir string) bool {
+	if path == dir {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// ProcessLimits returns the open file and memory limits the server process
+// will run under. A limit of zero means it could not be determined.
+func ProcessLimits() (fdLimit, memoryLimit uint64) {
+	fdLimit = openFileLimit()
+	if vm, err := mem.VirtualMemory(); err == nil {
+		memoryLimit = vm.Total
+	}
+	if cg := cgroupMemoryLimit(); cg != 0 && (memoryLimit == 0 || cg < memoryLimit) {
+		memoryLimit = cg
+	}
+	return fdLimit, memoryLimit
+}
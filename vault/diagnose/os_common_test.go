@@ -0,0 +1,68 @@
+// +build !openbsd !arm
+
+package diagnose
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsPathWithin(t *testing.T) {
+	testCases := []struct {
+		path, dir string
+		expected  bool
+	}{
+		{"/var/lib/vault", "/", true},
+		{"/var/lib/vault", "/var/lib", true},
+		{"/var/lib/vault", "/var/lib/vault", true},
+		{"/var/lib/vault", "/var/li", false},
+		{"/var/lib", "/var/lib/vault", false},
+	}
+	for _, tc := range testCases {
+		if isPathWithin(tc.path, tc.dir) != tc.expected {
+			t.Errorf("isPathWithin(%q, %q) != %t", tc.path, tc.dir, tc.expected)
+		}
+	}
+}
+
+func TestStorageFilesystemCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-fs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := StorageFilesystemCheck(context.Background(), dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRaftFilesystemsCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-raft-fs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "raft", "snapshots"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	results := checkResults(t, func(ctx context.Context) {
+		if err := RaftFilesystemsCheck(ctx, dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := RaftFilesystemsCheck(ctx, filepath.Join(dir, "missing")); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(results) != 2 || results[0].Status != OkStatus || results[1].Status != SkippedStatus {
+		t.Fatalf("unexpected results: %#v", results)
+	}
+	if !strings.Contains(results[0].Message, "snapshots on ") {
+		t.Fatalf("expected the snapshots to be located, got %q", results[0].Message)
+	}
+}
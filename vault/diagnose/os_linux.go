@@ -0,0 +1,377 @@
+// +build linux
+
+package diagnose
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	procRoot    = "/proc"
+	procSysRoot = "/proc/sys"
+	etcRoot     = "/etc"
+	cgroupRoot  = "/sys/fs/cgroup"
+
+	minSomaxconn      = 1024
+	minEphemeralPorts = 10000
+)
+
+// readSysctl reads the value of a dotted kernel parameter name from the given
+// procfs sys root.
+func readSysctl(root, name string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, strings.ReplaceAll(name, ".", "/")))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// kernelNetworkChecks reports kernel parameters that limit connection handling,
+// warning when they are set to values likely to bottleneck a busy server.
+func kernelNetworkChecks(ctx context.Context) {
+	checkKernelNetworkParams(ctx, procSysRoot)
+}
+
+func checkKernelNetworkParams(ctx context.Context, root string) {
+	testName := "kernel network parameters"
+
+	if v, err := readSysctl(root, "net.core.somaxconn"); err != nil {
+		Warn(ctx, fmt.Sprintf("could not read net.core.somaxconn: %v", err))
+	} else if n, err := strconv.Atoi(v); err != nil {
+		Warn(ctx, fmt.Sprintf("could not parse net.core.somaxconn value %q", v))
+	} else if n < minSomaxconn {
+		SpotWarn(ctx, testName, fmt.Sprintf("net.core.somaxconn is %d, which may limit the listen backlog under load; consider at least %d", n, minSomaxconn))
+	} else {
+		SpotOk(ctx, testName, fmt.Sprintf("net.core.somaxconn is %d", n))
+	}
+
+	if v, err := readSysctl(root, "net.ipv4.ip_local_port_range"); err != nil {
+		Warn(ctx, fmt.Sprintf("could not read net.ipv4.ip_local_port_range: %v", err))
+	} else {
+		fields := strings.Fields(v)
+		var low, high int
+		if len(fields) == 2 {
+			low, err = strconv.Atoi(fields[0])
+			if err == nil {
+				high, err = strconv.Atoi(fields[1])
+			}
+		}
+		switch {
+		case len(fields) != 2 || err != nil:
+			Warn(ctx, fmt.Sprintf("could not parse net.ipv4.ip_local_port_range value %q", v))
+		case high-low+1 < minEphemeralPorts:
+			SpotWarn(ctx, testName, fmt.Sprintf("net.ipv4.ip_local_port_range is %d-%d, which leaves only %d ephemeral ports for outbound connections",
+				low, high, high-low+1))
+		default:
+			SpotOk(ctx, testName, fmt.Sprintf("net.ipv4.ip_local_port_range is %d-%d", low, high))
+		}
+	}
+
+	if v, err := readSysctl(root, "net.ipv4.tcp_tw_reuse"); err != nil {
+		Warn(ctx, fmt.Sprintf("could not read net.ipv4.tcp_tw_reuse: %v", err))
+	} else if v == "0" {
+		SpotWarn(ctx, testName, "net.ipv4.tcp_tw_reuse is disabled, so sockets in TIME_WAIT cannot be reused for new outbound connections")
+	} else {
+		SpotOk(ctx, testName, fmt.Sprintf("net.ipv4.tcp_tw_reuse is %s", v))
+	}
+}
+
+// MlockCheck reports whether the process can lock its memory, as Vault does
+// unless disable_mlock is set, which needs either CAP_IPC_LOCK or an unlimited
+// RLIMIT_MEMLOCK.
+func MlockCheck(ctx context.Context) {
+	info, err := readContainerInfo(procRoot, "/", os.Getenv)
+	if err != nil {
+		SpotError(ctx, "mlock", err)
+		return
+	}
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &limit); err != nil {
+		SpotError(ctx, "mlock", fmt.Errorf("could not determine the locked memory limit: %w", err))
+		return
+	}
+	checkMlock(ctx, info.capIPCLock, limit.Cur)
+}
+
+func checkMlock(ctx context.Context, capIPCLock bool, limit uint64) {
+	switch {
+	case capIPCLock:
+		SpotOk(ctx, "mlock", "CAP_IPC_LOCK is in the effective capability set, so memory can be locked")
+	case limit == unix.RLIM_INFINITY:
+		SpotOk(ctx, "mlock", "the locked memory limit is unlimited, so memory can be locked")
+	default:
+		SpotWarn(ctx, "mlock", fmt.Sprintf("without CAP_IPC_LOCK, memory locking is limited to %d bytes, "+
+			"so a server with mlock enabled will fail to start", limit),
+			Advice("Grant the capability with setcap cap_ipc_lock=+ep on the vault binary, or set disable_mlock = true "+
+				"where swap is disabled or encrypted."))
+	}
+}
+
+// RandomSourceCheck confirms that the process can read randomness, which the
+// server's secure random reader needs to generate keys during core
+// initialization. Go reads it with getrandom(2), and falls back to
+// /dev/urandom when the syscall fails, so both are probed: a restrictive
+// seccomp profile can block the syscall, and a minimal container may lack the
+// device. getrandom is called without blocking, so an uninitialized entropy
+// pool is reported rather than waited for.
+func RandomSourceCheck(ctx context.Context) {
+	buf := make([]byte, 16)
+	_, getrandomErr := unix.Getrandom(buf, unix.GRND_NONBLOCK)
+	var urandomErr error
+	if f, err := os.Open("/dev/urandom"); err != nil {
+		urandomErr = err
+	} else {
+		_, urandomErr = f.Read(buf)
+		f.Close()
+	}
+	checkRandomSource(ctx, getrandomErr, urandomErr)
+}
+
+func checkRandomSource(ctx context.Context, getrandomErr, urandomErr error) {
+	testName := "random-source"
+	describe := func(err error) string {
+		switch {
+		case errors.Is(err, unix.ENOSYS):
+			return "not supported by the kernel or blocked by seccomp (ENOSYS)"
+		case errors.Is(err, unix.EPERM):
+			return "blocked by seccomp (EPERM)"
+		}
+		return err.Error()
+	}
+	switch {
+	case getrandomErr == nil:
+		SpotOk(ctx, testName, "randomness is read with getrandom(2)")
+	case errors.Is(getrandomErr, unix.EAGAIN):
+		SpotWarn(ctx, testName, "getrandom(2) works, but the kernel's entropy pool is not yet initialized, so "+
+			"core initialization will block until it is",
+			Advice("Run an entropy daemon such as rngd or haveged, or enable a hardware RNG such as virtio-rng."))
+	case urandomErr == nil:
+		SpotOk(ctx, testName, fmt.Sprintf("randomness is read from /dev/urandom, since getrandom(2) is %s",
+			describe(getrandomErr)))
+	default:
+		SpotError(ctx, testName, fmt.Errorf("no randomness source is available, so core initialization will fail: "+
+			"getrandom(2) is %s, and /dev/urandom can't be read: %w", describe(getrandomErr), urandomErr),
+			Advice("Allow the getrandom syscall in the container's seccomp profile, or make /dev/urandom available."))
+	}
+}
+
+// pluginCanMlock reports whether a plugin executed from path can lock its
+// memory. Plugins don't inherit the server's capabilities across exec, but
+// they do inherit RLIMIT_MEMLOCK, so they need either an unlimited limit or
+// CAP_IPC_LOCK in the permitted set of their own file capabilities.
+func pluginCanMlock(path string) bool {
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &limit); err == nil && limit.Cur == unix.RLIM_INFINITY {
+		return true
+	}
+	// The xattr is a vfs_cap_data: a magic and flags word, followed by the
+	// permitted and inheritable sets' low words.
+	buf := make([]byte, 24)
+	n, err := unix.Getxattr(path, "security.capability", buf)
+	if err != nil || n < 8 {
+		return false
+	}
+	return binary.LittleEndian.Uint32(buf[4:8])&(1<<capIPCLock) != 0
+}
+
+// tcpSocketOptions reads the TCP socket options in effect on conn.
+func tcpSocketOptions(conn *net.TCPConn) (tcpOptions, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return tcpOptions{}, err
+	}
+	var opts tcpOptions
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		get := func(level, opt int) int {
+			v, err := unix.GetsockoptInt(int(fd), level, opt)
+			if err != nil && sockErr == nil {
+				sockErr = fmt.Errorf("could not read socket option %d: %w", opt, err)
+			}
+			return v
+		}
+		opts.noDelay = get(unix.IPPROTO_TCP, unix.TCP_NODELAY) != 0
+		opts.keepAlive = get(unix.SOL_SOCKET, unix.SO_KEEPALIVE) != 0
+		opts.keepAliveIdle = time.Duration(get(unix.IPPROTO_TCP, unix.TCP_KEEPIDLE)) * time.Second
+		opts.keepAliveInterval = time.Duration(get(unix.IPPROTO_TCP, unix.TCP_KEEPINTVL)) * time.Second
+		opts.keepAliveCount = get(unix.IPPROTO_TCP, unix.TCP_KEEPCNT)
+	})
+	if err != nil {
+		return tcpOptions{}, err
+	}
+	return opts, sockErr
+}
+
+// corePattern returns the kernel's core_pattern, or an empty string if it
+// cannot be read.
+func corePattern() string {
+	pattern, _ := readSysctl(procSysRoot, "kernel.core_pattern")
+	return pattern
+}
+
+// cgroupMemoryLimit returns the memory limit of the process's cgroup, checking
+// the cgroup v2 and then the v1 location, or zero if there is none.
+func cgroupMemoryLimit() uint64 {
+	for _, name := range []string{"memory.max", "memory/memory.limit_in_bytes"} {
+		data, err := ioutil.ReadFile(filepath.Join(cgroupRoot, name))
+		if err != nil {
+			continue
+		}
+		limit, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			// cgroup v2 reports "max" when unlimited
+			continue
+		}
+		return limit
+	}
+	return 0
+}
+
+// cgroupCPULimit returns the number of CPUs the process's cgroup quota
+// allows, checking the cgroup v2 and then the v1 location, or zero if there
+// is no quota.
+func cgroupCPULimit() float64 {
+	return readCgroupCPULimit(cgroupRoot)
+}
+
+func readCgroupCPULimit(root string) float64 {
+	var quota, period string
+	if data, err := ioutil.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		// cgroup v2 reports "$MAX $PERIOD", where $MAX is "max" when unlimited
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 {
+			quota, period = fields[0], fields[1]
+		}
+	} else {
+		q, qErr := ioutil.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+		p, pErr := ioutil.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+		if qErr != nil || pErr != nil {
+			return 0
+		}
+		quota, period = strings.TrimSpace(string(q)), strings.TrimSpace(string(p))
+	}
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return q / p
+}
+
+// timeSyncDaemons maps the process names of time synchronization daemons, as
+// truncated by the kernel to 15 characters, to their usual names.
+var timeSyncDaemons = map[string]string{
+	"chronyd":         "chronyd",
+	"ntpd":            "ntpd",
+	"systemd-timesyn": "systemd-timesyncd",
+}
+
+// timeSyncChecks reports the running time synchronization daemon, warning
+// when it is configured to step the clock rather than slew it. A large step
+// looks to raft like a stalled or runaway node and can cost leadership.
+func timeSyncChecks(ctx context.Context) {
+	checkTimeSync(ctx, procRoot, etcRoot)
+}
+
+func checkTimeSync(ctx context.Context, procRoot, etcRoot string) {
+	testName := "time synchronization"
+
+	daemon, cmdline := findTimeSyncDaemon(procRoot)
+	switch daemon {
+	case "":
+		SpotWarn(ctx, testName, "no time synchronization daemon (chronyd, ntpd or systemd-timesyncd) is running; "+
+			"raft relies on closely synchronized clocks")
+	case "chronyd":
+		step := configDirective(filepath.Join(etcRoot, "chrony.conf"), "makestep")
+		if step == nil {
+			step = configDirective(filepath.Join(etcRoot, "chrony", "chrony.conf"), "makestep")
+		}
+		switch {
+		case step == nil:
+			SpotOk(ctx, testName, "chronyd slews the clock and never steps it")
+		case len(step) >= 2 && step[1] == "-1":
+			SpotWarn(ctx, testName, fmt.Sprintf("chronyd may step the clock at any time (makestep %s); "+
+				"limit steps to startup, for example with makestep 1.0 3", strings.Join(step, " ")))
+		default:
+			SpotOk(ctx, testName, fmt.Sprintf("chronyd slews the clock, stepping only at startup (makestep %s)", strings.Join(step, " ")))
+		}
+	case "ntpd":
+		tinker := configDirective(filepath.Join(etcRoot, "ntp.conf"), "tinker")
+		slew := len(tinker) == 2 && tinker[0] == "step" && tinker[1] == "0"
+		for _, arg := range cmdline {
+			if arg == "-x" {
+				slew = true
+			}
+		}
+		if slew {
+			SpotOk(ctx, testName, "ntpd slews the clock and never steps it")
+		} else {
+			SpotWarn(ctx, testName, "ntpd steps the clock when it is more than 128ms off; "+
+				"start it with -x or set tinker step 0 so that it slews instead")
+		}
+	default:
+		SpotInfo(ctx, testName, fmt.Sprintf("%s slews small offsets and steps the clock only when it is far off", daemon))
+	}
+}
+
+// findTimeSyncDaemon returns the name and command line of the first time
+// synchronization daemon found among the running processes.
+func findTimeSyncDaemon(procRoot string) (string, []string) {
+	entries, err := ioutil.ReadDir(procRoot)
+	if err != nil {
+		return "", nil
+	}
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		comm, err := ioutil.ReadFile(filepath.Join(procRoot, e.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+		daemon, ok := timeSyncDaemons[strings.TrimSpace(string(comm))]
+		if !ok {
+			continue
+		}
+		cmdline, _ := ioutil.ReadFile(filepath.Join(procRoot, e.Name(), "cmdline"))
+		return daemon, strings.Split(string(bytes.TrimRight(cmdline, "\x00")), "\x00")
+	}
+	return "", nil
+}
+
+// configDirective returns the arguments of the last occurrence of directive in
+// a whitespace-separated configuration file, or nil if it is not present.
+func configDirective(path, directive string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var args []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && fields[0] == directive {
+			args = fields[1:]
+		}
+	}
+	return args
+}
@@ -0,0 +1,175 @@
+// +build linux
+
+package diagnose
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestReadSysctl(t *testing.T) {
+	root, err := ioutil.TempDir("", "diagnose-sysctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := os.MkdirAll(filepath.Join(root, "net", "ipv4"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "net", "ipv4", "ip_local_port_range"), []byte("32768\t60999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := readSysctl(root, "net.ipv4.ip_local_port_range")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "32768\t60999" {
+		t.Fatalf("unexpected value %q", v)
+	}
+	if _, err := readSysctl(root, "net.core.somaxconn"); err == nil {
+		t.Fatalf("expected an error reading a missing parameter")
+	}
+
+	// Missing parameters must only produce warnings
+	checkKernelNetworkParams(context.Background(), root)
+}
+
+func TestCheckTimeSync(t *testing.T) {
+	root, err := ioutil.TempDir("", "diagnose-timesync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	procRoot := filepath.Join(root, "proc")
+	etcRoot := filepath.Join(root, "etc")
+	if err := os.MkdirAll(filepath.Join(procRoot, "42"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(etcRoot, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(path, data string) {
+		t.Helper()
+		if err := ioutil.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		comm    string
+		cmdline string
+		conf    string
+		data    string
+		status  status
+	}{
+		{"none", "bash\n", "bash\x00", "", "", WarningStatus},
+		{"chrony startup", "chronyd\n", "/usr/sbin/chronyd\x00", "chrony.conf", "pool pool.ntp.org iburst\nmakestep 1.0 3\n", OkStatus},
+		{"chrony always", "chronyd\n", "/usr/sbin/chronyd\x00", "chrony.conf", "makestep 1.0 -1\n", WarningStatus},
+		{"ntpd stepping", "ntpd\n", "/usr/sbin/ntpd\x00-g\x00", "ntp.conf", "server pool.ntp.org\n", WarningStatus},
+		{"ntpd slewing", "ntpd\n", "/usr/sbin/ntpd\x00-x\x00", "ntp.conf", "server pool.ntp.org\n", OkStatus},
+		{"timesyncd", "systemd-timesyn\n", "/lib/systemd/systemd-timesyncd\x00", "", "", InfoStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			write(filepath.Join(procRoot, "42", "comm"), tc.comm)
+			write(filepath.Join(procRoot, "42", "cmdline"), tc.cmdline)
+			if tc.conf != "" {
+				write(filepath.Join(etcRoot, tc.conf), tc.data)
+				defer os.Remove(filepath.Join(etcRoot, tc.conf))
+			}
+			results := checkResults(t, func(ctx context.Context) {
+				checkTimeSync(ctx, procRoot, etcRoot)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
+
+func TestReadCgroupCPULimit(t *testing.T) {
+	root, err := ioutil.TempDir("", "diagnose-cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if limit := readCgroupCPULimit(root); limit != 0 {
+		t.Fatalf("expected no limit without cgroup files, got %v", limit)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "cpu"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"), []byte("-1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"), []byte("100000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if limit := readCgroupCPULimit(root); limit != 0 {
+		t.Fatalf("expected no limit for an unlimited v1 quota, got %v", limit)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(root, "cpu.max"), []byte("150000 100000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if limit := readCgroupCPULimit(root); limit != 1.5 {
+		t.Fatalf("expected a limit of 1.5 CPUs, got %v", limit)
+	}
+}
+
+func TestCheckMlock(t *testing.T) {
+	testCases := []struct {
+		name       string
+		capIPCLock bool
+		limit      uint64
+		status     status
+	}{
+		{"capability", true, 65536, OkStatus},
+		{"unlimited", false, unix.RLIM_INFINITY, OkStatus},
+		{"limited", false, 65536, WarningStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkMlock(ctx, tc.capIPCLock, tc.limit)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
+
+func TestCheckRandomSource(t *testing.T) {
+	urandomMissing := &os.PathError{Op: "open", Path: "/dev/urandom", Err: unix.ENOENT}
+	testCases := []struct {
+		name         string
+		getrandomErr error
+		urandomErr   error
+		status       status
+	}{
+		{"getrandom", nil, nil, OkStatus},
+		{"urandom fallback", unix.EPERM, nil, OkStatus},
+		{"entropy pool uninitialized", unix.EAGAIN, nil, WarningStatus},
+		{"none", unix.ENOSYS, urandomMissing, ErrorStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkRandomSource(ctx, tc.getrandomErr, tc.urandomErr)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+// +build !linux
+
+package diagnose
+
+import (
+	"context"
+	"net"
+)
+
+func kernelNetworkChecks(ctx context.Context) {
+	SpotSkipped(ctx, "kernel network parameters", SkipNotApplicablePlatform, "unsupported on this platform")
+}
+
+func timeSyncChecks(ctx context.Context) {
+	SpotSkipped(ctx, "time synchronization", SkipNotApplicablePlatform, "unsupported on this platform")
+}
+
+func cgroupMemoryLimit() uint64 {
+	return 0
+}
+
+func cgroupCPULimit() float64 {
+	return 0
+}
+
+func corePattern() string {
+	return ""
+}
+
+// MlockCheck is skipped outside Linux, where the capabilities that govern
+// memory locking differ.
+func MlockCheck(ctx context.Context) {
+	SpotSkipped(ctx, "mlock", SkipNotApplicablePlatform, "unsupported on this platform")
+}
+
+// RandomSourceCheck is skipped outside Linux, where the platform's randomness
+// source isn't restricted by container profiles.
+func RandomSourceCheck(ctx context.Context) {
+	SpotSkipped(ctx, "random-source", SkipNotApplicablePlatform, "unsupported on this platform")
+}
+
+// pluginCanMlock is true outside Linux, where plugins either can lock their
+// memory or, without mlock support, don't try.
+func pluginCanMlock(path string) bool {
+	return true
+}
+
+func tcpSocketOptions(conn *net.TCPConn) (tcpOptions, error) {
+	return tcpOptions{}, errSocketOptionsUnsupported
+}
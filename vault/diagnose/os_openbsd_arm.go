@@ -5,6 +5,20 @@ package diagnose
 import "context"
 
 func diskUsage(ctx context.Context) error {
-	SpotSkipped(ctx, "disk usage", "unsupported on this platform")
+	SpotSkipped(ctx, "disk usage", SkipNotApplicablePlatform, "unsupported on this platform")
 	return nil
 }
+
+func StorageFilesystemCheck(ctx context.Context, path string) error {
+	SpotSkipped(ctx, "storage filesystem", SkipNotApplicablePlatform, "unsupported on this platform")
+	return nil
+}
+
+func RaftFilesystemsCheck(ctx context.Context, path string) error {
+	SpotSkipped(ctx, "raft filesystems", SkipNotApplicablePlatform, "unsupported on this platform")
+	return nil
+}
+
+func ProcessLimits() (fdLimit, memoryLimit uint64) {
+	return 0, 0
+}
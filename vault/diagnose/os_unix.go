@@ -4,10 +4,26 @@ package diagnose
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
 	"golang.org/x/sys/unix"
 )
 
+// maxOwnershipEntries bounds the number of entries StorageOwnershipCheck
+// inspects, as a file storage directory can hold one file per key.
+const maxOwnershipEntries = 10000
+
+// errOwnershipLimit stops the walk of the storage directory once
+// maxOwnershipEntries have been inspected.
+var errOwnershipLimit = errors.New("ownership entry limit reached")
+
 func OSChecks(ctx context.Context) {
 	ctx, span := StartSpan(ctx, "operating system")
 	defer span.End()
@@ -27,5 +43,142 @@ func OSChecks(ctx context.Context) {
 		}
 	}
 
+	kernelNetworkChecks(ctx)
+	timeSyncChecks(ctx)
+	coreDumpChecks(ctx)
+	TempDirCheck(ctx)
 	diskUsage(ctx)
 }
+
+// coreDumpChecks reports whether the process can write a core dump, which
+// would put the secrets in Vault's memory on disk.
+func coreDumpChecks(ctx context.Context) {
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_CORE, &limit); err != nil {
+		SpotError(ctx, "core dumps", fmt.Errorf("could not determine the core file size limit: %w", err))
+		return
+	}
+	checkCoreDumps(ctx, uint64(limit.Cur), corePattern())
+}
+
+// checkCoreDumps warns when the core file size limit allows core dumps, and
+// reports where the kernel sends them when pattern, the kernel's core_pattern,
+// is known.
+func checkCoreDumps(ctx context.Context, limit uint64, pattern string) {
+	testName := "core dumps"
+	if limit == 0 {
+		SpotOk(ctx, testName, "disabled by a core file size limit of 0")
+		return
+	}
+	size := fmt.Sprintf("%d bytes", limit)
+	if limit >= math.MaxInt64 {
+		size = "unlimited"
+	}
+	msg := fmt.Sprintf("enabled with a core file size limit of %s", size)
+	switch {
+	case strings.HasPrefix(pattern, "|") && len(strings.Fields(pattern[1:])) > 0:
+		msg += fmt.Sprintf(", and piped to %s, which may store them on disk", strings.Fields(pattern[1:])[0])
+	case pattern != "":
+		msg += fmt.Sprintf(", and written to %s", pattern)
+	}
+	SpotWarn(ctx, testName, msg+"; a core dump of Vault contains the secrets in its memory",
+		Advice("Disable core dumps for Vault, for example with LimitCORE=0 in its systemd unit or ulimit -c 0."))
+}
+
+// openFileLimit returns the effective open file limit, or zero if it cannot be
+// determined.
+func openFileLimit() uint64 {
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &limit); err != nil {
+		return 0
+	}
+	if limit.Max < limit.Cur {
+		return uint64(limit.Max)
+	}
+	return uint64(limit.Cur)
+}
+
+// StorageOwnershipCheck verifies that the storage data directory and its
+// contents are owned by the effective user, or by one of its groups. A
+// directory created by a run as root can't be written by the vault user
+// later, though its permissions look right.
+func StorageOwnershipCheck(ctx context.Context, path string) error {
+	groups, err := os.Getgroups()
+	if err != nil {
+		return fmt.Errorf("could not determine the groups of the process: %w", err)
+	}
+	gids := []uint32{uint32(os.Getegid())}
+	for _, g := range groups {
+		gids = append(gids, uint32(g))
+	}
+	return checkStorageOwnership(ctx, path, uint32(os.Geteuid()), gids)
+}
+
+func checkStorageOwnership(ctx context.Context, path string, uid uint32, gids []uint32) error {
+	testName := "storage-ownership"
+	owned := func(st *syscall.Stat_t) bool {
+		if st.Uid == uid {
+			return true
+		}
+		for _, gid := range gids {
+			if st.Gid == gid {
+				return true
+			}
+		}
+		return false
+	}
+
+	var dir *syscall.Stat_t
+	var mismatched []string
+	entries := 0
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entries == maxOwnershipEntries {
+			return errOwnershipLimit
+		}
+		entries++
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+		if p == path {
+			dir = st
+		}
+		if !owned(st) {
+			mismatched = append(mismatched, fmt.Sprintf("%s (uid %d, gid %d)", p, st.Uid, st.Gid))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errOwnershipLimit) {
+		return fmt.Errorf("could not inspect %s: %w", path, err)
+	}
+	if dir == nil {
+		return fmt.Errorf("could not determine the owner of %s", path)
+	}
+
+	if len(mismatched) > 0 {
+		examples := mismatched
+		if len(examples) > 3 {
+			examples = examples[:3]
+		}
+		SpotError(ctx, testName, fmt.Errorf("%d of %d entries under %s are not owned by uid %d or gids %v, so "+
+			"the server may fail to write them: %s", len(mismatched), entries, path, uid, gids,
+			strings.Join(examples, ", ")),
+			Advice(fmt.Sprintf("Change the owner of %s and its contents to the user Vault runs as, for example "+
+				"with chown -R.", path)))
+		return nil
+	}
+	message := fmt.Sprintf("%s and its contents are owned by uid %d, gid %d", path, dir.Uid, dir.Gid)
+	if errors.Is(err, errOwnershipLimit) {
+		message = fmt.Sprintf("%s and the first %d entries under it are owned by uid %d, gid %d", path, entries,
+			dir.Uid, dir.Gid)
+	}
+	SpotOk(ctx, testName, message)
+	return nil
+}
@@ -0,0 +1,87 @@
+// +build !windows
+
+package diagnose
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckCoreDumps(t *testing.T) {
+	testCases := []struct {
+		name     string
+		limit    uint64
+		pattern  string
+		status   status
+		contains string
+	}{
+		{"disabled", 0, "core", OkStatus, "limit of 0"},
+		{"unlimited", math.MaxUint64, "core", WarningStatus, "written to core"},
+		{"piped", 1 << 20, "|/usr/lib/systemd/systemd-coredump %P %u", WarningStatus, "piped to /usr/lib/systemd/systemd-coredump"},
+		{"unknown pattern", math.MaxInt64, "", WarningStatus, "limit of unlimited"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkCoreDumps(ctx, tc.limit, tc.pattern)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+			if !strings.Contains(results[0].Message, tc.contains) {
+				t.Fatalf("expected %q in %q", tc.contains, results[0].Message)
+			}
+		})
+	}
+}
+
+func TestCheckStorageOwnership(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-ownership")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "raft", "snapshots"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "raft", "raft.db"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	uid, gid := uint32(os.Geteuid()), uint32(os.Getegid())
+
+	testCases := []struct {
+		name     string
+		uid      uint32
+		gids     []uint32
+		status   status
+		contains string
+	}{
+		{"owner", uid, nil, OkStatus, "owned by uid"},
+		{"group", uid + 1, []uint32{gid}, OkStatus, "owned by uid"},
+		{"mismatch", uid + 1, []uint32{gid + 1}, ErrorStatus, "4 of 4 entries"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				if err := checkStorageOwnership(ctx, dir, tc.uid, tc.gids); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if len(results) != 1 {
+				t.Fatalf("expected 1 result, got %d", len(results))
+			}
+			if results[0].Status != tc.status || !strings.Contains(results[0].Message, tc.contains) {
+				t.Fatalf("unexpected result: %s %s", results[0].Status, results[0].Message)
+			}
+		})
+	}
+
+	if err := checkStorageOwnership(context.Background(), filepath.Join(dir, "missing"), uid, nil); err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+}
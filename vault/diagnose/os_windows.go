@@ -9,5 +9,19 @@ import (
 func OSChecks(ctx context.Context) {
 	ctx, span := StartSpan(ctx, "operating system")
 	defer span.End()
+	TempDirCheck(ctx)
 	diskUsage(ctx)
 }
+
+// openFileLimit returns zero, as Windows has no per-process open file limit
+// comparable to RLIMIT_NOFILE.
+func openFileLimit() uint64 {
+	return 0
+}
+
+// StorageOwnershipCheck is skipped on Windows, where access is governed by
+// ACLs rather than file ownership.
+func StorageOwnershipCheck(ctx context.Context, path string) error {
+	Skipped(ctx, SkipNotApplicablePlatform, "file ownership is not checked on Windows")
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"go.opentelemetry.io/otel/attribute"
 	"io"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -23,15 +24,29 @@ const (
 	status_failed  = "\u001b[31m[failed]\u001b[0m "
 	status_warn    = "\u001b[33m[ warn ]\u001b[0m "
 	status_skipped = "\u001b[90m[ skip ]\u001b[0m "
+	status_info    = "\u001b[36m[ info ]\u001b[0m "
 	same_line      = "\x0d"
 	ErrorStatus    = 2
 	WarningStatus  = 1
 	OkStatus       = 0
 	SkippedStatus  = -1
+	InfoStatus     = -2
 )
 
 var errUnimplemented = errors.New("unimplemented")
 
+// ansiEscape matches the color escape sequences in the status prefixes.
+var ansiEscape = regexp.MustCompile("\u001b\\[[0-9;]*m")
+
+// colorize returns prefix unchanged if color is set, and stripped of its
+// color escape sequences otherwise.
+func colorize(prefix string, color bool) string {
+	if color {
+		return prefix
+	}
+	return ansiEscape.ReplaceAllString(prefix, "")
+}
+
 type status int
 
 func (s status) String() string {
@@ -42,6 +57,10 @@ func (s status) String() string {
 		return "warn"
 	case ErrorStatus:
 		return "fail"
+	case SkippedStatus:
+		return "skip"
+	case InfoStatus:
+		return "info"
 	}
 	return "invalid"
 }
@@ -50,6 +69,25 @@ func (s status) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprint("\"", s.String(), "\"")), nil
 }
 
+func (s *status) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), "\"") {
+	case "ok":
+		*s = OkStatus
+	case "warn":
+		*s = WarningStatus
+	case "fail":
+		*s = ErrorStatus
+	case "skip", "invalid":
+		// Older versions wrote skipped results as "invalid"
+		*s = SkippedStatus
+	case "info":
+		*s = InfoStatus
+	default:
+		return fmt.Errorf("unknown status %s", data)
+	}
+	return nil
+}
+
 type Result struct {
 	Time     time.Time `json:"time"`
 	Name     string    `json:"name"`
@@ -58,6 +96,56 @@ type Result struct {
 	Message  string    `json:"message,omitempty"`
 	Advice   string
 	Children []*Result `json:"children,omitempty"`
+
+	// SkipReason is set on skipped results only.
+	SkipReason SkipReason `json:"skip_reason,omitempty"`
+
+	// HealthScore is set on the root result only; see Summary.HealthScore.
+	HealthScore *int `json:"health_score,omitempty"`
+
+	// Labels are set on the root result only, from -label. They are passed
+	// through to the output for the caller's own filtering, and not
+	// interpreted.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Metrics are the measurements the check took; see RecordMetric.
+	Metrics []Metric `json:"metrics,omitempty"`
+
+	// elapsed is how long the span behind the result ran, shown next to the
+	// top-level sections of the human readable output.
+	elapsed time.Duration
+}
+
+// Metric is a measurement a check took, such as the median fsync latency.
+type Metric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
+// CheckMetric is a metric with the name of the check that recorded it.
+type CheckMetric struct {
+	Check string `json:"check"`
+	Metric
+}
+
+// AllMetrics returns the metrics recorded by r and its descendants, in result
+// order.
+func (r *Result) AllMetrics() []CheckMetric {
+	return r.appendMetrics(nil)
+}
+
+func (r *Result) appendMetrics(metrics []CheckMetric) []CheckMetric {
+	if r == nil {
+		return metrics
+	}
+	for _, m := range r.Metrics {
+		metrics = append(metrics, CheckMetric{Check: r.Name, Metric: m})
+	}
+	for _, c := range r.Children {
+		metrics = c.appendMetrics(metrics)
+	}
+	return metrics
 }
 
 func (r *Result) finalize() status {
@@ -79,9 +167,82 @@ func (r *Result) finalize() status {
 	return maxStatus
 }
 
+// Summary counts the checks in a results tree by status.
+type Summary struct {
+	Ok       int
+	Info     int
+	Warnings int
+	Errors   int
+	Skipped  int
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%d ok, %d info, %d warnings, %d errors, %d skipped", s.Ok, s.Info, s.Warnings, s.Errors, s.Skipped)
+}
+
+// HealthScore condenses the summary into a number from 0 to 100 for
+// dashboards. Each ok check earns 1 point and each warning half a point,
+// while errors count four times against the total:
+//
+//	score = floor(100 * (ok + warnings/2) / (ok + warnings + 4*errors))
+//
+// Info and skipped checks don't affect the score, and a run with no scored
+// checks has a score of 100.
+func (s Summary) HealthScore() int {
+	total := s.Ok + s.Warnings + 4*s.Errors
+	if total == 0 {
+		return 100
+	}
+	return (200*s.Ok + 100*s.Warnings) / (2 * total)
+}
+
+// Summarize counts the leaf checks of the results tree by status. Sections
+// aren't counted, since their status is derived from their children.
+func (r *Result) Summarize() Summary {
+	var s Summary
+	r.summarize(&s)
+	return s
+}
+
+func (r *Result) summarize(s *Summary) {
+	if len(r.Children) > 0 {
+		for _, c := range r.Children {
+			c.summarize(s)
+		}
+		return
+	}
+	switch r.Status {
+	case OkStatus:
+		s.Ok++
+	case InfoStatus:
+		s.Info++
+	case WarningStatus:
+		s.Warnings++
+	case ErrorStatus:
+		s.Errors++
+	case SkippedStatus:
+		s.Skipped++
+	}
+}
+
+// Find returns the first result named name in a depth-first walk of the tree
+// rooted at r, or nil if there is none.
+func (r *Result) Find(name string) *Result {
+	if r.Name == name {
+		return r
+	}
+	for _, c := range r.Children {
+		if found := c.Find(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
 func (r *Result) ZeroTimes() {
 	var zero time.Time
 	r.Time = zero
+	r.elapsed = 0
 	for _, c := range r.Children {
 		c.ZeroTimes()
 	}
@@ -95,6 +256,7 @@ type TelemetryCollector struct {
 	rootSpan   sdktrace.ReadOnlySpan
 	results    map[trace.SpanID]*Result
 	RootResult *Result
+	color      bool
 	mu         sync.Mutex
 }
 
@@ -106,6 +268,7 @@ func NewTelemetryCollector(w io.Writer) *TelemetryCollector {
 		ui:      w,
 		spans:   make(map[trace.SpanID]sdktrace.ReadOnlySpan),
 		results: make(map[trace.SpanID]*Result),
+		color:   true,
 	}
 }
 
@@ -115,7 +278,7 @@ func (t *TelemetryCollector) OnStart(_ context.Context, s sdktrace.ReadWriteSpan
 	defer t.mu.Unlock()
 	t.spans[s.SpanContext().SpanID()] = s
 	if isMainSection(s) {
-		fmt.Fprintf(t.ui, status_unknown+s.Name())
+		fmt.Fprintf(t.ui, colorize(status_unknown, t.color)+s.Name())
 	}
 }
 
@@ -153,7 +316,7 @@ func (t *TelemetryCollector) OnEnd(e sdktrace.ReadOnlySpan) {
 		r := t.getOrBuildResult(e.SpanContext().SpanID())
 		if r != nil {
 			fmt.Print(same_line)
-			fmt.Fprintln(t.ui, r.String())
+			fmt.Fprintln(t.ui, r.stringWrapped(80, t.color))
 		}
 	}
 }
@@ -180,6 +343,9 @@ func (t *TelemetryCollector) getOrBuildResult(id trace.SpanID) *Result {
 			Message: s.StatusMessage(),
 			Time:    s.StartTime(),
 		}
+		if !s.EndTime().IsZero() {
+			r.elapsed = s.EndTime().Sub(s.StartTime())
+		}
 		for _, e := range s.Events() {
 			switch e.Name {
 			case warningEventName:
@@ -190,6 +356,8 @@ func (t *TelemetryCollector) getOrBuildResult(id trace.SpanID) *Result {
 				}
 			case skippedEventName:
 				r.Status = SkippedStatus
+				reason, _ := findAttributes(e, skipReasonKey, "")
+				r.SkipReason = SkipReason(reason)
 			case "fail":
 				message, action := findAttributes(e, errorMessageKey, actionKey)
 				if message != "" && action != "" {
@@ -234,16 +402,42 @@ func (t *TelemetryCollector) getOrBuildResult(id trace.SpanID) *Result {
 						})
 				}
 			case spotCheckSkippedEventName:
+				checkName, message := findAttributes(e, nameKey, messageKey)
+				reason, _ := findAttributes(e, skipReasonKey, "")
+				if checkName != "" {
+					r.Children = append(r.Children,
+						&Result{
+							Name:       checkName,
+							Status:     SkippedStatus,
+							Message:    message,
+							SkipReason: SkipReason(reason),
+							Time:       e.Time,
+						})
+				}
+			case spotCheckInfoEventName:
 				checkName, message := findAttributes(e, nameKey, messageKey)
 				if checkName != "" {
 					r.Children = append(r.Children,
 						&Result{
 							Name:    checkName,
-							Status:  SkippedStatus,
+							Status:  InfoStatus,
 							Message: message,
 							Time:    e.Time,
 						})
 				}
+			case metricEventName:
+				m := Metric{}
+				for _, a := range e.Attributes {
+					switch a.Key {
+					case nameKey:
+						m.Name = a.Value.AsString()
+					case metricValueKey:
+						m.Value = a.Value.AsFloat64()
+					case metricUnitKey:
+						m.Unit = a.Value.AsString()
+					}
+				}
+				r.Metrics = append(r.Metrics, m)
 			case adviceEventName:
 				message, _ := findAttributes(e, adviceKey, "")
 				if message != "" {
@@ -288,9 +482,13 @@ func findAttributes(e trace.Event, attr1, attr2 attribute.Key) (string, string)
 
 // Write outputs a human readable version of the results tree
 func (r *Result) Write(writer io.Writer, wrapLimit int) error {
-	var sb strings.Builder
-	r.write(&sb, 0, wrapLimit)
-	_, err := writer.Write([]byte(sb.String()))
+	return r.WriteColor(writer, wrapLimit, true)
+}
+
+// WriteColor writes the results as Write does, with the status prefixes in
+// color only if color is set.
+func (r *Result) WriteColor(writer io.Writer, wrapLimit int, color bool) error {
+	_, err := writer.Write([]byte(r.stringWrapped(wrapLimit, color)))
 	return err
 }
 
@@ -310,12 +508,16 @@ func (r *Result) String() string {
 }
 
 func (r *Result) StringWrapped(wrapLimit int) string {
+	return r.stringWrapped(wrapLimit, true)
+}
+
+func (r *Result) stringWrapped(wrapLimit int, color bool) string {
 	var sb strings.Builder
-	r.write(&sb, 0, wrapLimit)
+	r.write(&sb, 0, wrapLimit, color)
 	return sb.String()
 }
 
-func (r *Result) write(sb *strings.Builder, depth int, limit int) {
+func (r *Result) write(sb *strings.Builder, depth int, limit int, color bool) {
 	indent(sb, depth)
 	var prelude string
 	if len(r.Warnings) == 0 {
@@ -328,8 +530,10 @@ func (r *Result) write(sb *strings.Builder, depth int, limit int) {
 			prelude = status_failed
 		case SkippedStatus:
 			prelude = status_skipped
+		case InfoStatus:
+			prelude = status_info
 		}
-		prelude = prelude + r.Name
+		prelude = colorize(prelude, color) + r.Name
 
 		if r.Message != "" {
 			prelude = prelude + ": " + r.Message
@@ -337,14 +541,17 @@ func (r *Result) write(sb *strings.Builder, depth int, limit int) {
 	}
 	warnings := r.Warnings
 	if r.Message == "" && len(warnings) > 0 {
-		prelude = status_warn + r.Name + ": " + warnings[0]
+		prelude = colorize(status_warn, color) + r.Name + ": " + warnings[0]
 		warnings = warnings[1:]
 	}
+	if depth == 1 && r.elapsed > 0 {
+		prelude += fmt.Sprintf(" (%s)", roundElapsed(r.elapsed))
+	}
 	writeWrapped(sb, prelude, depth+1, limit)
 	for _, w := range warnings {
 		sb.WriteRune('\n')
 		indent(sb, depth)
-		sb.WriteString(status_warn)
+		sb.WriteString(colorize(status_warn, color))
 		sb.WriteString(r.Name)
 		sb.WriteString(": ")
 		writeWrapped(sb, w, depth+1, limit)
@@ -358,8 +565,17 @@ func (r *Result) write(sb *strings.Builder, depth int, limit int) {
 	}
 	sb.WriteRune('\n')
 	for _, c := range r.Children {
-		c.write(sb, depth+1, limit)
+		c.write(sb, depth+1, limit, color)
+	}
+}
+
+// roundElapsed rounds d to a precision suited to display: tenths of a second
+// from a second up, and milliseconds below.
+func roundElapsed(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(100 * time.Millisecond)
 	}
+	return d.Round(time.Millisecond)
 }
 
 func writeWrapped(sb *strings.Builder, msg string, depth int, limit int) {
@@ -0,0 +1,73 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// PerformanceStandbyCheck reports the effective disable_performance_standby
+// setting. Performance standbys are an Enterprise feature, so the check is
+// skipped in a community build, where EditionCheck reports the setting as
+// ignored.
+func PerformanceStandbyCheck(ctx context.Context, disable bool) {
+	checkPerformanceStandby(ctx, disable, enterpriseBuild)
+}
+
+func checkPerformanceStandby(ctx context.Context, disable, enterprise bool) {
+	testName := "performance-standby"
+	switch {
+	case !enterprise:
+		Skipped(ctx, SkipNotApplicable, "performance standbys are an Enterprise feature")
+	case disable:
+		SpotInfo(ctx, testName, "disable_performance_standby is true, so as a standby this node forwards every "+
+			"request to the active node")
+	default:
+		SpotInfo(ctx, testName, "disable_performance_standby is false, so as a standby this node serves reads as "+
+			"a performance standby where the license includes them")
+	}
+}
+
+// PerformanceStandbyLiveCheck compares the local disable_performance_standby
+// setting with how the running server behaves as a standby. The setting only
+// takes effect at startup and is easily set differently across nodes, so a
+// mismatch means the running server was started with another configuration.
+// Only an Enterprise standby reveals its behavior; otherwise the check is
+// skipped.
+func PerformanceStandbyLiveCheck(ctx context.Context, client *api.Client, disable bool) error {
+	health, err := client.Sys().Health()
+	if err != nil {
+		return fmt.Errorf("could not read sys/health: %w", err)
+	}
+	checkPerformanceStandbyLive(ctx, health, disable)
+	return nil
+}
+
+func checkPerformanceStandbyLive(ctx context.Context, health *api.HealthResponse, disable bool) {
+	testName := "performance-standby"
+	switch {
+	case !strings.Contains(health.Version, "+ent"):
+		Skipped(ctx, SkipNotApplicable, fmt.Sprintf("the running server, version %s, is not Enterprise", health.Version))
+		return
+	case health.Sealed || !health.Standby:
+		Skipped(ctx, SkipNotApplicable, "the running server is not an unsealed standby, so its standby behavior "+
+			"can't be observed; run diagnose on a standby")
+		return
+	}
+
+	switch {
+	case disable && health.PerformanceStandby:
+		SpotWarn(ctx, testName, "the running server is a performance standby, but the local configuration sets "+
+			"disable_performance_standby, so the server was started with a different configuration",
+			Advice("Restart the server so that it applies the configuration, and set the same value on every node."))
+	case !disable && !health.PerformanceStandby:
+		SpotWarn(ctx, testName, "the running server is a standby that forwards every request, but the local "+
+			"configuration allows performance standbys; the server was started with disable_performance_standby "+
+			"set, or the license doesn't include performance standbys",
+			Advice("Set the same disable_performance_standby on every node, and check the license's features."))
+	default:
+		SpotOk(ctx, testName, fmt.Sprintf("the running standby behaves as disable_performance_standby = %t configures", disable))
+	}
+}
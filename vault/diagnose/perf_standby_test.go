@@ -0,0 +1,71 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestCheckPerformanceStandby(t *testing.T) {
+	cases := []struct {
+		name       string
+		disable    bool
+		enterprise bool
+		expected   []status
+	}{
+		{"community", false, false, nil},
+		{"community set", true, false, nil},
+		{"enterprise", false, true, []status{InfoStatus}},
+		{"enterprise disabled", true, true, []status{InfoStatus}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkPerformanceStandby(ctx, tc.disable, tc.enterprise)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %#v", len(tc.expected), results)
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("result %d: expected %s, got %s: %s", i, tc.expected[i], r.Status, r.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckPerformanceStandbyLive(t *testing.T) {
+	perfStandby := &api.HealthResponse{Initialized: true, Standby: true, PerformanceStandby: true, Version: "1.8.0+ent"}
+	standby := &api.HealthResponse{Initialized: true, Standby: true, Version: "1.8.0+ent"}
+	cases := []struct {
+		name     string
+		health   *api.HealthResponse
+		disable  bool
+		expected []status
+	}{
+		{"performance standby", perfStandby, false, []status{OkStatus}},
+		{"disabled but performance standby", perfStandby, true, []status{WarningStatus}},
+		{"standby disabled", standby, true, []status{OkStatus}},
+		{"standby not disabled", standby, false, []status{WarningStatus}},
+		// The enclosing test is skipped, recording no results of its own.
+		{"active", &api.HealthResponse{Initialized: true, Version: "1.8.0+ent"}, false, nil},
+		{"community", &api.HealthResponse{Initialized: true, Standby: true, Version: "1.8.0"}, false, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkPerformanceStandbyLive(ctx, tc.health, tc.disable)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %#v", len(tc.expected), results)
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("result %d: expected %s, got %s: %s", i, tc.expected[i], r.Status, r.Message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// pluginFile is a binary in the plugin directory.
+type pluginFile struct {
+	name string
+	mode os.FileMode
+
+	// canMlock is whether the plugin, once executed, can lock its memory.
+	canMlock bool
+}
+
+// PluginExecutionCheck reports how plugins in the plugin directory are
+// executed, and warns about settings that endanger them or prevent them from
+// starting. The directory and its binaries must not be writable by group or
+// other, since anyone who can write them can add plugins or replace them,
+// which at best keeps them from starting when the checksum registered in the
+// catalog no longer matches. Unless disable_mlock is set, the server also has
+// plugins lock their memory, and a plugin that can't fails to start.
+func PluginExecutionCheck(ctx context.Context, dir string, disableMlock bool) error {
+	if dir == "" {
+		Skipped(ctx, SkipStanzaAbsent, "plugin_directory is not set")
+		return nil
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("could not stat plugin_directory %s: %w", dir, err)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("could not read plugin_directory %s: %w", dir, err)
+	}
+	var plugins []pluginFile
+	for _, e := range entries {
+		if !e.Mode().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		plugins = append(plugins, pluginFile{name: e.Name(), mode: e.Mode(), canMlock: pluginCanMlock(path)})
+	}
+	checkPluginExecution(ctx, dir, info.Mode(), plugins, disableMlock)
+	return nil
+}
+
+func checkPluginExecution(ctx context.Context, dir string, dirMode os.FileMode, plugins []pluginFile, disableMlock bool) {
+	testName := "plugin-execution"
+	mlock := "enabled"
+	if disableMlock {
+		mlock = "disabled, as disable_mlock is set"
+	}
+	SpotInfo(ctx, testName, fmt.Sprintf("plugin_directory %s holds %d plugin binaries; plugins run with memory locking %s",
+		dir, len(plugins), mlock))
+
+	ok := true
+	// File modes don't govern access on Windows.
+	if runtime.GOOS != "windows" {
+		if dirMode.Perm()&0o022 != 0 {
+			ok = false
+			SpotWarn(ctx, testName, fmt.Sprintf("plugin_directory %s has mode %#o, so users other than its owner can "+
+				"add or replace plugin binaries", dir, dirMode.Perm()),
+				Advice(fmt.Sprintf("Run chmod go-w %s.", dir)))
+		}
+		var writable []string
+		for _, p := range plugins {
+			if p.mode.Perm()&0o022 != 0 {
+				writable = append(writable, fmt.Sprintf("%s (%#o)", p.name, p.mode.Perm()))
+			}
+		}
+		if len(writable) > 0 {
+			ok = false
+			SpotWarn(ctx, testName, fmt.Sprintf("the plugin binaries %s are writable by users other than their owner",
+				strings.Join(writable, ", ")),
+				Advice("Remove group and other write permission from the plugin binaries."))
+		}
+	}
+
+	if !disableMlock {
+		var cannot []string
+		for _, p := range plugins {
+			if !p.canMlock {
+				cannot = append(cannot, p.name)
+			}
+		}
+		sort.Strings(cannot)
+		if len(cannot) > 0 {
+			ok = false
+			SpotWarn(ctx, testName, fmt.Sprintf("memory locking is enabled, but the plugins %s can't lock their memory: "+
+				"the locked memory limit isn't unlimited and they lack the cap_ipc_lock file capability, which they "+
+				"don't inherit from the server, so they will fail to start", strings.Join(cannot, ", ")),
+				Advice("Run setcap cap_ipc_lock=+ep on each plugin binary, raise the server's memlock limit to "+
+					"unlimited, or set disable_mlock = true where swap is disabled or encrypted."))
+		}
+	}
+
+	if ok {
+		SpotOk(ctx, testName, "the plugin directory and binaries are protected and plugins can run as configured")
+	}
+}
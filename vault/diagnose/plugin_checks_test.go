@@ -0,0 +1,74 @@
+package diagnose
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestPluginExecutionCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "vault-plugin-secrets-kv"), []byte("plugin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	results := checkResults(t, func(ctx context.Context) {
+		if err := PluginExecutionCheck(ctx, dir, true); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(results) != 2 || results[0].Status != InfoStatus || results[1].Status != OkStatus {
+		t.Fatalf("unexpected results: %#v", results)
+	}
+
+	checkResults(t, func(ctx context.Context) {
+		if err := PluginExecutionCheck(ctx, filepath.Join(dir, "missing"), true); err == nil {
+			t.Fatal("expected an error for a missing plugin directory")
+		}
+	})
+}
+
+func TestCheckPluginExecution(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not checked on Windows")
+	}
+	kv := pluginFile{name: "vault-plugin-secrets-kv", mode: 0o755, canMlock: true}
+	cases := []struct {
+		name         string
+		dirMode      os.FileMode
+		plugins      []pluginFile
+		disableMlock bool
+		expected     []status
+	}{
+		{"protected", 0o755, []pluginFile{kv}, false, []status{InfoStatus, OkStatus}},
+		{"writable directory", 0o777, []pluginFile{kv}, false, []status{InfoStatus, WarningStatus}},
+		{"writable plugin", 0o755, []pluginFile{{name: "vault-plugin-auth-jwt", mode: 0o775, canMlock: true}}, false,
+			[]status{InfoStatus, WarningStatus}},
+		{"plugin can't mlock", 0o755, []pluginFile{kv, {name: "vault-plugin-auth-jwt", mode: 0o755}}, false,
+			[]status{InfoStatus, WarningStatus}},
+		{"mlock disabled", 0o755, []pluginFile{{name: "vault-plugin-auth-jwt", mode: 0o755}}, true,
+			[]status{InfoStatus, OkStatus}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkPluginExecution(ctx, "/etc/vault/plugins", tc.dirMode, tc.plugins, tc.disableMlock)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %#v", len(tc.expected), results)
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("result %d: expected %s, got %s: %s", i, tc.expected[i], r.Status, r.Message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package diagnose
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	raftchunking "github.com/hashicorp/go-raftchunking"
+	"github.com/hashicorp/raft"
+	"github.com/hashicorp/vault/sdk/helper/parseutil"
+)
+
+// raftDefaultMaxEntrySize mirrors the default applied by the raft storage
+// backend when max_entry_size is not set.
+var raftDefaultMaxEntrySize = uint64(2 * raftchunking.ChunkSize)
+
+const (
+	// raftMaxEntrySizeFloor is the smallest max_entry_size that comfortably
+	// accommodates typical KV secrets, policies and plugin catalog entries.
+	raftMaxEntrySizeFloor = uint64(512 * 1024)
+
+	// raftDefaultPerformanceMultiplier mirrors the default applied by the
+	// raft storage backend when performance_multiplier is not set.
+	raftDefaultPerformanceMultiplier = 5
+
+	// raftMaxPerformanceMultiplier is the largest performance_multiplier
+	// before failover becomes sluggish.
+	raftMaxPerformanceMultiplier = 10
+
+	// raftLargeCluster is the expected cluster size above which the most
+	// aggressive timings risk leadership flapping.
+	raftLargeCluster = 5
+
+	// raftAssumedWriteRate and raftAssumedReplayRate are the sustained write
+	// rate of a busy cluster and the rate at which a node reapplies log
+	// entries to its FSM at startup, in entries per second, used to estimate
+	// the worst-case replay after a crash.
+	raftAssumedWriteRate  = 500
+	raftAssumedReplayRate = 5000
+
+	// raftMaxReplay is the estimated startup replay above which recovering
+	// from a crash is slow enough to matter.
+	raftMaxReplay = time.Minute
+
+	// raftAssumedTransferRate is the rate, in bytes per second, at which the
+	// leader streams a snapshot to a joining peer and the peer installs it,
+	// used to estimate how long a join takes.
+	raftAssumedTransferRate = 25 * 1024 * 1024
+
+	// raftMaxJoinTransfer is the estimated snapshot transfer above which
+	// adding a peer is slow enough to matter when scaling the cluster.
+	raftMaxJoinTransfer = 5 * time.Minute
+)
+
+// RaftMaxEntrySizeCheck reports the effective raft max_entry_size, warning when
+// it is low enough to reject ordinary writes.
+func RaftMaxEntrySizeCheck(ctx context.Context, conf map[string]string) error {
+	testName := "raft-max-entry-size"
+	maxEntrySize := raftDefaultMaxEntrySize
+	if raw := conf["max_entry_size"]; raw != "" {
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return SpotError(ctx, testName, fmt.Errorf("failed to parse 'max_entry_size': %w", err))
+		}
+		maxEntrySize = uint64(i)
+	}
+
+	if maxEntrySize < raftMaxEntrySizeFloor {
+		SpotWarn(ctx, testName, fmt.Sprintf("max_entry_size is %d bytes, which is below %d bytes and may reject "+
+			"ordinary writes such as large KV secrets or plugin registrations", maxEntrySize, raftMaxEntrySizeFloor))
+	} else {
+		SpotOk(ctx, testName, fmt.Sprintf("max_entry_size is %d bytes", maxEntrySize))
+	}
+	return nil
+}
+
+// RaftTimingCheck reports the effective raft election, heartbeat and leader
+// lease timeouts after applying performance_multiplier, warning when they are
+// outside a healthy range for the expected cluster size. The expected size is
+// taken from the number of retry_join stanzas plus this node.
+func RaftTimingCheck(ctx context.Context, conf map[string]string) error {
+	testName := "raft-timing"
+	multiplier, err := raftPerformanceMultiplier(conf)
+	if err != nil {
+		return SpotError(ctx, testName, err)
+	}
+
+	clusterSize := 1
+	if raw := conf["retry_join"]; raw != "" {
+		var joins []interface{}
+		if err := json.Unmarshal([]byte(raw), &joins); err == nil {
+			clusterSize += len(joins)
+		}
+	}
+
+	defaults := raft.DefaultConfig()
+	timings := fmt.Sprintf("election timeout %s, heartbeat timeout %s, leader lease timeout %s (performance_multiplier %d)",
+		defaults.ElectionTimeout*time.Duration(multiplier),
+		defaults.HeartbeatTimeout*time.Duration(multiplier),
+		defaults.LeaderLeaseTimeout*time.Duration(multiplier),
+		multiplier)
+
+	for _, key := range []string{"election_timeout", "heartbeat_timeout"} {
+		if _, ok := conf[key]; ok {
+			SpotWarn(ctx, testName, fmt.Sprintf("%s is not a raft storage option and is ignored; "+
+				"tune the timeouts with performance_multiplier instead", key))
+		}
+	}
+
+	switch {
+	case multiplier > raftMaxPerformanceMultiplier:
+		SpotWarn(ctx, testName, timings+fmt.Sprintf("; a multiplier above %d makes failover slow to detect a failed leader",
+			raftMaxPerformanceMultiplier))
+	case multiplier == 1 && clusterSize > raftLargeCluster:
+		SpotWarn(ctx, testName, timings+fmt.Sprintf("; with an expected %d nodes, the most aggressive timings risk "+
+			"leadership flapping under load", clusterSize))
+	default:
+		SpotOk(ctx, testName, timings)
+	}
+	return nil
+}
+
+// raftPerformanceMultiplier returns the effective performance_multiplier,
+// which scales the raft timeouts.
+func raftPerformanceMultiplier(conf map[string]string) (int, error) {
+	multiplier := raftDefaultPerformanceMultiplier
+	if raw := conf["performance_multiplier"]; raw != "" {
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse 'performance_multiplier': %w", err)
+		}
+		multiplier = i
+	}
+	if multiplier <= 0 {
+		return 0, fmt.Errorf("performance_multiplier is %d, which makes the raft timeouts zero or negative", multiplier)
+	}
+	return multiplier, nil
+}
+
+// RaftPathConfigCheck warns when the raft data directory is inside one of the
+// configuration directories passed to -config, where the files raft writes
+// sit alongside, and can be mistaken for, configuration.
+func RaftPathConfigCheck(ctx context.Context, raftPath string, configPaths []string) {
+	if raftPath == "" {
+		return
+	}
+	data := resolvedPath(raftPath)
+	nested := false
+	for _, cp := range configPaths {
+		if fi, err := os.Stat(cp); err != nil || !fi.IsDir() {
+			continue
+		}
+		dir := resolvedPath(cp)
+		rel, err := filepath.Rel(dir, data)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		SpotWarn(ctx, "raft-path", fmt.Sprintf("the raft path %s is inside the configuration directory %s; "+
+			"move it elsewhere so that data files aren't mixed with configuration", data, dir))
+		nested = true
+	}
+	if !nested {
+		SpotOk(ctx, "raft-path", fmt.Sprintf("%s is outside the configuration directories", data))
+	}
+}
+
+// resolvedPath returns the absolute form of path with symlinks resolved. Only
+// the existing part of the path is resolved, as the raft path may not have
+// been created yet.
+func resolvedPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	var missing []string
+	for {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, missing...)...)
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
+
+// RaftLogStoreCheck reports the raft log store in effect. This version of Vault
+// stores the raft log in BoltDB only; newer versions can use a write-ahead log
+// instead when raft_wal is set. It warns when raft_wal is configured, as it is
+// ignored here, and errors when the raft directory holds a write-ahead log,
+// which this version can't read: the node would start from an empty log
+// rather than the entries it has.
+func RaftLogStoreCheck(ctx context.Context, conf map[string]string) error {
+	testName := "raft-log-store"
+	raftDir := filepath.Join(conf["path"], "raft")
+	boltPath := filepath.Join(raftDir, "raft.db")
+	walPath := filepath.Join(raftDir, "wal")
+
+	if raw, ok := conf["raft_wal"]; ok {
+		wal, err := parseutil.ParseBool(raw)
+		if err != nil {
+			return SpotError(ctx, testName, fmt.Errorf("failed to parse 'raft_wal': %w", err))
+		}
+		if wal {
+			SpotWarn(ctx, testName, "raft_wal is set, but this version of Vault only supports the BoltDB log store, "+
+				"so it is ignored", Advice("Remove raft_wal, or upgrade to a version that supports the "+
+				"write-ahead log store before enabling it."))
+		}
+	}
+
+	_, boltErr := os.Stat(boltPath)
+	walInfo, walErr := os.Stat(walPath)
+	hasWAL := walErr == nil && walInfo.IsDir()
+	switch {
+	case hasWAL && boltErr == nil:
+		SpotWarn(ctx, testName, fmt.Sprintf("%s holds both a BoltDB log store and a write-ahead log; "+
+			"the node last ran with a different log store than this version uses, so the BoltDB log may be stale", raftDir),
+			Advice("Remove the node's raft data and rejoin it to the cluster so that it is rebuilt from a snapshot."))
+	case hasWAL:
+		return SpotError(ctx, testName, fmt.Errorf("%s holds a write-ahead log, which this version of Vault can't read; "+
+			"it would start with an empty BoltDB log instead of the node's raft state", walPath),
+			Advice("Run the version of Vault that wrote the write-ahead log, or remove the node's raft data and "+
+				"rejoin it to the cluster."))
+	case boltErr == nil:
+		SpotOk(ctx, testName, fmt.Sprintf("the raft log is stored in BoltDB at %s", boltPath))
+	default:
+		SpotOk(ctx, testName, fmt.Sprintf("the raft log will be stored in BoltDB at %s", boltPath))
+	}
+	return nil
+}
+
+// RaftSnapshotReplayCheck estimates how long a node replays log entries at
+// startup after a crash, from snapshot_threshold and snapshot_interval, and
+// warns when it could be excessive. A node restarts from the state it last
+// applied and reapplies every entry since its last snapshot. Raft only
+// considers taking a snapshot every one to two snapshot_intervals, and only
+// once snapshot_threshold entries have accumulated, so in the worst case the
+// replay is the threshold plus two intervals of writes. The estimate assumes
+// a busy cluster's write rate and a typical replay rate, so it is a rough
+// guide for planning maintenance windows rather than a measurement.
+func RaftSnapshotReplayCheck(ctx context.Context, conf map[string]string) error {
+	testName := "raft-snapshot-replay"
+	defaults := raft.DefaultConfig()
+	threshold := defaults.SnapshotThreshold
+	if raw := conf["snapshot_threshold"]; raw != "" {
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return SpotError(ctx, testName, fmt.Errorf("failed to parse 'snapshot_threshold': %w", err))
+		}
+		threshold = uint64(i)
+	}
+	interval := defaults.SnapshotInterval
+	if raw := conf["snapshot_interval"]; raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return SpotError(ctx, testName, fmt.Errorf("failed to parse 'snapshot_interval': %w", err))
+		}
+		interval = d
+	}
+
+	entries := threshold + uint64(2*interval.Seconds()*raftAssumedWriteRate)
+	replay := time.Duration(float64(entries) / raftAssumedReplayRate * float64(time.Second)).Round(time.Second)
+	estimate := fmt.Sprintf("with snapshot_threshold %d and snapshot_interval %s, a node may replay up to %d entries "+
+		"after a crash, about %s at %d writes/s and %d replayed entries/s",
+		threshold, interval, entries, replay, raftAssumedWriteRate, raftAssumedReplayRate)
+	if info, err := os.Stat(filepath.Join(conf["path"], "raft", "raft.db")); err == nil {
+		estimate += fmt.Sprintf("; the log store is currently %d MiB", info.Size()/(1024*1024))
+	}
+	if replay > raftMaxReplay {
+		SpotWarn(ctx, testName, estimate,
+			Advice("Lower snapshot_interval or snapshot_threshold so that snapshots are taken more often."))
+		return nil
+	}
+	SpotOk(ctx, testName, estimate)
+	return nil
+}
+
+// RaftJoinTransferCheck estimates how long a peer takes to join, or to catch
+// up after falling behind by more than trailing_logs, from the size of the
+// snapshot it is sent, which is the FSM database in the raft path, and warns
+// when the join would be slow. The leader keeps only trailing_logs entries
+// after compacting its log, so the writes made while the snapshot is sent
+// must fit in them; otherwise the peer needs another snapshot as soon as it
+// installs the first and may never catch up. Like RaftSnapshotReplayCheck,
+// the estimate assumes a busy cluster's write rate and a typical transfer
+// rate.
+func RaftJoinTransferCheck(ctx context.Context, conf map[string]string) error {
+	testName := "raft-join-transfer"
+	trailingLogs := raft.DefaultConfig().TrailingLogs
+	if raw := conf["trailing_logs"]; raw != "" {
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return SpotError(ctx, testName, fmt.Errorf("failed to parse 'trailing_logs': %w", err))
+		}
+		trailingLogs = uint64(i)
+	}
+	info, err := os.Stat(filepath.Join(conf["path"], "vault.db"))
+	if err != nil {
+		SpotInfo(ctx, testName, fmt.Sprintf("there is no FSM database in the raft path yet to estimate the snapshot "+
+			"a joining peer is sent; trailing_logs %d covers about %s of writes at %d writes/s",
+			trailingLogs, raftTrailingLogsCoverage(trailingLogs), raftAssumedWriteRate))
+		return nil
+	}
+	checkRaftJoinTransfer(ctx, trailingLogs, info.Size())
+	return nil
+}
+
+// raftTrailingLogsCoverage is how long the assumed write rate takes to write
+// trailingLogs entries.
+func raftTrailingLogsCoverage(trailingLogs uint64) time.Duration {
+	return time.Duration(float64(trailingLogs) / raftAssumedWriteRate * float64(time.Second)).Round(time.Second)
+}
+
+func checkRaftJoinTransfer(ctx context.Context, trailingLogs uint64, snapshotSize int64) {
+	testName := "raft-join-transfer"
+	transfer := time.Duration(float64(snapshotSize) / raftAssumedTransferRate * float64(time.Second)).Round(time.Second)
+	coverage := raftTrailingLogsCoverage(trailingLogs)
+	estimate := fmt.Sprintf("a joining peer is sent a snapshot of about %d MiB, taking about %s at %d MiB/s, during "+
+		"which about %d entries are written at %d writes/s; trailing_logs %d covers about %s of writes",
+		snapshotSize/(1024*1024), transfer, raftAssumedTransferRate/(1024*1024),
+		uint64(transfer.Seconds()*raftAssumedWriteRate), raftAssumedWriteRate, trailingLogs, coverage)
+	switch {
+	case transfer > coverage:
+		SpotWarn(ctx, testName, estimate+"; the leader may truncate the entries a peer needs before it installs the "+
+			"snapshot, sending it another, so joins may be slow or never complete",
+			Advice(fmt.Sprintf("Raise trailing_logs above %d so that it covers the writes made while a snapshot is sent.",
+				uint64(transfer.Seconds()*raftAssumedWriteRate))))
+	case transfer > raftMaxJoinTransfer:
+		SpotWarn(ctx, testName, estimate+"; adding or rebuilding a peer will be slow",
+			Advice("Plan for slow joins when scaling the cluster, and keep peers from falling more than trailing_logs behind."))
+	default:
+		SpotOk(ctx, testName, estimate)
+	}
+}
+
+// raftPathWriteData is what RaftPathWriteCheck writes and reads back.
+var raftPathWriteData = []byte("vault operator diagnose write check\n")
+
+// RaftPathWriteCheck creates, writes, fsyncs, reads back and deletes a file
+// in the raft path as the diagnose process, which is the most direct test
+// that raft can persist data when the server runs as the same user. Unlike
+// checking permissions, it also catches ACLs, read-only mounts, quotas and
+// security modules such as SELinux. A failure reports the step, the errno and
+// the effective uid and gid.
+func RaftPathWriteCheck(ctx context.Context, path string) error {
+	testName := "raft-path-write"
+	identity := "the current user"
+	if uid := os.Geteuid(); uid >= 0 {
+		identity = fmt.Sprintf("uid %d, gid %d", uid, os.Getegid())
+	}
+	if err := raftPathWrite(path); err != nil {
+		var errno syscall.Errno
+		if errors.As(err, &errno) {
+			err = fmt.Errorf("%w (errno %d)", err, int(errno))
+		}
+		return SpotError(ctx, testName, fmt.Errorf("as %s, %w", identity, err),
+			Advice("Run the server as a user that can write to the raft path, or fix the path's ownership, mode or mount."))
+	}
+	SpotOk(ctx, testName, fmt.Sprintf("created, wrote, fsynced, read back and deleted a file in %s as %s", path, identity))
+	return nil
+}
+
+func raftPathWrite(path string) error {
+	name := filepath.Join(path, fmt.Sprintf(".diagnose-write-%d", os.Getpid()))
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not create a file in %s: %w", path, err)
+	}
+	removed := false
+	defer func() {
+		if !removed {
+			os.Remove(name)
+		}
+	}()
+	if _, err := f.Write(raftPathWriteData); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write to %s: %w", name, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("could not fsync %s: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not close %s: %w", name, err)
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("could not read back %s: %w", name, err)
+	}
+	if !bytes.Equal(data, raftPathWriteData) {
+		return fmt.Errorf("%s read back %d bytes that differ from the %d written", name, len(data), len(raftPathWriteData))
+	}
+	removed = true
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("could not delete %s: %w", name, err)
+	}
+	return nil
+}
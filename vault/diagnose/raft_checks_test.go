@@ -0,0 +1,327 @@
+package diagnose
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+// checkResults runs f inside a diagnose session and returns the results
+// recorded against the enclosing span.
+func checkResults(t *testing.T, f func(ctx context.Context)) []*Result {
+	t.Helper()
+	sess := New(os.Stdout)
+	ctx := Context(context.Background(), sess)
+	func() {
+		ctx, span := StartSpan(ctx, "raft")
+		defer span.End()
+		f(ctx)
+	}()
+	results := sess.Finalize(ctx)
+	results.ZeroTimes()
+	return results.Children
+}
+
+func TestRaftMaxEntrySizeCheck(t *testing.T) {
+	testCases := []struct {
+		name     string
+		conf     map[string]string
+		expected []*Result
+	}{
+		{
+			"default",
+			map[string]string{},
+			[]*Result{{Name: "raft-max-entry-size", Status: OkStatus, Message: "max_entry_size is 1048576 bytes"}},
+		},
+		{
+			"too small",
+			map[string]string{"max_entry_size": "1024"},
+			[]*Result{{Name: "raft-max-entry-size", Status: WarningStatus}},
+		},
+		{
+			"unparseable",
+			map[string]string{"max_entry_size": "big"},
+			[]*Result{{Name: "raft-max-entry-size", Status: ErrorStatus}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				RaftMaxEntrySizeCheck(ctx, tc.conf)
+			})
+			for _, r := range results {
+				if tc.expected[0].Message == "" {
+					r.Message = ""
+				}
+			}
+			if !reflect.DeepEqual(results, tc.expected) {
+				t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results, tc.expected), "\n"))
+			}
+		})
+	}
+}
+
+func TestRaftTimingCheck(t *testing.T) {
+	fiveNodeJoin := `[{"leader_api_addr":"a"},{"leader_api_addr":"b"},{"leader_api_addr":"c"},{"leader_api_addr":"d"},{"leader_api_addr":"e"}]`
+	testCases := []struct {
+		name     string
+		conf     map[string]string
+		expected []*Result
+	}{
+		{
+			"default",
+			map[string]string{},
+			[]*Result{{Name: "raft-timing", Status: OkStatus, Message: "election timeout 5s, heartbeat timeout 5s, " +
+				"leader lease timeout 2.5s (performance_multiplier 5)"}},
+		},
+		{
+			"sluggish",
+			map[string]string{"performance_multiplier": "20"},
+			[]*Result{{Name: "raft-timing", Status: WarningStatus}},
+		},
+		{
+			"aggressive for cluster size",
+			map[string]string{"performance_multiplier": "1", "retry_join": fiveNodeJoin},
+			[]*Result{{Name: "raft-timing", Status: WarningStatus}},
+		},
+		{
+			"zero",
+			map[string]string{"performance_multiplier": "0"},
+			[]*Result{{Name: "raft-timing", Status: ErrorStatus}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				RaftTimingCheck(ctx, tc.conf)
+			})
+			for _, r := range results {
+				if tc.expected[0].Message == "" {
+					r.Message = ""
+				}
+			}
+			if !reflect.DeepEqual(results, tc.expected) {
+				t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results, tc.expected), "\n"))
+			}
+		})
+	}
+}
+
+func TestRaftPathConfigCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-raft-path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configDir := filepath.Join(dir, "config")
+	if err := os.Mkdir(configDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(dir, "vault.hcl")
+	if err := ioutil.WriteFile(configFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		raftPath string
+		configs  []string
+		status   status
+	}{
+		{"nested", filepath.Join(configDir, "raft"), []string{configFile, configDir}, WarningStatus},
+		{"same directory", configDir, []string{configDir}, WarningStatus},
+		{"sibling", filepath.Join(dir, "config-raft"), []string{configDir}, OkStatus},
+		{"config file only", filepath.Join(dir, "raft"), []string{configFile}, OkStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				RaftPathConfigCheck(ctx, tc.raftPath, tc.configs)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
+
+func TestRaftLogStoreCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-raft-log-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	layout := func(name string, files ...string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Join(path, "raft"), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			if f == "wal" {
+				err = os.Mkdir(filepath.Join(path, "raft", f), 0o700)
+			} else {
+				err = ioutil.WriteFile(filepath.Join(path, "raft", f), nil, 0o600)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return path
+	}
+
+	testCases := []struct {
+		name   string
+		conf   map[string]string
+		status status
+	}{
+		{"new", map[string]string{"path": layout("new")}, OkStatus},
+		{"boltdb", map[string]string{"path": layout("boltdb", "raft.db")}, OkStatus},
+		{"raft_wal set", map[string]string{"path": layout("raft_wal", "raft.db"), "raft_wal": "true"}, WarningStatus},
+		{"both stores", map[string]string{"path": layout("both", "raft.db", "wal")}, WarningStatus},
+		{"wal only", map[string]string{"path": layout("wal", "wal")}, ErrorStatus},
+		{"invalid raft_wal", map[string]string{"path": layout("invalid"), "raft_wal": "maybe"}, ErrorStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				RaftLogStoreCheck(ctx, tc.conf)
+			})
+			var worst status = SkippedStatus
+			for _, r := range results {
+				if r.Status > worst {
+					worst = r.Status
+				}
+			}
+			if worst != tc.status {
+				t.Fatalf("expected %s, got %#v", tc.status, results)
+			}
+		})
+	}
+}
+
+func TestRaftSnapshotReplayCheck(t *testing.T) {
+	testCases := []struct {
+		name     string
+		conf     map[string]string
+		expected status
+	}{
+		{"default", map[string]string{}, OkStatus},
+		{"long interval", map[string]string{"snapshot_interval": "10m"}, WarningStatus},
+		{"high threshold", map[string]string{"snapshot_threshold": "1000000"}, WarningStatus},
+		{"frequent", map[string]string{"snapshot_interval": "30s", "snapshot_threshold": "1024"}, OkStatus},
+		{"invalid", map[string]string{"snapshot_interval": "often"}, ErrorStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				RaftSnapshotReplayCheck(ctx, tc.conf)
+			})
+			if len(results) != 1 || results[0].Status != tc.expected {
+				t.Fatalf("expected a %s result, got %#v", tc.expected, results)
+			}
+		})
+	}
+
+	results := checkResults(t, func(ctx context.Context) {
+		RaftSnapshotReplayCheck(ctx, map[string]string{})
+	})
+	expected := "with snapshot_threshold 8192 and snapshot_interval 2m0s, a node may replay up to 128192 entries " +
+		"after a crash, about 26s"
+	if !strings.HasPrefix(results[0].Message, expected) {
+		t.Fatalf("expected %q, got %q", expected, results[0].Message)
+	}
+}
+
+func TestRaftJoinTransferCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-raft-join")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	check := func(conf map[string]string) []*Result {
+		return checkResults(t, func(ctx context.Context) {
+			RaftJoinTransferCheck(ctx, conf)
+		})
+	}
+	if results := check(map[string]string{"path": dir}); len(results) != 1 || results[0].Status != InfoStatus {
+		t.Fatalf("expected an info result without a database, got %#v", results)
+	}
+	if results := check(map[string]string{"path": dir, "trailing_logs": "many"}); len(results) != 1 || results[0].Status != ErrorStatus {
+		t.Fatalf("expected an error result, got %#v", results)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "vault.db"), make([]byte, 1024*1024), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if results := check(map[string]string{"path": dir}); len(results) != 1 || results[0].Status != OkStatus {
+		t.Fatalf("expected an ok result, got %#v", results)
+	}
+
+	const mib = 1024 * 1024
+	testCases := []struct {
+		name         string
+		trailingLogs uint64
+		snapshotSize int64
+		expected     status
+	}{
+		{"small snapshot", 10240, 10 * mib, OkStatus},
+		{"snapshot outlasts trailing logs", 10240, 1024 * mib, WarningStatus},
+		{"tiny trailing logs", 100, 100 * mib, WarningStatus},
+		{"slow join", 1000000, 10240 * mib, WarningStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkRaftJoinTransfer(ctx, tc.trailingLogs, tc.snapshotSize)
+			})
+			if len(results) != 1 || results[0].Status != tc.expected {
+				t.Fatalf("expected a %s result, got %#v", tc.expected, results)
+			}
+		})
+	}
+
+	results := checkResults(t, func(ctx context.Context) {
+		checkRaftJoinTransfer(ctx, 10240, 1024*mib)
+	})
+	expected := "a joining peer is sent a snapshot of about 1024 MiB, taking about 41s at 25 MiB/s, during which " +
+		"about 20500 entries are written at 500 writes/s; trailing_logs 10240 covers about 20s of writes"
+	if !strings.HasPrefix(results[0].Message, expected) {
+		t.Fatalf("expected %q, got %q", expected, results[0].Message)
+	}
+}
+
+func TestRaftPathWriteCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-raft-write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	results := checkResults(t, func(ctx context.Context) {
+		RaftPathWriteCheck(ctx, dir)
+	})
+	if len(results) != 1 || results[0].Status != OkStatus {
+		t.Fatalf("expected an ok result, got %#v", results)
+	}
+	if entries, err := ioutil.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("expected the test file to be deleted, got %v, %v", entries, err)
+	}
+
+	results = checkResults(t, func(ctx context.Context) {
+		RaftPathWriteCheck(ctx, filepath.Join(dir, "missing"))
+	})
+	if len(results) != 1 || results[0].Status != ErrorStatus {
+		t.Fatalf("expected an error result, got %#v", results)
+	}
+	if !strings.Contains(results[0].Message, "could not create a file") || !strings.Contains(results[0].Message, "errno") {
+		t.Fatalf("expected the failed step and errno in %q", results[0].Message)
+	}
+}
@@ -0,0 +1,118 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// raftClockSkewThreshold is the skew between two nodes' clocks above which
+// the cluster is warned about. Raft's own timeouts don't depend on wall
+// clocks, but lease and token expirations, autopilot's stabilization and the
+// leader's lock are computed from them, and are taken over by another node
+// after a failover.
+const raftClockSkewThreshold = 2 * time.Second
+
+// peerClock is the skew of a peer's clock from this node's, or the error
+// reaching it.
+type peerClock struct {
+	addr string
+	skew time.Duration
+	err  error
+}
+
+// RaftClockSkewLiveCheck queries sys/health on the server client points at,
+// the active node and each peer in peerAddrs, and compares the time each
+// reports with this node's clock. Peers are reached by their API addresses;
+// the raft configuration only lists cluster addresses, so the retry_join
+// leader_api_addr entries are what is known of the other nodes.
+func RaftClockSkewLiveCheck(ctx context.Context, client *api.Client, peerAddrs []string) error {
+	addrs := []string{client.Address()}
+	if leader, err := client.Sys().Leader(); err == nil && leader.LeaderAddress != "" {
+		addrs = append(addrs, leader.LeaderAddress)
+	}
+	addrs = append(addrs, peerAddrs...)
+
+	seen := make(map[string]bool)
+	var clocks []peerClock
+	for _, addr := range addrs {
+		key := strings.TrimSuffix(addr, "/")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		clocks = append(clocks, peerClockOf(client, key))
+	}
+	checkRaftClockSkew(ctx, clocks)
+	return nil
+}
+
+// peerClockOf measures the skew of the clock of the server at addr. The
+// server reports its time in whole seconds, so it is compared with the middle
+// of that second, against the middle of the request.
+func peerClockOf(client *api.Client, addr string) peerClock {
+	probe, err := client.Clone()
+	if err != nil {
+		return peerClock{addr: addr, err: err}
+	}
+	if err := probe.SetAddress(addr); err != nil {
+		return peerClock{addr: addr, err: err}
+	}
+	probe.SetMaxRetries(0)
+
+	sent := time.Now()
+	health, err := probe.Sys().Health()
+	received := time.Now()
+	if err != nil {
+		return peerClock{addr: addr, err: err}
+	}
+	if health.ServerTimeUTC == 0 {
+		return peerClock{addr: addr, err: fmt.Errorf("sys/health did not report the server's time")}
+	}
+	remote := time.Unix(health.ServerTimeUTC, int64(500*time.Millisecond))
+	return peerClock{addr: addr, skew: remote.Sub(sent.Add(received.Sub(sent) / 2))}
+}
+
+func checkRaftClockSkew(ctx context.Context, clocks []peerClock) {
+	testName := "raft-clock-skew"
+	// This node's own clock is part of the comparison, at no skew.
+	lowest, highest := time.Duration(0), time.Duration(0)
+	reached := 0
+	for _, c := range clocks {
+		if c.err != nil {
+			SpotInfo(ctx, testName, fmt.Sprintf("%s could not be reached, so its clock wasn't compared: %v", c.addr, c.err))
+			continue
+		}
+		reached++
+		direction := "ahead of"
+		abs := c.skew
+		if abs < 0 {
+			abs = -abs
+			direction = "behind"
+		}
+		SpotInfo(ctx, testName, fmt.Sprintf("%s: clock is %s %s this node's", c.addr, abs.Round(time.Millisecond), direction))
+		if c.skew < lowest {
+			lowest = c.skew
+		}
+		if c.skew > highest {
+			highest = c.skew
+		}
+	}
+	if reached == 0 {
+		SpotWarn(ctx, testName, "no server could be reached, so no clocks were compared")
+		return
+	}
+
+	spread := (highest - lowest).Round(time.Millisecond)
+	if spread > raftClockSkewThreshold {
+		SpotWarn(ctx, testName, fmt.Sprintf("the clocks of this node and %d reachable servers differ by up to %s, more "+
+			"than %s; lease and token expirations are computed from each node's clock, so they would shift after a "+
+			"failover", reached, spread, raftClockSkewThreshold),
+			Advice("Synchronize the clocks of all Vault nodes with NTP, and check that each node's NTP client is in sync rather than merely running."))
+		return
+	}
+	SpotOk(ctx, testName, fmt.Sprintf("the clocks of this node and %d reachable servers differ by at most %s", reached, spread))
+}
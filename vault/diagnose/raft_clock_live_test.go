@@ -0,0 +1,91 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestPeerClockOf(t *testing.T) {
+	offset := time.Hour
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/sys/health" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"initialized":true,"sealed":false,"server_time_utc":%d}`, time.Now().Add(offset).Unix())
+	}))
+	defer srv.Close()
+
+	conf := api.DefaultConfig()
+	conf.Address = srv.URL
+	client, err := api.NewClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := peerClockOf(client, srv.URL)
+	if clock.err != nil {
+		t.Fatal(clock.err)
+	}
+	if diff := clock.skew - offset; diff < -time.Second || diff > time.Second {
+		t.Fatalf("expected a skew of about %s, got %s", offset, clock.skew)
+	}
+}
+
+func TestCheckRaftClockSkew(t *testing.T) {
+	unreachable := peerClock{addr: "https://10.0.0.3:8200", err: errors.New("connection refused")}
+	cases := []struct {
+		name     string
+		clocks   []peerClock
+		expected []status
+	}{
+		{
+			name: "in sync",
+			clocks: []peerClock{
+				{addr: "https://10.0.0.1:8200", skew: 200 * time.Millisecond},
+				{addr: "https://10.0.0.2:8200", skew: -300 * time.Millisecond},
+			},
+			expected: []status{InfoStatus, InfoStatus, OkStatus},
+		},
+		{
+			name: "peers disagree though each is close to this node",
+			clocks: []peerClock{
+				{addr: "https://10.0.0.1:8200", skew: 1500 * time.Millisecond},
+				{addr: "https://10.0.0.2:8200", skew: -1500 * time.Millisecond},
+			},
+			expected: []status{InfoStatus, InfoStatus, WarningStatus},
+		},
+		{
+			name:     "peer far ahead",
+			clocks:   []peerClock{{addr: "https://10.0.0.1:8200", skew: time.Minute}, unreachable},
+			expected: []status{InfoStatus, InfoStatus, WarningStatus},
+		},
+		{
+			name:     "none reachable",
+			clocks:   []peerClock{unreachable},
+			expected: []status{InfoStatus, WarningStatus},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkRaftClockSkew(ctx, tc.clocks)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %d", len(tc.expected), len(results))
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("result %d: expected %s, got %s: %s", i, tc.expected[i], r.Status, r.Message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package diagnose
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/hashicorp/vault/sdk/helper/tlsutil"
+)
+
+const (
+	raftJoinDialTimeout = 5 * time.Second
+
+	// raftLatencySamples is the number of connections timed to each peer,
+	// of which the fastest is taken as the round trip time.
+	raftLatencySamples = 3
+
+	// raftLatencyMargin is how many round trips to a peer must fit within the
+	// leader lease timeout. A commit takes at least one round trip to a
+	// quorum, and the leader steps down, failing pending writes, when it
+	// can't reach a quorum within the lease.
+	raftLatencyMargin = 10
+)
+
+// raftJoinInfo holds the parts of a raft retry_join stanza that diagnose
+// checks. It mirrors the storage backend's own LeaderJoinInfo.
+type raftJoinInfo struct {
+	LeaderAPIAddr        string `json:"leader_api_addr"`
+	LeaderCACert         string `json:"leader_ca_cert"`
+	LeaderClientCert     string `json:"leader_client_cert"`
+	LeaderClientKey      string `json:"leader_client_key"`
+	LeaderCACertFile     string `json:"leader_ca_cert_file"`
+	LeaderClientCertFile string `json:"leader_client_cert_file"`
+	LeaderClientKeyFile  string `json:"leader_client_key_file"`
+	LeaderTLSServerName  string `json:"leader_tls_servername"`
+}
+
+// raftJoinInfos decodes the retry_join stanzas of a raft storage
+// configuration, which the configuration parser stores as JSON.
+func raftJoinInfos(conf map[string]string) ([]raftJoinInfo, error) {
+	if conf["retry_join"] == "" {
+		return nil, nil
+	}
+	var infos []raftJoinInfo
+	if err := json.Unmarshal([]byte(conf["retry_join"]), &infos); err != nil {
+		return nil, fmt.Errorf("failed to decode retry_join config: %w", err)
+	}
+	return infos, nil
+}
+
+// tlsConfig builds the TLS configuration used to join the leader, preferring
+// file paths over inline certificates as the storage backend does. It returns
+// nil if the stanza sets no TLS options, in which case the system roots are
+// used.
+func (info raftJoinInfo) tlsConfig() (*tls.Config, error) {
+	switch {
+	case info.LeaderCACertFile != "" || info.LeaderClientCertFile != "" || info.LeaderClientKeyFile != "":
+		return tlsutil.LoadClientTLSConfig(info.LeaderCACertFile, info.LeaderClientCertFile, info.LeaderClientKeyFile)
+	case info.LeaderCACert != "" || info.LeaderClientCert != "" || info.LeaderClientKey != "":
+		if info.LeaderCACert != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(info.LeaderCACert)) {
+			return nil, errors.New("leader_ca_cert contains no PEM certificates")
+		}
+		return tlsutil.ClientTLSConfig([]byte(info.LeaderCACert), []byte(info.LeaderClientCert), []byte(info.LeaderClientKey))
+	}
+	return nil, nil
+}
+
+// RaftRetryJoinTLSCheck validates the TLS settings of each retry_join stanza
+// that names a leader over https, then dials the leader to confirm that the
+// handshake succeeds and that its certificate is valid for the expected
+// server name. Stanzas using auto_join are skipped, since their peers are
+// only discovered at runtime.
+func RaftRetryJoinTLSCheck(ctx context.Context, conf map[string]string) error {
+	infos, err := raftJoinInfos(conf)
+	if err != nil {
+		return err
+	}
+	if len(infos) == 0 {
+		SpotSkipped(ctx, "retry-join-tls", SkipStanzaAbsent, "no retry_join stanzas are configured")
+		return nil
+	}
+
+	for _, info := range infos {
+		if info.LeaderAPIAddr == "" {
+			continue
+		}
+		u, err := url.Parse(info.LeaderAPIAddr)
+		if err != nil {
+			SpotError(ctx, "retry-join-tls", fmt.Errorf("could not parse leader_api_addr %q: %w", info.LeaderAPIAddr, err))
+			continue
+		}
+		if u.Scheme != "https" {
+			SpotSkipped(ctx, "retry-join-tls", SkipNotApplicable, fmt.Sprintf("%s does not use TLS", info.LeaderAPIAddr))
+			continue
+		}
+
+		tlsConfig, err := info.tlsConfig()
+		if err != nil {
+			SpotError(ctx, "retry-join-tls", fmt.Errorf("%s: invalid TLS settings: %w", info.LeaderAPIAddr, err))
+			continue
+		}
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		serverName := info.LeaderTLSServerName
+		if serverName == "" {
+			serverName = u.Hostname()
+		}
+		tlsConfig.ServerName = serverName
+
+		port := u.Port()
+		if port == "" {
+			port = "443"
+		}
+		dialer := &tls.Dialer{
+			NetDialer: &net.Dialer{Timeout: raftJoinDialTimeout},
+			Config:    tlsConfig,
+		}
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+		if err != nil {
+			SpotError(ctx, "retry-join-tls", fmt.Errorf("%s: TLS handshake expecting server name %q failed: %w",
+				info.LeaderAPIAddr, serverName, err))
+			continue
+		}
+		conn.Close()
+		SpotOk(ctx, "retry-join-tls", fmt.Sprintf("%s: TLS handshake succeeded with server name %q", info.LeaderAPIAddr, serverName))
+	}
+	return nil
+}
+
+// RaftLatencyCheck measures the round trip time to the leader of each
+// retry_join stanza, as the time to open a TCP connection to it, and compares
+// it with the leader lease timeout after applying performance_multiplier. This
+// version of Vault has no raft apply timeout, so writes wait on the commit;
+// they fail instead when the leader, unable to hear from a quorum within the
+// lease, steps down. It warns when the lease leaves too little margin over
+// the measured latency.
+func RaftLatencyCheck(ctx context.Context, conf map[string]string) error {
+	multiplier, err := raftPerformanceMultiplier(conf)
+	if err != nil {
+		return err
+	}
+	infos, err := raftJoinInfos(conf)
+	if err != nil {
+		return err
+	}
+
+	latencies := make(map[string]time.Duration)
+	var peers []string
+	for _, info := range infos {
+		if info.LeaderAPIAddr == "" {
+			continue
+		}
+		u, err := url.Parse(info.LeaderAPIAddr)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		port := u.Port()
+		if port == "" {
+			port = "443"
+			if u.Scheme == "http" {
+				port = "80"
+			}
+		}
+		addr := net.JoinHostPort(u.Hostname(), port)
+		peers = append(peers, addr)
+
+		dialer := &net.Dialer{Timeout: raftJoinDialTimeout}
+		for i := 0; i < raftLatencySamples; i++ {
+			start := time.Now()
+			conn, err := dialer.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				break
+			}
+			rtt := time.Since(start)
+			conn.Close()
+			if prev, ok := latencies[addr]; !ok || rtt < prev {
+				latencies[addr] = rtt
+			}
+		}
+	}
+	if len(peers) == 0 {
+		SpotSkipped(ctx, "raft-latency", SkipStanzaAbsent, "no retry_join stanzas name a leader to measure")
+		return nil
+	}
+
+	lease := raft.DefaultConfig().LeaderLeaseTimeout * time.Duration(multiplier)
+	raftLatencyCheck(ctx, peers, latencies, lease, multiplier)
+	return nil
+}
+
+func raftLatencyCheck(ctx context.Context, peers []string, latencies map[string]time.Duration, lease time.Duration, multiplier int) {
+	testName := "raft-latency"
+	for _, peer := range peers {
+		rtt, ok := latencies[peer]
+		if !ok {
+			SpotSkipped(ctx, testName, SkipDependencyFailed, fmt.Sprintf("could not connect to %s to measure its latency", peer))
+			continue
+		}
+		RecordMetric(ctx, "round-trip to "+peer, float64(rtt)/float64(time.Millisecond), "ms")
+		comparison := fmt.Sprintf("the round trip to %s takes %s against a leader lease timeout of %s "+
+			"(performance_multiplier %d)", peer, rtt.Round(time.Microsecond), lease, multiplier)
+		if rtt*raftLatencyMargin > lease {
+			SpotWarn(ctx, testName, comparison+fmt.Sprintf(", less than %dx margin, so writes may fail as the leader "+
+				"steps down under normal latency", raftLatencyMargin),
+				Advice("Raise performance_multiplier, or place the nodes closer together."))
+			continue
+		}
+		SpotOk(ctx, testName, comparison)
+	}
+}
@@ -0,0 +1,101 @@
+package diagnose
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRaftRetryJoinTLSCheck(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}))
+
+	retryJoin := func(infos ...raftJoinInfo) map[string]string {
+		data, err := json.Marshal(infos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return map[string]string{"retry_join": string(data)}
+	}
+
+	testCases := []struct {
+		name   string
+		conf   map[string]string
+		status status
+	}{
+		{"no retry_join", map[string]string{}, SkippedStatus},
+		{"plain http", retryJoin(raftJoinInfo{LeaderAPIAddr: "http://127.0.0.1:8200"}), SkippedStatus},
+		{"matching servername", retryJoin(raftJoinInfo{LeaderAPIAddr: ts.URL, LeaderCACert: caPEM, LeaderTLSServerName: "example.com"}), OkStatus},
+		{"mismatched servername", retryJoin(raftJoinInfo{LeaderAPIAddr: ts.URL, LeaderCACert: caPEM, LeaderTLSServerName: "vault.internal"}), ErrorStatus},
+		{"invalid CA", retryJoin(raftJoinInfo{LeaderAPIAddr: ts.URL, LeaderCACert: "not a certificate"}), ErrorStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				if err := RaftRetryJoinTLSCheck(ctx, tc.conf); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
+
+func TestRaftLatencyCheck(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	data, err := json.Marshal([]raftJoinInfo{{LeaderAPIAddr: ts.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := checkResults(t, func(ctx context.Context) {
+		if err := RaftLatencyCheck(ctx, map[string]string{"retry_join": string(data)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := RaftLatencyCheck(ctx, map[string]string{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Status != OkStatus || !strings.Contains(results[0].Message, "leader lease timeout of 2.5s") {
+		t.Fatalf("unexpected result: %s %s", results[0].Status, results[0].Message)
+	}
+	if results[1].Status != SkippedStatus {
+		t.Fatalf("expected a skip without retry_join, got %s", results[1].Status)
+	}
+
+	if err := RaftLatencyCheck(context.Background(), map[string]string{"performance_multiplier": "0"}); err == nil {
+		t.Fatal("expected an error for an invalid performance_multiplier")
+	}
+}
+
+func TestRaftLatencyMargin(t *testing.T) {
+	peers := []string{"10.0.0.1:8200", "10.0.0.2:8200", "10.0.0.3:8200"}
+	latencies := map[string]time.Duration{
+		"10.0.0.1:8200": time.Millisecond,
+		"10.0.0.2:8200": 100 * time.Millisecond,
+	}
+	results := checkResults(t, func(ctx context.Context) {
+		raftLatencyCheck(ctx, peers, latencies, 500*time.Millisecond, 1)
+	})
+	expected := []status{OkStatus, WarningStatus, SkippedStatus}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i, r := range results {
+		if r.Status != expected[i] {
+			t.Fatalf("result %d: expected %s, got %s: %s", i, expected[i], r.Status, r.Message)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// raftDefaultMaxTrailingLogs and raftDefaultLastContactThreshold mirror
+	// the autopilot defaults, used when its configuration can't be read.
+	raftDefaultMaxTrailingLogs      = 1000
+	raftDefaultLastContactThreshold = 10 * time.Second
+)
+
+// RaftPeer is a server in the raft configuration of a running cluster.
+type RaftPeer struct {
+	NodeID  string `mapstructure:"node_id"`
+	Address string `mapstructure:"address"`
+	Leader  bool   `mapstructure:"leader"`
+	Voter   bool   `mapstructure:"voter"`
+}
+
+// RaftLiveCheck queries the raft configuration and autopilot state of the
+// running Vault that client points at, and reports the health of each server.
+// localID is the node_id of this node, if known, so that its result can be
+// singled out.
+func RaftLiveCheck(ctx context.Context, client *api.Client, localID string) error {
+	secret, err := client.Logical().Read("sys/storage/raft/configuration")
+	if err != nil {
+		return fmt.Errorf("could not read the raft configuration: %w", err)
+	}
+	if secret == nil || secret.Data["config"] == nil {
+		return errors.New("no raft configuration found; the server may not use raft storage")
+	}
+	var conf struct {
+		Servers []RaftPeer `mapstructure:"servers"`
+	}
+	if err := mapstructure.Decode(secret.Data["config"], &conf); err != nil {
+		return fmt.Errorf("could not parse the raft configuration: %w", err)
+	}
+
+	state, err := client.Sys().RaftAutopilotState()
+	if err != nil {
+		return fmt.Errorf("could not read the autopilot state: %w", err)
+	}
+	if state == nil {
+		return errors.New("autopilot state is not available")
+	}
+
+	maxLag := uint64(raftDefaultMaxTrailingLogs)
+	maxContact := raftDefaultLastContactThreshold
+	if apConf, err := client.Sys().RaftAutopilotConfiguration(); err == nil && apConf != nil {
+		if apConf.MaxTrailingLogs > 0 {
+			maxLag = apConf.MaxTrailingLogs
+		}
+		if apConf.LastContactThreshold > 0 {
+			maxContact = apConf.LastContactThreshold
+		}
+	}
+
+	RaftHealthCheck(ctx, localID, conf.Servers, state, maxLag, maxContact)
+	return nil
+}
+
+// RaftHealthCheck reports each peer's voter status, how far its applied index
+// trails the leader's and when it last contacted the leader, warning when
+// either exceeds maxLag or maxContact.
+func RaftHealthCheck(ctx context.Context, localID string, peers []RaftPeer, state *api.AutopilotState, maxLag uint64, maxContact time.Duration) {
+	var leaderIndex uint64
+	if leader, ok := state.Servers[state.Leader]; ok {
+		leaderIndex = leader.LastIndex
+	}
+
+	for _, peer := range peers {
+		name := "raft-peer"
+		if localID != "" && peer.NodeID == localID {
+			name = "raft-local-node"
+		}
+		role := "non-voter"
+		switch {
+		case peer.Leader:
+			role = "leader"
+		case peer.Voter:
+			role = "voter"
+		}
+
+		server, ok := state.Servers[peer.NodeID]
+		if !ok {
+			SpotWarn(ctx, name, fmt.Sprintf("%s (%s, %s) is missing from the autopilot state", peer.NodeID, peer.Address, role))
+			continue
+		}
+		if peer.Leader {
+			SpotOk(ctx, name, fmt.Sprintf("%s (%s, leader) is at index %d", peer.NodeID, peer.Address, server.LastIndex))
+			continue
+		}
+
+		var lag uint64
+		if leaderIndex > server.LastIndex {
+			lag = leaderIndex - server.LastIndex
+		}
+		// Autopilot reports last contact as a duration string, such as "1.2ms".
+		contact, _ := time.ParseDuration(server.LastContact)
+		summary := fmt.Sprintf("%s (%s, %s) trails the leader by %d entries, last contact %s",
+			peer.NodeID, peer.Address, role, lag, server.LastContact)
+		switch {
+		case !server.Healthy:
+			SpotWarn(ctx, name, summary+"; autopilot considers it unhealthy")
+		case lag > maxLag:
+			SpotWarn(ctx, name, fmt.Sprintf("%s; more than %d entries behind means it is struggling to keep up", summary, maxLag))
+		case contact > maxContact:
+			SpotWarn(ctx, name, fmt.Sprintf("%s; more than %s since contact means it may be partitioned", summary, maxContact))
+		default:
+			SpotOk(ctx, name, summary)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestRaftHealthCheck(t *testing.T) {
+	peers := []RaftPeer{
+		{NodeID: "node1", Address: "10.0.0.1:8201", Leader: true, Voter: true},
+		{NodeID: "node2", Address: "10.0.0.2:8201", Voter: true},
+		{NodeID: "node3", Address: "10.0.0.3:8201", Voter: true},
+		{NodeID: "node4", Address: "10.0.0.4:8201"},
+	}
+	state := &api.AutopilotState{
+		Leader: "node1",
+		Servers: map[string]*api.AutopilotServer{
+			"node1": {LastIndex: 5000, Healthy: true},
+			"node2": {LastIndex: 4990, LastContact: "5ms", Healthy: true},
+			"node3": {LastIndex: 2000, LastContact: "5ms", Healthy: true},
+		},
+	}
+	results := checkResults(t, func(ctx context.Context) {
+		RaftHealthCheck(ctx, "node2", peers, state, 1000, 10*time.Second)
+	})
+	expected := []struct {
+		name   string
+		status status
+	}{
+		{"raft-peer", OkStatus},
+		{"raft-local-node", OkStatus},
+		{"raft-peer", WarningStatus},
+		{"raft-peer", WarningStatus},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %#v", len(expected), results)
+	}
+	for i, exp := range expected {
+		if results[i].Name != exp.name || results[i].Status != exp.status {
+			t.Fatalf("result %d: expected %s %s, got %#v", i, exp.name, exp.status, results[i])
+		}
+	}
+}
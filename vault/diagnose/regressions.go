@@ -0,0 +1,95 @@
+package diagnose
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Regression is a check whose status got worse between two runs.
+type Regression struct {
+	Path     string
+	Previous string
+	Current  string
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s: %s -> %s", r.Path, r.Previous, r.Current)
+}
+
+// LoadResults reads the JSON results of a previous run, as written with
+// -format=json.
+func LoadResults(path string) (*Result, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Result
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse previous results: %w", err)
+	}
+	return &r, nil
+}
+
+// FindRegressions compares the checks in current to those in previous and
+// returns the ones that were ok or warning before and are now worse. Checks
+// that were already failing or skipped, and checks that did not exist in the
+// previous run, are not regressions. Only leaf checks are compared, since a
+// section's status is derived from its children.
+func FindRegressions(previous, current *Result) []Regression {
+	prev := make(map[string]status)
+	for _, l := range leafResults(previous) {
+		prev[l.path] = l.result.Status
+	}
+
+	var regressions []Regression
+	for _, l := range leafResults(current) {
+		before, ok := prev[l.path]
+		if !ok || (before != OkStatus && before != WarningStatus) {
+			continue
+		}
+		if l.result.Status > before {
+			regressions = append(regressions, Regression{
+				Path:     l.path,
+				Previous: before.String(),
+				Current:  l.result.Status.String(),
+			})
+		}
+	}
+	return regressions
+}
+
+type leafResult struct {
+	path   string
+	result *Result
+}
+
+// leafResults returns the leaves of the results tree in order, each under its
+// slash-separated path. Repeated names under one parent, such as a spot check
+// run once per listener, are told apart by a numeric suffix.
+func leafResults(root *Result) []leafResult {
+	var leaves []leafResult
+	seen := make(map[string]int)
+	var walk func(r *Result, prefix string)
+	walk = func(r *Result, prefix string) {
+		path := r.Name
+		if prefix != "" {
+			path = prefix + "/" + r.Name
+		}
+		seen[path]++
+		if n := seen[path]; n > 1 {
+			path = fmt.Sprintf("%s#%d", path, n)
+		}
+		if len(r.Children) == 0 {
+			leaves = append(leaves, leafResult{path: path, result: r})
+			return
+		}
+		for _, c := range r.Children {
+			walk(c, path)
+		}
+	}
+	if root != nil {
+		walk(root, "")
+	}
+	return leaves
+}
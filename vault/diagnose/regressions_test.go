@@ -0,0 +1,127 @@
+package diagnose
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindRegressions(t *testing.T) {
+	previous := &Result{
+		Name: "initialization",
+		Children: []*Result{
+			{Name: "parse-config", Status: OkStatus},
+			{Name: "storage", Children: []*Result{
+				{Name: "test-access-storage", Status: WarningStatus},
+				{Name: "consul-tls", Status: ErrorStatus},
+			}},
+			{Name: "ocsp", Status: OkStatus},
+			{Name: "ocsp", Status: OkStatus},
+		},
+	}
+	current := &Result{
+		Name: "initialization",
+		Children: []*Result{
+			{Name: "parse-config", Status: OkStatus},
+			{Name: "storage", Children: []*Result{
+				{Name: "test-access-storage", Status: ErrorStatus},
+				{Name: "consul-tls", Status: ErrorStatus},
+			}},
+			{Name: "ocsp", Status: OkStatus},
+			{Name: "ocsp", Status: WarningStatus},
+			{Name: "new-check", Status: ErrorStatus},
+		},
+	}
+
+	expected := []Regression{
+		{Path: "initialization/storage/test-access-storage", Previous: "warn", Current: "fail"},
+		{Path: "initialization/ocsp#2", Previous: "ok", Current: "warn"},
+	}
+	if regressions := FindRegressions(previous, current); !reflect.DeepEqual(regressions, expected) {
+		t.Fatalf("unexpected regressions: %v", regressions)
+	}
+	if regressions := FindRegressions(current, current); len(regressions) != 0 {
+		t.Fatalf("expected no regressions against the same run, got %v", regressions)
+	}
+}
+
+func TestLoadResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-since")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	results := &Result{
+		Name:   "initialization",
+		Status: WarningStatus,
+		Children: []*Result{
+			{Name: "parse-config", Status: OkStatus},
+			{Name: "storage", Status: WarningStatus, Message: "slow"},
+			{Name: "autounseal", Status: SkippedStatus},
+		},
+	}
+	data, err := json.Marshal(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "previous.json")
+	if err := ioutil.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, results) {
+		t.Fatalf("round trip mismatch: %#v", loaded)
+	}
+}
+
+func TestLoadResultsBaselineFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-since")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Results as written by versions of diagnose that wrote skipped checks
+	// as "invalid".
+	data := `{
+  "time": "2021-07-01T12:00:00Z",
+  "name": "initialization",
+  "status": "warn",
+  "Advice": "",
+  "children": [
+    {"time": "2021-07-01T12:00:01Z", "name": "parse-config", "status": "ok", "Advice": ""},
+    {"time": "2021-07-01T12:00:02Z", "name": "service-discovery", "status": "invalid", "message": "no service registration configured", "Advice": ""},
+    {"time": "2021-07-01T12:00:03Z", "name": "storage", "status": "warn", "warnings": ["slow"], "Advice": ""}
+  ]
+}`
+	path := filepath.Join(dir, "previous.json")
+	if err := ioutil.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.ZeroTimes()
+	expected := &Result{
+		Name:   "initialization",
+		Status: WarningStatus,
+		Children: []*Result{
+			{Name: "parse-config", Status: OkStatus},
+			{Name: "service-discovery", Status: SkippedStatus, Message: "no service registration configured"},
+			{Name: "storage", Status: WarningStatus, Warnings: []string{"slow"}},
+		},
+	}
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Fatalf("unexpected results: %#v", loaded)
+	}
+}
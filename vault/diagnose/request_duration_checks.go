@@ -0,0 +1,49 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+// minMaxRequestDuration is the shortest request timeout that still leaves room
+// for routine administrative operations such as taking a raft snapshot or
+// writing a large batch of secrets.
+const minMaxRequestDuration = 30 * time.Second
+
+// MaxRequestDurationCheck reports the effective max request duration globally
+// and for each listener, and warns about timeouts short enough to abort
+// legitimate long-running requests. configured is default_max_request_duration
+// as set in the configuration, or zero if unset, and fallback is the duration
+// the server uses in that case.
+func MaxRequestDurationCheck(ctx context.Context, configured, fallback time.Duration, listeners []*configutil.Listener) {
+	global := configured
+	source := "default_max_request_duration"
+	if global == 0 {
+		global = fallback
+		source = "built-in default"
+	}
+	if global < minMaxRequestDuration {
+		SpotWarn(ctx, "max-request-duration", fmt.Sprintf("requests are aborted after %s (%s), which is shorter than %s "+
+			"and may interrupt long operations such as snapshots and bulk writes", global, source, minMaxRequestDuration))
+	} else {
+		SpotOk(ctx, "max-request-duration", fmt.Sprintf("requests are aborted after %s (%s)", global, source))
+	}
+
+	for _, l := range listeners {
+		if l.Type != "tcp" {
+			continue
+		}
+		switch {
+		case l.MaxRequestDuration == 0:
+			SpotOk(ctx, "listener-max-request-duration", fmt.Sprintf("%s: %s (inherited)", l.Address, global))
+		case l.MaxRequestDuration < minMaxRequestDuration:
+			SpotWarn(ctx, "listener-max-request-duration", fmt.Sprintf("%s: max_request_duration of %s is shorter than %s "+
+				"and may interrupt long operations such as snapshots and bulk writes", l.Address, l.MaxRequestDuration, minMaxRequestDuration))
+		default:
+			SpotOk(ctx, "listener-max-request-duration", fmt.Sprintf("%s: %s", l.Address, l.MaxRequestDuration))
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+func TestMaxRequestDurationCheck(t *testing.T) {
+	listeners := []*configutil.Listener{
+		{Type: "tcp", Address: "127.0.0.1:8200"},
+		{Type: "tcp", Address: "127.0.0.1:8300", MaxRequestDuration: 5 * time.Second},
+	}
+	results := checkResults(t, func(ctx context.Context) {
+		MaxRequestDurationCheck(ctx, 0, 90*time.Second, listeners)
+	})
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %#v", results)
+	}
+	if results[0].Status != OkStatus || results[1].Status != OkStatus {
+		t.Fatalf("expected the default durations to be ok, got %#v", results)
+	}
+	if results[2].Status != WarningStatus {
+		t.Fatalf("expected a warning for a short listener duration, got %#v", results[2])
+	}
+
+	results = checkResults(t, func(ctx context.Context) {
+		MaxRequestDurationCheck(ctx, 10*time.Second, 90*time.Second, nil)
+	})
+	if len(results) != 1 || results[0].Status != WarningStatus {
+		t.Fatalf("expected a warning for a short global duration, got %#v", results)
+	}
+}
@@ -0,0 +1,119 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+// consulMaxValueSize is the Consul agent's default kv_max_value_size, the
+// largest value it stores. The agent's setting isn't visible to Vault.
+const consulMaxValueSize = 512 * 1024
+
+// storageEntryLimit returns the largest entry the storage backend persists,
+// or zero if it has no limit diagnose knows of, along with the setting that
+// set it, or an empty string if it is the backend's default.
+func storageEntryLimit(storageType string, conf map[string]string) (int64, string, error) {
+	switch storageType {
+	case "raft":
+		if raw := conf["max_entry_size"]; raw != "" {
+			i, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return 0, "", fmt.Errorf("failed to parse 'max_entry_size': %w", err)
+			}
+			return i, "max_entry_size", nil
+		}
+		return int64(raftDefaultMaxEntrySize), "", nil
+	case "consul":
+		return consulMaxValueSize, "", nil
+	}
+	return 0, "", nil
+}
+
+// MaxRequestSizeCheck reports the effective max_request_size of each tcp
+// listener and the largest entry the storage backend persists, and warns when
+// a listener accepts requests larger than storage can hold: such writes pass
+// the listener's validation and then fail at storage. fallback is the size
+// the server uses when a listener doesn't set max_request_size. The defaults
+// themselves differ, which is noted; a mismatch is only a warning when
+// max_request_size or the storage limit was configured.
+func MaxRequestSizeCheck(ctx context.Context, fallback int64, listeners []*configutil.Listener, storageType string, storageConf map[string]string) error {
+	testName := "max-request-size"
+	limit, limitSource, err := storageEntryLimit(storageType, storageConf)
+	if err != nil {
+		return SpotError(ctx, testName, err)
+	}
+
+	type listenerSize struct {
+		addr     string
+		size     int64
+		explicit bool
+	}
+	var tcp []listenerSize
+	var sizes []string
+	for _, l := range listeners {
+		if l.Type != "tcp" {
+			continue
+		}
+		ls := listenerSize{addr: l.Address, size: l.MaxRequestSize, explicit: l.MaxRequestSize != 0}
+		if !ls.explicit {
+			ls.size = fallback
+		}
+		tcp = append(tcp, ls)
+		desc := fmt.Sprintf("%s %s", ls.addr, requestSizeDesc(ls.size))
+		if !ls.explicit {
+			desc += " (default)"
+		}
+		sizes = append(sizes, desc)
+	}
+	if len(tcp) == 0 {
+		Skipped(ctx, SkipStanzaAbsent, "no tcp listeners are configured")
+		return nil
+	}
+	summary := "max_request_size is " + strings.Join(sizes, ", ")
+	if limit > 0 {
+		summary += fmt.Sprintf("; the %s storage accepts entries up to %d bytes", storageType, limit)
+	}
+	SpotInfo(ctx, testName, summary)
+
+	var defaultOver []string
+	warned := false
+	for _, ls := range tcp {
+		if limit == 0 || (ls.size > 0 && ls.size <= limit) {
+			continue
+		}
+		if !ls.explicit && limitSource == "" {
+			defaultOver = append(defaultOver, ls.addr)
+			continue
+		}
+		warned = true
+		storage := fmt.Sprintf("the %s storage rejects entries over %d bytes", storageType, limit)
+		if limitSource != "" {
+			storage += " (" + limitSource + ")"
+		}
+		SpotWarn(ctx, testName, fmt.Sprintf("the listener on %s accepts requests of %s, but %s, so larger writes pass "+
+			"the listener and fail at storage", ls.addr, requestSizeDesc(ls.size), storage),
+			Advice(fmt.Sprintf("Set max_request_size on the listener to at most %d.", limit)))
+	}
+	if len(defaultOver) > 0 {
+		SpotInfo(ctx, testName, fmt.Sprintf("with the default max_request_size, the listeners on %s accept requests "+
+			"larger than the %s storage's default entry limit, so the largest writes fail at storage rather than at "+
+			"the listener", strings.Join(defaultOver, ", "), storageType))
+	}
+	if !warned {
+		SpotOk(ctx, testName, "no listener's max_request_size was set above the storage's entry limit")
+	}
+	return nil
+}
+
+// requestSizeDesc describes a max_request_size, where a negative size
+// disables the limit.
+func requestSizeDesc(size int64) string {
+	if size < 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
@@ -0,0 +1,48 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+func TestMaxRequestSizeCheck(t *testing.T) {
+	const fallback = 32 * 1024 * 1024
+	defaultListener := &configutil.Listener{Type: "tcp", Address: "127.0.0.1:8200"}
+	testCases := []struct {
+		name        string
+		listeners   []*configutil.Listener
+		storageType string
+		conf        map[string]string
+		expected    []status
+	}{
+		{"defaults", []*configutil.Listener{defaultListener}, "raft", nil, []status{InfoStatus, InfoStatus, OkStatus}},
+		{"within the limit", []*configutil.Listener{{Type: "tcp", Address: "127.0.0.1:8200", MaxRequestSize: 512 * 1024}},
+			"raft", nil, []status{InfoStatus, OkStatus}},
+		{"explicit size over the limit", []*configutil.Listener{{Type: "tcp", Address: "127.0.0.1:8200", MaxRequestSize: 4 * 1024 * 1024}},
+			"consul", nil, []status{InfoStatus, WarningStatus}},
+		{"unlimited", []*configutil.Listener{{Type: "tcp", Address: "127.0.0.1:8200", MaxRequestSize: -1}},
+			"raft", nil, []status{InfoStatus, WarningStatus}},
+		{"configured entry size", []*configutil.Listener{defaultListener}, "raft",
+			map[string]string{"max_entry_size": "2097152"}, []status{InfoStatus, WarningStatus}},
+		{"no known limit", []*configutil.Listener{defaultListener}, "postgresql", nil, []status{InfoStatus, OkStatus}},
+		{"invalid entry size", []*configutil.Listener{defaultListener}, "raft",
+			map[string]string{"max_entry_size": "large"}, []status{ErrorStatus}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				MaxRequestSizeCheck(ctx, fallback, tc.listeners, tc.storageType, tc.conf)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %#v", len(tc.expected), results)
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("result %d: expected %s, got %s: %s", i, tc.expected[i], r.Status, r.Message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+// SealOrderCheck validates that the seal stanzas resolve to a single
+// well-defined barrier seal and at most one seal being migrated from. This
+// version of Vault has no seal priority: when several seals share a role,
+// the last one declared silently wins, so the result depends on file and
+// stanza order. The resolved order is reported on success.
+func SealOrderCheck(ctx context.Context, seals []*configutil.KMS) {
+	var active, disabled []string
+	for _, s := range seals {
+		if _, ok := s.Config["priority"]; ok {
+			SpotWarn(ctx, "seal-priority", fmt.Sprintf("the %s seal sets priority, which this version of Vault ignores", s.Type))
+		}
+		if s.Disabled {
+			disabled = append(disabled, s.Type)
+		} else {
+			active = append(active, s.Type)
+		}
+	}
+
+	var conflicts []string
+	if len(active) > 1 {
+		conflicts = append(conflicts, fmt.Sprintf("%d active seals (%s)", len(active), strings.Join(active, ", ")))
+	}
+	if len(disabled) > 1 {
+		conflicts = append(conflicts, fmt.Sprintf("%d disabled seals (%s)", len(disabled), strings.Join(disabled, ", ")))
+	}
+	if len(conflicts) > 0 {
+		SpotError(ctx, "seal-order", fmt.Errorf("%s are configured, but only one of each is used and the last declared wins; "+
+			"remove the extra seal stanzas", strings.Join(conflicts, " and ")))
+		return
+	}
+
+	switch {
+	case len(active) == 0 && len(disabled) == 0:
+		SpotInfo(ctx, "seal-order", "barrier seal is shamir")
+	case len(disabled) == 0:
+		SpotInfo(ctx, "seal-order", fmt.Sprintf("barrier seal is %s", active[0]))
+	case len(active) == 0:
+		SpotInfo(ctx, "seal-order", fmt.Sprintf("barrier seal is shamir, migrating from %s", disabled[0]))
+	default:
+		SpotInfo(ctx, "seal-order", fmt.Sprintf("barrier seal is %s, migrating from %s", active[0], disabled[0]))
+	}
+}
+
+// KeySharesCheck validates the key shares and threshold that will be passed
+// to "vault operator init". They split the unseal key under a shamir seal,
+// and the recovery key under an auto-unseal seal. A zero shares and
+// threshold means neither was given and the check is skipped.
+func KeySharesCheck(ctx context.Context, shares, threshold int, seals []*configutil.KMS) {
+	kind := "unseal"
+	for _, s := range seals {
+		if !s.Disabled && s.Type != "shamir" {
+			kind = "recovery"
+		}
+	}
+	if shares == 0 && threshold == 0 {
+		SpotSkipped(ctx, "key-shares", SkipStanzaAbsent, fmt.Sprintf("no %s key shares or threshold given", kind))
+		return
+	}
+
+	var err error
+	switch {
+	case shares < 1:
+		err = fmt.Errorf("shares must be at least one")
+	case threshold < 1:
+		err = fmt.Errorf("threshold must be at least one")
+	case shares > 255:
+		err = fmt.Errorf("shares must be less than 256")
+	case threshold > shares:
+		err = fmt.Errorf("threshold cannot be larger than shares")
+	case shares > 1 && threshold == 1:
+		err = fmt.Errorf("threshold must be greater than one for multiple shares")
+	}
+	if err != nil {
+		SpotError(ctx, "key-shares", fmt.Errorf("%d %s key shares with a threshold of %d will be rejected by init: %w",
+			shares, kind, threshold, err))
+		return
+	}
+	if threshold == 1 {
+		SpotWarn(ctx, "key-shares", fmt.Sprintf("a single %s key share can reconstruct the %s key, "+
+			"which is only suitable for development; use several shares with a threshold of at least 3", kind, kind))
+		return
+	}
+	SpotOk(ctx, "key-shares", fmt.Sprintf("%d %s key shares with a threshold of %d", shares, kind, threshold))
+}
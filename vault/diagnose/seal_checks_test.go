@@ -0,0 +1,95 @@
+package diagnose
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+func TestSealOrderCheck(t *testing.T) {
+	testCases := []struct {
+		name     string
+		seals    []*configutil.KMS
+		expected []*Result
+	}{
+		{
+			"no seals",
+			nil,
+			[]*Result{{Name: "seal-order", Status: InfoStatus, Message: "barrier seal is shamir"}},
+		},
+		{
+			"migration",
+			[]*configutil.KMS{{Type: "shamir", Disabled: true}, {Type: "transit"}},
+			[]*Result{{Name: "seal-order", Status: InfoStatus, Message: "barrier seal is transit, migrating from shamir"}},
+		},
+		{
+			"two active",
+			[]*configutil.KMS{{Type: "awskms"}, {Type: "transit", Config: map[string]string{"priority": "1"}}},
+			[]*Result{
+				{Name: "seal-priority", Status: WarningStatus, Message: "the transit seal sets priority, which this version of Vault ignores"},
+				{Name: "seal-order", Status: ErrorStatus, Message: "2 active seals (awskms, transit) are configured, but only one of each " +
+					"is used and the last declared wins; remove the extra seal stanzas"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				SealOrderCheck(ctx, tc.seals)
+			})
+			if !reflect.DeepEqual(results, tc.expected) {
+				t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results, tc.expected), "\n"))
+			}
+		})
+	}
+}
+
+func TestKeySharesCheck(t *testing.T) {
+	autoUnseal := []*configutil.KMS{{Type: "awskms"}}
+	testCases := []struct {
+		name      string
+		shares    int
+		threshold int
+		seals     []*configutil.KMS
+		expected  []*Result
+	}{
+		{
+			"unset",
+			0, 0, nil,
+			[]*Result{{Name: "key-shares", Status: SkippedStatus, Message: "no unseal key shares or threshold given", SkipReason: SkipStanzaAbsent}},
+		},
+		{
+			"ok",
+			5, 3, nil,
+			[]*Result{{Name: "key-shares", Status: OkStatus, Message: "5 unseal key shares with a threshold of 3"}},
+		},
+		{
+			"threshold above shares",
+			3, 5, autoUnseal,
+			[]*Result{{Name: "key-shares", Status: ErrorStatus, Message: "3 recovery key shares with a threshold of 5 " +
+				"will be rejected by init: threshold cannot be larger than shares"}},
+		},
+		{
+			"single share",
+			1, 1, nil,
+			[]*Result{{Name: "key-shares", Status: WarningStatus, Message: "a single unseal key share can reconstruct the " +
+				"unseal key, which is only suitable for development; use several shares with a threshold of at least 3"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				KeySharesCheck(ctx, tc.shares, tc.threshold, tc.seals)
+			})
+			if !reflect.DeepEqual(results, tc.expected) {
+				t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results, tc.expected), "\n"))
+			}
+		})
+	}
+}
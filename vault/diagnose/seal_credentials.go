@@ -0,0 +1,55 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/helper/awsutil"
+)
+
+// AWSKMSCredentialsCheck resolves the credentials an awskms seal will use, the
+// same way the seal does, and reports whether they are long-lived or
+// temporary. Temporary credentials from a provider that refreshes them, such
+// as an instance profile, are reported with their current expiry. A session
+// token set directly is never refreshed, so once it expires the seal can no
+// longer unseal; it warns about those.
+func AWSKMSCredentialsCheck(ctx context.Context, conf map[string]string) error {
+	credsConfig := &awsutil.CredentialsConfig{
+		AccessKey:    conf["access_key"],
+		SecretKey:    conf["secret_key"],
+		SessionToken: conf["session_token"],
+		Region:       conf["region"],
+		Logger:       log.NewNullLogger(),
+	}
+	creds, err := credsConfig.GenerateCredentialChain()
+	if err != nil {
+		return err
+	}
+	return awsCredentialsCheck(ctx, creds)
+}
+
+func awsCredentialsCheck(ctx context.Context, creds *credentials.Credentials) error {
+	testName := "kms-credentials"
+	value, err := creds.GetWithContext(ctx)
+	if err != nil {
+		return SpotError(ctx, testName, fmt.Errorf("could not load the AWS credentials: %w", err))
+	}
+	if value.SessionToken == "" {
+		SpotOk(ctx, testName, fmt.Sprintf("long-lived credentials from %s", value.ProviderName))
+		return nil
+	}
+	expiry, err := creds.ExpiresAt()
+	if err != nil {
+		SpotWarn(ctx, testName, fmt.Sprintf("temporary credentials from %s include a session token that is never "+
+			"refreshed; once it expires, the seal can no longer unseal Vault", value.ProviderName),
+			Advice("Use credentials that are refreshed automatically, such as an instance profile or an "+
+				"assumed role, or long-lived credentials."))
+		return nil
+	}
+	SpotOk(ctx, testName, fmt.Sprintf("temporary credentials from %s expire at %s, in %s, and are refreshed automatically",
+		value.ProviderName, expiry.Format(time.RFC3339), time.Until(expiry).Round(time.Second)))
+	return nil
+}
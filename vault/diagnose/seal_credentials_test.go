@@ -0,0 +1,54 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// refreshingProvider issues temporary credentials that expire after an hour,
+// as an instance profile does.
+type refreshingProvider struct {
+	credentials.Expiry
+}
+
+func (p *refreshingProvider) Retrieve() (credentials.Value, error) {
+	p.SetExpiration(time.Now().Add(time.Hour), 0)
+	return credentials.Value{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token", ProviderName: "test"}, nil
+}
+
+type failingProvider struct{}
+
+func (failingProvider) Retrieve() (credentials.Value, error) {
+	return credentials.Value{}, errors.New("no credentials")
+}
+
+func (failingProvider) IsExpired() bool {
+	return true
+}
+
+func TestAWSCredentialsCheck(t *testing.T) {
+	testCases := []struct {
+		name   string
+		creds  *credentials.Credentials
+		status status
+	}{
+		{"long-lived", credentials.NewStaticCredentials("AKID", "secret", ""), OkStatus},
+		{"static session token", credentials.NewStaticCredentials("AKID", "secret", "token"), WarningStatus},
+		{"refreshed", credentials.NewCredentials(&refreshingProvider{}), OkStatus},
+		{"unavailable", credentials.NewCredentials(failingProvider{}), ErrorStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				awsCredentialsCheck(ctx, tc.creds)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// RaftRetryJoinAddrs returns the leader_api_addr of each retry_join stanza in
+// a raft storage configuration. Entries using auto_join are left out, since
+// their addresses are only discovered at runtime.
+func RaftRetryJoinAddrs(conf map[string]string) []string {
+	joins, err := raftJoinInfos(conf)
+	if err != nil {
+		return nil
+	}
+	var addrs []string
+	for _, j := range joins {
+		if j.LeaderAPIAddr != "" {
+			addrs = append(addrs, j.LeaderAPIAddr)
+		}
+	}
+	return addrs
+}
+
+// TransitSealDependencyCheck checks that a transit seal doesn't depend on a
+// Vault that cannot be unsealed before this node is. A seal pointing at this
+// node itself, named by one of the addresses in self, is a circular
+// dependency that hangs startup, and is an error; a seal pointing at a member
+// of this node's own raft cluster, named by peers, hangs a full cluster
+// restart, and is a warning. self maps a description of each setting to its
+// address. The addresses compared and the detected dependency chain are
+// reported either way.
+func TransitSealDependencyCheck(ctx context.Context, conf map[string]string, self map[string]string, peers []string) {
+	target := conf["address"]
+	if target == "" {
+		target = api.DefaultConfig().Address
+	}
+
+	settings := make([]string, 0, len(self))
+	compared := make([]string, 0, len(self))
+	for setting := range self {
+		settings = append(settings, setting)
+	}
+	sort.Strings(settings)
+	for _, setting := range settings {
+		compared = append(compared, fmt.Sprintf("%s %s", setting, self[setting]))
+	}
+	if len(compared) > 0 {
+		SpotInfo(ctx, "seal-dependency", fmt.Sprintf("compared the transit seal address %s with this node's %s",
+			target, strings.Join(compared, ", ")))
+	}
+
+	for _, setting := range settings {
+		if sameEndpoint(target, self[setting], true) {
+			SpotError(ctx, "seal-dependency", fmt.Errorf("transit seal -> %s -> %s %s (this node): "+
+				"the seal depends on this node being unsealed, so startup will hang", target, setting, self[setting]),
+				Advice("Point the transit seal at a separate Vault cluster."))
+			return
+		}
+	}
+	for _, peer := range peers {
+		if sameEndpoint(target, peer, false) {
+			SpotWarn(ctx, "seal-dependency", fmt.Sprintf("transit seal -> %s -> raft retry_join leader %s (this node's storage cluster): "+
+				"the seal depends on a cluster that needs this seal to unseal, so a full cluster restart will hang", target, peer))
+			return
+		}
+	}
+	SpotOk(ctx, "seal-dependency", fmt.Sprintf("transit seal -> %s, outside this node and its storage cluster", target))
+}
+
+// sameEndpoint reports whether the URLs or host:port pairs a and b name the
+// same endpoint. When local is set, b is an address of this node, so a
+// loopback host in a matches b if b is a loopback or unspecified address.
+func sameEndpoint(a, b string, local bool) bool {
+	hostA, portA := endpoint(a)
+	hostB, portB := endpoint(b)
+	if portA != portB {
+		return false
+	}
+	if strings.EqualFold(hostA, hostB) {
+		return true
+	}
+	if !local || !IsLoopbackAddr(hostA) {
+		return false
+	}
+	ip := net.ParseIP(hostB)
+	return IsLoopbackAddr(hostB) || (ip != nil && ip.IsUnspecified())
+}
+
+// endpoint splits a URL or host:port pair into its host and port, filling in
+// the port implied by a URL scheme.
+func endpoint(addr string) (string, string) {
+	host, port := addrHost(addr), ""
+	if strings.Contains(addr, "://") {
+		if u, err := url.Parse(addr); err == nil {
+			port = u.Port()
+			if port == "" && u.Scheme == "https" {
+				port = "443"
+			} else if port == "" && u.Scheme == "http" {
+				port = "80"
+			}
+		}
+	} else if _, p, err := net.SplitHostPort(addr); err == nil {
+		port = p
+	}
+	return host, port
+}
@@ -0,0 +1,52 @@
+package diagnose
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRaftRetryJoinAddrs(t *testing.T) {
+	conf := map[string]string{
+		"retry_join": `[{"leader_api_addr":"https://10.0.0.2:8200"},{"auto_join":"provider=aws"}]`,
+	}
+	if addrs := RaftRetryJoinAddrs(conf); !reflect.DeepEqual(addrs, []string{"https://10.0.0.2:8200"}) {
+		t.Fatalf("unexpected addresses: %v", addrs)
+	}
+	if addrs := RaftRetryJoinAddrs(map[string]string{}); addrs != nil {
+		t.Fatalf("expected no addresses, got %v", addrs)
+	}
+}
+
+func TestTransitSealDependencyCheck(t *testing.T) {
+	self := map[string]string{
+		"api_addr":           "https://vault-1.example.com:8200",
+		"listener 1 address": "0.0.0.0:8200",
+	}
+	peers := []string{"https://vault-2.example.com:8200"}
+	testCases := []struct {
+		name   string
+		target string
+		status status
+	}{
+		{"loopback", "https://127.0.0.1:8200", ErrorStatus},
+		{"api_addr", "https://VAULT-1.example.com:8200", ErrorStatus},
+		{"peer", "https://vault-2.example.com:8200", WarningStatus},
+		{"other port", "https://127.0.0.1:8300", OkStatus},
+		{"external", "https://transit.example.com", OkStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				TransitSealDependencyCheck(ctx, map[string]string{"address": tc.target}, self, peers)
+			})
+			if len(results) != 2 || results[1].Status != tc.status {
+				t.Fatalf("expected the compared addresses and a %s result, got %#v", tc.status, results)
+			}
+			if results[0].Status != InfoStatus || !strings.Contains(results[0].Message, "api_addr https://vault-1.example.com:8200") {
+				t.Fatalf("expected the compared addresses, got %#v", results[0])
+			}
+		})
+	}
+}
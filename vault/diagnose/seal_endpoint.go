@@ -0,0 +1,117 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// kmsEndpointSettings lists, for each KMS seal type, the settings that
+// override the endpoint of the KMS service, each with the environment
+// variables that take precedence over it, in the wrapper's order.
+var kmsEndpointSettings = map[string]map[string][]string{
+	"awskms": {
+		"endpoint": {"AWS_KMS_ENDPOINT"},
+	},
+	"ocikms": {
+		"crypto_endpoint":     {"OCIKMS_WRAPPER_CRYPTO_ENDPOINT", "VAULT_OCIKMS_CRYPTO_ENDPOINT"},
+		"management_endpoint": {"OCIKMS_WRAPPER_MANAGEMENT_ENDPOINT", "VAULT_OCIKMS_MANAGEMENT_ENDPOINT"},
+	},
+	"alicloudkms": {
+		"domain": {"ALICLOUD_DOMAIN"},
+	},
+}
+
+// kmsEndpointDialTimeout bounds the connection attempted to each endpoint.
+const kmsEndpointDialTimeout = 5 * time.Second
+
+// KMSEndpoint is a custom KMS endpoint and the setting or environment variable
+// that set it.
+type KMSEndpoint struct {
+	Source string
+	Value  string
+}
+
+// KMSEndpoints returns the custom endpoints configured for a KMS seal, applying
+// the same environment variable overrides as the wrapper.
+func KMSEndpoints(sealType string, conf map[string]string) []KMSEndpoint {
+	settings := kmsEndpointSettings[sealType]
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var endpoints []KMSEndpoint
+	for _, key := range keys {
+		e := KMSEndpoint{Source: key, Value: conf[key]}
+		for _, env := range settings[key] {
+			if v := os.Getenv(env); v != "" {
+				e = KMSEndpoint{Source: env, Value: v}
+				break
+			}
+		}
+		if e.Value != "" {
+			endpoints = append(endpoints, e)
+		}
+	}
+	return endpoints
+}
+
+// KMSEndpointCheck validates the custom KMS endpoints of a seal, before the
+// seal is created and attempts its first encryption: each must parse as an
+// https URL, or a bare host as the SDKs accept, and accept a TCP connection.
+// This separates a mistyped or unreachable endpoint from the credential and
+// permission errors the seal would otherwise report.
+func KMSEndpointCheck(ctx context.Context, sealType string, endpoints []KMSEndpoint) {
+	for _, e := range endpoints {
+		kmsEndpointCheck(ctx, sealType, e.Source, e.Value)
+	}
+}
+
+func kmsEndpointCheck(ctx context.Context, sealType, source, value string) {
+	testName := "kms-endpoint"
+	raw := value
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		SpotError(ctx, testName, fmt.Errorf("the %s seal endpoint %q set by %s is not a valid URL: %w", sealType, value, source, err))
+		return
+	}
+	switch {
+	case u.Scheme != "https" && u.Scheme != "http":
+		SpotError(ctx, testName, fmt.Errorf("the %s seal endpoint %q set by %s has scheme %q; it must be https",
+			sealType, value, source, u.Scheme))
+		return
+	case u.Hostname() == "":
+		SpotError(ctx, testName, fmt.Errorf("the %s seal endpoint %q set by %s has no host", sealType, value, source))
+		return
+	case u.Scheme == "http":
+		SpotWarn(ctx, testName, fmt.Sprintf("the %s seal endpoint %s set by %s uses http, so requests to the KMS, "+
+			"including its credentials, are not encrypted", sealType, u, source))
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	addr := net.JoinHostPort(u.Hostname(), port)
+	dialer := &net.Dialer{Timeout: kmsEndpointDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		SpotError(ctx, testName, fmt.Errorf("the %s seal endpoint %s set by %s is not reachable: %w", sealType, u, source, err))
+		return
+	}
+	conn.Close()
+	SpotOk(ctx, testName, fmt.Sprintf("the %s seal uses endpoint %s, set by %s", sealType, u, source))
+}
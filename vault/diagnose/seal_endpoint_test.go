@@ -0,0 +1,60 @@
+package diagnose
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestKMSEndpointCheck(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	testCases := []struct {
+		name     string
+		sealType string
+		conf     map[string]string
+		env      map[string]string
+		expected []status
+	}{
+		{"no endpoint", "awskms", map[string]string{"kms_key_id": "alias/vault"}, nil, nil},
+		{"not a kms seal", "transit", map[string]string{"address": "https://127.0.0.1:8200"}, nil, nil},
+		{"reachable", "awskms", map[string]string{"endpoint": server.URL}, nil, []status{OkStatus}},
+		{"bare host", "alicloudkms", map[string]string{"domain": server.Listener.Addr().String()}, nil, []status{OkStatus}},
+		{"from the environment", "awskms", map[string]string{"endpoint": "https://kms.invalid"},
+			map[string]string{"AWS_KMS_ENDPOINT": server.URL}, []status{OkStatus}},
+		{"http", "awskms", map[string]string{"endpoint": "http://" + server.Listener.Addr().String()}, nil, []status{WarningStatus, OkStatus}},
+		{"bad scheme", "awskms", map[string]string{"endpoint": "htps://" + server.Listener.Addr().String()}, nil, []status{ErrorStatus}},
+		{"unparseable", "awskms", map[string]string{"endpoint": "https://kms.example.com:port"}, nil, []status{ErrorStatus}},
+		{"unreachable", "ocikms", map[string]string{"crypto_endpoint": "https://" + closedAddr, "management_endpoint": server.URL},
+			nil, []status{ErrorStatus, OkStatus}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			results := checkResults(t, func(ctx context.Context) {
+				KMSEndpointCheck(ctx, tc.sealType, KMSEndpoints(tc.sealType, tc.conf))
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %#v", len(tc.expected), results)
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("expected %s for result %d, got %#v", tc.expected[i], i, r)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sealSetting is a seal stanza setting and the environment variables the
+// wrapper consults for it.
+type sealSetting struct {
+	key string
+	env []string
+
+	// configFirst is whether the seal stanza takes precedence over the
+	// environment, rather than the other way around.
+	configFirst bool
+
+	// fallback describes where the wrapper looks when neither the stanza nor
+	// the environment sets an optional setting. A setting without one is
+	// required, and the wrapper fails to start without it.
+	fallback string
+}
+
+// sealSettings lists, for each seal type, the settings that can come from the
+// environment, in the order the wrapper resolves them.
+var sealSettings = map[string][]sealSetting{
+	"awskms": {
+		{key: "kms_key_id", env: []string{"AWSKMS_WRAPPER_KEY_ID", "VAULT_AWSKMS_SEAL_KEY_ID"}},
+		{key: "region", env: []string{"AWS_REGION", "AWS_DEFAULT_REGION"}, configFirst: true,
+			fallback: "the shared AWS configuration or instance metadata"},
+		{key: "access_key", env: []string{"AWS_ACCESS_KEY_ID"}, configFirst: true,
+			fallback: "the shared credentials file or instance role"},
+	},
+	"azurekeyvault": {
+		{key: "tenant_id", env: []string{"AZURE_TENANT_ID"}, fallback: "the managed identity"},
+		{key: "client_id", env: []string{"AZURE_CLIENT_ID"}, fallback: "the managed identity"},
+		{key: "client_secret", env: []string{"AZURE_CLIENT_SECRET"}, fallback: "the managed identity"},
+		{key: "vault_name", env: []string{"AZUREKEYVAULT_WRAPPER_VAULT_NAME", "VAULT_AZUREKEYVAULT_VAULT_NAME"}},
+		{key: "key_name", env: []string{"AZUREKEYVAULT_WRAPPER_KEY_NAME", "VAULT_AZUREKEYVAULT_KEY_NAME"}},
+	},
+	"gcpckms": {
+		{key: "credentials", env: []string{"GOOGLE_CREDENTIALS"},
+			fallback: "GOOGLE_APPLICATION_CREDENTIALS or the instance service account"},
+		{key: "project", env: []string{"GOOGLE_PROJECT"}},
+		{key: "region", env: []string{"GOOGLE_REGION"}},
+		{key: "key_ring", env: []string{"GCPCKMS_WRAPPER_KEY_RING", "VAULT_GCPCKMS_SEAL_KEY_RING"}},
+		{key: "crypto_key", env: []string{"GCPCKMS_WRAPPER_CRYPTO_KEY", "VAULT_GCPCKMS_SEAL_CRYPTO_KEY"}},
+	},
+	"alicloudkms": {
+		{key: "kms_key_id", env: []string{"ALICLOUDKMS_WRAPPER_KEY_ID", "VAULT_ALICLOUDKMS_SEAL_KEY_ID"}},
+		{key: "region", env: []string{"ALICLOUD_REGION"}, fallback: "the default region, cn-beijing"},
+		{key: "access_key", env: []string{"ALICLOUD_ACCESS_KEY"}, fallback: "the instance RAM role"},
+	},
+	"ocikms": {
+		{key: "key_id", env: []string{"OCIKMS_WRAPPER_KEY_ID", "VAULT_OCIKMS_SEAL_KEY_ID"}},
+		{key: "crypto_endpoint", env: []string{"OCIKMS_WRAPPER_CRYPTO_ENDPOINT", "VAULT_OCIKMS_CRYPTO_ENDPOINT"}},
+		{key: "management_endpoint", env: []string{"OCIKMS_WRAPPER_MANAGEMENT_ENDPOINT", "VAULT_OCIKMS_MANAGEMENT_ENDPOINT"}},
+	},
+	"transit": {
+		{key: "token", env: []string{"VAULT_TOKEN"}, configFirst: true},
+		{key: "mount_path", env: []string{"TRANSIT_WRAPPER_MOUNT_PATH", "VAULT_TRANSIT_SEAL_MOUNT_PATH"}},
+		{key: "key_name", env: []string{"TRANSIT_WRAPPER_KEY_NAME", "VAULT_TRANSIT_SEAL_KEY_NAME"}},
+	},
+}
+
+// SealEnvVars returns the environment variables a seal of the given type
+// consults, or nil if it consults none.
+func SealEnvVars(sealType string) []string {
+	var vars []string
+	for _, s := range sealSettings[sealType] {
+		vars = append(vars, s.env...)
+	}
+	return vars
+}
+
+// sealSettingSource returns where the wrapper takes a setting from: the name
+// of the environment variable or stanza setting that sets it, or an empty
+// string if neither does.
+func sealSettingSource(s sealSetting, conf map[string]string, getenv func(string) string) string {
+	if s.configFirst && conf[s.key] != "" {
+		return s.key
+	}
+	for _, env := range s.env {
+		if getenv(env) != "" {
+			return env
+		}
+	}
+	if conf[s.key] != "" {
+		return s.key
+	}
+	return ""
+}
+
+// SealEnvCheck reports where a seal takes each of its settings from, the
+// seal stanza or the environment variables the wrapper consults, and warns
+// when a required setting is in neither. An unset variable otherwise
+// surfaces as a confusing authentication or not-found error from the KMS.
+// Only the source of each setting is reported, never its value.
+func SealEnvCheck(ctx context.Context, sealType string, conf map[string]string) {
+	checkSealEnv(ctx, sealType, conf, os.Getenv)
+}
+
+func checkSealEnv(ctx context.Context, sealType string, conf map[string]string, getenv func(string) string) {
+	testName := "seal-env"
+	var sources []string
+	missing := false
+	for _, s := range sealSettings[sealType] {
+		source := sealSettingSource(s, conf, getenv)
+		switch {
+		case source != "":
+			sources = append(sources, fmt.Sprintf("%s from %s", s.key, source))
+		case s.fallback != "":
+			sources = append(sources, fmt.Sprintf("%s from %s", s.key, s.fallback))
+		default:
+			missing = true
+			SpotWarn(ctx, testName, fmt.Sprintf("the %s seal requires %s, but it is not set in the seal stanza and %s",
+				sealType, s.key, unsetVars(s.env)),
+				Advice(fmt.Sprintf("Set %s in the seal stanza, or export %s in the server's environment.", s.key, s.env[0])))
+		}
+	}
+	SpotInfo(ctx, testName, fmt.Sprintf("the %s seal consults %s; it takes %s",
+		sealType, strings.Join(SealEnvVars(sealType), ", "), strings.Join(sources, ", ")))
+	if !missing {
+		SpotOk(ctx, testName, fmt.Sprintf("the %s seal's required settings are set", sealType))
+	}
+}
+
+// unsetVars describes a list of unset environment variables.
+func unsetVars(vars []string) string {
+	if len(vars) == 1 {
+		return vars[0] + " is unset"
+	}
+	return strings.Join(vars[:len(vars)-1], ", ") + " and " + vars[len(vars)-1] + " are unset"
+}
@@ -0,0 +1,62 @@
+package diagnose
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSealSettingSource(t *testing.T) {
+	env := map[string]string{"VAULT_AWSKMS_SEAL_KEY_ID": "env-key", "AWS_REGION": "us-west-2", "VAULT_TOKEN": "s.env"}
+	getenv := func(name string) string { return env[name] }
+	testCases := []struct {
+		sealType string
+		key      string
+		conf     map[string]string
+		expected string
+	}{
+		{"awskms", "kms_key_id", map[string]string{"kms_key_id": "conf-key"}, "VAULT_AWSKMS_SEAL_KEY_ID"},
+		{"awskms", "region", map[string]string{"region": "eu-west-1"}, "region"},
+		{"awskms", "region", map[string]string{}, "AWS_REGION"},
+		{"transit", "token", map[string]string{"token": "s.conf"}, "token"},
+		{"transit", "key_name", map[string]string{}, ""},
+	}
+	for _, tc := range testCases {
+		for _, s := range sealSettings[tc.sealType] {
+			if s.key != tc.key {
+				continue
+			}
+			if source := sealSettingSource(s, tc.conf, getenv); source != tc.expected {
+				t.Fatalf("%s %s: expected %q, got %q", tc.sealType, tc.key, tc.expected, source)
+			}
+		}
+	}
+}
+
+func TestCheckSealEnv(t *testing.T) {
+	env := map[string]string{"VAULT_GCPCKMS_SEAL_KEY_RING": "vault"}
+	getenv := func(name string) string { return env[name] }
+
+	results := checkResults(t, func(ctx context.Context) {
+		checkSealEnv(ctx, "gcpckms", map[string]string{"project": "p", "region": "global", "crypto_key": "k"}, getenv)
+	})
+	if len(results) != 2 || results[1].Status != OkStatus {
+		t.Fatalf("expected the sources and an ok result, got %#v", results)
+	}
+	for _, s := range []string{"key_ring from VAULT_GCPCKMS_SEAL_KEY_RING", "project from project",
+		"credentials from GOOGLE_APPLICATION_CREDENTIALS"} {
+		if !strings.Contains(results[0].Message, s) {
+			t.Fatalf("expected %q in %q", s, results[0].Message)
+		}
+	}
+
+	results = checkResults(t, func(ctx context.Context) {
+		checkSealEnv(ctx, "awskms", map[string]string{}, getenv)
+	})
+	if len(results) != 2 || results[0].Status != WarningStatus || results[1].Status != InfoStatus {
+		t.Fatalf("expected a warning for kms_key_id, got %#v", results)
+	}
+	if !strings.Contains(results[0].Message, "AWSKMS_WRAPPER_KEY_ID and VAULT_AWSKMS_SEAL_KEY_ID are unset") {
+		t.Fatalf("expected the unset variables in %q", results[0].Message)
+	}
+}
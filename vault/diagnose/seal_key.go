@@ -0,0 +1,196 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest"
+	log "github.com/hashicorp/go-hclog"
+	wrapping "github.com/hashicorp/go-kms-wrapping"
+	"github.com/hashicorp/go-kms-wrapping/wrappers/alicloudkms"
+	"github.com/hashicorp/go-kms-wrapping/wrappers/awskms"
+	"github.com/hashicorp/go-kms-wrapping/wrappers/azurekeyvault"
+	"github.com/hashicorp/go-kms-wrapping/wrappers/gcpckms"
+	"github.com/hashicorp/go-kms-wrapping/wrappers/ocikms"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// keyErrorKind is what an error from a KMS says about the seal's key.
+type keyErrorKind int
+
+const (
+	keyErrorOther keyErrorKind = iota
+	keyNotFound
+	keyAccessDenied
+
+	// keyNotFoundOrDenied is an error the KMS returns alike for a key that
+	// doesn't exist and one the caller may not see, so as not to disclose
+	// which keys exist.
+	keyNotFoundOrDenied
+)
+
+// kmsKeyWrappers constructs the wrapper of each KMS seal whose key can be
+// resolved. Configuring the wrapper resolves the key the same way the seal
+// does: AWS, Azure and AliCloud describe it, while GCP and OCI encrypt with it,
+// which fails the same way for a missing key.
+var kmsKeyWrappers = map[string]func(*wrapping.WrapperOptions) kmsKeyWrapper{
+	"awskms":        func(opts *wrapping.WrapperOptions) kmsKeyWrapper { return awskms.NewWrapper(opts) },
+	"azurekeyvault": func(opts *wrapping.WrapperOptions) kmsKeyWrapper { return azurekeyvault.NewWrapper(opts) },
+	"gcpckms":       func(opts *wrapping.WrapperOptions) kmsKeyWrapper { return gcpckms.NewWrapper(opts) },
+	"alicloudkms":   func(opts *wrapping.WrapperOptions) kmsKeyWrapper { return alicloudkms.NewWrapper(opts) },
+	"ocikms":        func(opts *wrapping.WrapperOptions) kmsKeyWrapper { return ocikms.NewWrapper(opts) },
+}
+
+// kmsKeyWrapper is the part of a KMS wrapper that resolves its key.
+type kmsKeyWrapper interface {
+	SetConfig(map[string]string) (map[string]string, error)
+	KeyID() string
+}
+
+// KMSKeyResolvable reports whether KMSKeyCheck can resolve the key of a seal
+// of the given type.
+func KMSKeyResolvable(sealType string) bool {
+	_, ok := kmsKeyWrappers[sealType]
+	return ok
+}
+
+// KMSKeyCheck resolves the key a KMS seal is configured with, before the seal
+// is set up, and reports the key it resolves to, such as the key an alias
+// names or the current version of a named key. A key that doesn't exist is
+// reported apart from one the credentials may not use, since a mistyped key
+// name and a missing permission are otherwise easily confused.
+func KMSKeyCheck(ctx context.Context, sealType string, conf map[string]string) error {
+	newWrapper, ok := kmsKeyWrappers[sealType]
+	if !ok {
+		Skipped(ctx, SkipNotApplicable, fmt.Sprintf("the key of a %s seal is not resolved", sealType))
+		return nil
+	}
+	wrapper := newWrapper(&wrapping.WrapperOptions{Logger: log.NewNullLogger()})
+	_, err := wrapper.SetConfig(conf)
+	var resolved string
+	if err == nil {
+		resolved = wrapper.KeyID()
+	}
+	return checkKMSKey(ctx, sealType, kmsKeyName(sealType, conf, os.Getenv), resolved, err)
+}
+
+// kmsKeyName describes the key a KMS seal is configured with, from the
+// settings that name it.
+func kmsKeyName(sealType string, conf map[string]string, getenv func(string) string) string {
+	value := func(key string) string {
+		if v := sealSettingValue(sealType, key, conf, getenv); v != "" {
+			return v
+		}
+		return "(unset)"
+	}
+	switch sealType {
+	case "awskms", "alicloudkms":
+		return value("kms_key_id")
+	case "azurekeyvault":
+		return fmt.Sprintf("%s in vault %s", value("key_name"), value("vault_name"))
+	case "gcpckms":
+		return fmt.Sprintf("projects/%s/locations/%s/keyRings/%s/cryptoKeys/%s",
+			value("project"), value("region"), value("key_ring"), value("crypto_key"))
+	case "ocikms":
+		return value("key_id")
+	}
+	return ""
+}
+
+func checkKMSKey(ctx context.Context, sealType, key, resolved string, err error) error {
+	testName := "kms-key"
+	if err == nil {
+		if resolved == "" || resolved == key {
+			SpotOk(ctx, testName, fmt.Sprintf("the %s seal's key %s exists", sealType, key))
+		} else {
+			SpotOk(ctx, testName, fmt.Sprintf("the %s seal's key %s exists and resolves to %s", sealType, key, resolved))
+		}
+		return nil
+	}
+	switch kmsKeyErrorKind(err) {
+	case keyNotFound:
+		return SpotError(ctx, testName, fmt.Errorf("the %s seal's key %s was not found: %w", sealType, key, err),
+			Advice("Check the key's name or ID, and the region or project it is looked up in, for typos."))
+	case keyAccessDenied:
+		return SpotError(ctx, testName, fmt.Errorf("the %s seal's credentials are not permitted to use the key %s: %w",
+			sealType, key, err),
+			Advice("Grant the seal's credentials permission to describe, encrypt with and decrypt with the key."))
+	case keyNotFoundOrDenied:
+		return SpotError(ctx, testName, fmt.Errorf("the %s seal's key %s either doesn't exist or the seal's credentials "+
+			"are not permitted to use it; the KMS doesn't say which: %w", sealType, key, err),
+			Advice("Check the key's ID for typos, then the policies granting the seal's credentials access to it."))
+	}
+	return SpotError(ctx, testName, fmt.Errorf("could not resolve the %s seal's key %s: %w", sealType, key, err))
+}
+
+// kmsKeyErrorKind classifies the error a KMS returned for the seal's key, from
+// the codes and HTTP statuses of each cloud's SDK.
+func kmsKeyErrorKind(err error) keyErrorKind {
+	// AWS and AliCloud errors carry a code that names the condition.
+	var coded interface{ Code() string }
+	if errors.As(err, &coded) {
+		switch coded.Code() {
+		case "NotFoundException":
+			return keyNotFound
+		case "AccessDeniedException", "UnrecognizedClientException":
+			return keyAccessDenied
+		}
+	}
+	var aliCoded interface{ ErrorCode() string }
+	if errors.As(err, &aliCoded) {
+		switch {
+		case aliCoded.ErrorCode() == "Forbidden.KeyNotFound", aliCoded.ErrorCode() == "Forbidden.AliasNotFound":
+			return keyNotFound
+		case strings.HasPrefix(aliCoded.ErrorCode(), "Forbidden.RAM"), aliCoded.ErrorCode() == "Forbidden.NoPermission":
+			return keyAccessDenied
+		}
+	}
+	// OCI reports missing keys and ones the caller may not see alike.
+	var ociErr interface{ GetCode() string }
+	if errors.As(err, &ociErr) {
+		switch ociErr.GetCode() {
+		case "NotAuthorizedOrNotFound":
+			return keyNotFoundOrDenied
+		case "NotAuthenticated", "NotAuthorized":
+			return keyAccessDenied
+		}
+	}
+	var grpcErr interface{ GRPCStatus() *grpcstatus.Status }
+	if errors.As(err, &grpcErr) {
+		switch grpcErr.GRPCStatus().Code() {
+		case codes.NotFound:
+			return keyNotFound
+		case codes.PermissionDenied:
+			// GCP only reports a missing key to callers permitted on its key
+			// ring.
+			return keyNotFoundOrDenied
+		case codes.Unauthenticated:
+			return keyAccessDenied
+		}
+	}
+
+	var statusCode int
+	var detailed autorest.DetailedError
+	var httpErr interface{ StatusCode() int }
+	var aliHTTP interface{ HttpStatus() int }
+	switch {
+	case errors.As(err, &detailed):
+		statusCode, _ = detailed.StatusCode.(int)
+	case errors.As(err, &httpErr):
+		statusCode = httpErr.StatusCode()
+	case errors.As(err, &aliHTTP):
+		statusCode = aliHTTP.HttpStatus()
+	}
+	switch statusCode {
+	case http.StatusNotFound:
+		return keyNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return keyAccessDenied
+	}
+	return keyErrorOther
+}
@@ -0,0 +1,110 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	alierrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// ociError is an error as the OCI SDK returns it.
+type ociError struct {
+	code string
+}
+
+func (e ociError) Error() string   { return e.code }
+func (e ociError) GetCode() string { return e.code }
+
+func TestKMSKeyErrorKind(t *testing.T) {
+	wrap := func(err error) error {
+		return fmt.Errorf("error fetching key information: %w", err)
+	}
+	cases := []struct {
+		name     string
+		err      error
+		expected keyErrorKind
+	}{
+		{"aws not found", wrap(awserr.NewRequestFailure(awserr.New("NotFoundException", "Alias not found", nil), 400, "")), keyNotFound},
+		{"aws access denied", wrap(awserr.NewRequestFailure(awserr.New("AccessDeniedException", "not authorized", nil), 400, "")), keyAccessDenied},
+		{"aws throttled", wrap(awserr.NewRequestFailure(awserr.New("ThrottlingException", "slow down", nil), 400, "")), keyErrorOther},
+		{"gcp not found", wrap(grpcstatus.Error(codes.NotFound, "CryptoKey not found")), keyNotFound},
+		{"gcp permission denied", wrap(grpcstatus.Error(codes.PermissionDenied, "denied (or it may not exist)")), keyNotFoundOrDenied},
+		{"azure not found", wrap(autorest.DetailedError{StatusCode: 404}), keyNotFound},
+		{"azure forbidden", wrap(autorest.DetailedError{StatusCode: 403}), keyAccessDenied},
+		{"alicloud not found", wrap(alierrors.NewServerError(404, `{"Code":"Forbidden.KeyNotFound"}`, "")), keyNotFound},
+		{"alicloud no permission", wrap(alierrors.NewServerError(403, `{"Code":"Forbidden.RAM"}`, "")), keyAccessDenied},
+		{"oci not found or denied", wrap(ociError{"NotAuthorizedOrNotFound"}), keyNotFoundOrDenied},
+		{"other", errors.New("connection refused"), keyErrorOther},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if kind := kmsKeyErrorKind(tc.err); kind != tc.expected {
+				t.Fatalf("expected %d, got %d", tc.expected, kind)
+			}
+		})
+	}
+}
+
+func TestKMSKeyName(t *testing.T) {
+	getenv := func(name string) string {
+		if name == "VAULT_GCPCKMS_SEAL_KEY_RING" {
+			return "ring"
+		}
+		return ""
+	}
+	name := kmsKeyName("gcpckms", map[string]string{"project": "p", "region": "global", "crypto_key": "k"}, getenv)
+	if expected := "projects/p/locations/global/keyRings/ring/cryptoKeys/k"; name != expected {
+		t.Fatalf("expected %s, got %s", expected, name)
+	}
+	if name := kmsKeyName("azurekeyvault", map[string]string{"key_name": "k"}, getenv); name != "k in vault (unset)" {
+		t.Fatalf("unexpected name %s", name)
+	}
+}
+
+func TestCheckKMSKey(t *testing.T) {
+	cases := []struct {
+		name     string
+		resolved string
+		err      error
+		message  string
+	}{
+		{
+			name:     "resolved alias",
+			resolved: "1234abcd-12ab-34cd-56ef-1234567890ab",
+			message:  "the awskms seal's key alias/vault exists and resolves to 1234abcd-12ab-34cd-56ef-1234567890ab",
+		},
+		{
+			name:    "not found",
+			err:     awserr.New("NotFoundException", "Alias not found", nil),
+			message: "the awskms seal's key alias/vault was not found: NotFoundException: Alias not found",
+		},
+		{
+			name:    "access denied",
+			err:     awserr.New("AccessDeniedException", "not authorized", nil),
+			message: "the awskms seal's credentials are not permitted to use the key alias/vault: AccessDeniedException: not authorized",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				checkKMSKey(ctx, "awskms", "alias/vault", tc.resolved, tc.err)
+			})
+			if len(results) != 1 {
+				t.Fatalf("expected 1 result, got %d", len(results))
+			}
+			var expected status = OkStatus
+			if tc.err != nil {
+				expected = ErrorStatus
+			}
+			if results[0].Status != expected || results[0].Message != tc.message {
+				t.Fatalf("expected %s %q, got %s %q", expected, tc.message, results[0].Status, results[0].Message)
+			}
+		})
+	}
+}
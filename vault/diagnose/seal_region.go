@@ -0,0 +1,160 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// cloudLocation is where a seal's key or a storage backend's data lives in a
+// cloud provider. Either of region and project may be unknown.
+type cloudLocation struct {
+	cloud   string
+	region  string
+	project string
+}
+
+func (l cloudLocation) String() string {
+	var parts []string
+	if l.project != "" {
+		parts = append(parts, "project "+l.project)
+	}
+	if l.region != "" {
+		parts = append(parts, "region "+l.region)
+	}
+	return fmt.Sprintf("%s %s", l.cloud, strings.Join(parts, ", "))
+}
+
+// sealSettingValue returns the value the wrapper of a seal of the given type
+// takes a setting from, or an empty string if neither the stanza nor the
+// environment sets it.
+func sealSettingValue(sealType, key string, conf map[string]string, getenv func(string) string) string {
+	for _, s := range sealSettings[sealType] {
+		if s.key != key {
+			continue
+		}
+		source := sealSettingSource(s, conf, getenv)
+		if source == key {
+			return conf[key]
+		}
+		if source != "" {
+			return getenv(source)
+		}
+	}
+	return ""
+}
+
+// sealLocation returns where the key of a KMS seal lives, or false if the
+// seal isn't a cloud KMS or its location can't be known from the
+// configuration.
+func sealLocation(sealType string, conf map[string]string, getenv func(string) string) (cloudLocation, bool) {
+	switch sealType {
+	case "awskms":
+		// Without a region the wrapper takes it from the shared configuration
+		// or instance metadata, which diagnose doesn't resolve.
+		region := sealSettingValue(sealType, "region", conf, getenv)
+		return cloudLocation{cloud: "aws", region: region}, region != ""
+	case "gcpckms":
+		loc := cloudLocation{
+			cloud:   "gcp",
+			region:  sealSettingValue(sealType, "region", conf, getenv),
+			project: sealSettingValue(sealType, "project", conf, getenv),
+		}
+		// A global key ring isn't in any one region.
+		if loc.region == "global" {
+			loc.region = ""
+		}
+		return loc, loc.region != "" || loc.project != ""
+	case "alicloudkms":
+		region := sealSettingValue(sealType, "region", conf, getenv)
+		if region == "" {
+			region = "cn-beijing"
+		}
+		return cloudLocation{cloud: "alicloud", region: region}, true
+	}
+	return cloudLocation{}, false
+}
+
+// storageLocation returns where a storage backend's data lives, or false if
+// the backend isn't a cloud service or its location can't be known from the
+// configuration.
+func storageLocation(storageType string, conf map[string]string, getenv func(string) string) (cloudLocation, bool) {
+	switch storageType {
+	case "s3", "dynamodb":
+		return cloudLocation{cloud: "aws", region: awsStorageRegion(conf, "us-east-1", getenv)}, true
+	case "spanner":
+		// The database is named projects/<project>/instances/<instance>/databases/<database>;
+		// the instance's region is in its instance configuration, which isn't
+		// part of the storage stanza.
+		database := getenv("GOOGLE_SPANNER_DATABASE")
+		if database == "" {
+			database = conf["database"]
+		}
+		parts := strings.Split(database, "/")
+		if len(parts) < 2 || parts[0] != "projects" || parts[1] == "" {
+			return cloudLocation{}, false
+		}
+		return cloudLocation{cloud: "gcp", project: parts[1]}, true
+	case "alicloudoss":
+		// Endpoints are named oss-<region>.aliyuncs.com, or
+		// oss-<region>-internal.aliyuncs.com within the region's network.
+		endpoint := getenv("ALICLOUD_OSS_ENDPOINT")
+		if endpoint == "" {
+			endpoint = conf["endpoint"]
+		}
+		if i := strings.Index(endpoint, "://"); i >= 0 {
+			endpoint = endpoint[i+3:]
+		}
+		host := strings.SplitN(endpoint, ".", 2)[0]
+		if !strings.HasPrefix(host, "oss-") {
+			return cloudLocation{}, false
+		}
+		region := strings.TrimSuffix(strings.TrimPrefix(host, "oss-"), "-internal")
+		return cloudLocation{cloud: "alicloud", region: region}, true
+	}
+	return cloudLocation{}, false
+}
+
+// SealRegionCheck compares the region and project of a cloud KMS seal's key
+// with those of a cloud storage backend, and warns when they differ: each
+// unseal then crosses regions, adding latency to startup and transfer costs,
+// though the seal and storage checks alone find nothing wrong.
+func SealRegionCheck(ctx context.Context, sealType string, sealConf map[string]string, storageType string, storageConf map[string]string) {
+	checkSealRegion(ctx, sealType, sealConf, storageType, storageConf, os.Getenv)
+}
+
+func checkSealRegion(ctx context.Context, sealType string, sealConf map[string]string, storageType string, storageConf map[string]string, getenv func(string) string) {
+	testName := "seal-region"
+	seal, ok := sealLocation(sealType, sealConf, getenv)
+	if !ok {
+		Skipped(ctx, SkipNotApplicable, fmt.Sprintf("the location of the %s seal's key is not known from the configuration", sealType))
+		return
+	}
+	storage, ok := storageLocation(storageType, storageConf, getenv)
+	if !ok {
+		Skipped(ctx, SkipNotApplicable, fmt.Sprintf("the location of the %s storage backend is not known from the configuration", storageType))
+		return
+	}
+	SpotInfo(ctx, testName, fmt.Sprintf("the %s seal's key is in %s; the %s storage is in %s", sealType, seal, storageType, storage))
+
+	if seal.cloud != storage.cloud {
+		SpotWarn(ctx, testName, fmt.Sprintf("the %s seal and the %s storage are in different clouds, %s and %s, so "+
+			"each unseal crosses providers", sealType, storageType, seal.cloud, storage.cloud))
+		return
+	}
+	var mismatches []string
+	if seal.region != "" && storage.region != "" && seal.region != storage.region {
+		mismatches = append(mismatches, fmt.Sprintf("region %s and region %s", seal.region, storage.region))
+	}
+	if seal.project != "" && storage.project != "" && seal.project != storage.project {
+		mismatches = append(mismatches, fmt.Sprintf("project %s and project %s", seal.project, storage.project))
+	}
+	if len(mismatches) > 0 {
+		SpotWarn(ctx, testName, fmt.Sprintf("the %s seal's key and the %s storage are in %s, so each unseal "+
+			"crosses regions, adding latency and transfer costs", sealType, storageType, strings.Join(mismatches, ", and ")),
+			Advice("Use a key in the storage's region and project, unless the seal is deliberately kept apart for disaster recovery."))
+		return
+	}
+	SpotOk(ctx, testName, fmt.Sprintf("the %s seal's key and the %s storage are in the same location", sealType, storageType))
+}
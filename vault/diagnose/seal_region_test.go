@@ -0,0 +1,98 @@
+package diagnose
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheckSealRegion(t *testing.T) {
+	cases := []struct {
+		name        string
+		sealType    string
+		sealConf    map[string]string
+		storageType string
+		storageConf map[string]string
+		env         map[string]string
+		expected    []status
+		contains    string
+	}{
+		{
+			name:        "same aws region",
+			sealType:    "awskms",
+			sealConf:    map[string]string{"region": "eu-west-1"},
+			storageType: "dynamodb",
+			env:         map[string]string{"AWS_REGION": "eu-west-1"},
+			expected:    []status{InfoStatus, OkStatus},
+		},
+		{
+			name:        "aws region mismatch",
+			sealType:    "awskms",
+			sealConf:    map[string]string{"region": "eu-west-1"},
+			storageType: "s3",
+			storageConf: map[string]string{"region": "us-west-2"},
+			expected:    []status{InfoStatus, WarningStatus},
+			contains:    "region eu-west-1 and region us-west-2",
+		},
+		{
+			name:        "storage default region",
+			sealType:    "awskms",
+			env:         map[string]string{"AWS_DEFAULT_REGION": "ap-south-1"},
+			storageType: "s3",
+			expected:    []status{InfoStatus, OkStatus},
+		},
+		{
+			name:        "gcp project mismatch",
+			sealType:    "gcpckms",
+			sealConf:    map[string]string{"project": "vault-keys", "region": "global"},
+			storageType: "spanner",
+			storageConf: map[string]string{"database": "projects/vault-data/instances/vault/databases/vault"},
+			expected:    []status{InfoStatus, WarningStatus},
+			contains:    "project vault-keys and project vault-data",
+		},
+		{
+			name:        "alicloud default region",
+			sealType:    "alicloudkms",
+			storageType: "alicloudoss",
+			storageConf: map[string]string{"endpoint": "oss-cn-beijing-internal.aliyuncs.com"},
+			expected:    []status{InfoStatus, OkStatus},
+		},
+		{
+			name:        "different clouds",
+			sealType:    "alicloudkms",
+			storageType: "dynamodb",
+			expected:    []status{InfoStatus, WarningStatus},
+			contains:    "different clouds",
+		},
+		{
+			name:        "unknown seal region",
+			sealType:    "awskms",
+			storageType: "s3",
+		},
+		{
+			name:        "storage not in a cloud",
+			sealType:    "awskms",
+			sealConf:    map[string]string{"region": "eu-west-1"},
+			storageType: "raft",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			getenv := func(name string) string { return tc.env[name] }
+			results := checkResults(t, func(ctx context.Context) {
+				checkSealRegion(ctx, tc.sealType, tc.sealConf, tc.storageType, tc.storageConf, getenv)
+			})
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %d", len(tc.expected), len(results))
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("result %d: expected %s, got %s: %s", i, tc.expected[i], r.Status, r.Message)
+				}
+			}
+			if tc.contains != "" && !strings.Contains(results[len(results)-1].Message, tc.contains) {
+				t.Fatalf("expected %q in %q", tc.contains, results[len(results)-1].Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package diagnose
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+const transitRoundTripValue = "diagnose-transit-seal"
+
+// TransitSealConfig is the effective configuration of a transit seal, after
+// applying the same environment variable overrides as the transit wrapper.
+type TransitSealConfig struct {
+	Address   string
+	Token     string
+	MountPath string
+	KeyName   string
+	Namespace string
+	TLSConfig *api.TLSConfig
+}
+
+// firstNonEmpty returns the first non-empty value among the named environment
+// variables, falling back to the given config value.
+func firstNonEmpty(configValue string, envVars ...string) string {
+	for _, e := range envVars {
+		if v := os.Getenv(e); v != "" {
+			return v
+		}
+	}
+	return configValue
+}
+
+// ResolveTransitSealConfig returns the effective transit seal configuration,
+// or an error naming the first required field that is missing.
+func ResolveTransitSealConfig(conf map[string]string) (*TransitSealConfig, error) {
+	c := &TransitSealConfig{
+		Address:   conf["address"],
+		Token:     conf["token"],
+		MountPath: firstNonEmpty(conf["mount_path"], "TRANSIT_WRAPPER_MOUNT_PATH", "VAULT_TRANSIT_SEAL_MOUNT_PATH"),
+		KeyName:   firstNonEmpty(conf["key_name"], "TRANSIT_WRAPPER_KEY_NAME", "VAULT_TRANSIT_SEAL_KEY_NAME"),
+		Namespace: firstNonEmpty(conf["namespace"], api.EnvVaultNamespace),
+	}
+	// As with the transit wrapper, the address and token in the seal stanza
+	// take precedence over the environment.
+	if c.Address == "" {
+		c.Address = api.DefaultConfig().Address
+	}
+	if c.Token == "" {
+		c.Token = os.Getenv(api.EnvVaultToken)
+	}
+
+	switch {
+	case c.Token == "":
+		return nil, fmt.Errorf("token is required, either in the seal stanza or in %s", api.EnvVaultToken)
+	case c.MountPath == "":
+		return nil, fmt.Errorf("mount_path is required")
+	case c.KeyName == "":
+		return nil, fmt.Errorf("key_name is required")
+	}
+
+	if conf["tls_ca_cert"] != "" || conf["tls_ca_path"] != "" || conf["tls_client_cert"] != "" ||
+		conf["tls_client_key"] != "" || conf["tls_server_name"] != "" || conf["tls_skip_verify"] != "" {
+		var skipVerify bool
+		if conf["tls_skip_verify"] != "" {
+			var err error
+			if skipVerify, err = strconv.ParseBool(conf["tls_skip_verify"]); err != nil {
+				return nil, fmt.Errorf("could not parse tls_skip_verify: %w", err)
+			}
+		}
+		c.TLSConfig = &api.TLSConfig{
+			CACert:        conf["tls_ca_cert"],
+			CAPath:        conf["tls_ca_path"],
+			ClientCert:    conf["tls_client_cert"],
+			ClientKey:     conf["tls_client_key"],
+			TLSServerName: conf["tls_server_name"],
+			Insecure:      skipVerify,
+		}
+	}
+	return c, nil
+}
+
+// Client returns a Vault API client for the remote Vault named by the config.
+func (c *TransitSealConfig) Client() (*api.Client, error) {
+	apiConfig := api.DefaultConfig()
+	apiConfig.Address = c.Address
+	if c.TLSConfig != nil {
+		if err := apiConfig.ConfigureTLS(c.TLSConfig); err != nil {
+			return nil, err
+		}
+	}
+	client, err := api.NewClient(apiConfig)
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(c.Token)
+	if c.Namespace != "" {
+		client.SetNamespace(c.Namespace)
+	}
+	return client, nil
+}
+
+// TransitSealCheck validates the configuration of a transit seal and performs
+// an encrypt and decrypt round trip against the remote Vault's transit engine.
+func TransitSealCheck(ctx context.Context, conf map[string]string) error {
+	tc, err := ResolveTransitSealConfig(conf)
+	if err != nil {
+		return SpotError(ctx, "transit-seal-config", err)
+	}
+	SpotOk(ctx, "transit-seal-config", fmt.Sprintf("key %q at mount %q on %s", tc.KeyName, tc.MountPath, tc.Address))
+
+	client, err := tc.Client()
+	if err != nil {
+		return SpotError(ctx, "transit-seal-roundtrip", err)
+	}
+	transitNamespaceCheck(ctx, client, tc)
+
+	mount := path.Clean("/" + tc.MountPath)[1:]
+	secret, err := client.Logical().Write(path.Join(mount, "encrypt", tc.KeyName), map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString([]byte(transitRoundTripValue)),
+	})
+	if err != nil {
+		return SpotError(ctx, "transit-seal-roundtrip", transitNamespaceError(client, tc, transitError(tc, err), err))
+	}
+	if secret == nil || secret.Data["ciphertext"] == nil {
+		return SpotError(ctx, "transit-seal-roundtrip", fmt.Errorf("remote Vault at %s returned no ciphertext", tc.Address))
+	}
+
+	secret, err = client.Logical().Write(path.Join(mount, "decrypt", tc.KeyName), map[string]interface{}{
+		"ciphertext": secret.Data["ciphertext"],
+	})
+	if err != nil {
+		return SpotError(ctx, "transit-seal-roundtrip", transitError(tc, err))
+	}
+	var plaintext []byte
+	if secret != nil {
+		if encoded, ok := secret.Data["plaintext"].(string); ok {
+			plaintext, _ = base64.StdEncoding.DecodeString(encoded)
+		}
+	}
+	if string(plaintext) != transitRoundTripValue {
+		return SpotError(ctx, "transit-seal-roundtrip", fmt.Errorf("remote Vault at %s returned an incorrect decrypted value", tc.Address))
+	}
+
+	SpotOk(ctx, "transit-seal-roundtrip", fmt.Sprintf("encrypted and decrypted with key %q on %s", tc.KeyName, tc.Address))
+
+	transitKeySpecCheck(ctx, client, tc, mount)
+	return nil
+}
+
+// transitNamespaceCheck reports the namespace and mount the round trip runs
+// in, and warns when a namespace is set on a remote Vault that is not
+// Enterprise and so ignores it.
+func transitNamespaceCheck(ctx context.Context, client *api.Client, tc *TransitSealConfig) {
+	testName := "transit-seal-namespace"
+	if tc.Namespace == "" {
+		SpotInfo(ctx, testName, fmt.Sprintf("mount %q in the root namespace", tc.MountPath))
+		return
+	}
+	if enterprise, known := remoteIsEnterprise(client); known && !enterprise {
+		SpotWarn(ctx, testName, fmt.Sprintf("namespace %q is set, but the remote Vault at %s is not Vault Enterprise "+
+			"and ignores it, so mount %q is used from the root namespace", tc.Namespace, tc.Address, tc.MountPath))
+		return
+	}
+	SpotInfo(ctx, testName, fmt.Sprintf("mount %q in namespace %q", tc.MountPath, tc.Namespace))
+}
+
+// transitNamespaceError adds a hint to err, the translation of the remote
+// Vault's response orig, when a missing namespace is a likely cause: the
+// mount was not found or access was denied, no namespace is set and the
+// remote Vault is Enterprise, so the mount may live in a child namespace.
+func transitNamespaceError(client *api.Client, tc *TransitSealConfig, err, orig error) error {
+	var respErr *api.ResponseError
+	if tc.Namespace != "" || !errors.As(orig, &respErr) ||
+		(respErr.StatusCode != http.StatusNotFound && respErr.StatusCode != http.StatusForbidden) {
+		return err
+	}
+	if enterprise, _ := remoteIsEnterprise(client); !enterprise {
+		return err
+	}
+	return fmt.Errorf("%w; the remote Vault is Vault Enterprise and no namespace is set, so %q is looked up in the "+
+		"root namespace; set namespace if the transit mount lives in a child namespace", err, tc.MountPath)
+}
+
+// remoteIsEnterprise reports whether the remote Vault is Vault Enterprise,
+// judged from the version in its health response, and whether that could be
+// determined at all.
+func remoteIsEnterprise(client *api.Client) (enterprise, known bool) {
+	health, err := client.Sys().Health()
+	if err != nil || health == nil || health.Version == "" {
+		return false, false
+	}
+	return strings.Contains(health.Version, "+ent"), true
+}
+
+// transitKeySpecCheck reads the transit key's metadata and checks that its
+// type is suited to wrapping the barrier key. Asymmetric encryption keys work,
+// but a symmetric key is recommended; signing-only keys cannot be used at all.
+func transitKeySpecCheck(ctx context.Context, client *api.Client, tc *TransitSealConfig, mount string) {
+	testName := "transit-seal-key-spec"
+	secret, err := client.Logical().Read(path.Join(mount, "keys", tc.KeyName))
+	if err != nil {
+		var respErr *api.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusForbidden {
+			SpotSkipped(ctx, testName, SkipDependencyFailed, fmt.Sprintf("the token cannot read %s/keys/%s, so the key type is unknown", tc.MountPath, tc.KeyName))
+			return
+		}
+		SpotWarn(ctx, testName, transitError(tc, err).Error())
+		return
+	}
+	if secret == nil {
+		SpotWarn(ctx, testName, fmt.Sprintf("remote Vault at %s returned no metadata for key %q", tc.Address, tc.KeyName))
+		return
+	}
+
+	keyType, _ := secret.Data["type"].(string)
+	switch keyType {
+	case "aes128-gcm96", "aes256-gcm96", "chacha20-poly1305":
+		SpotOk(ctx, testName, fmt.Sprintf("key %q is of type %s", tc.KeyName, keyType))
+	case "rsa-2048", "rsa-3072", "rsa-4096":
+		SpotWarn(ctx, testName, fmt.Sprintf("key %q is of type %s; asymmetric keys are slower and limit the size of "+
+			"what can be wrapped, so a symmetric key such as aes256-gcm96 is recommended", tc.KeyName, keyType))
+	case "":
+		SpotWarn(ctx, testName, fmt.Sprintf("remote Vault at %s did not report the type of key %q", tc.Address, tc.KeyName))
+	default:
+		SpotError(ctx, testName, fmt.Errorf("key %q is of type %s, which does not support encryption and cannot "+
+			"be used by the transit seal", tc.KeyName, keyType))
+	}
+}
+
+// transitError translates errors from the remote Vault into messages that
+// distinguish connectivity problems from authorization problems.
+func transitError(tc *TransitSealConfig, err error) error {
+	var respErr *api.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.StatusCode {
+		case http.StatusForbidden:
+			return fmt.Errorf("the token was denied access to transit key %q on %s; check its policy grants "+
+				"update on %s/encrypt/%s and %s/decrypt/%s", tc.KeyName, tc.Address, tc.MountPath, tc.KeyName, tc.MountPath, tc.KeyName)
+		case http.StatusNotFound:
+			return fmt.Errorf("no transit engine is mounted at %q on %s", tc.MountPath, tc.Address)
+		}
+		return fmt.Errorf("remote Vault at %s returned an error: %w", tc.Address, err)
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("remote Vault at %s is unreachable: %w", tc.Address, urlErr.Err)
+	}
+	return err
+}
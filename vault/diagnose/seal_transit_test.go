@@ -0,0 +1,154 @@
+package diagnose
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeTransit is a minimal stand-in for a remote Vault's transit engine that
+// echoes the plaintext back as the ciphertext, and reports keys as keyType.
+func fakeTransit(t *testing.T, status int, keyType string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		if r.Method == http.MethodGet && r.URL.Path == "/v1/transit/keys/unseal" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"type": keyType}})
+			return
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		var data map[string]interface{}
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/unseal":
+			data = map[string]interface{}{"ciphertext": body["plaintext"]}
+		case "/v1/transit/decrypt/unseal":
+			data = map[string]interface{}{"plaintext": body["ciphertext"]}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+}
+
+func TestResolveTransitSealConfig(t *testing.T) {
+	_, err := ResolveTransitSealConfig(map[string]string{
+		"address": "https://vault.example.com:8200",
+		"token":   "s.token",
+	})
+	if err == nil || !strings.Contains(err.Error(), "mount_path") {
+		t.Fatalf("expected a missing mount_path error, got %v", err)
+	}
+
+	tc, err := ResolveTransitSealConfig(map[string]string{
+		"address":    "https://vault.example.com:8200",
+		"token":      "s.token",
+		"mount_path": "transit/",
+		"key_name":   "unseal",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.Address != "https://vault.example.com:8200" || tc.KeyName != "unseal" {
+		t.Fatalf("unexpected config: %#v", tc)
+	}
+}
+
+func TestTransitSealCheck(t *testing.T) {
+	ts := fakeTransit(t, http.StatusOK, "aes256-gcm96")
+	defer ts.Close()
+
+	conf := map[string]string{
+		"address":    ts.URL,
+		"token":      "s.token",
+		"mount_path": "transit/",
+		"key_name":   "unseal",
+	}
+	if err := TransitSealCheck(context.Background(), conf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	denied := fakeTransit(t, http.StatusForbidden, "")
+	defer denied.Close()
+	conf["address"] = denied.URL
+	err := TransitSealCheck(context.Background(), conf)
+	if err == nil || !strings.Contains(err.Error(), "denied access") {
+		t.Fatalf("expected a permission error, got %v", err)
+	}
+}
+
+func TestTransitSealKeySpec(t *testing.T) {
+	testCases := []struct {
+		keyType  string
+		expected status
+	}{
+		{"aes256-gcm96", OkStatus},
+		{"rsa-2048", WarningStatus},
+		{"ed25519", ErrorStatus},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.keyType, func(t *testing.T) {
+			ts := fakeTransit(t, http.StatusOK, tc.keyType)
+			defer ts.Close()
+
+			conf := map[string]string{
+				"address":    ts.URL,
+				"token":      "s.token",
+				"mount_path": "transit/",
+				"key_name":   "unseal",
+			}
+			results := checkResults(t, func(ctx context.Context) {
+				TransitSealCheck(ctx, conf)
+			})
+			last := results[len(results)-1]
+			if last.Name != "transit-seal-key-spec" || last.Status != tc.expected {
+				t.Fatalf("expected a key spec result with status %s, got %#v", tc.expected, last)
+			}
+		})
+	}
+}
+
+func TestTransitSealNamespace(t *testing.T) {
+	var version string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/sys/health" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"initialized": true, "version": version})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[]}`))
+	}))
+	defer ts.Close()
+
+	conf := map[string]string{
+		"address":    ts.URL,
+		"token":      "s.token",
+		"mount_path": "transit/",
+		"key_name":   "unseal",
+	}
+
+	version = "1.8.0+ent"
+	err := TransitSealCheck(context.Background(), conf)
+	if err == nil || !strings.Contains(err.Error(), "set namespace") {
+		t.Fatalf("expected a namespace hint, got %v", err)
+	}
+
+	version = "1.8.0"
+	conf["namespace"] = "team-a"
+	results := checkResults(t, func(ctx context.Context) {
+		TransitSealCheck(ctx, conf)
+	})
+	if len(results) < 2 || results[1].Name != "transit-seal-namespace" || results[1].Status != WarningStatus {
+		t.Fatalf("expected a warning that the namespace is ignored, got %#v", results)
+	}
+}
@@ -3,6 +3,8 @@ package diagnose
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,6 +29,7 @@ func EndToEndLatencyCheckWrite(ctx context.Context, uuid string, b physical.Back
 	if err != nil {
 		return time.Duration(0), err
 	}
+	RecordMetric(ctx, "write-latency", float64(duration)/float64(time.Millisecond), "ms")
 	if duration > latencyThreshold {
 		return duration, nil
 	}
@@ -47,6 +50,7 @@ func EndToEndLatencyCheckRead(ctx context.Context, uuid string, b physical.Backe
 	if val.Key != uuid && string(val.Value) != secretVal {
 		return time.Duration(0), fmt.Errorf(wrongRWValsPrefix+"expecting diagnose, but got %s, %s", val.Key, val.Value)
 	}
+	RecordMetric(ctx, "read-latency", float64(duration)/float64(time.Millisecond), "ms")
 	if duration > latencyThreshold {
 		return duration, nil
 	}
@@ -60,6 +64,7 @@ func EndToEndLatencyCheckDelete(ctx context.Context, uuid string, b physical.Bac
 	if err != nil {
 		return time.Duration(0), err
 	}
+	RecordMetric(ctx, "delete-latency", float64(duration)/float64(time.Millisecond), "ms")
 	if duration > latencyThreshold {
 		return duration, nil
 	}
@@ -79,3 +84,245 @@ func ConsulDirectAccess(config map[string]string) string {
 	}
 	return ""
 }
+
+// storageTargetDefaults holds the default address and key prefix of storage
+// types that can share a cluster between storage and ha_storage.
+var storageTargetDefaults = map[string]struct{ address, prefix string }{
+	"consul":    {"127.0.0.1:8500", "vault/"},
+	"etcd":      {"http://127.0.0.1:2379", "/vault"},
+	"zookeeper": {"localhost:2181", "/vault/"},
+}
+
+// StorageTarget is the cluster address and key prefix a storage stanza
+// writes to.
+type StorageTarget struct {
+	Type    string
+	Address string
+	Prefix  string
+}
+
+func (t StorageTarget) String() string {
+	return fmt.Sprintf("%s at %s under %q", t.Type, t.Address, t.Prefix)
+}
+
+// storageTarget returns the target of a storage stanza, filling in the
+// backend's defaults. It returns false for types whose target isn't known.
+func storageTarget(storageType string, conf map[string]string) (StorageTarget, bool) {
+	defaults, ok := storageTargetDefaults[storageType]
+	if !ok {
+		return StorageTarget{}, false
+	}
+	t := StorageTarget{Type: storageType, Address: conf["address"], Prefix: conf["path"]}
+	if t.Address == "" {
+		t.Address = defaults.address
+	}
+	if t.Prefix == "" {
+		t.Prefix = defaults.prefix
+	}
+	return t, true
+}
+
+// StorageOverlapCheck warns when storage and ha_storage point at the same
+// cluster with overlapping key prefixes, where the HA lock and leader
+// entries could interfere with stored data.
+func StorageOverlapCheck(ctx context.Context, storageType string, storageConf map[string]string, haType string, haConf map[string]string) {
+	st, ok := storageTarget(storageType, storageConf)
+	ht, haOk := storageTarget(haType, haConf)
+	if !ok || !haOk || st.Type != ht.Type {
+		SpotSkipped(ctx, "storage-overlap", SkipNotApplicable, fmt.Sprintf("storage is %s and ha_storage is %s", storageType, haType))
+		return
+	}
+
+	targets := fmt.Sprintf("storage is %s; ha_storage is %s", st, ht)
+	sp, hp := strings.Trim(st.Prefix, "/")+"/", strings.Trim(ht.Prefix, "/")+"/"
+	if strings.EqualFold(strings.TrimSuffix(st.Address, "/"), strings.TrimSuffix(ht.Address, "/")) &&
+		(strings.HasPrefix(sp, hp) || strings.HasPrefix(hp, sp)) {
+		SpotWarn(ctx, "storage-overlap", targets+"; these overlap, so HA locks may interfere with stored data. "+
+			"Use a distinct path for ha_storage.")
+		return
+	}
+	SpotOk(ctx, "storage-overlap", targets)
+}
+
+const (
+	// storageMinParallel is the smallest max_parallel that keeps up with a
+	// production workload.
+	storageMinParallel = 16
+
+	// sqlDefaultMaxIdleConns mirrors database/sql's default idle pool size,
+	// used when max_idle_connections is not set.
+	sqlDefaultMaxIdleConns = 2
+)
+
+// storagePoolBackends lists the storage types with a max_parallel setting, and
+// whether they also pool idle SQL connections. Types not limited by default
+// treat an unset max_parallel as unlimited. Where the service behind the
+// backend caps concurrent connections by default, connLimit is that cap and
+// connLimitOf names it.
+var storagePoolBackends = map[string]struct {
+	sqlPool, unlimitedByDefault bool
+	connLimit                   int
+	connLimitOf                 string
+}{
+	"alicloudoss": {},
+	"azure":       {},
+	"cockroachdb": {},
+	"consul":      {connLimit: 200, connLimitOf: "the Consul agent's default limits.http_max_conns_per_client"},
+	"couchdb":     {},
+	"dynamodb":    {},
+	"gcs":         {unlimitedByDefault: true},
+	"manta":       {},
+	"mssql":       {},
+	"mysql":       {sqlPool: true, connLimit: 151, connLimitOf: "MySQL's default max_connections"},
+	"postgresql":  {sqlPool: true, connLimit: 100, connLimitOf: "PostgreSQL's default max_connections"},
+	"s3":          {},
+	"spanner":     {unlimitedByDefault: true},
+	"swift":       {},
+}
+
+// StoragePoolCheck reports the effective connection pool settings of the
+// storage backend and warns when they are implausibly low for production, or
+// higher than the process's open file limit or the backend's service can
+// sustain, since each parallel operation may hold a connection.
+func StoragePoolCheck(ctx context.Context, storageType string, conf map[string]string) error {
+	return storagePoolCheck(ctx, storageType, conf, openFileLimit())
+}
+
+func storagePoolCheck(ctx context.Context, storageType string, conf map[string]string, fdLimit uint64) error {
+	testName := "storage-pool"
+	backend, ok := storagePoolBackends[storageType]
+	if !ok {
+		SpotSkipped(ctx, testName, SkipNotApplicable, fmt.Sprintf("%s storage has no connection pool settings", storageType))
+		return nil
+	}
+
+	var settings, warnings []string
+	maxParallel := physical.DefaultParallelOperations
+	if backend.unlimitedByDefault {
+		maxParallel = 0
+	}
+	if raw := conf["max_parallel"]; raw != "" {
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return SpotError(ctx, testName, fmt.Errorf("failed to parse 'max_parallel': %w", err))
+		}
+		if i > 0 || backend.unlimitedByDefault {
+			maxParallel = i
+		}
+	}
+	switch {
+	case maxParallel <= 0:
+		settings = append(settings, "max_parallel unlimited")
+	case maxParallel < storageMinParallel:
+		settings = append(settings, fmt.Sprintf("max_parallel %d", maxParallel))
+		warnings = append(warnings, fmt.Sprintf("max_parallel of %d will throttle requests under load", maxParallel))
+	default:
+		settings = append(settings, fmt.Sprintf("max_parallel %d", maxParallel))
+	}
+	// Half the descriptors are left for listeners, client connections and
+	// the files the server opens.
+	if maxParallel > 0 && fdLimit > 0 && uint64(maxParallel) > fdLimit/2 {
+		warnings = append(warnings, fmt.Sprintf("max_parallel of %d could take more than half of the open file "+
+			"limit of %d, leaving too few descriptors for client connections", maxParallel, fdLimit))
+	}
+	if backend.connLimit > 0 && maxParallel > backend.connLimit {
+		warnings = append(warnings, fmt.Sprintf("max_parallel of %d exceeds %s of %d, so operations beyond it "+
+			"will fail under load unless the limit was raised", maxParallel, backend.connLimitOf, backend.connLimit))
+	}
+
+	if backend.sqlPool {
+		if raw := conf["max_idle_connections"]; raw != "" {
+			i, err := strconv.Atoi(raw)
+			if err != nil {
+				return SpotError(ctx, testName, fmt.Errorf("failed to parse 'max_idle_connections': %w", err))
+			}
+			settings = append(settings, fmt.Sprintf("max_idle_connections %d", i))
+			if i <= 0 {
+				warnings = append(warnings, "max_idle_connections disables idle connections, so each request opens a new connection")
+			}
+		} else {
+			settings = append(settings, fmt.Sprintf("max_idle_connections %d (default)", sqlDefaultMaxIdleConns))
+		}
+	}
+
+	summary := strings.Join(settings, ", ")
+	if len(warnings) > 0 {
+		SpotWarn(ctx, testName, summary+"; "+strings.Join(warnings, "; "))
+		return nil
+	}
+	SpotOk(ctx, testName, summary)
+	return nil
+}
+
+// StorageTransactionsCheck reports whether the storage backend supports
+// transactions. Without them, updates that span several keys, such as
+// mounting a secrets engine alongside its configuration, are written one key
+// at a time and can be left partially applied if the server stops midway.
+// That only matters when another node can take over from the one that
+// stopped, so the check warns only when HA is enabled, either by the backend
+// itself or by an ha_storage stanza (haStorage).
+func StorageTransactionsCheck(ctx context.Context, storageType string, b physical.Backend, haStorage bool) {
+	if _, ok := b.(physical.Transactional); ok {
+		SpotInfo(ctx, "storage-transactions", fmt.Sprintf("the %s storage backend supports transactions", storageType))
+		return
+	}
+	ha, ok := b.(physical.HABackend)
+	if !haStorage && !(ok && ha.HAEnabled()) {
+		SpotInfo(ctx, "storage-transactions", fmt.Sprintf("the %s storage backend does not support transactions", storageType))
+		return
+	}
+	SpotWarn(ctx, "storage-transactions", fmt.Sprintf("the %s storage backend does not support transactions, and HA is "+
+		"enabled, so a standby that takes over from an active node that stopped midway through a multi-key update "+
+		"can find it partially applied; integrated storage (raft) and consul support transactions", storageType))
+}
+
+// StoragePathCheck reports the absolute location of a raft or file storage
+// path, warning when the configured path is relative: it is resolved against
+// the server's working directory, which differs between a shell and a service
+// manager, so the data may land somewhere other than expected.
+func StoragePathCheck(ctx context.Context, path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return SpotError(ctx, "storage-path", fmt.Errorf("could not resolve storage path %s: %w", path, err))
+	}
+	if filepath.IsAbs(path) {
+		SpotOk(ctx, "storage-path", abs)
+		return nil
+	}
+	SpotWarn(ctx, "storage-path", fmt.Sprintf("storage path %s is relative and resolves to %s from the current working "+
+		"directory, but the server resolves it from its own working directory, which is often / under systemd", path, abs),
+		Advice(fmt.Sprintf("Set path to an absolute path, such as %s.", abs)))
+	return nil
+}
+
+// RaftHAStorageCheck reports an ha_storage stanza alongside raft storage as
+// an error. Raft provides HA itself, so the server refuses to start with any
+// ha_storage stanza; this is usually a stanza left behind by a migration from
+// consul HA to raft.
+func RaftHAStorageCheck(ctx context.Context, storageType, haType string) error {
+	if storageType != "raft" {
+		Skipped(ctx, SkipNotApplicable, fmt.Sprintf("storage is %s, not raft", storageType))
+		return nil
+	}
+	return SpotError(ctx, "raft-ha-storage", fmt.Errorf("the configuration has both a raft storage stanza and an "+
+		"ha_storage %s stanza, but raft provides HA itself, so the server refuses to start with any ha_storage", haType),
+		Advice(fmt.Sprintf("Remove the ha_storage %q stanza; it is often left behind after migrating to raft.", haType)))
+}
+
+// HAStorageCheck reports whether the ha_storage backend ha, of type haType,
+// supports high availability; ha is nil for a backend that doesn't implement
+// HA. Not every backend implements HA, and some only do when ha_enabled is
+// set; either way the server refuses to start with a generic error, so this
+// names the type and what to change.
+func HAStorageCheck(ctx context.Context, haType string, ha physical.HABackend) error {
+	if ha == nil {
+		return SpotError(ctx, "ha-storage", fmt.Errorf("the %s storage backend does not support HA, so it can't be "+
+			"used as ha_storage", haType), Advice("Use a backend that supports HA, such as consul or raft, as ha_storage."))
+	}
+	if !ha.HAEnabled() {
+		return SpotError(ctx, "ha-storage", fmt.Errorf("the %s storage backend supports HA, but it is disabled", haType),
+			Advice("Set ha_enabled to \"true\" in the ha_storage stanza."))
+	}
+	SpotOk(ctx, "ha-storage", fmt.Sprintf("the %s storage backend supports HA, and it is enabled", haType))
+	return nil
+}
@@ -2,11 +2,16 @@ package diagnose
 
 import (
 	"context"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-test/deep"
+	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/physical"
+	"github.com/hashicorp/vault/sdk/physical/inmem"
 )
 
 func TestStorageTimeout(t *testing.T) {
@@ -73,3 +78,223 @@ func TestStorageTimeout(t *testing.T) {
 		}
 	}
 }
+
+func TestStorageOverlapCheck(t *testing.T) {
+	testCases := []struct {
+		name     string
+		haConf   map[string]string
+		expected status
+	}{
+		{"same defaults", map[string]string{}, WarningStatus},
+		{"same prefix", map[string]string{"address": "127.0.0.1:8500", "path": "vault"}, WarningStatus},
+		{"nested prefix", map[string]string{"path": "vault/ha/"}, WarningStatus},
+		{"distinct prefix", map[string]string{"path": "vault-ha/"}, OkStatus},
+		{"distinct cluster", map[string]string{"address": "10.0.0.2:8500"}, OkStatus},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				StorageOverlapCheck(ctx, "consul", map[string]string{}, "consul", tc.haConf)
+			})
+			if len(results) != 1 {
+				t.Fatalf("expected one result, got %d", len(results))
+			}
+			if results[0].Status != tc.expected {
+				t.Fatalf("expected status %s, got %s: %s", tc.expected, results[0].Status, results[0].Message)
+			}
+		})
+	}
+}
+
+func TestStoragePoolCheck(t *testing.T) {
+	testCases := []struct {
+		name        string
+		storageType string
+		conf        map[string]string
+		expected    []*Result
+	}{
+		{
+			"consul default",
+			"consul",
+			map[string]string{},
+			[]*Result{{Name: "storage-pool", Status: OkStatus, Message: "max_parallel 128"}},
+		},
+		{
+			"postgresql low",
+			"postgresql",
+			map[string]string{"max_parallel": "4", "max_idle_connections": "0"},
+			[]*Result{{Name: "storage-pool", Status: WarningStatus, Message: "max_parallel 4, max_idle_connections 0; " +
+				"max_parallel of 4 will throttle requests under load; " +
+				"max_idle_connections disables idle connections, so each request opens a new connection"}},
+		},
+		{
+			"gcs unlimited",
+			"gcs",
+			map[string]string{},
+			[]*Result{{Name: "storage-pool", Status: OkStatus, Message: "max_parallel unlimited"}},
+		},
+		{
+			"raft",
+			"raft",
+			map[string]string{},
+			[]*Result{{Name: "storage-pool", Status: SkippedStatus, Message: "raft storage has no connection pool settings", SkipReason: SkipNotApplicable}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				StoragePoolCheck(ctx, tc.storageType, tc.conf)
+			})
+			if !reflect.DeepEqual(results, tc.expected) {
+				t.Fatalf("results mismatch: %s", strings.Join(deep.Equal(results, tc.expected), "\n"))
+			}
+		})
+	}
+}
+
+func TestStoragePoolLimits(t *testing.T) {
+	testCases := []struct {
+		name        string
+		storageType string
+		conf        map[string]string
+		fdLimit     uint64
+		expected    status
+		contains    string
+	}{
+		{"within limits", "consul", map[string]string{"max_parallel": "150"}, 65536, OkStatus, ""},
+		{"fd limit", "consul", map[string]string{"max_parallel": "150"}, 256, WarningStatus, "open file limit of 256"},
+		{"consul agent limit", "consul", map[string]string{"max_parallel": "500"}, 65536, WarningStatus,
+			"limits.http_max_conns_per_client of 200"},
+		{"postgresql max_connections", "postgresql", map[string]string{"max_parallel": "128"}, 65536, WarningStatus,
+			"max_connections of 100"},
+		{"unknown fd limit", "s3", map[string]string{"max_parallel": "4096"}, 0, OkStatus, ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				storagePoolCheck(ctx, tc.storageType, tc.conf, tc.fdLimit)
+			})
+			if len(results) != 1 || results[0].Status != tc.expected {
+				t.Fatalf("expected a %s result, got %#v", tc.expected, results)
+			}
+			if !strings.Contains(results[0].Message, tc.contains) {
+				t.Fatalf("expected %q in %q", tc.contains, results[0].Message)
+			}
+		})
+	}
+}
+
+func TestRaftHAStorageCheck(t *testing.T) {
+	results := checkResults(t, func(ctx context.Context) {
+		RaftHAStorageCheck(ctx, "raft", "consul")
+	})
+	if len(results) != 1 || results[0].Status != ErrorStatus {
+		t.Fatalf("expected an error result, got %#v", results)
+	}
+	if !strings.Contains(results[0].Message, "ha_storage consul") {
+		t.Fatalf("expected the conflicting stanzas in %q", results[0].Message)
+	}
+
+	results = checkResults(t, func(ctx context.Context) {
+		RaftHAStorageCheck(ctx, "file", "consul")
+	})
+	if len(results) != 0 {
+		t.Fatalf("expected the check to be skipped, got %#v", results)
+	}
+}
+
+func TestStorageTransactionsCheck(t *testing.T) {
+	plain, err := inmem.NewInmem(nil, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactional, err := inmem.NewTransactionalInmem(nil, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plainHA, err := inmem.NewInmemHA(nil, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := checkResults(t, func(ctx context.Context) {
+		StorageTransactionsCheck(ctx, "inmem", plain, false)
+		StorageTransactionsCheck(ctx, "inmem_transactional", transactional, true)
+		StorageTransactionsCheck(ctx, "inmem", plain, true)
+		StorageTransactionsCheck(ctx, "inmem_ha", plainHA, false)
+	})
+	expected := []status{InfoStatus, InfoStatus, WarningStatus, WarningStatus}
+	if len(results) != len(expected) {
+		t.Fatalf("unexpected results: %#v", results)
+	}
+	for i, r := range results {
+		if r.Status != expected[i] {
+			t.Fatalf("result %d: expected %s, got %#v", i, expected[i], r)
+		}
+	}
+}
+
+func TestStoragePathCheck(t *testing.T) {
+	testCases := []struct {
+		name   string
+		path   string
+		status status
+	}{
+		{"absolute", "/opt/vault/data", OkStatus},
+		{"relative", "data", WarningStatus},
+		{"dot relative", "./vault/data", WarningStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				StoragePathCheck(ctx, tc.path)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+			if !strings.Contains(results[0].Message, string(filepath.Separator)) {
+				t.Fatalf("expected the resolved path to be reported, got %q", results[0].Message)
+			}
+		})
+	}
+}
+
+// disabledHABackend is an HA backend with HA turned off, as backends with an
+// ha_enabled setting are by default.
+type disabledHABackend struct {
+	physical.Backend
+	physical.HABackend
+}
+
+func (disabledHABackend) HAEnabled() bool {
+	return false
+}
+
+func TestHAStorageCheck(t *testing.T) {
+	ha, err := inmem.NewInmemHA(nil, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name    string
+		backend physical.HABackend
+		status  status
+	}{
+		{"not HA", nil, ErrorStatus},
+		{"HA disabled", disabledHABackend{ha, ha.(physical.HABackend)}, ErrorStatus},
+		{"HA", ha.(physical.HABackend), OkStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				HAStorageCheck(ctx, "inmem", tc.backend)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
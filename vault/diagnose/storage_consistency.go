@@ -0,0 +1,59 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+)
+
+// cassandraQuorumConsistencies are the cassandra consistency levels at which
+// every read overlaps the replicas of every earlier write, since the backend
+// uses the same level for both.
+var cassandraQuorumConsistencies = map[string]bool{
+	"QUORUM":       true,
+	"LOCAL_QUORUM": true,
+	"EACH_QUORUM":  true,
+	"ALL":          true,
+}
+
+// StorageConsistencyCheck reports the read consistency of storage backends
+// that have one, warning when a setting lets Vault read stale data. Vault
+// assumes that it reads back what it last wrote: a stale read of the keyring
+// or of a lease can undo a rotation or revive a revoked secret, and a standby
+// taking over reads everything afresh.
+func StorageConsistencyCheck(ctx context.Context, storageType string, conf map[string]string) {
+	testName := "storage-consistency"
+	switch storageType {
+	case "consul":
+		mode := conf["consistency_mode"]
+		switch mode {
+		case "", "default":
+			SpotInfo(ctx, testName, "consistency_mode is default: reads are served by the Consul leader, which can "+
+				"briefly return stale data during a leader change; strong confirms leadership on every read at the "+
+				"cost of latency")
+		case "strong":
+			SpotOk(ctx, testName, "consistency_mode is strong: every read confirms the Consul leader's leadership")
+		default:
+			SpotError(ctx, testName, fmt.Errorf("consistency_mode is %q; it must be default or strong", mode))
+		}
+	case "dynamodb":
+		SpotOk(ctx, testName, "the dynamodb backend always uses strongly consistent reads")
+	case "cassandra":
+		level := conf["consistency"]
+		if level == "" {
+			level = "LOCAL_QUORUM"
+		}
+		switch {
+		case cassandraQuorumConsistencies[level]:
+			SpotOk(ctx, testName, fmt.Sprintf("consistency is %s for reads and writes, so every read overlaps the "+
+				"replicas of the latest write", level))
+		case level == "ANY" || level == "ONE" || level == "TWO" || level == "THREE" || level == "LOCAL_ONE":
+			SpotWarn(ctx, testName, fmt.Sprintf("consistency is %s for reads and writes, so a read can reach replicas "+
+				"that missed the latest write and return stale data", level),
+				Advice("Set consistency to LOCAL_QUORUM or QUORUM."))
+		default:
+			SpotError(ctx, testName, fmt.Errorf("consistency is %q, which is not a cassandra consistency level", level))
+		}
+	default:
+		Skipped(ctx, SkipNotApplicable, fmt.Sprintf("the %s backend has no consistency setting", storageType))
+	}
+}
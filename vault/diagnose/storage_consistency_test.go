@@ -0,0 +1,33 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStorageConsistencyCheck(t *testing.T) {
+	testCases := []struct {
+		name        string
+		storageType string
+		conf        map[string]string
+		status      status
+	}{
+		{"consul default", "consul", map[string]string{}, InfoStatus},
+		{"consul strong", "consul", map[string]string{"consistency_mode": "strong"}, OkStatus},
+		{"consul invalid", "consul", map[string]string{"consistency_mode": "stale"}, ErrorStatus},
+		{"dynamodb", "dynamodb", map[string]string{}, OkStatus},
+		{"cassandra default", "cassandra", map[string]string{}, OkStatus},
+		{"cassandra one", "cassandra", map[string]string{"consistency": "ONE"}, WarningStatus},
+		{"cassandra invalid", "cassandra", map[string]string{"consistency": "SOME"}, ErrorStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				StorageConsistencyCheck(ctx, tc.storageType, tc.conf)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
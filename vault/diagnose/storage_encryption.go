@@ -0,0 +1,221 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/helper/awsutil"
+	"github.com/hashicorp/vault/sdk/helper/parseutil"
+	"github.com/hashicorp/vault/sdk/helper/useragent"
+	"google.golang.org/api/option"
+)
+
+// cloudStorageTypes are the storage backends hosted by a cloud provider.
+var cloudStorageTypes = map[string]bool{"s3": true, "dynamodb": true, "gcs": true, "azure": true}
+
+// IsCloudStorage reports whether storageType is a backend hosted by a cloud
+// provider, which StorageEncryptionCheck applies to.
+func IsCloudStorage(storageType string) bool {
+	return cloudStorageTypes[storageType]
+}
+
+// StorageEncryptionCheck reports whether a cloud storage backend encrypts data
+// at rest, warning when it does not. Vault's barrier encrypts every value, but
+// keys and metadata are stored as is, so encryption at rest still matters for
+// defense in depth.
+func StorageEncryptionCheck(ctx context.Context, storageType string, conf map[string]string) error {
+	switch storageType {
+	case "s3":
+		return s3EncryptionCheck(ctx, conf)
+	case "dynamodb":
+		return dynamoDBEncryptionCheck(ctx, conf)
+	case "gcs":
+		return gcsEncryptionCheck(ctx, conf)
+	case "azure":
+		SpotInfo(ctx, "storage-encryption", "Azure Storage always encrypts data at rest; whether it uses a "+
+			"customer-managed key is an account setting that the storage credentials can't read")
+		return nil
+	}
+	return fmt.Errorf("%s is not a cloud storage backend", storageType)
+}
+
+// awsStorageSession creates an AWS session from a storage configuration the
+// way the s3 and dynamodb backends do, with endpointEnv and defaultRegion
+// differing between them.
+func awsStorageSession(conf map[string]string, endpointEnv, defaultRegion string) (*session.Session, string, error) {
+	endpoint := os.Getenv(endpointEnv)
+	if endpoint == "" {
+		endpoint = conf["endpoint"]
+	}
+	region := awsStorageRegion(conf, defaultRegion, os.Getenv)
+
+	credsConfig := &awsutil.CredentialsConfig{
+		AccessKey:    conf["access_key"],
+		SecretKey:    conf["secret_key"],
+		SessionToken: conf["session_token"],
+		Logger:       log.NewNullLogger(),
+	}
+	creds, err := credsConfig.GenerateCredentialChain()
+	if err != nil {
+		return nil, "", err
+	}
+	sess, err := session.NewSession(aws.NewConfig().
+		WithCredentials(creds).
+		WithRegion(region).
+		WithEndpoint(endpoint))
+	if err != nil {
+		return nil, "", err
+	}
+	return sess, region, nil
+}
+
+// awsStorageRegion returns the region the s3 and dynamodb backends use, where
+// the environment takes precedence over the storage stanza.
+func awsStorageRegion(conf map[string]string, defaultRegion string, getenv func(string) string) string {
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := getenv(env); region != "" {
+			return region
+		}
+	}
+	if conf["region"] != "" {
+		return conf["region"]
+	}
+	return defaultRegion
+}
+
+func s3EncryptionCheck(ctx context.Context, conf map[string]string) error {
+	bucket := os.Getenv("AWS_S3_BUCKET")
+	if bucket == "" {
+		bucket = conf["bucket"]
+	}
+	if bucket == "" {
+		return errors.New("no bucket configured")
+	}
+	if key := conf["kms_key_id"]; key != "" {
+		SpotOk(ctx, "storage-encryption", fmt.Sprintf("Vault requests that objects in bucket %s be encrypted with KMS key %s", bucket, key))
+		return nil
+	}
+
+	sess, region, err := awsStorageSession(conf, "AWS_S3_ENDPOINT", "us-east-1")
+	if err != nil {
+		return err
+	}
+	s3Conf := aws.NewConfig()
+	if v, ok := conf["s3_force_path_style"]; ok {
+		forcePathStyle, err := parseutil.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean set for s3_force_path_style: %q", v)
+		}
+		s3Conf = s3Conf.WithS3ForcePathStyle(forcePathStyle)
+	}
+	if v, ok := conf["disable_ssl"]; ok {
+		disableSSL, err := parseutil.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean set for disable_ssl: %q", v)
+		}
+		s3Conf = s3Conf.WithDisableSSL(disableSSL)
+	}
+	out, err := s3.New(sess, s3Conf).GetBucketEncryptionWithContext(ctx, &s3.GetBucketEncryptionInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == "ServerSideEncryptionConfigurationNotFoundError" {
+			SpotWarn(ctx, "storage-encryption", fmt.Sprintf("bucket %s has no default encryption and kms_key_id is not set, "+
+				"so objects may be stored unencrypted", bucket))
+			return nil
+		}
+		return fmt.Errorf("could not read the encryption configuration of bucket %q in region %q: %w", bucket, region, err)
+	}
+	if out.ServerSideEncryptionConfiguration != nil {
+		for _, rule := range out.ServerSideEncryptionConfiguration.Rules {
+			def := rule.ApplyServerSideEncryptionByDefault
+			if def == nil {
+				continue
+			}
+			desc := aws.StringValue(def.SSEAlgorithm)
+			if key := aws.StringValue(def.KMSMasterKeyID); key != "" {
+				desc += " with key " + key
+			}
+			SpotOk(ctx, "storage-encryption", fmt.Sprintf("bucket %s encrypts objects by default using %s", bucket, desc))
+			return nil
+		}
+	}
+	SpotWarn(ctx, "storage-encryption", fmt.Sprintf("bucket %s has no default encryption rule and kms_key_id is not set, "+
+		"so objects may be stored unencrypted", bucket))
+	return nil
+}
+
+// dynamoDBTable returns the table the dynamodb backend uses.
+func dynamoDBTable(conf map[string]string) string {
+	table := os.Getenv("AWS_DYNAMODB_TABLE")
+	if table == "" {
+		table = conf["table"]
+	}
+	if table == "" {
+		table = "vault-dynamodb-backend"
+	}
+	return table
+}
+
+func dynamoDBEncryptionCheck(ctx context.Context, conf map[string]string) error {
+	table := dynamoDBTable(conf)
+	sess, region, err := awsStorageSession(conf, "AWS_DYNAMODB_ENDPOINT", "us-east-1")
+	if err != nil {
+		return err
+	}
+	out, err := dynamodb.New(sess).DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return fmt.Errorf("could not describe table %q in region %q: %w", table, region, err)
+	}
+
+	// DynamoDB always encrypts at rest; a table without an SSE description
+	// uses a key owned by AWS.
+	sse := out.Table.SSEDescription
+	switch {
+	case sse == nil:
+		SpotOk(ctx, "storage-encryption", fmt.Sprintf("table %s is encrypted with a key owned by AWS", table))
+	case aws.StringValue(sse.Status) == dynamodb.SSEStatusEnabled || aws.StringValue(sse.Status) == dynamodb.SSEStatusUpdating:
+		SpotOk(ctx, "storage-encryption", fmt.Sprintf("table %s is encrypted with KMS key %s", table, aws.StringValue(sse.KMSMasterKeyArn)))
+	default:
+		SpotWarn(ctx, "storage-encryption", fmt.Sprintf("table %s has encryption status %s; if its KMS key is inaccessible, "+
+			"the table can't be read", table, aws.StringValue(sse.Status)))
+	}
+	return nil
+}
+
+func gcsEncryptionCheck(ctx context.Context, conf map[string]string) error {
+	bucket := os.Getenv("GOOGLE_STORAGE_BUCKET")
+	if bucket == "" {
+		bucket = conf["bucket"]
+	}
+	if bucket == "" {
+		return errors.New("no bucket configured")
+	}
+
+	client, err := storage.NewClient(ctx, option.WithUserAgent(useragent.String()))
+	if err != nil {
+		return fmt.Errorf("could not create a GCS client: %w", err)
+	}
+	defer client.Close()
+	attrs, err := client.Bucket(bucket).Attrs(ctx)
+	if err != nil {
+		return fmt.Errorf("could not read the attributes of bucket %q: %w", bucket, err)
+	}
+
+	// GCS always encrypts at rest, with a key managed by Google unless the
+	// bucket names its own.
+	if attrs.Encryption != nil && attrs.Encryption.DefaultKMSKeyName != "" {
+		SpotOk(ctx, "storage-encryption", fmt.Sprintf("bucket %s encrypts objects with KMS key %s", bucket, attrs.Encryption.DefaultKMSKeyName))
+	} else {
+		SpotOk(ctx, "storage-encryption", fmt.Sprintf("bucket %s encrypts objects with a key managed by Google", bucket))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package diagnose
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestStorageEncryptionCheckS3(t *testing.T) {
+	var encrypted bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		if !encrypted {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>ServerSideEncryptionConfigurationNotFoundError</Code>` +
+				`<Message>The server side encryption configuration was not found</Message></Error>`))
+			return
+		}
+		w.Write([]byte(`<ServerSideEncryptionConfiguration><Rule><ApplyServerSideEncryptionByDefault>` +
+			`<SSEAlgorithm>aws:kms</SSEAlgorithm><KMSMasterKeyID>alias/vault</KMSMasterKeyID>` +
+			`</ApplyServerSideEncryptionByDefault></Rule></ServerSideEncryptionConfiguration>`))
+	}))
+	defer ts.Close()
+
+	os.Unsetenv("AWS_S3_ENDPOINT")
+	conf := map[string]string{
+		"bucket":              "vault",
+		"endpoint":            ts.URL,
+		"region":              "us-west-2",
+		"access_key":          "AKIAEXAMPLE",
+		"secret_key":          "secret",
+		"s3_force_path_style": "true",
+	}
+	run := func(conf map[string]string) *Result {
+		t.Helper()
+		results := checkResults(t, func(ctx context.Context) {
+			if err := StorageEncryptionCheck(ctx, "s3", conf); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if len(results) != 1 {
+			t.Fatalf("expected a single result, got %#v", results)
+		}
+		return results[0]
+	}
+
+	if r := run(conf); r.Status != WarningStatus {
+		t.Fatalf("expected a warning for an unencrypted bucket, got %#v", r)
+	}
+	encrypted = true
+	if r := run(conf); r.Status != OkStatus {
+		t.Fatalf("expected an encrypted bucket to pass, got %#v", r)
+	}
+
+	encrypted = false
+	conf["kms_key_id"] = "alias/vault"
+	if r := run(conf); r.Status != OkStatus {
+		t.Fatalf("expected kms_key_id to pass, got %#v", r)
+	}
+}
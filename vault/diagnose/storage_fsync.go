@@ -0,0 +1,80 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	// fsyncSamples is the number of write and fsync cycles timed.
+	fsyncSamples = 10
+
+	// fsyncNoopThreshold is the median fsync latency below which the storage
+	// likely acknowledges fsyncs without persisting the data. Even fast
+	// NVMe drives take tens of microseconds to flush.
+	fsyncNoopThreshold = 20 * time.Microsecond
+
+	// fsyncSlowThreshold is the median fsync latency above which raft commits
+	// will be slow enough to cause heartbeat timeouts under load.
+	fsyncSlowThreshold = 100 * time.Millisecond
+)
+
+// fsyncBlock is the size of each write, the size of a typical raft log batch.
+var fsyncBlock = make([]byte, 4096)
+
+// StorageFsyncCheck writes to a temporary file in the storage path, fsyncing
+// after each write, and reports the median fsync latency. It warns when the
+// latency is so low that fsync is likely a no-op, as on some network
+// filesystems and virtualized disks with write caching, which endangers raft's
+// durability, or so high that commits will stall.
+func StorageFsyncCheck(ctx context.Context, path string) error {
+	f, err := ioutil.TempFile(path, "diagnose-fsync")
+	if err != nil {
+		return fmt.Errorf("could not create a file in %s: %w", path, err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	latencies := make([]time.Duration, 0, fsyncSamples)
+	for i := 0; i < fsyncSamples; i++ {
+		if _, err := f.Write(fsyncBlock); err != nil {
+			return fmt.Errorf("could not write to %s: %w", f.Name(), err)
+		}
+		start := time.Now()
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("could not fsync %s: %w", f.Name(), err)
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+	fsyncLatencyCheck(ctx, path, latencies)
+	return nil
+}
+
+func fsyncLatencyCheck(ctx context.Context, path string, latencies []time.Duration) {
+	median := medianDuration(latencies)
+	RecordMetric(ctx, "fsync-median-latency", float64(median)/float64(time.Millisecond), "ms")
+	switch {
+	case median < fsyncNoopThreshold:
+		SpotWarn(ctx, "storage-fsync", fmt.Sprintf("fsync in %s takes a median of %s, too fast for the data to reach "+
+			"stable storage; the filesystem or disk may be acknowledging fsyncs without persisting writes, "+
+			"which risks losing committed raft entries on power loss", path, median))
+	case median > fsyncSlowThreshold:
+		SpotWarn(ctx, "storage-fsync", fmt.Sprintf("fsync in %s takes a median of %s; storage this slow will delay "+
+			"every commit", path, median))
+	default:
+		SpotOk(ctx, "storage-fsync", fmt.Sprintf("fsync in %s takes a median of %s", path, median))
+	}
+}
+
+// medianDuration returns the median of durations, which it sorts in place.
+func medianDuration(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations[len(durations)/2]
+}
@@ -0,0 +1,51 @@
+package diagnose
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStorageFsyncCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-fsync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	results := checkResults(t, func(ctx context.Context) {
+		if err := StorageFsyncCheck(ctx, dir); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(results) != 1 || results[0].Name != "storage-fsync" {
+		t.Fatalf("expected a single storage-fsync result, got %#v", results)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("expected the test file to be removed, found %d files", len(files))
+	}
+}
+
+func TestFsyncLatencyCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		latencies []time.Duration
+		status    status
+	}{
+		{"no-op", []time.Duration{time.Microsecond, 2 * time.Microsecond, time.Millisecond}, WarningStatus},
+		{"ssd", []time.Duration{500 * time.Microsecond, time.Millisecond, 2 * time.Millisecond}, OkStatus},
+		{"slow", []time.Duration{200 * time.Millisecond, 300 * time.Millisecond, time.Millisecond}, WarningStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				fsyncLatencyCheck(ctx, "/var/lib/vault", tc.latencies)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// throttlingAdvice is, per storage type, how to raise the rate limit of a
+// backend that throttles requests. Only the types listed are reported as
+// unthrottled when their checks succeed.
+var throttlingAdvice = map[string]string{
+	"dynamodb": "Raise the table's provisioned read_capacity and write_capacity, or switch it to on-demand capacity.",
+	"gcs":      "Spread writes over more objects, or request a higher quota for the project; GCS limits writes to the same object to about one per second.",
+	"s3":       "Request a higher request rate for the bucket, or spread the keys over more prefixes.",
+	"spanner":  "Add nodes or processing units to the Spanner instance, or request a higher quota.",
+}
+
+// throttlingCodes are the error codes the AWS SDK returns for throttled
+// requests.
+var throttlingCodes = map[string]bool{
+	"ProvisionedThroughputExceededException": true,
+	"RequestLimitExceeded":                   true,
+	"ThrottlingException":                    true,
+	"Throttling":                             true,
+	"TooManyRequestsException":               true,
+	"SlowDown":                               true,
+}
+
+// StorageThrottleCheck reports whether the storage backend throttled the
+// requests of diagnose's own storage checks, given the error those requests
+// returned, if any. The latency and throughput checks can trip the rate limit
+// of a cloud backend, and a throttled backend is reachable and healthy, so
+// throttling is reported as a warning that the backend throttled diagnose,
+// and nil is returned, rather than failing the check. Any other error is
+// returned as is. When there is no error, rate-limited backends are reported
+// as not having throttled diagnose.
+func StorageThrottleCheck(ctx context.Context, storageType, operation string, err error) error {
+	testName := "storage-throttling"
+	if err == nil {
+		if _, ok := throttlingAdvice[storageType]; ok {
+			SpotOk(ctx, testName, fmt.Sprintf("the %s backend did not throttle diagnose's requests", storageType))
+		}
+		return nil
+	}
+	if !storageThrottled(err) {
+		return err
+	}
+	advice, ok := throttlingAdvice[storageType]
+	if !ok {
+		advice = "Raise the backend's rate limits, or run diagnose when the backend is less busy."
+	}
+	SpotWarn(ctx, testName, fmt.Sprintf("backend throttled diagnose: the %s backend rejected a %s over its rate "+
+		"limit, so it is reachable but the check's result is not a measure of its health: %v", storageType, operation, err),
+		Advice(advice))
+	return nil
+}
+
+// storageThrottled reports whether err is a storage backend's rejection of a
+// request over its rate limit, from the error codes and statuses of the cloud
+// SDKs the backends use.
+func storageThrottled(err error) bool {
+	var coded interface{ Code() string }
+	if errors.As(err, &coded) && throttlingCodes[coded.Code()] {
+		return true
+	}
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) {
+		if googleErr.Code == http.StatusTooManyRequests {
+			return true
+		}
+		for _, item := range googleErr.Errors {
+			if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+				return true
+			}
+		}
+	}
+	var grpcErr interface{ GRPCStatus() *grpcstatus.Status }
+	if errors.As(err, &grpcErr) && grpcErr.GRPCStatus().Code() == codes.ResourceExhausted {
+		return true
+	}
+	var httpErr interface{ StatusCode() int }
+	return errors.As(err, &httpErr) && httpErr.StatusCode() == http.StatusTooManyRequests
+}
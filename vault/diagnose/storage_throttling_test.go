@@ -0,0 +1,76 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestStorageThrottled(t *testing.T) {
+	wrap := func(err error) error {
+		return fmt.Errorf("failed to put data: %w", err)
+	}
+	cases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"dynamodb capacity", wrap(awserr.New("ProvisionedThroughputExceededException", "exceeded", nil)), true},
+		{"s3 slow down", wrap(awserr.NewRequestFailure(awserr.New("SlowDown", "reduce your request rate", nil), 503, "")), true},
+		{"gcs too many requests", wrap(&googleapi.Error{Code: 429}), true},
+		{"gcs rate limit", wrap(&googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}), true},
+		{"gcs forbidden", wrap(&googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "forbidden"}}}), false},
+		{"spanner exhausted", wrap(grpcstatus.Error(codes.ResourceExhausted, "quota exceeded")), true},
+		{"aws access denied", wrap(awserr.New("AccessDeniedException", "denied", nil)), false},
+		{"other", errors.New("connection refused"), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if throttled := storageThrottled(tc.err); throttled != tc.expected {
+				t.Fatalf("expected %t, got %t", tc.expected, throttled)
+			}
+		})
+	}
+}
+
+func TestStorageThrottleCheck(t *testing.T) {
+	throttled := awserr.New("ThrottlingException", "rate exceeded", nil)
+	failed := errors.New("connection refused")
+	cases := []struct {
+		name        string
+		storageType string
+		err         error
+		returned    error
+		expected    []status
+	}{
+		{"rate-limited backend not throttled", "dynamodb", nil, nil, []status{OkStatus}},
+		{"other backend", "raft", nil, nil, nil},
+		{"throttled", "dynamodb", throttled, nil, []status{WarningStatus}},
+		{"failed", "dynamodb", failed, failed, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var returned error
+			results := checkResults(t, func(ctx context.Context) {
+				returned = StorageThrottleCheck(ctx, tc.storageType, "write", tc.err)
+			})
+			if returned != tc.returned {
+				t.Fatalf("expected %v to be returned, got %v", tc.returned, returned)
+			}
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %d", len(tc.expected), len(results))
+			}
+			for i, r := range results {
+				if r.Status != tc.expected[i] {
+					t.Fatalf("result %d: expected %s, got %s: %s", i, tc.expected[i], r.Status, r.Message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/physical"
+)
+
+const (
+	// throughputWrites is the number of entries the throughput test writes.
+	throughputWrites = 200
+
+	// throughputConcurrency is the number of writers the throughput test runs
+	// at once.
+	throughputConcurrency = 16
+)
+
+// throughputFloors are, per storage type, the write rates below which a
+// healthy backend of that type is unusually slow. Types not listed use
+// defaultThroughputFloor.
+var throughputFloors = map[string]float64{
+	"inmem":  1000,
+	"file":   200,
+	"raft":   100,
+	"consul": 100,
+}
+
+const defaultThroughputFloor = 50
+
+// StorageThroughputCheck writes a fixed number of entries to the storage
+// backend from several writers at once and reports the achieved writes per
+// second, warning when it is far below what the storage type normally
+// sustains. Unlike the latency check, this reveals backends that are fast for
+// a single operation but serialize concurrent ones. The writers stop once
+// timeout passes, and every entry written is deleted before the check
+// returns, including when a write fails. A write the backend throttles is
+// reported by StorageThrottleCheck rather than as a failure.
+func StorageThroughputCheck(ctx context.Context, storageType, prefix string, b physical.Backend, timeout time.Duration) error {
+	testName := "storage-throughput"
+	keys := make(chan string, throughputWrites)
+	for i := 0; i < throughputWrites; i++ {
+		keys <- prefix + strconv.Itoa(i)
+	}
+	close(keys)
+
+	var (
+		mu        sync.Mutex
+		attempted []string
+		written   int
+		firstErr  error
+		wg        sync.WaitGroup
+	)
+	// A write that failed or was cancelled may still have been stored, so
+	// every key attempted is deleted.
+	defer func() {
+		for _, k := range attempted {
+			b.Delete(context.Background(), k)
+		}
+	}()
+
+	writeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	for w := 0; w < throughputConcurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range keys {
+				if writeCtx.Err() != nil {
+					return
+				}
+				mu.Lock()
+				attempted = append(attempted, k)
+				mu.Unlock()
+				err := b.Put(writeCtx, &physical.Entry{Key: k, Value: []byte(secretVal)})
+				mu.Lock()
+				if err == nil {
+					written++
+				} else if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	if err := writeCtx.Err(); err != nil {
+		if ctx.Err() == nil {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		return SpotError(ctx, testName, fmt.Errorf("only %d of %d writes completed: %w", written, throughputWrites, err))
+	}
+	if firstErr != nil {
+		if storageThrottled(firstErr) {
+			return StorageThrottleCheck(ctx, storageType, "concurrent write", firstErr)
+		}
+		return SpotError(ctx, testName, fmt.Errorf("a concurrent write failed: %w", firstErr))
+	}
+
+	rate := float64(throughputWrites) / elapsed.Seconds()
+	RecordMetric(ctx, "write-rate", rate, "writes/s")
+	floor, ok := throughputFloors[storageType]
+	if !ok {
+		floor = defaultThroughputFloor
+	}
+	StorageThrottleCheck(ctx, storageType, "", nil)
+	msg := fmt.Sprintf("%.0f writes per second with %d concurrent writers (%d writes in %s)",
+		rate, throughputConcurrency, throughputWrites, elapsed.Round(time.Millisecond))
+	if rate < floor {
+		SpotWarn(ctx, testName, msg+fmt.Sprintf(", below the %.0f expected of %s storage", floor, storageType))
+		return nil
+	}
+	SpotOk(ctx, testName, msg)
+	return nil
+}
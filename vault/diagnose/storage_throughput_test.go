@@ -0,0 +1,92 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/physical"
+	"github.com/hashicorp/vault/sdk/physical/inmem"
+)
+
+// slowBackend delays every write, as a backend that serializes writes would.
+type slowBackend struct {
+	physical.Backend
+}
+
+func (s slowBackend) Put(ctx context.Context, entry *physical.Entry) error {
+	time.Sleep(100 * time.Millisecond)
+	return s.Backend.Put(ctx, entry)
+}
+
+// throttledBackend rejects every write as DynamoDB does over its provisioned
+// capacity.
+type throttledBackend struct {
+	physical.Backend
+}
+
+func (throttledBackend) Put(context.Context, *physical.Entry) error {
+	return awserr.New("ProvisionedThroughputExceededException", "The level of configured provisioned throughput for the table was exceeded.", nil)
+}
+
+func TestStorageThroughputCheck(t *testing.T) {
+	b, err := inmem.NewInmem(nil, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name        string
+		storageType string
+		backend     physical.Backend
+		status      status
+	}{
+		{"fast", "inmem", b, OkStatus},
+		{"slow", "inmem", slowBackend{b}, WarningStatus},
+		{"failing", "consul", mockStorageBackend{callType: errCallWrite}, ErrorStatus},
+		{"throttled", "dynamodb", throttledBackend{b}, WarningStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				StorageThroughputCheck(ctx, tc.storageType, "diagnose/throughput/", tc.backend, time.Minute)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+			keys, err := b.List(context.Background(), "diagnose/throughput/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != 0 {
+				t.Fatalf("expected the test entries to be deleted, found %d", len(keys))
+			}
+		})
+	}
+}
+
+func TestStorageThroughputCheckTimeout(t *testing.T) {
+	b, err := inmem.NewInmem(nil, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The slow backend needs over a second for all the writes.
+	start := time.Now()
+	results := checkResults(t, func(ctx context.Context) {
+		StorageThroughputCheck(ctx, "inmem", "diagnose/throughput/", slowBackend{b}, 250*time.Millisecond)
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the writers to stop at the timeout, took %s", elapsed)
+	}
+	if len(results) != 1 || results[0].Status != ErrorStatus {
+		t.Fatalf("expected a single error result, got %#v", results)
+	}
+	keys, err := b.List(context.Background(), "diagnose/throughput/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("expected the test entries to be deleted, found %d", len(keys))
+	}
+}
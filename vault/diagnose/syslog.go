@@ -0,0 +1,47 @@
+package diagnose
+
+import (
+	"fmt"
+
+	gsyslog "github.com/hashicorp/go-syslog"
+)
+
+// SyslogTag is the tag diagnose results are written to syslog with.
+const SyslogTag = "vault-diagnose"
+
+// SyslogWriter is the part of a syslog connection used to write results.
+type SyslogWriter interface {
+	WriteLevel(gsyslog.Priority, []byte) error
+}
+
+// WriteSyslog writes each check in the results tree to syslog as its own
+// entry, with a priority matching its status. Warnings recorded against a
+// check are written as separate warning entries.
+func WriteSyslog(w SyslogWriter, r *Result) error {
+	for _, l := range leafResults(r) {
+		line := fmt.Sprintf("%s: %s", l.path, l.result.Status)
+		if l.result.Message != "" {
+			line += ": " + l.result.Message
+		}
+		if err := w.WriteLevel(syslogPriority(l.result.Status), []byte(line)); err != nil {
+			return err
+		}
+		for _, warning := range l.result.Warnings {
+			if err := w.WriteLevel(gsyslog.LOG_WARNING, []byte(fmt.Sprintf("%s: warn: %s", l.path, warning))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func syslogPriority(s status) gsyslog.Priority {
+	switch s {
+	case ErrorStatus:
+		return gsyslog.LOG_ERR
+	case WarningStatus:
+		return gsyslog.LOG_WARNING
+	default:
+		return gsyslog.LOG_INFO
+	}
+}
@@ -0,0 +1,51 @@
+package diagnose
+
+import (
+	"reflect"
+	"testing"
+
+	gsyslog "github.com/hashicorp/go-syslog"
+)
+
+type syslogEntry struct {
+	priority gsyslog.Priority
+	line     string
+}
+
+type fakeSyslog struct {
+	entries []syslogEntry
+}
+
+func (f *fakeSyslog) WriteLevel(p gsyslog.Priority, b []byte) error {
+	f.entries = append(f.entries, syslogEntry{p, string(b)})
+	return nil
+}
+
+func TestWriteSyslog(t *testing.T) {
+	results := &Result{
+		Name: "initialization",
+		Children: []*Result{
+			{Name: "parse-config", Status: OkStatus},
+			{Name: "storage", Children: []*Result{
+				{Name: "test-access-storage", Status: WarningStatus, Warnings: []string{"slow"}},
+				{Name: "consul-tls", Status: ErrorStatus, Message: "no ca"},
+			}},
+			{Name: "edition", Status: InfoStatus, Message: "community"},
+		},
+	}
+
+	var w fakeSyslog
+	if err := WriteSyslog(&w, results); err != nil {
+		t.Fatal(err)
+	}
+	expected := []syslogEntry{
+		{gsyslog.LOG_INFO, "initialization/parse-config: ok"},
+		{gsyslog.LOG_WARNING, "initialization/storage/test-access-storage: warn"},
+		{gsyslog.LOG_WARNING, "initialization/storage/test-access-storage: warn: slow"},
+		{gsyslog.LOG_ERR, "initialization/storage/consul-tls: fail: no ca"},
+		{gsyslog.LOG_INFO, "initialization/edition: info: community"},
+	}
+	if !reflect.DeepEqual(w.entries, expected) {
+		t.Fatalf("unexpected syslog entries: %#v", w.entries)
+	}
+}
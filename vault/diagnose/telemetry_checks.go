@@ -0,0 +1,33 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+// minPrometheusRetention is the shortest prometheus_retention_time that
+// outlives common scrape intervals, which range up to a minute. Metrics that
+// expire between scrapes leave gaps in the collected series.
+const minPrometheusRetention = time.Minute
+
+// TelemetryCheck reports the effective telemetry settings that commonly
+// break metrics collection without any error.
+func TelemetryCheck(ctx context.Context, telemetry *configutil.Telemetry) {
+	if telemetry == nil {
+		SpotSkipped(ctx, "prometheus-retention", SkipStanzaAbsent, "no telemetry stanza is configured")
+		return
+	}
+	retention := telemetry.PrometheusRetentionTime
+	switch {
+	case retention == 0:
+		SpotSkipped(ctx, "prometheus-retention", SkipNotApplicable, "prometheus metrics are disabled by a prometheus_retention_time of 0")
+	case retention < minPrometheusRetention:
+		SpotWarn(ctx, "prometheus-retention", fmt.Sprintf("prometheus_retention_time is %s, so metrics may expire "+
+			"before they are scraped; set it to at least %s, and longer than the scrape interval", retention, minPrometheusRetention))
+	default:
+		SpotOk(ctx, "prometheus-retention", fmt.Sprintf("prometheus_retention_time is %s", retention))
+	}
+}
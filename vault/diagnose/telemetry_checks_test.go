@@ -0,0 +1,32 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/internalshared/configutil"
+)
+
+func TestTelemetryCheck(t *testing.T) {
+	testCases := []struct {
+		name      string
+		telemetry *configutil.Telemetry
+		status    status
+	}{
+		{"no stanza", nil, SkippedStatus},
+		{"disabled", &configutil.Telemetry{}, SkippedStatus},
+		{"too short", &configutil.Telemetry{PrometheusRetentionTime: 10 * time.Second}, WarningStatus},
+		{"default", &configutil.Telemetry{PrometheusRetentionTime: configutil.PrometheusDefaultRetentionTime}, OkStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := checkResults(t, func(ctx context.Context) {
+				TelemetryCheck(ctx, tc.telemetry)
+			})
+			if len(results) != 1 || results[0].Status != tc.status {
+				t.Fatalf("expected a single %s result, got %#v", tc.status, results)
+			}
+		})
+	}
+}
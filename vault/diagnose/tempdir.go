@@ -0,0 +1,47 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+)
+
+// TempDirCheck confirms the effective temp directory, which honors TMPDIR,
+// exists and is writable by creating and removing a file in it. On platforms
+// with Unix permissions, it also warns when the directory is world-writable
+// without the sticky bit, which lets any user remove or replace others' files.
+func TempDirCheck(ctx context.Context) {
+	checkTempDir(ctx, os.TempDir())
+}
+
+func checkTempDir(ctx context.Context, dir string) {
+	testName := "temp directory"
+	info, err := os.Stat(dir)
+	if err != nil {
+		SpotError(ctx, testName, fmt.Errorf("temp directory %s is not usable: %w", dir, err))
+		return
+	}
+	if !info.IsDir() {
+		SpotError(ctx, testName, fmt.Errorf("temp directory %s is not a directory", dir))
+		return
+	}
+
+	f, err := ioutil.TempFile(dir, "vault-diagnose-")
+	if err != nil {
+		SpotError(ctx, testName, fmt.Errorf("temp directory %s is not writable: %w", dir, err))
+		return
+	}
+	f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		SpotWarn(ctx, testName, fmt.Sprintf("could not remove test file from temp directory %s: %v", dir, err))
+		return
+	}
+
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o002 != 0 && info.Mode()&os.ModeSticky == 0 {
+		SpotWarn(ctx, testName, fmt.Sprintf("temp directory %s is world-writable without the sticky bit", dir))
+		return
+	}
+	SpotOk(ctx, testName, dir)
+}
@@ -0,0 +1,45 @@
+package diagnose
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCheckTempDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-tempdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	results := checkResults(t, func(ctx context.Context) {
+		checkTempDir(ctx, dir)
+	})
+	if len(results) != 1 || results[0].Status != OkStatus || results[0].Message != dir {
+		t.Fatalf("expected an ok result naming %s, got %#v", dir, results)
+	}
+
+	results = checkResults(t, func(ctx context.Context) {
+		checkTempDir(ctx, filepath.Join(dir, "missing"))
+	})
+	if len(results) != 1 || results[0].Status != ErrorStatus {
+		t.Fatalf("expected an error for a missing directory, got %#v", results)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if err := os.Chmod(dir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	results = checkResults(t, func(ctx context.Context) {
+		checkTempDir(ctx, dir)
+	})
+	if len(results) != 1 || results[0].Status != WarningStatus {
+		t.Fatalf("expected a warning for a world-writable directory, got %#v", results)
+	}
+}
@@ -0,0 +1,138 @@
+package diagnose
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/internalshared/listenerutil"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	ocspSlowThreshold = 2 * time.Second
+	ocspTimeout       = 10 * time.Second
+)
+
+var errNoOCSPResponder = errors.New("certificate does not name an OCSP responder")
+
+// OCSPResult describes the response of an OCSP responder for a certificate.
+type OCSPResult struct {
+	Responder string
+	Status    int
+	Duration  time.Duration
+}
+
+// CheckOCSP queries the OCSP responder named by the leaf certificate in
+// certFilePath. The issuing certificate must be present in the same file so
+// that a request can be built and the response verified.
+func CheckOCSP(ctx context.Context, certFilePath string) (*OCSPResult, error) {
+	data, err := ioutil.ReadFile(certFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tls_cert_file: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	for rest := data; len(rest) != 0; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("A pem block does not parse to a certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found in cert file")
+	}
+
+	leaf := certs[0]
+	if len(leaf.OCSPServer) == 0 {
+		return nil, errNoOCSPResponder
+	}
+	var issuer *x509.Certificate
+	for _, c := range certs[1:] {
+		if bytes.Equal(c.RawSubject, leaf.RawIssuer) {
+			issuer = c
+			break
+		}
+	}
+	if issuer == nil {
+		return nil, fmt.Errorf("the issuing certificate is not present in the cert file, so an OCSP request cannot be built")
+	}
+
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	responder := leaf.OCSPServer[0]
+	ctx, cancel := context.WithTimeout(ctx, ocspTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, responder, bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/ocsp-request")
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("OCSP responder %s is unreachable: %w", responder, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	duration := time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder %s returned HTTP status %d", responder, resp.StatusCode)
+	}
+
+	parsed, err := ocsp.ParseResponseForCert(body, leaf, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response from OCSP responder %s: %w", responder, err)
+	}
+	return &OCSPResult{
+		Responder: responder,
+		Status:    parsed.Status,
+		Duration:  duration,
+	}, nil
+}
+
+// ListenerOCSPChecks queries the OCSP responder for each TLS listener's
+// certificate, skipping listeners whose certificate names no responder.
+func ListenerOCSPChecks(ctx context.Context, listeners []listenerutil.Listener) {
+	for _, ln := range listeners {
+		l := ln.Config
+		if l.TLSDisable {
+			continue
+		}
+		res, err := CheckOCSP(ctx, l.TLSCertFile)
+		switch {
+		case err == errNoOCSPResponder:
+			SpotSkipped(ctx, "ocsp", SkipNotApplicable, fmt.Sprintf("%s: %s", l.Address, err))
+		case err != nil:
+			SpotWarn(ctx, "ocsp", fmt.Sprintf("%s: %s", l.Address, err))
+		case res.Status == ocsp.Revoked:
+			SpotError(ctx, "ocsp", fmt.Errorf("%s: certificate has been revoked according to %s", l.Address, res.Responder))
+		case res.Status != ocsp.Good:
+			SpotWarn(ctx, "ocsp", fmt.Sprintf("%s: %s does not know the certificate's status", l.Address, res.Responder))
+		case res.Duration > ocspSlowThreshold:
+			SpotWarn(ctx, "ocsp", fmt.Sprintf("%s: %s responded slowly (%s), which may stall TLS handshakes",
+				l.Address, res.Responder, res.Duration))
+		default:
+			SpotOk(ctx, "ocsp", fmt.Sprintf("%s: good status from %s in %s", l.Address, res.Responder, res.Duration))
+		}
+	}
+}
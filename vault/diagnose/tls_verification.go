@@ -2,12 +2,16 @@ package diagnose
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/vault/internalshared/configutil"
 	"github.com/hashicorp/vault/internalshared/listenerutil"
 	"github.com/hashicorp/vault/sdk/helper/tlsutil"
 )
@@ -122,3 +126,59 @@ func TLSFileChecks(certFilePath, keyFilePath string) error {
 
 	return nil
 }
+
+// clientCAExpiryWarning is how close to expiry a client CA certificate may get
+// before it is flagged.
+const clientCAExpiryWarning = 30 * 24 * time.Hour
+
+// TLSClientCAChecks loads the client CA bundle of an mTLS listener and checks
+// that it parses and that its certificates are current. An unreadable bundle,
+// or one with no unexpired certificate, rejects every client and is an error.
+func TLSClientCAChecks(ctx context.Context, l *configutil.Listener) {
+	data, err := ioutil.ReadFile(l.TLSClientCAFile)
+	if err != nil {
+		SpotError(ctx, "client-ca", fmt.Errorf("%s: failed to read tls_client_ca_file: %w", l.Address, err))
+		return
+	}
+
+	var certs []*x509.Certificate
+	for rest := data; len(rest) != 0; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			SpotError(ctx, "client-ca", fmt.Errorf("%s: a pem block in tls_client_ca_file does not parse to a certificate: %w", l.Address, err))
+			return
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		SpotError(ctx, "client-ca", fmt.Errorf("%s: no certificates found in tls_client_ca_file", l.Address))
+		return
+	}
+
+	now := time.Now()
+	var subjects []string
+	for _, cert := range certs {
+		subject := cert.Subject.String()
+		switch {
+		case now.After(cert.NotAfter):
+			SpotWarn(ctx, "client-ca", fmt.Sprintf("%s: client CA %q expired on %s", l.Address, subject, cert.NotAfter.Format(time.RFC3339)))
+		case now.Before(cert.NotBefore):
+			SpotWarn(ctx, "client-ca", fmt.Sprintf("%s: client CA %q is not valid until %s", l.Address, subject, cert.NotBefore.Format(time.RFC3339)))
+		default:
+			if cert.NotAfter.Sub(now) < clientCAExpiryWarning {
+				SpotWarn(ctx, "client-ca", fmt.Sprintf("%s: client CA %q expires on %s", l.Address, subject, cert.NotAfter.Format(time.RFC3339)))
+			}
+			subjects = append(subjects, subject)
+		}
+	}
+	if len(subjects) == 0 {
+		SpotError(ctx, "client-ca", fmt.Errorf("%s: no certificate in tls_client_ca_file is currently valid, so all client certificates will be rejected", l.Address))
+		return
+	}
+	SpotOk(ctx, "client-ca", fmt.Sprintf("%s: %s", l.Address, strings.Join(subjects, "; ")))
+}
@@ -1,9 +1,22 @@
 package diagnose
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/internalshared/configutil"
 	"github.com/hashicorp/vault/internalshared/listenerutil"
@@ -315,3 +328,69 @@ func TestTLSInvalidMaxVersion(t *testing.T) {
 		t.Errorf("Bad error message: %w", err)
 	}
 }
+
+// clientCAPEM returns a self-signed CA certificate in PEM form, valid between
+// notBefore and notAfter.
+func clientCAPEM(t *testing.T, cn string, notBefore, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestTLSClientCAChecks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnose-client-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	valid := clientCAPEM(t, "valid-ca", now.Add(-time.Hour), now.Add(365*24*time.Hour))
+	expired := clientCAPEM(t, "expired-ca", now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+
+	testCases := []struct {
+		name     string
+		contents []byte
+		expected []status
+	}{
+		{"valid", valid, []status{OkStatus}},
+		{"one expired", append(append([]byte{}, valid...), expired...), []status{WarningStatus, OkStatus}},
+		{"all expired", expired, []status{WarningStatus, ErrorStatus}},
+		{"not a certificate", []byte("not a certificate"), []status{ErrorStatus}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, "ca.pem")
+			if err := ioutil.WriteFile(path, tc.contents, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			results := checkResults(t, func(ctx context.Context) {
+				TLSClientCAChecks(ctx, &configutil.Listener{Address: "127.0.0.1:8200", TLSClientCAFile: path})
+			})
+			var statuses []status
+			for _, r := range results {
+				statuses = append(statuses, r.Status)
+			}
+			if !reflect.DeepEqual(statuses, tc.expected) {
+				t.Fatalf("expected statuses %v, got %v", tc.expected, statuses)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package diagnose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// legacyTLSVersions are the tls_min_version and tls_max_version values that
+// allow TLS versions deprecated by RFC 8996.
+var legacyTLSVersions = map[string]bool{"tls10": true, "tls11": true}
+
+// LegacyTLSCheck fails each setting in versions, which maps the name of a
+// tls_min_version or tls_max_version setting to its value, that allows TLS 1.0
+// or 1.1, and reports the versions of the rest.
+func LegacyTLSCheck(ctx context.Context, versions map[string]string) {
+	settings := make([]string, 0, len(versions))
+	for setting := range versions {
+		settings = append(settings, setting)
+	}
+	sort.Strings(settings)
+
+	var found []string
+	for _, setting := range settings {
+		version := versions[setting]
+		if legacyTLSVersions[version] {
+			SpotError(ctx, "legacy-tls", fmt.Errorf("%s is %s, which allows a deprecated TLS version; use tls12 or tls13",
+				setting, version))
+			continue
+		}
+		found = append(found, fmt.Sprintf("%s is %s", setting, version))
+	}
+	if len(found) > 0 {
+		SpotOk(ctx, "legacy-tls", strings.Join(found, ", "))
+	}
+}
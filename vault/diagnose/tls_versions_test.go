@@ -0,0 +1,33 @@
+package diagnose
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLegacyTLSCheck(t *testing.T) {
+	versions := map[string]string{
+		"listener 1 tls_min_version":        "tls12",
+		"listener 2 tls_min_version":        "tls10",
+		"storage zookeeper tls_min_version": "tls11",
+	}
+	results := checkResults(t, func(ctx context.Context) {
+		LegacyTLSCheck(ctx, versions)
+	})
+	expected := []struct {
+		status  status
+		message string
+	}{
+		{ErrorStatus, "listener 2 tls_min_version is tls10, which allows a deprecated TLS version; use tls12 or tls13"},
+		{ErrorStatus, "storage zookeeper tls_min_version is tls11, which allows a deprecated TLS version; use tls12 or tls13"},
+		{OkStatus, "listener 1 tls_min_version is tls12"},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %#v", len(expected), results)
+	}
+	for i, exp := range expected {
+		if results[i].Status != exp.status || results[i].Message != exp.message {
+			t.Fatalf("result %d: expected %s %q, got %#v", i, exp.status, exp.message, results[i])
+		}
+	}
+}